@@ -1,26 +1,38 @@
 package anticheat
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"time"
+
+	"github.com/Hucaru/Valhalla/repository"
 )
 
+// ErrPlayerNotFound is returned when a character name does not resolve to an account
+var ErrPlayerNotFound = errors.New("player not found")
+
 type AntiCheat struct {
-	violations map[string][]time.Time
-	failedAuth map[string][]time.Time
-	db         *sql.DB
-	dispatch   chan func()
-	onBan      func(accountID int32)
+	violations  map[string][]time.Time
+	failedAuth  map[string][]time.Time
+	repo        repository.Repositories
+	dispatch    chan func()
+	onBan       func(accountID int32)
+	onKick      func(accountID int32)
+	onJail      func(accountID int32, d time.Duration, reason string)
+	onViolation func(accountID int32, violation, detail string)
+	policies    map[string]Policy
+	shadow      bool
 }
 
-func New(db *sql.DB, dispatch chan func()) *AntiCheat {
+func New(repo repository.Repositories, dispatch chan func()) *AntiCheat {
 	return &AntiCheat{
 		violations: make(map[string][]time.Time),
 		failedAuth: make(map[string][]time.Time),
-		db:         db,
+		repo:       repo,
 		dispatch:   dispatch,
+		policies:   make(map[string]Policy),
 	}
 }
 
@@ -141,21 +153,13 @@ func (ac *AntiCheat) IssueBan(accountID int32, hours int, reason string, ip, hwi
 		banEnd = time.Now().Add(time.Duration(hours) * time.Hour)
 	}
 
-	var accountParam any
-	if accountID == 0 {
-		accountParam = nil
-	} else {
-		accountParam = accountID
-	}
-
-	_, err := ac.db.Exec(`INSERT INTO bans (accountID, reason, banEnd, ip, hwid) VALUES (?, ?, ?, ?, ?)`,
-		accountParam, reason, banEnd, ip, hwid)
+	err := ac.repo.Bans.Create(repository.Ban{AccountID: accountID, Reason: reason, End: banEnd, IP: ip, HWID: hwid})
 	if err != nil {
 		return err
 	}
 
 	if accountID > 0 {
-		_, err = ac.db.Exec(`UPDATE accounts SET isBanned = 1 WHERE accountID = ?`, accountID)
+		err = ac.repo.Accounts.SetBanned(accountID, true)
 
 		if ac.onBan != nil {
 			ac.post(func() {
@@ -166,7 +170,7 @@ func (ac *AntiCheat) IssueBan(accountID int32, hours int, reason string, ip, hwi
 		}
 
 		if hours > 0 {
-			count, _ := ac.incrementTempBans(accountID)
+			count, _ := ac.repo.Bans.IncrementEscalation(accountID)
 			if count >= 3 {
 				err := ac.IssueBan(accountID, 0, "Escalated: 3+ temporary bans", ip, hwid)
 				if err != nil {
@@ -179,68 +183,48 @@ func (ac *AntiCheat) IssueBan(accountID int32, hours int, reason string, ip, hwi
 }
 
 func (ac *AntiCheat) LockAccount(accountID int32) error {
-	_, err := ac.db.Exec(`UPDATE accounts SET isLocked = 1 WHERE accountID = ?`, accountID)
-	return err
+	return ac.repo.Accounts.SetLocked(accountID, true)
 }
 
 func (ac *AntiCheat) IsBanned(accountID int32, ip, hwid string) (bool, string, int64, error) {
-	var reason string
-	var banEndRaw []byte
-
-	err := ac.db.QueryRow(`
-SELECT reason, banEnd FROM bans
-WHERE (accountID = ? OR ip = ? OR (hwid = ? AND hwid != ''))
-AND (banEnd IS NULL OR banEnd > NOW())
-ORDER BY createdAt DESC
-LIMIT 1`, accountID, ip, hwid).Scan(&reason, &banEndRaw)
-
-	if err == sql.ErrNoRows {
-		return false, "", 0, nil
-	}
-
+	ban, found, err := ac.repo.Bans.Active(accountID, ip, hwid)
 	if err != nil {
 		log.Println(err)
-		return false, "", 0, err
+		return found, ban.Reason, 0, err
 	}
 
-	if banEndRaw == nil {
-		return true, reason, 0, nil
-	}
-
-	s := string(banEndRaw)
-	layouts := []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02 15:04:05.999999",
-		"2006-01-02 15:04:05.999999999",
+	if !found {
+		return false, "", 0, nil
 	}
 
-	var parsed time.Time
-	var parseErr error
-	for _, layout := range layouts {
-		parsed, parseErr = time.ParseInLocation(layout, s, time.Local)
-		if parseErr == nil {
-			return true, reason, parsed.UnixMilli()*10000 + 116444592000000000, nil
-		}
+	if ban.Permanent() {
+		return true, ban.Reason, 0, nil
 	}
 
-	return true, reason, 0, fmt.Errorf("failed to parse banEnd %q: %w", s, parseErr)
+	return true, ban.Reason, ban.End.UnixMilli()*10000 + 116444592000000000, nil
 }
 
 func (ac *AntiCheat) accountIDByPlayerName(name string) (int32, error) {
-	var accountID int32
-	err := ac.db.QueryRow(
-		`SELECT accountID FROM characters WHERE name = ? LIMIT 1`,
-		name,
-	).Scan(&accountID)
+	accountID, err := ac.repo.Characters.AccountIDByName(name)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("player %q not found", name)
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, fmt.Errorf("%w: %s", ErrPlayerNotFound, name)
 		}
 		return 0, err
 	}
 	return accountID, nil
 }
 
+// BanByName bans the account owning the named character, it does not need to be online (hours=0 means permanent)
+func (ac *AntiCheat) BanByName(name string, hours int, reason string) error {
+	accountID, err := ac.accountIDByPlayerName(name)
+	if err != nil {
+		return err
+	}
+
+	return ac.IssueBan(accountID, hours, reason, "", "")
+}
+
 // Unban removes all bans for an account (resolved by player name)
 func (ac *AntiCheat) Unban(name string) error {
 	accountID, err := ac.accountIDByPlayerName(name)
@@ -248,100 +232,241 @@ func (ac *AntiCheat) Unban(name string) error {
 		return err
 	}
 
-	if _, err := ac.db.Exec(`DELETE FROM bans WHERE accountID = ?`, accountID); err != nil {
+	if err := ac.repo.Bans.DeleteByAccount(accountID); err != nil {
 		return err
 	}
 
-	_, err = ac.db.Exec(`UPDATE accounts SET isBanned = 0 WHERE accountID = ?`, accountID)
-	return err
+	return ac.repo.Accounts.SetBanned(accountID, false)
 }
 
-// GetBanHistory returns recent ban records (resolved by player name)
+// GetBanHistory returns recent ban, mute and jail records, newest first (resolved by player name)
 func (ac *AntiCheat) GetBanHistory(name string, limit int) ([]string, error) {
 	accountID, err := ac.accountIDByPlayerName(name)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := ac.db.Query(`
-SELECT reason, banEnd, createdAt FROM bans
-WHERE accountID = ?
-ORDER BY createdAt DESC
-LIMIT ?`, accountID, limit)
+	bans, err := ac.repo.Bans.ByAccount(accountID, limit)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var history []string
-	for rows.Next() {
-		var reason string
-		var banEnd sql.NullTime
-		var createdAt time.Time
-		if err := rows.Scan(&reason, &banEnd, &createdAt); err != nil {
-			continue
-		}
+	mutes, err := ac.repo.Mutes.ByAccount(accountID, limit)
+	if err != nil {
+		return nil, err
+	}
 
+	jails, err := ac.repo.Jails.ByAccount(accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		at   time.Time
+		line string
+	}
+
+	var entries []entry
+	for _, ban := range bans {
 		durStr := "permanent"
-		if banEnd.Valid {
-			durStr = banEnd.Time.Format("2006-01-02 15:04")
+		if !ban.Permanent() {
+			durStr = ban.End.Format("2006-01-02 15:04")
 		}
-		history = append(history, fmt.Sprintf("%s: %s (until %s)",
-			createdAt.Format("2006-01-02 15:04"), reason, durStr))
+		entries = append(entries, entry{ban.CreatedAt, fmt.Sprintf("%s: %s (until %s)",
+			ban.CreatedAt.Format("2006-01-02 15:04"), ban.Reason, durStr)})
+	}
+	for _, mute := range mutes {
+		entries = append(entries, entry{mute.CreatedAt, formatMute(mute)})
+	}
+	for _, jail := range jails {
+		entries = append(entries, entry{jail.CreatedAt, formatJail(jail)})
 	}
-	return history, rows.Err()
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at.After(entries[j].at) })
+
+	var history []string
+	for i := 0; i < len(entries) && i < limit; i++ {
+		history = append(history, entries[i].line)
+	}
+	return history, nil
 }
 
-// Internal: track temp ban count for escalation
-func (ac *AntiCheat) incrementTempBans(accountID int32) (int, error) {
-	_, err := ac.db.Exec(`
-		INSERT INTO ban_escalation (accountID, count) VALUES (?, 1)
-		ON DUPLICATE KEY UPDATE count = count + 1`, accountID)
+// Mute an account for d (0 means permanent), by names the GM or system that issued it
+func (ac *AntiCheat) Mute(accountID int32, d time.Duration, reason, by string) (repository.Mute, error) {
+	mute := repository.Mute{AccountID: accountID, Reason: reason, IssuedBy: by}
+	if d > 0 {
+		mute.End = time.Now().Add(d)
+	}
+
+	err := ac.repo.Mutes.Create(&mute)
+
+	return mute, err
+}
+
+// MuteByName mutes the account owning the named character, it does not need to be online (d=0 means permanent)
+func (ac *AntiCheat) MuteByName(name string, d time.Duration, reason, by string) (repository.Mute, error) {
+	accountID, err := ac.accountIDByPlayerName(name)
+	if err != nil {
+		return repository.Mute{}, err
+	}
+
+	return ac.Mute(accountID, d, reason, by)
+}
+
+// Unmute lifts every mute in force on the account owning the named character and returns the account
+func (ac *AntiCheat) Unmute(name, by string) (int32, error) {
+	accountID, err := ac.accountIDByPlayerName(name)
 	if err != nil {
 		return 0, err
 	}
 
-	var count int
-	err = ac.db.QueryRow(`SELECT count FROM ban_escalation WHERE accountID = ?`, accountID).Scan(&count)
-	return count, err
+	return accountID, ac.repo.Mutes.Lift(accountID, by)
+}
+
+// ActiveMute on the account, if any
+func (ac *AntiCheat) ActiveMute(accountID int32) (repository.Mute, bool, error) {
+	return ac.repo.Mutes.Active(accountID)
 }
 
-// Detection helpers - track violations and auto-ban on threshold
+// GetMuteHistory returns recent mute records (resolved by player name)
+func (ac *AntiCheat) GetMuteHistory(name string, limit int) ([]string, error) {
+	accountID, err := ac.accountIDByPlayerName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mutes, err := ac.repo.Mutes.ByAccount(accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []string
+	for _, mute := range mutes {
+		history = append(history, formatMute(mute))
+	}
+	return history, nil
+}
+
+func formatMute(mute repository.Mute) string {
+	durStr := "permanent"
+	if !mute.Permanent() {
+		durStr = mute.End.Format("2006-01-02 15:04")
+	}
+
+	line := fmt.Sprintf("%s: Muted by %s: %s (until %s)", mute.CreatedAt.Format("2006-01-02 15:04"), mute.IssuedBy, mute.Reason, durStr)
+	if !mute.LiftedAt.IsZero() {
+		line += fmt.Sprintf(", lifted by %s at %s", mute.LiftedBy, mute.LiftedAt.Format("2006-01-02 15:04"))
+	}
+
+	return line
+}
+
+// Jail an account for d, by names the GM or system that issued it
+func (ac *AntiCheat) Jail(accountID int32, d time.Duration, reason, by string) (repository.Jail, error) {
+	jail := repository.Jail{AccountID: accountID, Reason: reason, IssuedBy: by, End: time.Now().Add(d)}
+
+	err := ac.repo.Jails.Create(&jail)
+
+	return jail, err
+}
+
+// JailByName jails the account owning the named character, it does not need to be online
+func (ac *AntiCheat) JailByName(name string, d time.Duration, reason, by string) (repository.Jail, error) {
+	accountID, err := ac.accountIDByPlayerName(name)
+	if err != nil {
+		return repository.Jail{}, err
+	}
+
+	return ac.Jail(accountID, d, reason, by)
+}
+
+// Unjail releases the account owning the named character from every sentence it is serving and returns the account
+func (ac *AntiCheat) Unjail(name, by string) (int32, error) {
+	accountID, err := ac.accountIDByPlayerName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return accountID, ac.repo.Jails.Release(accountID, by)
+}
+
+// ActiveJail sentence on the account, if any
+func (ac *AntiCheat) ActiveJail(accountID int32) (repository.Jail, bool, error) {
+	return ac.repo.Jails.Active(accountID)
+}
+
+func formatJail(jail repository.Jail) string {
+	line := fmt.Sprintf("%s: Jailed by %s: %s (until %s)", jail.CreatedAt.Format("2006-01-02 15:04"), jail.IssuedBy, jail.Reason,
+		jail.End.Format("2006-01-02 15:04"))
+	if !jail.ReleasedAt.IsZero() {
+		line += fmt.Sprintf(", released by %s at %s", jail.ReleasedBy, jail.ReleasedAt.Format("2006-01-02 15:04"))
+	}
+
+	return line
+}
+
+// Detection helpers - track violations and apply the violation type's policy on threshold
 func (ac *AntiCheat) LogDamageViolation(accountID int32, damage, maxDamage int32) {
 	if damage > maxDamage*2 {
-		if ac.Track(accountID, "damage", 5, 5*time.Minute) {
-			ac.IssueBan(accountID, 168, fmt.Sprintf("Excessive damage: %d > %d", damage, maxDamage), "", "")
-		}
+		ac.enforce(accountID, ViolationDamage, fmt.Sprintf("Excessive damage: %d > %d", damage, maxDamage),
+			fmt.Sprintf("%d damage, max %d", damage, maxDamage))
 	}
 }
 
+// LogAttackSpeedViolation is called for every attack and returns true if the player was removed and the attack should
+// be dropped. Only reaching the threshold is recorded as a violation.
 func (ac *AntiCheat) LogAttackSpeedViolation(accountID int32) bool {
-	return ac.Track(accountID, "attack_speed", 120, 1*time.Minute)
+	p := ac.policy(ViolationAttackSpeed)
+
+	reached, removed := ac.apply(accountID, ViolationAttackSpeed, "Attack speed hack detected")
+	if reached {
+		ac.record(accountID, ViolationAttackSpeed, fmt.Sprintf("%d attacks within %s", p.Threshold, p.Window))
+	}
+
+	return removed
 }
 
 func (ac *AntiCheat) LogMovementViolation(accountID int32, distance int16, moveType byte) {
 	if distance > 1000 {
 		log.Println("Teleport hack detected - movement type:", moveType, fmt.Sprintf("Suspicious movement: %d pixels", distance), "accountID:", accountID)
-		if ac.Track(accountID, "teleport", 3, 5*time.Minute) {
-			ac.IssueBan(accountID, 168, fmt.Sprintf("Teleport hack: %d pixels", distance), "", "")
-		}
+		ac.enforce(accountID, ViolationTeleport, fmt.Sprintf("Teleport hack: %d pixels", distance),
+			fmt.Sprintf("%d pixels, movement type %d", distance, moveType))
 	}
 }
 
 func (ac *AntiCheat) LogInvalidItemViolation(accountID int32) {
-	if ac.Track(accountID, "invalid_item", 5, 5*time.Minute) {
-		ac.IssueBan(accountID, 168, "Using items not in inventory", "", "")
-	}
+	ac.enforce(accountID, ViolationInvalidItem, "Using items not in inventory", "Item not in inventory")
 }
 
 func (ac *AntiCheat) LogInvalidTradeViolation(accountID int32, reason string) {
-	if ac.Track(accountID, "invalid_trade", 5, 5*time.Minute) {
-		ac.IssueBan(accountID, 168, "Invalid trade: "+reason, "", "")
+	ac.enforce(accountID, ViolationInvalidTrade, "Invalid trade: "+reason, reason)
+}
+
+// LogPickupRangeViolation for a drop picked up by the player, or their pet, from further away than they could reach.
+// It returns true if the player was removed and the pickup should be refused.
+func (ac *AntiCheat) LogPickupRangeViolation(accountID int32, dropID int32, dx, dy int, pet bool) bool {
+	by := "player"
+	if pet {
+		by = "pet"
 	}
+
+	return ac.enforce(accountID, ViolationPickupRange, fmt.Sprintf("Picked up a drop from %d, %d pixels away", dx, dy),
+		fmt.Sprintf("Drop %d %d, %d pixels from the %s", dropID, dx, dy, by))
+}
+
+// LogPickupRateViolation for a pickup over the limit of pickups within window. It returns true if the player was
+// removed and the pickup should be refused.
+func (ac *AntiCheat) LogPickupRateViolation(accountID int32, limit int, window time.Duration) bool {
+	return ac.enforce(accountID, ViolationPickupRate, "Picking up drops too quickly",
+		fmt.Sprintf("More than %d pickups within %s", limit, window))
+}
+
+// LogMobVacViolation for a mob moved by its controller somewhere it could not have got to. It returns true if the
+// player was removed and the move should be dropped.
+func (ac *AntiCheat) LogMobVacViolation(accountID int32, mobID int32, problem string) bool {
+	return ac.enforce(accountID, ViolationMobVac, "Mob vac: "+problem, fmt.Sprintf("Mob %d %s", mobID, problem))
 }
 
 func (ac *AntiCheat) LogSkillAbuseViolation(accountID int32, skillID int32) {
-	if ac.Track(accountID, "skill_abuse", 5, 5*time.Minute) {
-		ac.IssueBan(accountID, 168, fmt.Sprintf("Skill abuse: ID %d", skillID), "", "")
-	}
+	ac.enforce(accountID, ViolationSkillAbuse, fmt.Sprintf("Skill abuse: ID %d", skillID), fmt.Sprintf("Skill %d", skillID))
 }
@@ -0,0 +1,192 @@
+package anticheat
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Violation types with a configurable policy
+const (
+	ViolationDamage       = "damage"
+	ViolationAttackSpeed  = "attack_speed"
+	ViolationTeleport     = "teleport"
+	ViolationInvalidItem  = "invalid_item"
+	ViolationInvalidTrade = "invalid_trade"
+	ViolationSkillAbuse   = "skill_abuse"
+	ViolationPickupRange  = "pickup_range"
+	ViolationPickupRate   = "pickup_rate"
+	ViolationMobVac       = "mob_vac"
+)
+
+// Action taken once a violation type reaches its threshold
+type Action string
+
+const (
+	ActionLog     Action = "log"
+	ActionKick    Action = "kick"
+	ActionJail    Action = "jail"
+	ActionTempBan Action = "tempban" // counts towards escalation to a permanent ban
+	ActionPermBan Action = "permban"
+)
+
+// ParseAction from a config value
+func ParseAction(s string) (Action, error) {
+	switch a := Action(strings.ToLower(strings.TrimSpace(s))); a {
+	case ActionLog, ActionKick, ActionJail, ActionTempBan, ActionPermBan:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown anti-cheat action %q, expected log, kick, jail, tempban or permban", s)
+	}
+}
+
+// Policy for a violation type, Action is taken when Threshold violations happen within Window
+type Policy struct {
+	Threshold int
+	Window    time.Duration
+	Action    Action
+	BanHours  int           // length of a temporary ban
+	JailTime  time.Duration // length of a jail sentence
+	Shadow    bool          // only log what Action would have done
+}
+
+// DefaultPolicies for each violation type. The pickup and mob vac checks depend on positions the client reports and
+// can trip on lag, they only log until the thresholds have been tuned against live traffic.
+var DefaultPolicies = map[string]Policy{
+	ViolationDamage:       {Threshold: 5, Window: 5 * time.Minute, Action: ActionTempBan, BanHours: 168, JailTime: 30 * time.Minute},
+	ViolationAttackSpeed:  {Threshold: 120, Window: time.Minute, Action: ActionTempBan, BanHours: 24, JailTime: 30 * time.Minute},
+	ViolationTeleport:     {Threshold: 3, Window: 5 * time.Minute, Action: ActionTempBan, BanHours: 168, JailTime: 30 * time.Minute},
+	ViolationInvalidItem:  {Threshold: 5, Window: 5 * time.Minute, Action: ActionTempBan, BanHours: 168, JailTime: 30 * time.Minute},
+	ViolationInvalidTrade: {Threshold: 5, Window: 5 * time.Minute, Action: ActionTempBan, BanHours: 168, JailTime: 30 * time.Minute},
+	ViolationSkillAbuse:   {Threshold: 5, Window: 5 * time.Minute, Action: ActionTempBan, BanHours: 168, JailTime: 30 * time.Minute},
+	ViolationPickupRange:  {Threshold: 10, Window: 5 * time.Minute, Action: ActionLog, BanHours: 168, JailTime: 30 * time.Minute},
+	ViolationPickupRate:   {Threshold: 5, Window: 5 * time.Minute, Action: ActionLog, BanHours: 168, JailTime: 30 * time.Minute},
+	ViolationMobVac:       {Threshold: 10, Window: 5 * time.Minute, Action: ActionLog, BanHours: 168, JailTime: 30 * time.Minute},
+}
+
+// SetPolicy for a violation type
+func (ac *AntiCheat) SetPolicy(violation string, p Policy) {
+	ac.policies[violation] = p
+}
+
+// SetShadow puts every policy in shadow mode, violations are tracked and logged but nobody is kicked, jailed or banned
+func (ac *AntiCheat) SetShadow(shadow bool) {
+	ac.shadow = shadow
+}
+
+// SetOnKick is called to disconnect an account
+func (ac *AntiCheat) SetOnKick(fn func(accountID int32)) {
+	ac.onKick = fn
+}
+
+// SetOnViolation is called with the details of every violation, e.g. to store it
+func (ac *AntiCheat) SetOnViolation(fn func(accountID int32, violation, detail string)) {
+	ac.onViolation = fn
+}
+
+// SetOnJail is called to jail an account, without it the jail action kicks instead
+func (ac *AntiCheat) SetOnJail(fn func(accountID int32, d time.Duration, reason string)) {
+	ac.onJail = fn
+}
+
+func (ac *AntiCheat) policy(violation string) Policy {
+	if p, ok := ac.policies[violation]; ok {
+		return p
+	}
+
+	return DefaultPolicies[violation]
+}
+
+// enforce records a violation with its detail and applies the policy, reason is used for the log and any ban. It
+// reports whether the player was kicked, jailed or banned so the caller can stop handling their packet.
+func (ac *AntiCheat) enforce(accountID int32, violation, reason, detail string) bool {
+	ac.record(accountID, violation, detail)
+
+	_, removed := ac.apply(accountID, violation, reason)
+
+	return removed
+}
+
+func (ac *AntiCheat) record(accountID int32, violation, detail string) {
+	if ac.onViolation == nil {
+		return
+	}
+
+	ac.post(func() {
+		ac.onViolation(accountID, violation, detail)
+	})
+}
+
+// apply tracks a violation against its policy and carries out the action once the threshold is reached
+func (ac *AntiCheat) apply(accountID int32, violation, reason string) (reached, removed bool) {
+	p := ac.policy(violation)
+
+	if !ac.Track(accountID, violation, p.Threshold, p.Window) {
+		return false, false
+	}
+
+	// Start counting again so the action is not repeated for every further violation
+	ac.post(func() {
+		delete(ac.violations, fmt.Sprintf("%d:%s", accountID, violation))
+	})
+
+	if p.Shadow || ac.shadow {
+		log.Printf("Anti-cheat (shadow): would %s account %d for %s: %s", describe(p), accountID, violation, reason)
+		return true, false
+	}
+
+	log.Printf("Anti-cheat: %s account %d for %s: %s", describe(p), accountID, violation, reason)
+
+	switch p.Action {
+	case ActionKick:
+		ac.kick(accountID)
+	case ActionJail:
+		if ac.onJail == nil {
+			ac.kick(accountID)
+			break
+		}
+
+		ac.post(func() {
+			ac.onJail(accountID, p.JailTime, reason)
+		})
+	case ActionTempBan:
+		if err := ac.IssueBan(accountID, p.BanHours, reason, "", ""); err != nil {
+			log.Println("Anti-cheat: failed to ban account", accountID, ":", err)
+		}
+	case ActionPermBan:
+		if err := ac.IssueBan(accountID, 0, reason, "", ""); err != nil {
+			log.Println("Anti-cheat: failed to ban account", accountID, ":", err)
+		}
+	default:
+		return true, false
+	}
+
+	return true, true
+}
+
+func (ac *AntiCheat) kick(accountID int32) {
+	if ac.onKick == nil {
+		return
+	}
+
+	ac.post(func() {
+		ac.onKick(accountID)
+	})
+}
+
+// describe the action of p for the log
+func describe(p Policy) string {
+	switch p.Action {
+	case ActionKick:
+		return "kick"
+	case ActionJail:
+		return fmt.Sprintf("jail for %s", p.JailTime)
+	case ActionTempBan:
+		return fmt.Sprintf("ban for %d hours", p.BanHours)
+	case ActionPermBan:
+		return "permanently ban"
+	default:
+		return "flag"
+	}
+}
@@ -0,0 +1,114 @@
+package anticheat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Action
+		wantErr bool
+	}{
+		{"log", ActionLog, false},
+		{" TempBan ", ActionTempBan, false},
+		{"jail", ActionJail, false},
+		{"ban", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAction(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseAction(%q) = %q, %v, want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestClientPositionPoliciesOnlyLog(t *testing.T) {
+	for _, v := range []string{ViolationPickupRange, ViolationPickupRate, ViolationMobVac} {
+		if a := DefaultPolicies[v].Action; a != ActionLog {
+			t.Errorf("default %s action = %q, want %q", v, a, ActionLog)
+		}
+	}
+}
+
+func TestApplyThreshold(t *testing.T) {
+	ac := New(repository.NewMemory().Repositories(), nil)
+	ac.SetPolicy("test", Policy{Threshold: 3, Window: time.Minute, Action: ActionKick})
+
+	kicked := 0
+	ac.SetOnKick(func(int32) { kicked++ })
+
+	for i, want := range []bool{false, false, true, false} {
+		reached, removed := ac.apply(1, "test", "testing")
+		if reached != want || removed != want {
+			t.Errorf("violation %d apply() = %v, %v, want %v", i+1, reached, removed, want)
+		}
+	}
+
+	if kicked != 1 {
+		t.Errorf("kicked %d times, want 1", kicked)
+	}
+}
+
+func TestApplyLogAndShadow(t *testing.T) {
+	ac := New(repository.NewMemory().Repositories(), nil)
+	ac.SetPolicy("log", Policy{Threshold: 1, Window: time.Minute, Action: ActionLog})
+	ac.SetPolicy("shadow", Policy{Threshold: 1, Window: time.Minute, Action: ActionKick, Shadow: true})
+	ac.SetOnKick(func(int32) { t.Error("kicked by a log or shadow policy") })
+
+	for _, v := range []string{"log", "shadow"} {
+		if reached, removed := ac.apply(1, v, "testing"); !reached || removed {
+			t.Errorf("apply(%s) = %v, %v, want reached without removing the player", v, reached, removed)
+		}
+	}
+}
+
+func TestTempBanEscalation(t *testing.T) {
+	repo := repository.NewMemory().Repositories()
+	ac := New(repo, nil)
+
+	id, err := repo.Accounts.Create(repository.Account{Username: "cheater"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ac.IssueBan(id, 24, "testing", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ban, _, _ := repo.Bans.Active(id, "", ""); ban.Permanent() {
+		t.Fatal("permanent ban after 2 temporary bans")
+	}
+
+	if err := ac.IssueBan(id, 24, "testing", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if ban, found, _ := repo.Bans.Active(id, "", ""); !found || !ban.Permanent() {
+		t.Errorf("Active() = %+v, %v, want a permanent ban after the third temporary ban", ban, found)
+	}
+}
+
+func TestPositionViolationsOnlyRefuseWhenRemoved(t *testing.T) {
+	ac := New(repository.NewMemory().Repositories(), nil)
+
+	if ac.LogPickupRangeViolation(1, 1, 500, 0, false) || ac.LogPickupRateViolation(1, 10, time.Second) ||
+		ac.LogMobVacViolation(1, 100100, "above its foothold") {
+		t.Fatal("a log only policy asked for the packet to be refused")
+	}
+
+	kicked := 0
+	ac.SetOnKick(func(int32) { kicked++ })
+	ac.SetPolicy(ViolationMobVac, Policy{Threshold: 1, Window: time.Minute, Action: ActionKick})
+
+	if !ac.LogMobVacViolation(1, 100100, "above its foothold") || kicked != 1 {
+		t.Errorf("LogMobVacViolation() with a kick policy did not remove the player, kicked %d times", kicked)
+	}
+}
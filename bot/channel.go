@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Hucaru/Valhalla/common/opcode"
+	"github.com/Hucaru/Valhalla/mpacket"
+)
+
+// Player state as reported by the channel server when the character enters the game
+type Player struct {
+	ID        int32
+	Name      string
+	ChannelID int32
+	Level     byte
+	Job       int16
+	HP, MaxHP int16
+	MP, MaxMP int16
+	MapID     int32
+	X, Y      int16
+
+	portalCount byte
+}
+
+// Migrate finishes a login or change channel migration on a freshly dialled channel server connection
+func (c *Client) Migrate(charID int32) (*Player, error) {
+	p := mpacket.CreateWithOpcode(opcode.RecvClientMigrate)
+	p.WriteInt32(charID)
+
+	if err := c.Send(p); err != nil {
+		return nil, err
+	}
+
+	reader, err := c.Expect(opcode.SendChannelWarpToMap)
+	if err != nil {
+		return nil, err
+	}
+
+	plr := &Player{}
+	plr.ChannelID = reader.ReadInt32()
+	plr.portalCount = reader.ReadByte()
+
+	if !reader.ReadBool() {
+		return nil, fmt.Errorf("expected character data for %d, got a map change", charID)
+	}
+
+	reader.Skip(16 + 2) // rng seeds, buff mask
+
+	plr.ID = reader.ReadInt32()
+	plr.Name = trimPadded(reader.ReadBytes(13))
+
+	reader.Skip(1 + 1 + 4 + 4 + 8) // gender, skin, face, hair, pet cash id
+
+	plr.Level = reader.ReadByte()
+	plr.Job = reader.ReadInt16()
+
+	reader.Skip(4 * 2) // str, dex, int, luk
+
+	plr.HP = reader.ReadInt16()
+	plr.MaxHP = reader.ReadInt16()
+	plr.MP = reader.ReadInt16()
+	plr.MaxMP = reader.ReadInt16()
+
+	reader.Skip(2 + 2 + 4 + 2) // ap, sp, exp, fame
+
+	plr.MapID = reader.ReadInt32()
+
+	return plr, nil
+}
+
+// Move sends a single normal movement fragment from the player's last known position to x, y
+func (c *Client) Move(plr *Player, x, y, foothold int16, stance byte) error {
+	p := mpacket.CreateWithOpcode(opcode.RecvChannelPlayerMovement)
+	p.WriteByte(plr.portalCount)
+	p.WriteInt16(plr.X)
+	p.WriteInt16(plr.Y)
+	p.WriteByte(1)
+
+	p.WriteByte(0) // normal movement
+	p.WriteInt16(x)
+	p.WriteInt16(y)
+	p.WriteInt16(0) // vx
+	p.WriteInt16(0) // vy
+	p.WriteInt16(foothold)
+	p.WriteByte(stance)
+	p.WriteInt16(100) // duration
+
+	p.WriteByte(0) // keypad states
+
+	if err := c.Send(p); err != nil {
+		return err
+	}
+
+	plr.X, plr.Y = x, y
+
+	return nil
+}
+
+// Attack sends a basic melee attack hitting the mob with the given spawn id once per damage value
+func (c *Client) Attack(plr *Player, spawnID int32, damages ...int32) error {
+	if len(damages) == 0 || len(damages) > 0x0F {
+		return fmt.Errorf("invalid number of hits %d", len(damages))
+	}
+
+	p := mpacket.CreateWithOpcode(opcode.RecvChannelMeleeSkill)
+	p.WriteByte(0x10 | byte(len(damages))) // one target
+	p.WriteInt32(0)                        // skill id
+	p.WriteByte(0)                         // option
+	p.WriteByte(0)                         // action
+	p.WriteByte(0)                         // attack type
+	p.WriteInt32(0)
+
+	p.WriteInt32(spawnID)
+	p.WriteByte(0) // hit action
+	p.WriteByte(0) // fore action
+	p.WriteByte(0) // frame index
+	p.WriteByte(0) // calc damage stat index
+	p.WriteInt16(plr.X)
+	p.WriteInt16(plr.Y)
+	p.WriteInt16(plr.X)
+	p.WriteInt16(plr.Y)
+	p.WriteInt16(0) // hit delay
+
+	for _, dmg := range damages {
+		p.WriteInt32(dmg)
+	}
+
+	p.WriteInt16(plr.X)
+	p.WriteInt16(plr.Y)
+
+	return c.Send(p)
+}
+
+// Chat sends a message to the player's map. Messages starting with / are treated as GM commands by admins.
+func (c *Client) Chat(msg string) error {
+	p := mpacket.CreateWithOpcode(opcode.RecvChannelPlayerSendAllChat)
+	p.WriteString(msg)
+
+	return c.Send(p)
+}
+
+// ChangeChannel requests a channel change and returns the address of the destination channel server
+func (c *Client) ChangeChannel(channelID byte) (string, error) {
+	p := mpacket.CreateWithOpcode(opcode.RecvCHannelChangeChannel)
+	p.WriteByte(channelID)
+
+	if err := c.Send(p); err != nil {
+		return "", err
+	}
+
+	reader, err := c.Expect(opcode.SendChannelChange)
+	if err != nil {
+		return "", err
+	}
+
+	if !reader.ReadBool() {
+		return "", fmt.Errorf("change to channel %d refused", channelID)
+	}
+
+	ip := net.IP(reader.ReadBytes(4))
+	port := reader.ReadInt16()
+
+	return net.JoinHostPort(ip.String(), fmt.Sprint(uint16(port))), nil
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/mnet/crypt"
+	"github.com/Hucaru/Valhalla/mpacket"
+)
+
+// Client is a headless connection to a login, channel or cash shop server. It performs the same handshake
+// as the v28 client and frames packets the way mnet expects them so handlers can be driven without the game client.
+type Client struct {
+	conn      net.Conn
+	cryptSend *crypt.Maple
+	cryptRecv *crypt.Maple
+	timeout   time.Duration
+	hwid      []byte
+}
+
+// Dial connects to addr and completes the plaintext handshake that carries the IVs
+func Dial(addr string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{conn: conn, timeout: timeout, hwid: make([]byte, 4)}
+	_, _ = rand.Read(c.hwid)
+
+	if err := c.handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) handshake() error {
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return fmt.Errorf("handshake header: %w", err)
+	}
+
+	body := make(mpacket.Packet, int(header[0])|int(header[1])<<8)
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		return fmt.Errorf("handshake body: %w", err)
+	}
+
+	reader := mpacket.NewReader(&body, time.Now().Unix())
+
+	if version := reader.ReadInt16(); version != constant.MapleVersion {
+		return fmt.Errorf("handshake version mismatch: server %d, bot %d", version, constant.MapleVersion)
+	}
+
+	reader.ReadString(reader.ReadInt16()) // patch location
+
+	// The server lists its receive IV first, which is the IV this side sends with
+	var recvIV, sendIV [4]byte
+	copy(sendIV[:], reader.ReadBytes(4))
+	copy(recvIV[:], reader.ReadBytes(4))
+
+	c.cryptSend = crypt.New(sendIV, constant.MapleVersion)
+	c.cryptRecv = crypt.New(recvIV, constant.MapleVersion)
+
+	return nil
+}
+
+// Send encrypts and writes a packet created with mpacket.CreateWithOpcode
+func (c *Client) Send(p mpacket.Packet) error {
+	tmp := make(mpacket.Packet, len(p))
+	copy(tmp, p)
+
+	c.cryptSend.Encrypt(tmp, true, false)
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(tmp)
+
+	return err
+}
+
+// Recv reads and decrypts the next packet. The returned reader is positioned at the opcode.
+func (c *Client) Recv() (mpacket.Reader, error) {
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+
+	header := make([]byte, constant.ClientHeaderSize)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return mpacket.Reader{}, err
+	}
+
+	body := make(mpacket.Packet, crypt.GetPacketLength(header))
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		return mpacket.Reader{}, err
+	}
+
+	c.cryptRecv.Decrypt(body, true, false)
+
+	return mpacket.NewReader(&body, time.Now().Unix()), nil
+}
+
+// Expect discards packets until one with the given opcode arrives. The returned reader is positioned after the opcode.
+func (c *Client) Expect(op byte) (mpacket.Reader, error) {
+	deadline := time.Now().Add(c.timeout)
+
+	for time.Now().Before(deadline) {
+		reader, err := c.Recv()
+		if err != nil {
+			return reader, err
+		}
+
+		if reader.ReadByte() == op {
+			return reader, nil
+		}
+	}
+
+	return mpacket.Reader{}, fmt.Errorf("timed out waiting for opcode 0x%02X", op)
+}
+
+// Close the underlying connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) String() string {
+	return c.conn.LocalAddr().String()
+}
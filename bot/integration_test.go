@@ -0,0 +1,53 @@
+//go:build integration
+
+package bot
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func env(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return fallback
+}
+
+// TestIntegrationSmoke plays the smoke scenario against servers that are already running, for example a dev server
+// started with ./Valhalla -type dev -config config_dev.toml. The defaults match config_dev.toml.
+func TestIntegrationSmoke(t *testing.T) {
+	cfg := Config{
+		LoginAddress:  env("VALHALLA_BOT_LOGIN", "127.0.0.1:8484"),
+		Username:      env("VALHALLA_BOT_USERNAME", "bot"),
+		Password:      env("VALHALLA_BOT_PASSWORD", "bot"),
+		CharacterName: env("VALHALLA_BOT_CHARACTER", "SmokeBot"),
+		Timeout:       10 * time.Second,
+	}
+
+	// The channels register with the login server some time after it starts listening
+	deadline := time.Now().Add(2 * time.Minute)
+
+	for {
+		s, err := Connect(cfg)
+		if err == nil {
+			_ = s.Close()
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("servers did not become ready:", err)
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+
+	// Give the channel time to log the character out before it enters again
+	time.Sleep(2 * time.Second)
+
+	if err := Run(cfg, Smoke()...); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,279 @@
+package bot
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Hucaru/Valhalla/common/opcode"
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/mpacket"
+)
+
+// World as advertised in the login server world list
+type World struct {
+	ID       byte
+	Name     string
+	Ribbon   byte
+	Message  string
+	Channels []WorldChannel
+}
+
+// WorldChannel entry in a world listing
+type WorldChannel struct {
+	ID         byte
+	Name       string
+	Population int32
+}
+
+// Character as displayed on the character select screen
+type Character struct {
+	ID    int32
+	Name  string
+	Level byte
+	Job   int16
+	MapID int32
+}
+
+// Login sends the account credentials. Accounts that have not accepted the EULA accept it and retry once.
+func (c *Client) Login(username, password string) (int32, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		p := mpacket.CreateWithOpcode(opcode.RecvLoginRequest)
+		p.WriteString(username)
+		p.WriteString(password)
+		p.WriteBytes(make([]byte, 6))
+		p.WriteBytes(c.hwid)
+
+		if err := c.Send(p); err != nil {
+			return 0, err
+		}
+
+		reader, err := c.Expect(opcode.SendLoginResponse)
+		if err != nil {
+			return 0, err
+		}
+
+		result := reader.ReadByte()
+		reader.Skip(5)
+
+		switch result {
+		case constant.LoginResultSuccess:
+			return reader.ReadInt32(), nil
+		case constant.LoginResultEULA:
+			eula := mpacket.CreateWithOpcode(opcode.RecvLoginEULA)
+			eula.WriteBool(true)
+
+			if err := c.Send(eula); err != nil {
+				return 0, err
+			}
+
+			if _, err := c.Expect(opcode.SendLoginRestarter); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("login rejected with result 0x%02X", result)
+		}
+	}
+
+	return 0, fmt.Errorf("login did not succeed after accepting EULA")
+}
+
+// Worlds requests the world list. The login server must have been started without PIN verification.
+func (c *Client) Worlds() ([]World, error) {
+	p := mpacket.CreateWithOpcode(opcode.RecvLoginCheckLogin)
+	p.WriteByte(1)
+	p.WriteByte(1)
+
+	if err := c.Send(p); err != nil {
+		return nil, err
+	}
+
+	var worlds []World
+
+	for {
+		reader, err := c.Expect(opcode.SendLoginWorldList)
+		if err != nil {
+			return nil, err
+		}
+
+		id := reader.ReadByte()
+		if id == 0xFF {
+			break
+		}
+
+		w := World{ID: id}
+		w.Name = reader.ReadString(reader.ReadInt16())
+		w.Ribbon = reader.ReadByte()
+		w.Message = reader.ReadString(reader.ReadInt16())
+		reader.ReadByte()
+
+		count := reader.ReadByte()
+		w.Channels = make([]WorldChannel, 0, count)
+
+		for i := byte(0); i < count; i++ {
+			ch := WorldChannel{}
+			ch.Name = reader.ReadString(reader.ReadInt16())
+			ch.Population = reader.ReadInt32()
+			reader.ReadByte() // world id
+			ch.ID = reader.ReadByte() - 1
+			reader.ReadByte()
+
+			w.Channels = append(w.Channels, ch)
+		}
+
+		worlds = append(worlds, w)
+	}
+
+	return worlds, nil
+}
+
+// SelectWorld chooses the world and returns the population warning byte
+func (c *Client) SelectWorld(worldID byte) (byte, error) {
+	p := mpacket.CreateWithOpcode(opcode.RecvLoginWorldSelect)
+	p.WriteByte(worldID)
+	p.WriteByte(0)
+
+	if err := c.Send(p); err != nil {
+		return 0, err
+	}
+
+	reader, err := c.Expect(opcode.SendLoginWorldMeta)
+	if err != nil {
+		return 0, err
+	}
+
+	return reader.ReadByte(), nil
+}
+
+// SelectChannel chooses a channel within the selected world and returns the account's characters
+func (c *Client) SelectChannel(worldID, channelID byte) ([]Character, error) {
+	p := mpacket.CreateWithOpcode(opcode.RecvLoginChannelSelect)
+	p.WriteByte(worldID)
+	p.WriteByte(channelID)
+
+	if err := c.Send(p); err != nil {
+		return nil, err
+	}
+
+	for {
+		reader, err := c.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		switch reader.ReadByte() {
+		case opcode.SendLoginRestarter:
+			return nil, fmt.Errorf("channel %d in world %d is offline", channelID, worldID)
+		case opcode.SendLoginCharacterData:
+			reader.ReadByte()
+			count := reader.ReadByte()
+
+			characters := make([]Character, 0, count)
+			for i := byte(0); i < count; i++ {
+				characters = append(characters, readCharacter(&reader))
+			}
+
+			return characters, nil
+		}
+	}
+}
+
+// CreateCharacter makes a beginner with the first entry of each allowed appearance list
+func (c *Client) CreateCharacter(name string) (Character, error) {
+	p := mpacket.CreateWithOpcode(opcode.RecvLoginNewCharacter)
+	p.WriteString(name)
+	p.WriteInt32(20000)   // face
+	p.WriteInt32(30000)   // hair
+	p.WriteInt32(0)       // hair colour
+	p.WriteInt32(0)       // skin
+	p.WriteInt32(1040002) // top
+	p.WriteInt32(1060002) // bottom
+	p.WriteInt32(1072001) // shoes
+	p.WriteInt32(1302000) // weapon
+	p.WriteByte(4)
+	p.WriteByte(4)
+	p.WriteByte(4)
+	p.WriteByte(13)
+
+	if err := c.Send(p); err != nil {
+		return Character{}, err
+	}
+
+	reader, err := c.Expect(opcode.SendLoginNewCharacterGood)
+	if err != nil {
+		return Character{}, err
+	}
+
+	if reader.ReadByte() != 0 {
+		return Character{}, fmt.Errorf("character %q was rejected", name)
+	}
+
+	return readCharacter(&reader), nil
+}
+
+// SelectCharacter asks the login server to migrate the character and returns the channel address to connect to
+func (c *Client) SelectCharacter(charID int32) (string, error) {
+	p := mpacket.CreateWithOpcode(opcode.RecvLoginSelectCharacter)
+	p.WriteInt32(charID)
+
+	if err := c.Send(p); err != nil {
+		return "", err
+	}
+
+	reader, err := c.Expect(opcode.SendLoginCharacterMigrate)
+	if err != nil {
+		return "", err
+	}
+
+	reader.Skip(2)
+	ip := net.IP(reader.ReadBytes(4))
+	port := reader.ReadInt16()
+
+	if port == 0 {
+		return "", fmt.Errorf("bad migrate for character %d", charID)
+	}
+
+	return net.JoinHostPort(ip.String(), fmt.Sprint(uint16(port))), nil
+}
+
+func readCharacter(reader *mpacket.Reader) Character {
+	char := Character{}
+	char.ID = reader.ReadInt32()
+	char.Name = trimPadded(reader.ReadBytes(13))
+
+	reader.Skip(1 + 1 + 4 + 4 + 8) // gender, skin, face, hair, pet cash id
+
+	char.Level = reader.ReadByte()
+	char.Job = reader.ReadInt16()
+
+	reader.Skip(10*2 + 4 + 2) // stats, ap, sp, exp, fame
+
+	char.MapID = reader.ReadInt32()
+	reader.ReadByte() // map pos
+
+	// Avatar look: visible and masked equips are each terminated by 0xFF
+	reader.Skip(1 + 1 + 4 + 1 + 4)
+	for i := 0; i < 2; i++ {
+		for {
+			slot := reader.ReadByte()
+			if slot == 0xFF {
+				break
+			}
+			reader.ReadInt32()
+		}
+	}
+	reader.ReadInt32() // cash weapon
+
+	reader.Skip(4 + 1 + 4*4) // rankings
+
+	return char
+}
+
+func trimPadded(b []byte) string {
+	for i, v := range b {
+		if v == 0 {
+			return string(b[:i])
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common/opcode"
+)
+
+// Config for a bot session against a running login server
+type Config struct {
+	LoginAddress  string
+	Username      string
+	Password      string
+	WorldID       byte
+	ChannelID     byte
+	CharacterName string
+	Timeout       time.Duration
+}
+
+// Session is a bot that has logged in and entered a channel
+type Session struct {
+	Config  Config
+	World   World // as listed by the login server when the session connected
+	Channel *Client
+	Player  *Player
+}
+
+// Step in a scripted scenario
+type Step struct {
+	Name string
+	Run  func(*Session) error
+}
+
+// Connect logs in, selects the world and channel, creates the character if the account does not have it yet and
+// migrates into the channel server
+func Connect(cfg Config) (*Session, error) {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	login, err := Dial(cfg.LoginAddress, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer login.Close()
+
+	if _, err := login.Login(cfg.Username, cfg.Password); err != nil {
+		return nil, err
+	}
+
+	worlds, err := login.Worlds()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(worlds) == 0 {
+		return nil, fmt.Errorf("login server has no worlds registered")
+	}
+
+	world, ok := findWorld(worlds, cfg.WorldID)
+	if !ok {
+		return nil, fmt.Errorf("login server does not list world %d", cfg.WorldID)
+	}
+
+	if _, err := login.SelectWorld(cfg.WorldID); err != nil {
+		return nil, err
+	}
+
+	characters, err := login.SelectChannel(cfg.WorldID, cfg.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	charID := int32(-1)
+	for _, c := range characters {
+		if c.Name == cfg.CharacterName {
+			charID = c.ID
+			break
+		}
+	}
+
+	if charID == -1 {
+		char, err := login.CreateCharacter(cfg.CharacterName)
+		if err != nil {
+			return nil, err
+		}
+		charID = char.ID
+	}
+
+	addr, err := login.SelectCharacter(charID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{Config: cfg, World: world}
+	if err := s.enter(addr, charID); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Session) enter(addr string, charID int32) error {
+	conn, err := Dial(addr, s.Config.Timeout)
+	if err != nil {
+		return err
+	}
+
+	plr, err := conn.Migrate(charID)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	s.Channel = conn
+	s.Player = plr
+
+	return nil
+}
+
+func findWorld(worlds []World, id byte) (World, bool) {
+	for _, w := range worlds {
+		if w.ID == id {
+			return w, true
+		}
+	}
+
+	return World{}, false
+}
+
+// OtherChannel picks a channel of the session's world to change to, false if the world only lists the one it is on
+func (s *Session) OtherChannel() (byte, bool) {
+	for _, ch := range s.World.Channels {
+		if int32(ch.ID) != s.Player.ChannelID {
+			return ch.ID, true
+		}
+	}
+
+	return 0, false
+}
+
+// ChangeChannel moves the session to another channel through the same path as the client's channel select
+func (s *Session) ChangeChannel(channelID byte) error {
+	addr, err := s.Channel.ChangeChannel(channelID)
+	if err != nil {
+		return err
+	}
+
+	_ = s.Channel.Close()
+
+	return s.enter(addr, s.Player.ID)
+}
+
+// Close disconnects from the channel server which logs the character out
+func (s *Session) Close() error {
+	if s.Channel == nil {
+		return nil
+	}
+
+	return s.Channel.Close()
+}
+
+// Run connects a session and executes the steps in order, stopping at the first failure
+func Run(cfg Config, steps ...Step) error {
+	s, err := Connect(cfg)
+	if err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	defer s.Close()
+
+	log.Println("Bot", s.Player.Name, "entered map", s.Player.MapID, "on channel", s.Player.ChannelID+1)
+
+	for _, step := range steps {
+		start := time.Now()
+
+		if err := step.Run(s); err != nil {
+			return fmt.Errorf("%s: %w", step.Name, err)
+		}
+
+		log.Println("Bot step", step.Name, "passed in", time.Since(start))
+	}
+
+	return nil
+}
+
+// Smoke is the default scenario: walk, swing at nothing, chat and hop to another channel of the world and back
+func Smoke() []Step {
+	return []Step{
+		{Name: "move", Run: func(s *Session) error {
+			return s.Channel.Move(s.Player, s.Player.X+50, s.Player.Y, 0, 2)
+		}},
+		{Name: "attack", Run: func(s *Session) error {
+			return s.Channel.Attack(s.Player, 0, 1)
+		}},
+		{Name: "chat", Run: func(s *Session) error {
+			msg := "bot smoke test"
+			if err := s.Channel.Chat(msg); err != nil {
+				return err
+			}
+
+			for {
+				reader, err := s.Channel.Expect(opcode.SendChannelAllChatMsg)
+				if err != nil {
+					return err
+				}
+
+				if reader.ReadInt32() == s.Player.ID {
+					reader.ReadBool()
+					if got := reader.ReadString(reader.ReadInt16()); got != msg {
+						return fmt.Errorf("chat echoed %q, sent %q", got, msg)
+					}
+					return nil
+				}
+			}
+		}},
+		{Name: "change channel", Run: func(s *Session) error {
+			target, ok := s.OtherChannel()
+			if !ok {
+				log.Println("Bot world", s.World.Name, "has a single channel, skipping the channel change")
+				return nil
+			}
+
+			origin := byte(s.Player.ChannelID)
+			if err := s.ChangeChannel(target); err != nil {
+				return err
+			}
+			return s.ChangeChannel(origin)
+		}},
+	}
+}
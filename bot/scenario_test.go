@@ -0,0 +1,45 @@
+package bot
+
+import "testing"
+
+func TestOtherChannel(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels []byte
+		on       int32
+		want     byte
+		wantOK   bool
+	}{
+		{"next channel", []byte{0, 1, 2}, 0, 1, true},
+		{"earlier channel", []byte{0, 1, 2}, 2, 0, true},
+		{"gap in the listing", []byte{0, 3}, 0, 3, true},
+		{"single channel", []byte{0}, 0, 0, false},
+		{"no channels", nil, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{Player: &Player{ChannelID: tt.on}}
+			for _, id := range tt.channels {
+				s.World.Channels = append(s.World.Channels, WorldChannel{ID: id})
+			}
+
+			got, ok := s.OtherChannel()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("OtherChannel() = %d, %t, want %d, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFindWorld(t *testing.T) {
+	worlds := []World{{ID: 0, Name: "Scania"}, {ID: 2, Name: "Broa"}}
+
+	if w, ok := findWorld(worlds, 2); !ok || w.Name != "Broa" {
+		t.Errorf("findWorld(2) = %v, %t", w, ok)
+	}
+
+	if _, ok := findWorld(worlds, 1); ok {
+		t.Error("findWorld(1) found a world that is not listed")
+	}
+}
@@ -0,0 +1,53 @@
+package cashshop
+
+import (
+	"net/http"
+
+	"github.com/Hucaru/Valhalla/channel"
+	"github.com/Hucaru/Valhalla/common"
+)
+
+func (server *Server) registerAdminHandlers() {
+	common.HandleAdmin("GET /admin/cashshop/players", server.adminListPlayers)
+	common.HandleAdmin("POST /admin/cashshop/players/{name}/kick", server.adminKickPlayer)
+}
+
+func (server *Server) adminDispatch(w http.ResponseWriter, fn func()) bool {
+	if err := common.AdminDispatch(server.dispatch, fn); err != nil {
+		common.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func (server *Server) adminListPlayers(w http.ResponseWriter, r *http.Request) {
+	var players []channel.PlayerSummary
+
+	if server.adminDispatch(w, func() {
+		players = server.players.Summaries()
+	}) {
+		common.WriteJSON(w, http.StatusOK, players)
+	}
+}
+
+func (server *Server) adminKickPlayer(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	found := false
+
+	if !server.adminDispatch(w, func() {
+		if plr, err := server.players.GetFromName(name); err == nil {
+			found = true
+			plr.Kick()
+		}
+	}) {
+		return
+	}
+
+	if !found {
+		common.WriteJSONError(w, http.StatusNotFound, "player is not in the cash shop")
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, map[string]string{"kicked": name})
+}
@@ -1,7 +1,11 @@
 package cashshop
 
 import (
+	"errors"
 	"log"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/Hucaru/Valhalla/channel"
 	"github.com/Hucaru/Valhalla/common"
@@ -11,6 +15,7 @@ import (
 	"github.com/Hucaru/Valhalla/mnet"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 func (server *Server) HandleClientPacket(conn mnet.Client, reader mpacket.Reader) {
@@ -33,39 +38,30 @@ func (server *Server) HandleClientPacket(conn mnet.Client, reader mpacket.Reader
 func (server *Server) handlePlayerConnect(conn mnet.Client, reader mpacket.Reader) {
 	charID := reader.ReadInt32()
 
-	// Fetch channelID, migrationID and accountID in a single query
-	var (
-		migrationID byte
-		channelID   int8
-		accountID   int32
-	)
-	err := common.DB.QueryRow(
-		"SELECT channelID, migrationID, accountID FROM characters WHERE ID=?",
-		charID,
-	).Scan(&channelID, &migrationID, &accountID)
+	char, err := common.Repo.Characters.ByID(charID)
 	if err != nil {
 		log.Println("playerConnect query error:", err)
 		return
 	}
 
-	if migrationID != 50 {
-		log.Println("cashshop:playerConnect: invalid migrationID:", migrationID)
+	if char.MigrationID != 50 {
+		log.Println("cashshop:playerConnect: invalid migrationID:", char.MigrationID)
 		return
 	}
 
+	accountID := char.AccountID
 	conn.SetAccountID(accountID)
 
-	var adminLevel int
-	err = common.DB.QueryRow("SELECT adminLevel FROM accounts WHERE accountID=?", conn.GetAccountID()).Scan(&adminLevel)
+	account, err := common.Repo.Accounts.ByID(conn.GetAccountID())
 
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	conn.SetAdminLevel(adminLevel)
+	conn.SetAdminLevel(account.AdminLevel)
 
-	_, err = common.DB.Exec("UPDATE characters SET migrationID=? WHERE ID=?", -1, charID)
+	err = common.Repo.Characters.SetMigration(charID, -1)
 
 	if err != nil {
 		log.Println(err)
@@ -84,14 +80,18 @@ func (server *Server) handlePlayerConnect(conn mnet.Client, reader mpacket.Reade
 
 	server.world.Send(internal.PacketChannelPlayerConnected(plr.ID, plr.Name, server.id, false, 0, 0))
 
-	plr.Send(packetCashShopSet(&plr))
+	wishlist, err := common.Repo.Wishlists.ByCharacter(plr.ID)
+	if err != nil {
+		log.Println("Failed to load wishlist for character", plr.ID, ":", err)
+	}
 
-	// Send cash shop storage items to player (before wishlist and amounts, matching OpenMG order)
+	plr.Send(packetCashShopSet(&plr, wishlist))
+
+	// Send cash shop storage items to player (before amounts, matching OpenMG order)
 	if storage != nil {
 		plr.Send(packetCashShopLoadLocker(storage, accountID, plr.ID))
 	}
 
-	//plr.Send(packetCashShopWishList(nil, false))
 	plr.Send(packetCashShopUpdateAmounts(plr.GetNX(), plr.GetMaplePoints()))
 }
 
@@ -101,9 +101,11 @@ func (server *Server) leaveCashShopToChannel(conn mnet.Client, reader mpacket.Re
 		return
 	}
 
-	var prevChanID int8
-	if err := common.DB.QueryRow("SELECT previousChannelID FROM characters WHERE ID=?", plr.ID).Scan(&prevChanID); err != nil {
+	var prevChanID int32 = -1
+	if char, err := common.Repo.Characters.ByID(plr.ID); err != nil {
 		log.Println("Failed to fetch previousChannelID:", err)
+	} else {
+		prevChanID = char.PreviousChannelID
 	}
 
 	targetChan := plr.ChannelID
@@ -111,7 +113,7 @@ func (server *Server) leaveCashShopToChannel(conn mnet.Client, reader mpacket.Re
 		targetChan = byte(prevChanID)
 	}
 
-	if _, err := common.DB.Exec("UPDATE characters SET migrationID=?, inCashShop=0 WHERE ID=?", targetChan, plr.ID); err != nil {
+	if err := common.Repo.Characters.LeaveCashShop(plr.ID, int32(targetChan)); err != nil {
 		log.Println("Failed to set migrationID:", err)
 		return
 	}
@@ -355,7 +357,38 @@ func (server *Server) handleCashShopOperation(conn mnet.Client, reader mpacket.R
 		plr.Send(packetCashShopUpdateAmounts(plrNX, plrMaplePoints))
 
 	case opcode.RecvCashShopGiftItem:
+		dob := reader.ReadInt32()
+		sn := reader.ReadInt32()
+		recipientName := reader.ReadString(reader.ReadInt16())
+		message := reader.ReadString(reader.ReadInt16())
+
+		server.giftItem(conn, plr, dob, sn, recipientName, message)
 	case opcode.RecvCashShopUpdateWishlist:
+		sns := make([]int32, 0, constant.CashShopWishlistSize)
+		for i := 0; i < constant.CashShopWishlistSize; i++ {
+			sn := reader.ReadInt32()
+			if sn == 0 {
+				continue
+			}
+
+			if commodity, ok := nx.GetCommodity(sn); !ok || commodity.ItemID == 0 {
+				continue
+			}
+
+			sns = append(sns, sn)
+		}
+
+		if err := common.Repo.Wishlists.Save(plr.ID, sns); err != nil {
+			log.Println("Failed to save wishlist for character", plr.ID, ":", err)
+			plr.Send(packetCashShopError(opcode.SendCashShopUpdateWishFailed, constant.CashShopErrorUnknown))
+			return
+		}
+
+		plr.Send(packetCashShopWishList(sns))
+	case opcode.RecvCashShopRedeemCoupon:
+		code := reader.ReadString(reader.ReadInt16())
+
+		server.redeemCoupon(conn, plr, code)
 	case opcode.RecvCashShopIncreaseSlots:
 		currencySel := reader.ReadByte()
 		invType := reader.ReadByte()
@@ -447,6 +480,8 @@ func (server *Server) handleCashShopOperation(conn mnet.Client, reader mpacket.R
 			return
 		}
 
+		storage.dropGift(item.GetCashID())
+
 		plr.Send(packetCashShopMoveLtoSDone(givenItem, targetSlot))
 
 	case opcode.RecvCashShopMoveStoL:
@@ -506,3 +541,232 @@ func (server *Server) handleCashShopOperation(conn mnet.Client, reader mpacket.R
 	}
 
 }
+
+// giftItem buys the commodity with the sender's NX and places it in the locker of the recipient's account
+func (server *Server) giftItem(conn mnet.Client, plr *channel.Player, dob, sn int32, recipientName, message string) {
+	plrNX := plr.GetNX()
+	plrMaplePoints := plr.GetMaplePoints()
+
+	commodity, ok := nx.GetCommodity(sn)
+	if !ok || commodity.ItemID == 0 || commodity.OnSale == 0 || commodity.Price <= 0 {
+		plr.Send(packetCashShopUpdateAmounts(plrNX, plrMaplePoints))
+		return
+	}
+
+	if len(message) > constant.CashShopGiftMessageMaxLength {
+		plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorUnknown))
+		return
+	}
+
+	account, err := common.Repo.Accounts.ByID(conn.GetAccountID())
+	if err != nil {
+		log.Println("giftItem: failed to load account", conn.GetAccountID(), ":", err)
+		plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorUnknown))
+		return
+	}
+
+	if int32(account.DOB) != dob {
+		plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorInvalidDateOfBirth))
+		return
+	}
+
+	price := commodity.Price
+	if plrNX < price {
+		plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorNotEnoughCash))
+		return
+	}
+
+	recipient, err := common.Repo.Characters.ByName(recipientName, plr.GetWorldID())
+	if err != nil || recipient.AccountID == conn.GetAccountID() {
+		plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorIneligibleRecipientNameOrGender))
+		return
+	}
+
+	count := int16(1)
+	if commodity.Count > 0 {
+		count = int16(commodity.Count)
+	}
+
+	newItem, err := channel.CreateItemFromID(commodity.ItemID, count)
+	if err != nil {
+		plr.Send(packetCashShopUpdateAmounts(plrNX, plrMaplePoints))
+		return
+	}
+
+	// The recipient's account may have a character in the cash shop right now, in which case its locker is cached
+	// on that connection and is written back on disconnect
+	var storage *CashShopStorage
+	if online, err := server.players.GetFromAccountID(recipient.AccountID); err == nil {
+		storage, err = server.GetOrLoadStorage(online.Conn)
+		if err != nil {
+			log.Println("giftItem: failed to get cash shop storage:", err)
+			plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorUnknown))
+			return
+		}
+	} else {
+		storage = NewCashShopStorage(recipient.AccountID)
+		if err := storage.load(); err != nil {
+			log.Println("giftItem: failed to load cash shop storage:", err)
+			plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorUnknown))
+			return
+		}
+	}
+
+	slotIdx, added := storage.addItem(newItem, sn)
+	if !added {
+		plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorExceededNumberOfCashItems))
+		return
+	}
+
+	if err := storage.save(); err != nil {
+		log.Println("giftItem: failed to save cash shop storage:", err)
+		storage.removeAt(slotIdx)
+		plr.Send(packetCashShopError(opcode.SendCashShopGiftFailed, constant.CashShopErrorUnknown))
+		return
+	}
+
+	gift := repository.Gift{
+		AccountID: recipient.AccountID,
+		CashID:    storage.items[slotIdx].GetCashID(),
+		ItemID:    commodity.ItemID,
+		Sender:    plr.Name,
+		Message:   message,
+	}
+
+	if err := common.Repo.Gifts.Create(gift); err != nil {
+		log.Println("giftItem: failed to store gift note from", plr.Name, "to", recipient.Name, ":", err)
+	}
+
+	plrNX -= price
+	plr.SetNX(plrNX)
+
+	plr.Send(packetCashShopUpdateAmounts(plrNX, plrMaplePoints))
+	plr.Send(packetCashShopGiftDone(recipient.Name, commodity.ItemID, count, price))
+}
+
+// redeemCoupon grants a coupon's rewards, cash items go to the account's locker and everything else to the character
+func (server *Server) redeemCoupon(conn mnet.Client, plr *channel.Player, code string) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || len(code) > constant.CashShopCouponCodeMaxLength {
+		plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCheckCouponNumber))
+		return
+	}
+
+	coupon, err := common.Repo.Coupons.ByCode(code)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			log.Println("redeemCoupon: failed to load coupon", code, ":", err)
+		}
+		plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCheckCouponNumber))
+		return
+	}
+
+	if coupon.Expired(time.Now().UnixMilli()) {
+		plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCouponExpired))
+		return
+	}
+
+	if coupon.MaxUses > 0 && coupon.Uses >= coupon.MaxUses {
+		plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCouponAlreadyUsed))
+		return
+	}
+
+	if int64(plr.GetMesos())+int64(coupon.Mesos) > math.MaxInt32 ||
+		int64(plr.GetNX())+int64(coupon.NX) > math.MaxInt32 ||
+		int64(plr.GetMaplePoints())+int64(coupon.MaplePoints) > math.MaxInt32 {
+		plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorUnknown))
+		return
+	}
+
+	cashItems := []channel.Item{}
+	items := []channel.Item{}
+	for _, reward := range coupon.Items {
+		item, err := channel.CreateItemFromID(reward.ItemID, reward.Amount)
+		if err != nil {
+			log.Println("redeemCoupon: coupon", code, "has invalid item", reward.ItemID, ":", err)
+			plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorUnknown))
+			return
+		}
+
+		if item.IsCash() {
+			cashItems = append(cashItems, item)
+		} else {
+			items = append(items, item)
+		}
+	}
+
+	if !plr.CanReceiveItems(items) {
+		plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCheckFullInventory))
+		return
+	}
+
+	storage, err := server.GetOrLoadStorage(conn)
+	if err != nil {
+		log.Println("redeemCoupon: failed to get cash shop storage:", err)
+		plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorUnknown))
+		return
+	}
+
+	added := make([]int, 0, len(cashItems))
+	undo := func() {
+		for i := len(added) - 1; i >= 0; i-- {
+			storage.removeAt(added[i])
+		}
+	}
+
+	for _, item := range cashItems {
+		slotIdx, ok := storage.addItem(item, 0)
+		if !ok {
+			undo()
+			plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorExceededNumberOfCashItems))
+			return
+		}
+		added = append(added, slotIdx)
+	}
+
+	if err := common.Repo.Coupons.Redeem(code, conn.GetAccountID(), plr.ID); err != nil {
+		undo()
+
+		switch {
+		case errors.Is(err, repository.ErrDuplicate), errors.Is(err, repository.ErrExhausted):
+			plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCouponAlreadyUsed))
+		case errors.Is(err, repository.ErrNotFound):
+			plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCheckCouponNumber))
+		case errors.Is(err, repository.ErrExpired):
+			plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorCouponExpired))
+		default:
+			log.Println("redeemCoupon: failed to redeem coupon", code, "for account", conn.GetAccountID(), ":", err)
+			plr.Send(packetCashShopError(opcode.SendCashShopUseCouponFailed, constant.CashShopErrorUnknown))
+		}
+		return
+	}
+
+	if len(added) > 0 {
+		if err := storage.save(); err != nil {
+			log.Println("CRITICAL: coupon", code, "redeemed but cash shop storage failed to save. accountID:", conn.GetAccountID(), ":", err)
+		}
+	}
+
+	storedCashItems := make([]channel.Item, 0, len(added))
+	for _, idx := range added {
+		storedCashItems = append(storedCashItems, storage.items[idx])
+	}
+
+	for _, item := range items {
+		if err, _ := plr.GiveItem(item); err != nil {
+			log.Println("CRITICAL: coupon", code, "redeemed but item", item.ID, "could not be given to player", plr.ID, ":", err)
+		}
+	}
+
+	plrNX := plr.GetNX() + coupon.NX
+	plrMaplePoints := plr.GetMaplePoints() + coupon.MaplePoints
+	plr.SetNX(plrNX)
+	plr.SetMaplePoints(plrMaplePoints)
+
+	if coupon.Mesos > 0 {
+		plr.GiveMesos(coupon.Mesos)
+	}
+
+	plr.Send(packetCashShopUpdateAmounts(plrNX, plrMaplePoints))
+	plr.Send(packetCashShopCouponRedeemed(storedCashItems, conn.GetAccountID(), plr.ID, coupon.MaplePoints, items, coupon.Mesos))
+}
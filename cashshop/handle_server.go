@@ -55,7 +55,7 @@ func (server *Server) handlePlayerDisconnect(conn mnet.Server, reader mpacket.Re
 		return
 	}
 
-	if _, err := common.DB.Exec("UPDATE characters SET inCashShop=0 WHERE ID=?", playerID); err != nil {
+	if err := common.Repo.Characters.SetInCashShop(playerID, false); err != nil {
 		return
 	}
 
@@ -3,11 +3,12 @@ package cashshop
 import (
 	"github.com/Hucaru/Valhalla/channel"
 	"github.com/Hucaru/Valhalla/common/opcode"
+	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
 )
 
-func packetCashShopSet(plr *channel.Player) mpacket.Packet {
+func packetCashShopSet(plr *channel.Player, wishlist []int32) mpacket.Packet {
 	p := mpacket.CreateWithOpcode(opcode.SendChannelSetCashShop)
 
 	plr.WriteCharacterInfoPacket(&p)
@@ -15,7 +16,10 @@ func packetCashShopSet(plr *channel.Player) mpacket.Packet {
 	p.WriteByte(1)
 	p.WriteString(plr.GetAccountName())
 
-	p.WriteInt16(0) // Wishlist
+	p.WriteInt16(int16(len(wishlist)))
+	for _, sn := range wishlist {
+		p.WriteInt32(sn)
+	}
 
 	p.WriteBytes(make([]byte, 121))
 
@@ -94,15 +98,33 @@ func packetCashShopShowBoughtQuestItem(position byte, itemID int32) mpacket.Pack
 	return p
 }
 
-func packetCashShopShowCouponRedeemedItem(itemID int32) mpacket.Packet {
+func packetCashShopCouponRedeemed(cashItems []channel.Item, accountID, characterID, maplePoints int32, items []channel.Item, mesos int32) mpacket.Packet {
 	p := mpacket.CreateWithOpcode(opcode.SendChannelCSAction)
-	p.WriteInt16(0x3A)
-	p.WriteInt32(0)
-	p.WriteInt32(1)
-	p.WriteInt16(1)
-	p.WriteInt16(0x1A)
-	p.WriteInt32(itemID)
-	p.WriteInt32(0)
+	p.WriteByte(opcode.SendCashShopUseCouponDone)
+
+	p.WriteByte(byte(len(cashItems)))
+	for _, csItem := range cashItems {
+		p.WriteInt64(csItem.GetCashID())
+		p.WriteInt32(accountID)
+		p.WriteInt32(characterID)
+		p.WriteInt32(csItem.ID)
+		p.WriteInt32(csItem.GetCashSN())
+		p.WriteInt16(csItem.GetAmount())
+		p.WritePaddedString("", 13) // GiftName
+		p.WriteInt64(csItem.GetExpireTime())
+		p.WriteInt64(0)
+	}
+
+	p.WriteInt32(maplePoints)
+
+	p.WriteInt32(int32(len(items)))
+	for _, item := range items {
+		p.WriteInt16(item.GetAmount())
+		p.WriteInt16(0x1A)
+		p.WriteInt32(item.ID)
+	}
+
+	p.WriteInt32(mesos)
 	return p
 }
 
@@ -116,15 +138,10 @@ func packetCashShopSendCSItemInventory(slotType byte, it channel.Item) mpacket.P
 	return p
 }
 
-func packetCashShopWishList(sns []int32, update bool) mpacket.Packet {
+func packetCashShopWishList(sns []int32) mpacket.Packet {
 	p := mpacket.CreateWithOpcode(opcode.SendChannelCSAction)
-	if update {
-		p.WriteByte(opcode.SendCashShopUpdateWishDone)
-	} else {
-		p.WriteByte(opcode.SendCashShopLoadWishDone)
-	}
-	count := 10
-	for i := 0; i < count; i++ {
+	p.WriteByte(opcode.SendCashShopUpdateWishDone)
+	for i := 0; i < constant.CashShopWishlistSize; i++ {
 		var v int32
 		if i < len(sns) {
 			v = sns[i]
@@ -148,12 +165,19 @@ func packetCashShopLoadLocker(storage *CashShopStorage, accountID, characterID i
 		p.WriteInt32(csItem.ID)
 		p.WriteInt32(csItem.GetCashSN())
 		p.WriteInt16(csItem.GetAmount())
-		p.WritePaddedString("", 13)
+		p.WritePaddedString(storage.giftFrom(csItem.GetCashID()), 13)
 		p.WriteInt64(csItem.GetExpireTime())
 		p.WriteInt64(0) // Padding
 	}
 
-	p.WriteInt16(0) // Gift count
+	p.WriteInt16(int16(len(storage.gifts)))
+	for _, gift := range storage.gifts {
+		p.WriteInt64(gift.CashID)
+		p.WriteInt32(gift.ItemID)
+		p.WritePaddedString(gift.Sender, 13)
+		p.WritePaddedString(gift.Message, 73)
+	}
+
 	p.WriteInt16(int16(storage.maxSlots))
 	return p
 }
@@ -195,9 +219,12 @@ func packetCashShopBuyDone(csItem channel.Item, accountID, characterID int32) mp
 	return p
 }
 
-func packetCashShopWrongCoupon() mpacket.Packet {
+func packetCashShopGiftDone(recipient string, itemID int32, count int16, price int32) mpacket.Packet {
 	p := mpacket.CreateWithOpcode(opcode.SendChannelCSAction)
-	p.WriteByte(0x40)
-	p.WriteByte(0x87)
+	p.WriteByte(opcode.SendCashShopGiftDone)
+	p.WriteString(recipient)
+	p.WriteInt32(itemID)
+	p.WriteInt16(count)
+	p.WriteInt32(price)
 	return p
 }
@@ -0,0 +1,30 @@
+package cashshop
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Hucaru/Valhalla/channel"
+)
+
+func TestPacketCashShopSetWishlist(t *testing.T) {
+	plr := &channel.Player{}
+	wishlist := []int32{10000001, 10000002}
+
+	base := packetCashShopSet(plr, nil)
+	withList := packetCashShopSet(plr, wishlist)
+
+	if got, want := len(withList)-len(base), 4*len(wishlist); got != want {
+		t.Errorf("wishlist added %d bytes, want %d", got, want)
+	}
+
+	want := binary.LittleEndian.AppendUint16(nil, uint16(len(wishlist)))
+	for _, sn := range wishlist {
+		want = binary.LittleEndian.AppendUint32(want, uint32(sn))
+	}
+
+	if !bytes.Contains(withList, want) {
+		t.Errorf("packet does not hold the wishlist count and SNs % x", want)
+	}
+}
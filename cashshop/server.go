@@ -39,6 +39,7 @@ func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress
 
 	log.Println("Initialised game state")
 
+	server.registerAdminHandlers()
 	common.StartMetrics()
 	log.Println("Started serving metrics on :" + common.MetricsPort)
 }
@@ -93,7 +94,7 @@ func (server *Server) ClientDisconnected(conn mnet.Client) {
 		server.migrating = append(server.migrating[:idx], server.migrating[idx+1:]...)
 	}
 
-	if _, dbErr := common.DB.Exec("UPDATE accounts SET isLogedIn=0 WHERE accountID=?", conn.GetAccountID()); dbErr != nil {
+	if dbErr := common.Repo.Accounts.SetLoggedIn(conn.GetAccountID(), false); dbErr != nil {
 		log.Println("Unable to complete logout for ", conn.GetAccountID())
 	}
 
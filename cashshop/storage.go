@@ -1,12 +1,13 @@
 package cashshop
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/Hucaru/Valhalla/channel"
 	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 // Cash shop storage capacity bounds
@@ -21,6 +22,7 @@ type CashShopStorage struct {
 	maxSlots       byte
 	totalSlotsUsed byte
 	items          []channel.Item
+	gifts          []repository.Gift
 }
 
 func clampByte(v, min, max byte) byte {
@@ -54,16 +56,10 @@ func (s *CashShopStorage) ensureCapacity() {
 
 // Load items and header from DB into channel.Item
 func (s *CashShopStorage) load() error {
-	var slots sql.NullInt64
-	if err := common.DB.QueryRow(
-		"SELECT slots FROM account_cashshop_storage WHERE accountID=?",
-		s.accountID,
-	).Scan(&slots); err != nil {
-		if err == sql.ErrNoRows {
-			if _, ierr := common.DB.Exec(
-				"INSERT INTO account_cashshop_storage(accountID, slots) VALUES(?,?)",
-				s.accountID, cashShopStorageMinSlots,
-			); ierr != nil {
+	contents, err := common.Repo.Storage.LoadCashShop(s.accountID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			if ierr := common.Repo.Storage.CreateCashShop(s.accountID, cashShopStorageMinSlots); ierr != nil {
 				return fmt.Errorf("couldn't initialize cash shop storage for account %d: %w", s.accountID, ierr)
 			}
 			s.maxSlots = cashShopStorageMinSlots
@@ -71,144 +67,124 @@ func (s *CashShopStorage) load() error {
 			s.totalSlotsUsed = 0
 			return nil
 		}
-		return fmt.Errorf("failed to load cash shop storage header for account %d: %w", s.accountID, err)
+		return fmt.Errorf("failed to load cash shop storage for account %d: %w", s.accountID, err)
 	}
 
-	if slots.Valid {
-		s.maxSlots = clampByte(byte(slots.Int64), cashShopStorageMinSlots, cashShopStorageMaxSlots)
-	} else {
-		s.maxSlots = cashShopStorageMinSlots
+	s.maxSlots = clampByte(contents.Slots, cashShopStorageMinSlots, cashShopStorageMaxSlots)
+
+	if s.gifts, err = common.Repo.Gifts.ByAccount(s.accountID); err != nil {
+		log.Println("Failed to load cash shop gifts for account", s.accountID, ":", err)
 	}
 
 	s.ensureCapacity()
 	s.totalSlotsUsed = 0
+	expired := false
 
-	rows, qerr := common.DB.Query(`
-		SELECT 
-			itemID, cashID, sn, slotNumber, amount,
-			flag, upgradeSlots, level, str, dex, intt, luk, hp, mp,
-			watk, matk, wdef, mdef, accuracy, avoid, hands, speed, jump,
-			expireTime, creatorName, UNIX_TIMESTAMP(purchaseDate)
-		FROM account_cashshop_storage_items
-		WHERE accountID=?
-		ORDER BY slotNumber ASC`, s.accountID)
-	if qerr != nil {
-		return fmt.Errorf("failed to load cash shop storage items for account %d: %w", s.accountID, qerr)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var slotNumber int16
-		var itemID int32
-		var amount int16
-		var flag int16
-		var upgradeSlots byte
-		var scrollLevel byte
-		var str, dex, intt, luk, hp, mp int16
-		var watk, matk, wdef, mdef int16
-		var accuracy, avoid, hands, speed, jump int16
-		var expireTime int64
-		var creatorName sql.NullString
-		var cashIDNullable sql.NullInt64
-		var sn int32
-		var _purchaseTS sql.NullInt64
-
-		if err := rows.Scan(
-			&itemID, &cashIDNullable, &sn, &slotNumber, &amount,
-			&flag, &upgradeSlots, &scrollLevel,
-			&str, &dex, &intt, &luk,
-			&hp, &mp, &watk, &matk,
-			&wdef, &mdef, &accuracy, &avoid,
-			&hands, &speed, &jump,
-			&expireTime, &creatorName, &_purchaseTS,
-		); err != nil {
-			log.Println("Error scanning cash shop storage item:", err)
-			continue
-		}
-
-		var creator string
-		if creatorName.Valid {
-			creator = creatorName.String
-		}
-
+	for _, row := range contents.Items {
 		it, ierr := channel.CreateItemFromDBValues(
-			itemID, slotNumber, amount, flag, upgradeSlots, scrollLevel,
-			str, dex, intt, luk, hp, mp, watk, matk, wdef, mdef,
-			accuracy, avoid, hands, speed, jump, expireTime, creator,
+			row.ItemID, row.Slot, row.Amount, row.Flag, row.UpgradeSlots, row.Level,
+			row.Str, row.Dex, row.Intt, row.Luk, row.HP, row.MP, row.Watk, row.Matk, row.Wdef, row.Mdef,
+			row.Accuracy, row.Avoid, row.Hands, row.Speed, row.Jump, row.ExpireTime, row.CreatorName,
 		)
 		if ierr != nil {
 			log.Println("Error creating item from DB values:", ierr)
 			continue
 		}
 
-		if cashIDNullable.Valid {
-			it.SetCashID(cashIDNullable.Int64)
+		if row.CashID != 0 {
+			it.SetCashID(row.CashID)
 		} else {
 			it.SetCashID(channel.GenerateCashID())
 		}
-		it.SetCashSN(sn)
+		it.SetCashSN(row.CashSN)
 
-		if slotNumber <= 0 || slotNumber > int16(s.maxSlots) {
+		if it.Expired() {
+			log.Println("Cash shop storage: item", row.ItemID, "of account", s.accountID, "has expired")
+			expired = true
 			continue
 		}
-		idx := int(slotNumber - 1)
+
+		if row.Slot <= 0 || row.Slot > int16(s.maxSlots) {
+			continue
+		}
+		idx := int(row.Slot - 1)
 		s.items[idx] = it
-		if itemID != 0 {
+		if row.ItemID != 0 {
 			s.totalSlotsUsed++
 		}
 	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error while reading cash shop storage items for account %d: %w", s.accountID, err)
+
+	// Time-limited items that ran out are dropped from the locker for good
+	if expired {
+		if err := s.save(); err != nil {
+			log.Println("Failed to remove expired items from cash shop storage of account", s.accountID, ":", err)
+		}
 	}
 
+	s.pruneGifts()
+
 	return nil
 }
 
-func (s *CashShopStorage) save() (err error) {
-	tx, terr := common.DB.Begin()
-	if terr != nil {
-		return fmt.Errorf("couldn't open transaction to save cash shop storage (acct %d): %w", s.accountID, terr)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
+func (s *CashShopStorage) save() error {
+	contents := repository.StorageContents{Slots: s.maxSlots}
+
+	for i := range s.items {
+		if s.items[i].ID == 0 {
+			continue
 		}
-	}()
-
-	if _, uerr := tx.Exec(
-		"UPDATE account_cashshop_storage SET slots=? WHERE accountID=?",
-		s.maxSlots, s.accountID,
-	); uerr != nil {
-		err = fmt.Errorf("failed to update cash shop storage header (acct %d): %w", s.accountID, uerr)
-		return
+
+		row := s.items[i].Row()
+		row.Slot = int16(i + 1)
+		contents.Items = append(contents.Items, row)
 	}
 
-	if _, derr := tx.Exec(
-		"DELETE FROM account_cashshop_storage_items WHERE accountID=?",
-		s.accountID,
-	); derr != nil {
-		err = fmt.Errorf("failed to clear cash shop storage items (acct %d): %w", s.accountID, derr)
-		return
+	return common.Repo.Storage.SaveCashShop(s.accountID, contents)
+}
+
+// giftFrom returns the name of the character that gifted the item, empty if it was bought
+func (s *CashShopStorage) giftFrom(cashID int64) string {
+	for _, g := range s.gifts {
+		if g.CashID == cashID {
+			return g.Sender
+		}
 	}
+	return ""
+}
 
-	for i := range s.items {
-		if s.items[i].ID == 0 {
+// dropGift removes the gift note of an item that has left the locker
+func (s *CashShopStorage) dropGift(cashID int64) {
+	kept := s.gifts[:0]
+
+	for _, g := range s.gifts {
+		if g.CashID != cashID {
+			kept = append(kept, g)
 			continue
 		}
 
-		slotNumber := int16(i + 1)
-		if ierr := s.items[i].SaveToCashShopStorage(tx, s.accountID, slotNumber); ierr != nil {
-			err = fmt.Errorf("failed inserting cash shop item (acct %d, slot %d): %w", s.accountID, slotNumber, ierr)
-			return
+		if err := common.Repo.Gifts.Delete(g.ID); err != nil {
+			log.Println("Failed to remove cash shop gift", g.ID, "for account", s.accountID, ":", err)
+			kept = append(kept, g)
 		}
 	}
 
-	if cerr := tx.Commit(); cerr != nil {
-		err = fmt.Errorf("failed to commit cash shop storage save (acct %d): %w", s.accountID, cerr)
-		return
+	s.gifts = kept
+}
+
+// pruneGifts removes the notes of gifted items that are no longer in the locker, e.g. ones that expired
+func (s *CashShopStorage) pruneGifts() {
+	inLocker := make(map[int64]bool, s.totalSlotsUsed)
+	for i := range s.items {
+		if s.items[i].ID != 0 {
+			inLocker[s.items[i].GetCashID()] = true
+		}
 	}
 
-	return nil
+	for _, g := range append([]repository.Gift(nil), s.gifts...) {
+		if !inLocker[g.CashID] {
+			s.dropGift(g.CashID)
+		}
+	}
 }
 
 // addItem adds an item with a generated cashID and provided SN
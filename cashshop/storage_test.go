@@ -0,0 +1,48 @@
+package cashshop
+
+import (
+	"testing"
+
+	"github.com/Hucaru/Valhalla/channel"
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+func TestGiftNotesFollowLockerItems(t *testing.T) {
+	common.Repo = repository.NewMemory().Repositories()
+
+	for _, g := range []repository.Gift{
+		{AccountID: 1, CashID: 100, ItemID: 5000000, Sender: "Kept"},
+		{AccountID: 1, CashID: 200, ItemID: 5000001, Sender: "Moved"},
+		{AccountID: 1, CashID: 300, ItemID: 5000002, Sender: "Gone"},
+	} {
+		if err := common.Repo.Gifts.Create(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewCashShopStorage(1)
+	s.gifts, _ = common.Repo.Gifts.ByAccount(1)
+
+	for _, cashID := range []int64{100, 200} {
+		it := channel.Item{ID: 5000000}
+		s.addItemWithCashID(it, 0, cashID)
+	}
+
+	s.pruneGifts()
+
+	if s.giftFrom(300) != "" {
+		t.Error("note of an item no longer in the locker was kept")
+	}
+
+	if s.giftFrom(100) != "Kept" || s.giftFrom(200) != "Moved" {
+		t.Error("notes of items still in the locker were removed")
+	}
+
+	s.dropGift(200)
+
+	stored, _ := common.Repo.Gifts.ByAccount(1)
+	if len(stored) != 1 || stored[0].CashID != 100 {
+		t.Errorf("stored notes = %v, want only the one for cash ID 100", stored)
+	}
+}
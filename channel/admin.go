@@ -0,0 +1,358 @@
+package channel
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Hucaru/Valhalla/common"
+)
+
+// PlayerSummary is the admin API view of an online player
+type PlayerSummary struct {
+	ID        int32  `json:"id"`
+	AccountID int32  `json:"accountID"`
+	Name      string `json:"name"`
+	Level     byte   `json:"level"`
+	Job       int16  `json:"job"`
+	MapID     int32  `json:"mapID"`
+	Instance  int    `json:"instance"`
+	GM        bool   `json:"gm"`
+}
+
+// Summaries of the online players sorted by name
+func (p Players) Summaries() []PlayerSummary {
+	summaries := make([]PlayerSummary, 0, len(p.conn))
+
+	for _, plr := range p.conn {
+		s := PlayerSummary{
+			ID:        plr.ID,
+			AccountID: plr.accountID,
+			Name:      plr.Name,
+			Level:     plr.level,
+			Job:       plr.job,
+			MapID:     plr.mapID,
+		}
+
+		if plr.inst != nil {
+			s.Instance = plr.inst.id
+		}
+
+		if plr.Conn != nil {
+			s.GM = plr.Conn.GetAdminLevel() > 0
+		}
+
+		summaries = append(summaries, s)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+
+	return summaries
+}
+
+type adminChannelInfo struct {
+	Channel int    `json:"channel"`
+	World   string `json:"world"`
+	Players int    `json:"players"`
+}
+
+type adminInstanceInfo struct {
+	ID       int                `json:"id"`
+	Players  []adminFieldPlayer `json:"players"`
+	Mobs     int                `json:"mobs"`
+	NPCs     int                `json:"npcs"`
+	Drops    int                `json:"drops"`
+	Rooms    int                `json:"rooms"`
+	Reactors int                `json:"reactors"`
+}
+
+type adminFieldPlayer struct {
+	Name string `json:"name"`
+	X    int16  `json:"x"`
+	Y    int16  `json:"y"`
+}
+
+type adminNoticeRequest struct {
+	Message string `json:"message"`
+	Type    string `json:"type"` // notice (default), box or header
+}
+
+type adminBanRequest struct {
+	Hours  *int   `json:"hours"` // defaults to a week, 0 is permanent
+	Reason string `json:"reason"`
+}
+
+var adminChannels = struct {
+	sync.Mutex
+	servers map[byte]*Server
+}{servers: make(map[byte]*Server)}
+
+// registerAdmin makes the channel reachable through the admin API, the world can hand out a different ID on reconnect
+func (server *Server) registerAdmin() {
+	adminChannels.Lock()
+	defer adminChannels.Unlock()
+
+	for id, v := range adminChannels.servers {
+		if v == server {
+			delete(adminChannels.servers, id)
+		}
+	}
+
+	adminChannels.servers[server.id] = server
+}
+
+func registerAdminHandlers() {
+	common.HandleAdmin("GET /admin/channels", adminListChannels)
+	common.HandleAdmin("GET /admin/channels/{channel}/players", adminListPlayers)
+	common.HandleAdmin("POST /admin/channels/{channel}/players/{name}/kick", adminKickPlayer)
+	common.HandleAdmin("POST /admin/channels/{channel}/players/{name}/ban", adminBanPlayer)
+	common.HandleAdmin("POST /admin/channels/{channel}/notice", adminNotice)
+	common.HandleAdmin("GET /admin/channels/{channel}/fields/{mapID}", adminInspectField)
+}
+
+// adminChannel resolves the {channel} path value, channels are numbered from 1 as players see them
+func adminChannel(w http.ResponseWriter, r *http.Request) (*Server, bool) {
+	n, err := strconv.Atoi(r.PathValue("channel"))
+	if err != nil || n < 1 {
+		common.WriteJSONError(w, http.StatusBadRequest, "invalid channel")
+		return nil, false
+	}
+
+	adminChannels.Lock()
+	server, ok := adminChannels.servers[byte(n-1)]
+	adminChannels.Unlock()
+
+	if !ok {
+		common.WriteJSONError(w, http.StatusNotFound, "channel is not served by this process")
+		return nil, false
+	}
+
+	return server, true
+}
+
+func (server *Server) adminDispatch(w http.ResponseWriter, fn func()) bool {
+	if err := common.AdminDispatch(server.dispatch, fn); err != nil {
+		common.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func adminListChannels(w http.ResponseWriter, r *http.Request) {
+	adminChannels.Lock()
+	servers := make(map[byte]*Server, len(adminChannels.servers))
+	for id, v := range adminChannels.servers {
+		servers[id] = v
+	}
+	adminChannels.Unlock()
+
+	channels := make([]adminChannelInfo, 0, len(servers))
+
+	for id, server := range servers {
+		info := adminChannelInfo{Channel: int(id) + 1}
+
+		if !server.adminDispatch(w, func() {
+			info.World = server.worldName
+			info.Players = server.players.count()
+		}) {
+			return
+		}
+
+		channels = append(channels, info)
+	}
+
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Channel < channels[j].Channel })
+
+	common.WriteJSON(w, http.StatusOK, channels)
+}
+
+func adminListPlayers(w http.ResponseWriter, r *http.Request) {
+	server, ok := adminChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var players []PlayerSummary
+
+	if server.adminDispatch(w, func() {
+		players = server.players.Summaries()
+	}) {
+		common.WriteJSON(w, http.StatusOK, players)
+	}
+}
+
+func adminKickPlayer(w http.ResponseWriter, r *http.Request) {
+	server, ok := adminChannel(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	found := false
+
+	if !server.adminDispatch(w, func() {
+		if plr, err := server.players.GetFromName(name); err == nil {
+			found = true
+			plr.Kick()
+		}
+	}) {
+		return
+	}
+
+	if !found {
+		common.WriteJSONError(w, http.StatusNotFound, "player is not on this channel")
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, map[string]string{"kicked": name})
+}
+
+// adminBanPlayer bans an online player through the channel's anti-cheat, which also kicks them
+func adminBanPlayer(w http.ResponseWriter, r *http.Request) {
+	server, ok := adminChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var req adminBanRequest
+	if err := common.ReadJSON(r, &req); err != nil {
+		common.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hours := 168
+	if req.Hours != nil {
+		hours = *req.Hours
+	}
+
+	if hours < 0 {
+		common.WriteJSONError(w, http.StatusBadRequest, "hours must not be negative")
+		return
+	}
+
+	if req.Reason == "" {
+		req.Reason = "Banned by admin"
+	}
+
+	name := r.PathValue("name")
+	found := false
+	var banErr error
+
+	// The ban kicks the player, so it has to run on the channel's loop like the lookup
+	if !server.adminDispatch(w, func() {
+		plr, err := server.players.GetFromName(name)
+		if err != nil {
+			return
+		}
+
+		found = true
+		banErr = server.ac.IssueBan(plr.accountID, hours, req.Reason, "", "")
+	}) {
+		return
+	}
+
+	if !found {
+		common.WriteJSONError(w, http.StatusNotFound, "player is not on this channel")
+		return
+	}
+
+	if banErr != nil {
+		common.WriteJSONError(w, http.StatusInternalServerError, banErr.Error())
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, map[string]any{"banned": name, "hours": hours})
+}
+
+func adminNotice(w http.ResponseWriter, r *http.Request) {
+	server, ok := adminChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var req adminNoticeRequest
+	if err := common.ReadJSON(r, &req); err != nil {
+		common.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Message == "" && req.Type != "header" {
+		common.WriteJSONError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+
+	switch req.Type {
+	case "", "notice", "box", "header":
+	default:
+		common.WriteJSONError(w, http.StatusBadRequest, "type must be notice, box or header")
+		return
+	}
+
+	if server.adminDispatch(w, func() {
+		switch req.Type {
+		case "box":
+			server.players.broadcast(packetMessageDialogueBox(req.Message))
+		case "header":
+			server.header = req.Message
+			server.players.broadcast(packetMessageScrollingHeader(server.header))
+		default:
+			server.players.broadcast(packetMessageNotice(req.Message))
+		}
+	}) {
+		common.WriteJSON(w, http.StatusOK, req)
+	}
+}
+
+func adminInspectField(w http.ResponseWriter, r *http.Request) {
+	server, ok := adminChannel(w, r)
+	if !ok {
+		return
+	}
+
+	mapID, err := strconv.ParseInt(r.PathValue("mapID"), 10, 32)
+	if err != nil {
+		common.WriteJSONError(w, http.StatusBadRequest, "invalid map id")
+		return
+	}
+
+	var instances []adminInstanceInfo
+	found := false
+
+	if !server.adminDispatch(w, func() {
+		field, ok := server.fields[int32(mapID)]
+		if !ok {
+			return
+		}
+
+		found = true
+
+		for _, inst := range field.instances {
+			info := adminInstanceInfo{
+				ID:       inst.id,
+				Players:  make([]adminFieldPlayer, 0, len(inst.players)),
+				Mobs:     len(inst.lifePool.mobs),
+				NPCs:     len(inst.lifePool.npcs),
+				Drops:    len(inst.dropPool.drops),
+				Rooms:    len(inst.roomPool.rooms),
+				Reactors: len(inst.reactorPool.reactors),
+			}
+
+			for _, plr := range inst.players {
+				info.Players = append(info.Players, adminFieldPlayer{Name: plr.Name, X: plr.pos.x, Y: plr.pos.y})
+			}
+
+			instances = append(instances, info)
+		}
+	}) {
+		return
+	}
+
+	if !found {
+		common.WriteJSONError(w, http.StatusNotFound, "unknown map")
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, map[string]any{"mapID": mapID, "instances": instances})
+}
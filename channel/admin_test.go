@@ -0,0 +1,81 @@
+package channel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Hucaru/Valhalla/anticheat"
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+func TestAdminBanPlayerKicksOnLoop(t *testing.T) {
+	saved := common.Repo
+	common.Repo = repository.NewMemory().Repositories()
+	t.Cleanup(func() { common.Repo = saved })
+
+	// With an unbuffered dispatch channel the anti-cheat runs the kick inline whenever the loop is busy
+	server := &Server{id: 9, dispatch: make(chan func()), players: NewPlayers()}
+	server.players.Add(&Player{ID: 1, accountID: 5, Name: "Bot"})
+
+	onLoop := false
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case fn := <-server.dispatch:
+				onLoop = true
+				fn()
+				onLoop = false
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	kicked := make(chan bool, 1)
+	server.ac = anticheat.New(common.Repo, server.dispatch)
+	server.ac.SetOnBan(func(accountID int32) { kicked <- onLoop && accountID == 5 })
+
+	adminChannels.Lock()
+	adminChannels.servers[server.id] = server
+	adminChannels.Unlock()
+
+	t.Cleanup(func() {
+		adminChannels.Lock()
+		delete(adminChannels.servers, server.id)
+		adminChannels.Unlock()
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"hours": 1}`))
+	r.SetPathValue("channel", "10")
+	r.SetPathValue("name", "Bot")
+	w := httptest.NewRecorder()
+
+	adminBanPlayer(w, r)
+
+	close(stop)
+	<-done
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("adminBanPlayer() status = %d, body %s", w.Code, w.Body)
+	}
+
+	select {
+	case ok := <-kicked:
+		if !ok {
+			t.Error("the banned player was kicked off the channel's loop")
+		}
+	default:
+		t.Error("the banned player was not kicked")
+	}
+
+	if _, banned, _ := common.Repo.Bans.Active(5, "", ""); !banned {
+		t.Error("the ban was not stored")
+	}
+}
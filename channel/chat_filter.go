@@ -0,0 +1,53 @@
+package channel
+
+import (
+	"log"
+	"time"
+)
+
+// allowChat checks a line of chat from plr against their mute and the chat filter, telling them why if it is blocked.
+// Every blocked line is a strike with the anti-cheat and enough strikes mute the player.
+func (server *Server) allowChat(plr *Player, msg string) bool {
+	if plr.mute != nil && plr.mute.ActiveAt(time.Now()) {
+		plr.Send(packetMessageRedText(muteMessage("You are muted", *plr.mute)))
+		return false
+	}
+
+	if plr.admin() {
+		return true
+	}
+
+	var reason string
+
+	switch {
+	case !server.filter.Clean(msg):
+		reason = "banned word"
+		plr.Send(packetMessageRedText("Your message was not sent as it contains a word that is not allowed"))
+	case server.filter.Flooding(plr.ID, msg):
+		reason = "spam"
+		plr.Send(packetMessageRedText("Your message was not sent, please slow down"))
+	default:
+		return true
+	}
+
+	log.Printf("Chat filter: blocked %s from %s: %q", reason, plr.Name, msg)
+
+	if server.ac != nil && server.ac.Track(plr.accountID, "chat_filter", server.filter.StrikeLimit, server.filter.StrikeWindow()) {
+		if err := server.mutePlayer(plr, server.filter.MuteDuration(), "Too many messages blocked by the chat filter", "Chat filter"); err != nil {
+			log.Println("Failed to store chat filter mute for", plr.Name, ":", err)
+		}
+	}
+
+	return false
+}
+
+// allowName checks names, titles and notices that other players will see against the word list
+func (server *Server) allowName(plr *Player, name string) bool {
+	if server.filter.Clean(name) {
+		return true
+	}
+
+	plr.Send(packetMessageRedText("That contains a word that is not allowed"))
+
+	return false
+}
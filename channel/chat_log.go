@@ -0,0 +1,36 @@
+package channel
+
+import (
+	"fmt"
+
+	"github.com/Hucaru/Valhalla/chatlog"
+	"github.com/Hucaru/Valhalla/mpacket"
+)
+
+// logChat records a line of chat sent by plr, target is only set for whispers
+func (server *Server) logChat(plr *Player, kind, target, msg string) {
+	server.chatLog.Log(chatlog.Entry{
+		Kind:     kind,
+		Channel:  server.id,
+		MapID:    plr.mapID,
+		SenderID: plr.ID,
+		Sender:   plr.Name,
+		Target:   target,
+		Message:  msg,
+	})
+}
+
+// groupChatMessage reads the message out of a buddy, party or guild chat packet without moving the caller's reader
+func groupChatMessage(reader mpacket.Reader) string {
+	reader.Skip(int(reader.ReadByte()) * 4)
+	return reader.ReadString(reader.ReadInt16())
+}
+
+func formatChatLine(e chatlog.Entry) string {
+	from := e.Sender
+	if e.Target != "" {
+		from += " > " + e.Target
+	}
+
+	return fmt.Sprintf("[%s ch%d %d] (%s) %s: %s", e.Time.Format("01-02 15:04:05"), e.Channel+1, e.MapID, e.Kind, from, e.Message)
+}
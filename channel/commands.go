@@ -2,6 +2,7 @@ package channel
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -9,6 +10,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Hucaru/Valhalla/anticheat"
+	"github.com/Hucaru/Valhalla/chatlog"
+	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/internal"
 
@@ -16,6 +20,7 @@ import (
 
 	"github.com/Hucaru/Valhalla/mnet"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 // TODO: Split these into ranks/levels (each rank can do everything the previous can):
@@ -143,6 +148,34 @@ func (server *Server) gmCommand(conn mnet.Client, msg string) {
 		server.players.broadcast(packetMessageScrollingHeader(server.header))
 	case "wheader": // sends to world server to propagate to all channels
 
+	case "reload":
+		kinds := map[string][]string{
+			"drops":        {dataDrops},
+			"reactors":     {dataReactors},
+			"reactorDrops": {dataReactorDrops},
+			"all":          {dataDrops, dataReactors, dataReactorDrops},
+		}
+
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /reload <drops | reactors | reactorDrops | all>"))
+			return
+		}
+
+		reload, ok := kinds[command[1]]
+		if !ok {
+			conn.Send(packetMessageRedText("Choose between drops/reactors/reactorDrops/all"))
+			return
+		}
+
+		server.reloadData(reload, func(kind string, err error) {
+			if err != nil {
+				conn.Send(packetMessageRedText(fmt.Sprintf("Reloading %s failed, keeping the old table: %v", kind, err)))
+				return
+			}
+
+			conn.Send(packetMessageNotice("Reloaded " + kind))
+		})
+
 	case "kill":
 		player, err := server.players.GetFromConn(conn)
 
@@ -1668,6 +1701,47 @@ func (server *Server) gmCommand(conn mnet.Client, msg string) {
 			info = "remaining time: " + time.Until(event.endTime).String()
 			conn.Send(packetMessageNotice(info))
 		}
+	case "raids":
+		conn.Send(packetMessageNotice("There are currently " + strconv.Itoa(len(server.raids)) + " raids"))
+
+		for id, r := range server.raids {
+			info := fmt.Sprintf("id: %d %s leader: %d players: %d/%d", id, r.script, r.leaderID, len(r.members), r.config.MaxPlayers)
+
+			if r.started {
+				info += fmt.Sprintf(" instance: %d cleared: %t remaining time: %s", r.inst.id, r.cleared, time.Until(r.endTime).Round(time.Second))
+			} else {
+				info += " signing up"
+			}
+
+			conn.Send(packetMessageNotice(info))
+		}
+	case "raidEnd":
+		if len(command) != 2 {
+			conn.Send(packetMessageRedText("Usage is <raid id>"))
+			return
+		}
+
+		id, err := strconv.Atoi(command[1])
+
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		r, ok := server.raids[int32(id)]
+
+		if !ok {
+			conn.Send(packetMessageRedText("Could not find raid: " + command[1]))
+			return
+		}
+
+		if r.started {
+			r.Fail("Ended by a GM")
+		} else {
+			r.disband("The " + r.config.Name + " raid signup list has been closed by a GM")
+		}
+
+		conn.Send(packetMessageNotice("Ended raid " + command[1]))
 	case "clearInstProps":
 		player, err := server.players.GetFromConn(conn)
 
@@ -1750,6 +1824,419 @@ func (server *Server) gmCommand(conn mnet.Client, msg string) {
 			conn.Send(packetMessageRedText(fmt.Sprintf("Banned %s for %d hours", targetName, hours)))
 		}
 
+	case "lieDetector":
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /lieDetector <player> [reporter]"))
+			return
+		}
+
+		target, err := server.players.GetFromName(command[1])
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		var reporterID int32
+		if len(command) > 2 {
+			reporter, err := server.players.GetFromName(command[2])
+			if err != nil {
+				conn.Send(packetMessageRedText(err.Error()))
+				return
+			}
+
+			reporterID = reporter.ID
+		}
+
+		if err := server.startLieDetector(target, reporterID); err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		conn.Send(packetMessageNotice("Lie detector test sent to " + target.Name))
+	case "reports":
+		limit := 10
+		if len(command) > 1 {
+			v, err := strconv.Atoi(command[1])
+			if err != nil || v < 1 {
+				conn.Send(packetMessageRedText("Command structure is /reports [count]"))
+				return
+			}
+			limit = v
+		}
+
+		reports, err := common.Repo.Reports.Unresolved(limit)
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		if len(reports) == 0 {
+			conn.Send(packetMessageNotice("No open reports"))
+			return
+		}
+
+		for _, rep := range reports {
+			conn.Send(packetMessageNotice(fmt.Sprintf("#%d (%s) %s reported %s for %s on map %d",
+				rep.ID, reportStatus(rep), rep.ReporterName, rep.TargetName, reportReason(rep.Reason), rep.MapID)))
+		}
+	case "claimReport":
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /claimReport <id>"))
+			return
+		}
+
+		id, err := strconv.Atoi(command[1])
+		if err != nil {
+			conn.Send(packetMessageRedText("Report id must be a number"))
+			return
+		}
+
+		gm, err := server.players.GetFromConn(conn)
+		if err != nil {
+			return
+		}
+
+		if err := common.Repo.Reports.Claim(int32(id), gm.Name); err != nil {
+			conn.Send(packetMessageRedText(reportError(int32(id), err)))
+			return
+		}
+
+		rep, err := common.Repo.Reports.ByID(int32(id))
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		sendReport(conn, rep)
+	case "resolveReport":
+		if len(command) < 3 {
+			conn.Send(packetMessageRedText("Command structure is /resolveReport <id> <dismiss | ban <hours | perm>> [note]"))
+			return
+		}
+
+		id, err := strconv.Atoi(command[1])
+		if err != nil {
+			conn.Send(packetMessageRedText("Report id must be a number"))
+			return
+		}
+
+		hours := -1
+		note := command[3:]
+
+		switch command[2] {
+		case "dismiss":
+		case "ban":
+			if len(command) < 4 {
+				conn.Send(packetMessageRedText("Command structure is /resolveReport <id> ban <hours | perm> [note]"))
+				return
+			}
+
+			if command[3] == "perm" {
+				hours = 0
+			} else if hours, err = strconv.Atoi(command[3]); err != nil || hours < 1 {
+				conn.Send(packetMessageRedText("Ban length must be a number of hours or perm"))
+				return
+			}
+
+			note = command[4:]
+		default:
+			conn.Send(packetMessageRedText("Command structure is /resolveReport <id> <dismiss | ban <hours | perm>> [note]"))
+			return
+		}
+
+		gm, err := server.players.GetFromConn(conn)
+		if err != nil {
+			return
+		}
+
+		if err := server.resolveReport(gm.Name, int32(id), hours, strings.Join(note, " ")); err != nil {
+			conn.Send(packetMessageRedText(reportError(int32(id), err)))
+			return
+		}
+
+		conn.Send(packetMessageNotice(fmt.Sprintf("Report #%d resolved", id)))
+	case "chatlog":
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /chatlog <player> [count]"))
+			return
+		}
+
+		limit := 20
+		if len(command) > 2 {
+			v, err := strconv.Atoi(command[2])
+			if err != nil || v < 1 || v > 100 {
+				conn.Send(packetMessageRedText("Count must be between 1 and 100"))
+				return
+			}
+			limit = v
+		}
+
+		lines, err := server.chatLog.Recent(command[1], limit)
+		if errors.Is(err, chatlog.ErrNoSearch) {
+			conn.Send(packetMessageRedText("Chat logging to the database is not enabled on this channel"))
+			return
+		} else if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		if len(lines) == 0 {
+			conn.Send(packetMessageNotice("No chat recorded for " + command[1]))
+			return
+		}
+
+		for i := len(lines) - 1; i >= 0; i-- {
+			conn.Send(packetMessageNotice(formatChatLine(lines[i])))
+		}
+	case "mute":
+		if len(command) < 3 {
+			conn.Send(packetMessageRedText("Command structure is /mute <player> <minutes | perm> [reason]"))
+			return
+		}
+
+		gm, err := server.players.GetFromConn(conn)
+		if err != nil || server.ac == nil {
+			return
+		}
+
+		var d time.Duration
+		if command[2] != "perm" {
+			minutes, err := strconv.Atoi(command[2])
+			if err != nil || minutes < 1 {
+				conn.Send(packetMessageRedText("Mute length must be a number of minutes or perm"))
+				return
+			}
+			d = time.Duration(minutes) * time.Minute
+		}
+
+		reason := "Muted by GM"
+		if len(command) > 3 {
+			reason = strings.Join(command[3:], " ")
+		}
+
+		if target, err := server.players.GetFromName(command[1]); err == nil {
+			err = server.mutePlayer(target, d, reason, gm.Name)
+		} else {
+			var mute repository.Mute
+			if mute, err = server.ac.MuteByName(command[1], d, reason, gm.Name); err == nil {
+				server.world.Send(internal.PacketChannelMuteChanged(mute.AccountID))
+			}
+		}
+
+		if errors.Is(err, anticheat.ErrPlayerNotFound) {
+			conn.Send(packetMessageRedText("Player not found"))
+			return
+		} else if err != nil {
+			log.Println("Failed to mute", command[1], ":", err)
+			conn.Send(packetMessageRedText("Failed to mute " + command[1]))
+			return
+		}
+
+		if d == 0 {
+			conn.Send(packetMessageNotice(fmt.Sprintf("Muted %s permanently", command[1])))
+		} else {
+			conn.Send(packetMessageNotice(fmt.Sprintf("Muted %s for %d minutes", command[1], int(d.Minutes()))))
+		}
+	case "unmute":
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /unmute <player>"))
+			return
+		}
+
+		gm, err := server.players.GetFromConn(conn)
+		if err != nil || server.ac == nil {
+			return
+		}
+
+		accountID, err := server.ac.Unmute(command[1], gm.Name)
+		if errors.Is(err, anticheat.ErrPlayerNotFound) {
+			conn.Send(packetMessageRedText("Player not found"))
+			return
+		} else if errors.Is(err, repository.ErrNotFound) {
+			conn.Send(packetMessageRedText(command[1] + " is not muted"))
+			return
+		} else if err != nil {
+			log.Println("Failed to unmute", command[1], ":", err)
+			conn.Send(packetMessageRedText("Failed to unmute " + command[1]))
+			return
+		}
+
+		server.refreshMute(accountID)
+		server.world.Send(internal.PacketChannelMuteChanged(accountID))
+		conn.Send(packetMessageNotice("Unmuted " + command[1]))
+	case "mutehistory":
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /mutehistory <player>"))
+			return
+		}
+
+		if server.ac == nil {
+			return
+		}
+
+		history, err := server.ac.GetMuteHistory(command[1], 10)
+		if errors.Is(err, anticheat.ErrPlayerNotFound) {
+			conn.Send(packetMessageRedText("Player not found"))
+			return
+		} else if err != nil {
+			log.Println("Failed to load mute history for", command[1], ":", err)
+			conn.Send(packetMessageRedText("Failed to load mute history"))
+			return
+		}
+
+		if len(history) == 0 {
+			conn.Send(packetMessageRedText("No mute history"))
+			return
+		}
+
+		for _, entry := range history {
+			conn.Send(packetMessageRedText(entry))
+		}
+	case "jail":
+		if len(command) < 3 {
+			conn.Send(packetMessageRedText("Command structure is /jail <player> <minutes> [reason]"))
+			return
+		}
+
+		gm, err := server.players.GetFromConn(conn)
+		if err != nil || server.ac == nil {
+			return
+		}
+
+		minutes, err := strconv.Atoi(command[2])
+		if err != nil || minutes < 1 {
+			conn.Send(packetMessageRedText("Jail length must be a number of minutes"))
+			return
+		}
+		d := time.Duration(minutes) * time.Minute
+
+		reason := "Jailed by GM"
+		if len(command) > 3 {
+			reason = strings.Join(command[3:], " ")
+		}
+
+		if target, err := server.players.GetFromName(command[1]); err == nil {
+			err = server.jailPlayer(target, d, reason, gm.Name)
+		} else {
+			var jail repository.Jail
+			if jail, err = server.ac.JailByName(command[1], d, reason, gm.Name); err == nil {
+				server.world.Send(internal.PacketChannelJailChanged(jail.AccountID))
+			}
+		}
+
+		if errors.Is(err, anticheat.ErrPlayerNotFound) {
+			conn.Send(packetMessageRedText("Player not found"))
+			return
+		} else if err != nil {
+			log.Println("Failed to jail", command[1], ":", err)
+			conn.Send(packetMessageRedText("Failed to jail " + command[1]))
+			return
+		}
+
+		conn.Send(packetMessageNotice(fmt.Sprintf("Jailed %s for %d minutes", command[1], minutes)))
+	case "unjail":
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /unjail <player>"))
+			return
+		}
+
+		gm, err := server.players.GetFromConn(conn)
+		if err != nil || server.ac == nil {
+			return
+		}
+
+		accountID, err := server.ac.Unjail(command[1], gm.Name)
+		if errors.Is(err, anticheat.ErrPlayerNotFound) {
+			conn.Send(packetMessageRedText("Player not found"))
+			return
+		} else if errors.Is(err, repository.ErrNotFound) {
+			conn.Send(packetMessageRedText(command[1] + " is not jailed"))
+			return
+		} else if err != nil {
+			log.Println("Failed to unjail", command[1], ":", err)
+			conn.Send(packetMessageRedText("Failed to unjail " + command[1]))
+			return
+		}
+
+		server.refreshJail(accountID)
+		server.world.Send(internal.PacketChannelJailChanged(accountID))
+		conn.Send(packetMessageNotice("Released " + command[1] + " from jail"))
+	case "violations":
+		if len(command) < 2 {
+			conn.Send(packetMessageRedText("Command structure is /violations <player> [count]"))
+			return
+		}
+
+		limit := 10
+		if len(command) > 2 {
+			v, err := strconv.Atoi(command[2])
+			if err != nil || v < 1 || v > 50 {
+				conn.Send(packetMessageRedText("Count must be between 1 and 50"))
+				return
+			}
+			limit = v
+		}
+
+		accountID, err := common.Repo.Characters.AccountIDByName(command[1])
+		if errors.Is(err, repository.ErrNotFound) {
+			conn.Send(packetMessageRedText("Player not found"))
+			return
+		} else if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		violations, err := common.Repo.Violations.ByAccount(accountID, limit)
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		if len(violations) == 0 {
+			conn.Send(packetMessageNotice("No violations recorded for " + command[1]))
+			return
+		}
+
+		for i := len(violations) - 1; i >= 0; i-- {
+			conn.Send(packetMessageNotice(formatViolation(violations[i])))
+		}
+	case "topOffenders":
+		hours := 24
+		if len(command) > 1 {
+			v, err := strconv.Atoi(command[1])
+			if err != nil || v < 1 {
+				conn.Send(packetMessageRedText("Command structure is /topOffenders [hours] [channel]"))
+				return
+			}
+			hours = v
+		}
+
+		channelID := server.id
+		if len(command) > 2 {
+			v, err := strconv.Atoi(command[2])
+			if err != nil || v < 1 || v > 255 {
+				conn.Send(packetMessageRedText("Command structure is /topOffenders [hours] [channel]"))
+				return
+			}
+			channelID = byte(v - 1)
+		}
+
+		offenders, err := common.Repo.Violations.TopOffenders(channelID, time.Now().Add(-time.Duration(hours)*time.Hour), 10)
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		if len(offenders) == 0 {
+			conn.Send(packetMessageNotice(fmt.Sprintf("No violations on channel %d in the last %d hours", channelID+1, hours)))
+			return
+		}
+
+		conn.Send(packetMessageNotice(fmt.Sprintf("Top offenders on channel %d in the last %d hours:", channelID+1, hours)))
+		for i, o := range offenders {
+			conn.Send(packetMessageNotice(fmt.Sprintf("%d. %s (account %d): %d violations, last at %s", i+1, o.CharacterName,
+				o.AccountID, o.Count, o.LastAt.Format("01-02 15:04:05"))))
+		}
 	case "unban":
 		if len(command) < 2 {
 			conn.Send(packetMessageRedText("/unban <player>"))
@@ -1786,6 +2273,32 @@ func (server *Server) gmCommand(conn mnet.Client, msg string) {
 			}
 		}
 
+	case "coupon":
+		player, err := server.players.GetFromConn(conn)
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		coupon, err := parseCouponCommand(command[1:], player.Name)
+		if err != nil {
+			conn.Send(packetMessageRedText(err.Error()))
+			return
+		}
+
+		if err := common.Repo.Coupons.Create(coupon); err != nil {
+			if errors.Is(err, repository.ErrDuplicate) {
+				conn.Send(packetMessageRedText("Coupon " + coupon.Code + " already exists"))
+			} else {
+				log.Println("Failed to create coupon", coupon.Code, ":", err)
+				conn.Send(packetMessageRedText("Failed to create coupon"))
+			}
+			return
+		}
+
+		conn.Send(packetMessageRedText(fmt.Sprintf("Created coupon %s (max uses: %d, rewards: %d items, %d nx, %d maple points, %d mesos)",
+			coupon.Code, coupon.MaxUses, len(coupon.Items), coupon.NX, coupon.MaplePoints, coupon.Mesos)))
+
 	default:
 		conn.Send(packetMessageRedText("Unknown gm command " + command[0]))
 	}
@@ -0,0 +1,116 @@
+package channel
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+const couponCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// generateCouponCode returns a random code without the easily confused characters 0, O, 1 and I
+func generateCouponCode() (string, error) {
+	b := make([]byte, constant.CashShopCouponCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = couponCodeAlphabet[int(b[i])%len(couponCodeAlphabet)]
+	}
+
+	return string(b), nil
+}
+
+// parseCouponCommand builds a coupon from the arguments of /coupon <code|auto> <maxUses> <days> <reward>...
+// where rewards are nx:<amount>, mp:<amount>, mesos:<amount> or item:<id>[x<amount>]
+func parseCouponCommand(args []string, createdBy string) (repository.Coupon, error) {
+	coupon := repository.Coupon{CreatedBy: createdBy}
+
+	if len(args) < 4 {
+		return coupon, fmt.Errorf("/coupon <code|auto> <maxUses> <days> <nx:amount|mp:amount|mesos:amount|item:id[xamount]>...")
+	}
+
+	if args[0] == "auto" {
+		code, err := generateCouponCode()
+		if err != nil {
+			return coupon, err
+		}
+		coupon.Code = code
+	} else {
+		coupon.Code = strings.ToUpper(args[0])
+	}
+
+	if len(coupon.Code) > constant.CashShopCouponCodeMaxLength {
+		return coupon, fmt.Errorf("coupon code must be at most %d characters", constant.CashShopCouponCodeMaxLength)
+	}
+
+	maxUses, err := strconv.Atoi(args[1])
+	if err != nil || maxUses < 0 {
+		return coupon, fmt.Errorf("invalid max uses %s, use 0 for unlimited", args[1])
+	}
+	coupon.MaxUses = int32(maxUses)
+
+	days, err := strconv.Atoi(args[2])
+	if err != nil || days < 0 {
+		return coupon, fmt.Errorf("invalid days %s, use 0 for no expiry", args[2])
+	}
+	if days > 0 {
+		coupon.ExpiresAt = time.Now().Add(time.Duration(days) * 24 * time.Hour).UnixMilli()
+	}
+
+	for _, reward := range args[3:] {
+		kind, value, ok := strings.Cut(reward, ":")
+		if !ok {
+			return coupon, fmt.Errorf("invalid reward %s", reward)
+		}
+
+		switch strings.ToLower(kind) {
+		case "nx", "mp", "mesos":
+			amount, err := strconv.ParseInt(value, 10, 32)
+			if err != nil || amount <= 0 {
+				return coupon, fmt.Errorf("invalid %s amount %s", kind, value)
+			}
+
+			switch strings.ToLower(kind) {
+			case "nx":
+				coupon.NX += int32(amount)
+			case "mp":
+				coupon.MaplePoints += int32(amount)
+			case "mesos":
+				coupon.Mesos += int32(amount)
+			}
+		case "item":
+			idText, amountText, hasAmount := strings.Cut(value, "x")
+
+			itemID, err := strconv.Atoi(idText)
+			if err != nil {
+				return coupon, fmt.Errorf("invalid item id %s", idText)
+			}
+
+			amount := int16(1)
+			if hasAmount {
+				v, err := strconv.ParseInt(amountText, 10, 16)
+				if err != nil || v <= 0 {
+					return coupon, fmt.Errorf("invalid item amount %s", amountText)
+				}
+				amount = int16(v)
+			}
+
+			if _, err := CreateItemFromID(int32(itemID), amount); err != nil {
+				return coupon, err
+			}
+
+			coupon.Items = append(coupon.Items, repository.CouponItem{ItemID: int32(itemID), Amount: amount})
+		default:
+			return coupon, fmt.Errorf("unknown reward type %s", kind)
+		}
+	}
+
+	return coupon, nil
+}
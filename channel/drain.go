@@ -0,0 +1,168 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/internal"
+	"github.com/Hucaru/Valhalla/mnet"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+const drainExitTimeout = 15 * time.Second
+
+// Drain prepares the channel for a restart. Migrations into the channel stop, players are warned with a countdown and
+// once it runs out everyone is saved and sent to a sibling channel through the change channel flow. Blocks until the
+// channel is empty or drainExitTimeout passes, players that are left are disconnected by the shutdown.
+func (server *Server) Drain(ctx context.Context, countdown time.Duration) {
+	if !server.runDrainStep(ctx, func() {
+		server.draining = true
+
+		if server.world != nil {
+			server.world.Send(internal.PacketChannelDraining(server.id))
+		}
+
+		server.players.broadcast(packetMessageNotice(fmt.Sprintf("This channel is restarting, you will be moved to another channel in %d seconds", int(countdown.Seconds()))))
+		server.SendCountdownToPlayers(int32(countdown.Seconds()))
+	}) {
+		return
+	}
+
+	log.Println("Draining channel, migrating players in", countdown)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	total := int(countdown.Seconds())
+	for secs := total; secs > 0; secs-- {
+		if secs != total && (secs == 60 || secs == 30 || secs == 10) {
+			msg := fmt.Sprintf("This channel is restarting in %d seconds", secs)
+			server.runDrainStep(ctx, func() {
+				server.players.broadcast(packetMessageNotice(msg))
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+
+	var moved, stranded int
+
+	server.runDrainStep(ctx, func() {
+		server.SendCountdownToPlayers(0)
+		server.players.Flush()
+
+		targets := server.drainTargets()
+		next := 0
+
+		server.players.observe(func(plr *Player) {
+			if len(targets) > 0 && server.changeChannel(plr, targets[next%len(targets)]) {
+				next++
+				moved++
+				return
+			}
+
+			stranded++
+			plr.Send(packetMessageNotice("No other channel is available, please log back in shortly"))
+		})
+	})
+
+	log.Printf("Drain moved %d players to other channels, %d have nowhere to go", moved, stranded)
+
+	exit := time.Now().Add(drainExitTimeout)
+
+	for time.Now().Before(exit) {
+		count := -1
+		server.runDrainStep(ctx, func() {
+			count = server.players.count()
+		})
+
+		if count <= stranded {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	log.Println("Drain timed out waiting for players to leave")
+}
+
+// drainTargets lists the sibling channels that are accepting players
+func (server *Server) drainTargets() []byte {
+	var targets []byte
+
+	for i, v := range server.channels {
+		if byte(i) != server.id && v.Port != 0 {
+			targets = append(targets, byte(i))
+		}
+	}
+
+	return targets
+}
+
+// refuseWhileDraining sends a character migrating in whilst the channel drains on to a sibling channel, without one to
+// go to the character is logged out and disconnected
+func (server *Server) refuseWhileDraining(conn mnet.Client, char repository.Character) {
+	if targets := server.drainTargets(); len(targets) > 0 {
+		id := targets[int(char.ID)%len(targets)]
+
+		err := common.Repo.Characters.SetMigration(char.ID, int32(id))
+		if err == nil {
+			conn.Send(packetChangeChannel(server.channels[id].IP, server.channels[id].Port))
+			return
+		}
+
+		log.Println(err)
+	}
+
+	if err := common.Repo.Characters.SetMigration(char.ID, -1); err != nil {
+		log.Println(err)
+	}
+
+	if err := common.Repo.Accounts.SetLoggedIn(char.AccountID, false); err != nil {
+		log.Println(err)
+	}
+
+	_ = conn.Close()
+}
+
+// runDrainStep runs fn on the dispatch loop and waits for it to finish
+func (server *Server) runDrainStep(ctx context.Context, fn func()) bool {
+	if server.dispatch == nil {
+		return false
+	}
+
+	done := make(chan struct{})
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(10 * time.Second):
+		log.Println("Drain: timed out scheduling work (dispatcher may be stopped)")
+		return false
+	case server.dispatch <- func() {
+		defer close(done)
+		fn()
+	}:
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(10 * time.Second):
+		log.Println("Drain: timed out waiting for dispatcher")
+		return false
+	case <-done:
+		return true
+	}
+}
@@ -0,0 +1,17 @@
+package channel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDrainTargets(t *testing.T) {
+	server := Server{id: 1}
+	server.channels[0].Port = 8685
+	server.channels[1].Port = 8686
+	server.channels[3].Port = 8688
+
+	if got, want := server.drainTargets(), []byte{0, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("drainTargets() = %v, want %v", got, want)
+	}
+}
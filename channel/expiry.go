@@ -0,0 +1,81 @@
+package channel
+
+import (
+	"time"
+
+	"github.com/Hucaru/Valhalla/constant"
+)
+
+// removeExpiredItems takes the time-limited items that have run out out of the player's inventories, it returns
+// their IDs and whether any of them were equipped
+func (d *Player) removeExpiredItems() ([]int32, bool) {
+	var expired []Item
+
+	for _, inv := range [][]Item{d.equip, d.use, d.setUp, d.etc, d.cash} {
+		for _, item := range inv {
+			if item.Expired() {
+				expired = append(expired, item)
+			}
+		}
+	}
+
+	ids := make([]int32, 0, len(expired))
+	equipped := false
+
+	for _, item := range expired {
+		d.removeItem(item, false)
+		ids = append(ids, item.ID)
+
+		if item.invID == constant.InventoryEquip && item.slotID < 0 {
+			equipped = true
+		}
+	}
+
+	return ids, equipped
+}
+
+// expireItems removes the player's time-limited items that have run out from their inventories and storage, telling
+// them about each one, and sends home any summoned pet whose life has ended
+func (server *Server) expireItems(plr *Player) {
+	ids, equipped := plr.removeExpiredItems()
+
+	if plr.storageInventory != nil {
+		ids = append(ids, plr.storageInventory.removeExpired(plr.accountID)...)
+	}
+
+	for _, id := range ids {
+		plr.Send(packetMessageItemExpired(id))
+	}
+
+	if equipped {
+		plr.RecalculateTotalStats()
+
+		if plr.inst != nil {
+			plr.inst.send(packetInventoryChangeEquip(*plr))
+		}
+	}
+
+	if plr.pet != nil && plr.pet.dead() {
+		plr.despawnPet(constant.PetRemoveExpire)
+	}
+}
+
+// notifyExpired tells a player who has just logged in about the items that ran out while they were away
+func (server *Server) notifyExpired(plr *Player) {
+	for _, id := range plr.expiredItems {
+		plr.Send(packetMessageItemExpired(id))
+	}
+
+	plr.expiredItems = nil
+}
+
+func scheduleItemExpiry(server *Server) {
+	ticker := time.NewTicker(constant.ItemExpiryCheckSecs * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		server.dispatch <- func() {
+			server.players.observe(server.expireItems)
+		}
+	}
+}
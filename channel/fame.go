@@ -1,7 +1,6 @@
 package channel
 
 import (
-	"database/sql"
 	"log"
 	"time"
 
@@ -12,28 +11,17 @@ import (
 )
 
 func fameHasRecentActivity(fromID int32, window time.Duration) bool {
-	var ts time.Time
-	row := common.DB.QueryRow(
-		"SELECT `time` FROM `fame_log` WHERE `from`=? AND `time` > (NOW() - INTERVAL ? SECOND) ORDER BY `time` DESC LIMIT 1",
-		fromID, int64(window.Seconds()),
-	)
-	switch err := row.Scan(&ts); err {
-	case nil:
-		return true
-	case sql.ErrNoRows:
-		return false
-	default:
+	given, err := common.Repo.Fame.GivenWithin(fromID, window)
+	if err != nil {
 		log.Println("fameHasRecentActivity:", err)
 		return true
 	}
+
+	return given
 }
 
 func fameInsertLog(fromID, toID int32) error {
-	_, err := common.DB.Exec(
-		"INSERT INTO fame_log (`from`, `to`, `time`) VALUES (?,?, NOW())",
-		fromID, toID,
-	)
-	return err
+	return common.Repo.Fame.Add(fromID, toID)
 }
 
 func packetFameError(code byte) mpacket.Packet {
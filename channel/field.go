@@ -576,9 +576,13 @@ type fieldInstance struct {
 	showBoat   bool
 	boatType   byte
 	properties map[string]interface{} // this is used to share state between npc and system scripts
+	raid       *raid                  // boss raid holding the instance, nil when there is none
 
 	bgm string
 
+	// Recent map chat, attached to character reports
+	chatLog []string
+
 	// Weather effect state
 	weatherID      int32
 	weatherMessage string
@@ -672,6 +676,12 @@ func (inst fieldInstance) findController() interface{} {
 func (inst *fieldInstance) addPlayer(plr *Player) error {
 	plr.inst = inst
 
+	// The owner replaces the avatar standing in for them at their hired merchant
+	merchant := inst.roomPool.merchantOf(plr.ID)
+	if merchant != nil {
+		inst.send(packetMapPlayerLeft(plr.ID))
+	}
+
 	for _, other := range inst.players {
 		other.Send(packetMapPlayerEnter(plr))
 		plr.Send(packetMapPlayerEnter(other))
@@ -694,6 +704,10 @@ func (inst *fieldInstance) addPlayer(plr *Player) error {
 
 	inst.players = append(inst.players, plr)
 
+	if merchant != nil {
+		inst.sendExcept(packetMapShowGameBox(merchant.displayBytes()), plr.Conn)
+	}
+
 	// For now pools run on all maps forever after first Player enters.
 	// If this hits perf too much then a set of params for each pool
 	// will need to be determined to allow it to stop updating e.g.
@@ -810,6 +824,11 @@ func (inst *fieldInstance) removePlayer(plr *Player, usedPortal bool) error {
 	inst.lifePool.removePlayer(plr, usedPortal)
 	inst.roomPool.removePlayer(plr)
 
+	if merchant := inst.roomPool.merchantOf(plr.ID); merchant != nil {
+		inst.send(packetMapMerchantAvatar(merchant.avatar))
+		inst.send(packetMapShowGameBox(merchant.displayBytes()))
+	}
+
 	return nil
 }
 
@@ -1099,16 +1118,16 @@ func packetMapPlayerEnter(plr *Player) mpacket.Packet {
 		p.WriteBool(false)
 	}
 
-	if plr.petCashID != 0 {
-		plr.pet.pos = plr.pos
+	if pt := plr.pet; pt != nil {
+		pt.pos = plr.pos
 		p.WriteBool(true)
-		p.WriteInt32(plr.pet.itemID)
-		p.WriteString(plr.pet.name)
-		p.WriteUint64(uint64(plr.pet.sn))
-		p.WriteInt16(plr.pet.pos.x)
-		p.WriteInt16(plr.pet.pos.y)
-		p.WriteByte(plr.pet.stance)
-		p.WriteInt16(plr.pet.pos.foothold)
+		p.WriteInt32(pt.itemID)
+		p.WriteString(pt.name)
+		p.WriteUint64(uint64(pt.sn))
+		p.WriteInt16(pt.pos.x)
+		p.WriteInt16(pt.pos.y)
+		p.WriteByte(pt.stance)
+		p.WriteInt16(pt.pos.foothold)
 	}
 	p.WriteInt32(0) // ?
 
@@ -8,6 +8,7 @@ import (
 	"github.com/Hucaru/Valhalla/common/opcode"
 	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/mpacket"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 type guild struct {
@@ -41,14 +42,12 @@ type guild struct {
 func loadGuildFromDb(guildID int32, players *Players) (*guild, error) {
 	loadedGuild := &guild{}
 
-	row, err := common.DB.Query("SELECT ID, guildRank, Name, job, level, channelID FROM characters WHERE guildID=?", guildID)
+	members, err := common.Repo.Guilds.Members(guildID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	defer row.Close()
-
 	loadedGuild.playerID = make([]int32, 0, constant.MaxGuildSize)
 	loadedGuild.names = make([]string, 0, constant.MaxGuildSize)
 	loadedGuild.jobs = make([]int32, 0, constant.MaxGuildSize)
@@ -56,38 +55,37 @@ func loadGuildFromDb(guildID int32, players *Players) (*guild, error) {
 	loadedGuild.online = make([]bool, 0, constant.MaxGuildSize)
 	loadedGuild.ranks = make([]byte, 0, constant.MaxGuildSize)
 
-	var channelID int32
-	var playerID int32
-	var rank byte
-	var name string
-	var job int32
-	var level int32
-
-	for row.Next() {
-		err = row.Scan(&playerID, &rank, &name, &job, &level, &channelID)
-
-		if err != nil {
-			log.Panicln(err)
-		}
-
-		loadedGuild.playerID = append(loadedGuild.playerID, playerID)
-		loadedGuild.names = append(loadedGuild.names, name)
-		loadedGuild.jobs = append(loadedGuild.jobs, job)
-		loadedGuild.levels = append(loadedGuild.levels, level)
-		loadedGuild.online = append(loadedGuild.online, channelID > -1)
-		loadedGuild.ranks = append(loadedGuild.ranks, rank)
+	for _, m := range members {
+		loadedGuild.playerID = append(loadedGuild.playerID, m.CharacterID)
+		loadedGuild.names = append(loadedGuild.names, m.Name)
+		loadedGuild.jobs = append(loadedGuild.jobs, m.Job)
+		loadedGuild.levels = append(loadedGuild.levels, m.Level)
+		loadedGuild.online = append(loadedGuild.online, m.Online)
+		loadedGuild.ranks = append(loadedGuild.ranks, m.Rank)
 	}
 
-	query := "SELECT ID,capacity,Name,notice,master,jrMaster,member1,member2,member3,logoBg,logoBgColour,logo,logoColour,points FROM guilds WHERE ID=?"
-	err = common.DB.QueryRow(query, guildID).Scan(&loadedGuild.id, &loadedGuild.capacity,
-		&loadedGuild.name, &loadedGuild.notice, &loadedGuild.master, &loadedGuild.jrMaster, &loadedGuild.member1,
-		&loadedGuild.member2, &loadedGuild.member3, &loadedGuild.logoBg, &loadedGuild.logoBgColour, &loadedGuild.logo,
-		&loadedGuild.logoColour, &loadedGuild.points)
+	row, err := common.Repo.Guilds.ByID(guildID)
 
 	if err != nil {
 		return nil, err
 	}
 
+	loadedGuild.id = row.ID
+	loadedGuild.worldID = row.WorldID
+	loadedGuild.capacity = row.Capacity
+	loadedGuild.name = row.Name
+	loadedGuild.notice = row.Notice
+	loadedGuild.master = row.Master
+	loadedGuild.jrMaster = row.JrMaster
+	loadedGuild.member1 = row.Member1
+	loadedGuild.member2 = row.Member2
+	loadedGuild.member3 = row.Member3
+	loadedGuild.logoBg = row.LogoBg
+	loadedGuild.logoBgColour = row.LogoBgColour
+	loadedGuild.logo = row.Logo
+	loadedGuild.logoColour = row.LogoColour
+	loadedGuild.points = row.Points
+
 	loadedGuild.players = players
 
 	return loadedGuild, nil
@@ -158,25 +156,25 @@ func (g *guild) signContract(playerID int32) bool {
 	}
 
 	if signed == len(g.online) {
-		query := "INSERT INTO guilds (Name, worldID, notice, master, jrMaster, member1, member2, member3) VALUES (?, ?, '', ?, ?, ?, ?, ?)"
-		res, err := common.DB.Exec(query, g.name, g.worldID, g.master, g.jrMaster, g.member1, g.member2, g.member3)
-
-		if err != nil {
-			return false
-		}
-
-		guildID, err := res.LastInsertId()
+		guildID, err := common.Repo.Guilds.Create(repository.Guild{
+			Name:     g.name,
+			WorldID:  g.worldID,
+			Master:   g.master,
+			JrMaster: g.jrMaster,
+			Member1:  g.member1,
+			Member2:  g.member2,
+			Member3:  g.member3,
+		})
 
 		if err != nil {
 			return false
 		}
 
-		g.id = int32(guildID)
+		g.id = guildID
 
 		for i, id := range g.playerID {
 			// add each member to guild
-			query = "UPDATE characters set guildID=?, guildRank=? WHERE ID=?"
-			_, err := common.DB.Exec(query, g.id, g.ranks[i], id)
+			err := common.Repo.Guilds.SetMember(id, g.id, g.ranks[i])
 
 			if err != nil {
 				continue
@@ -293,7 +291,7 @@ func (g *guild) addPlayer(playerID int32, name string, jobID, level int32, onlin
 		return
 	}
 
-	_, err = common.DB.Exec("UPDATE characters SET guildID=?, guildRank=? WHERE ID=?", g.id, rank, playerID)
+	err = common.Repo.Guilds.SetMember(playerID, g.id, rank)
 
 	if err != nil {
 		log.Fatal()
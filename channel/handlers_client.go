@@ -1,7 +1,6 @@
 package channel
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
 	"math"
@@ -12,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Hucaru/Valhalla/chatlog"
 	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/common/opcode"
 	"github.com/Hucaru/Valhalla/constant"
@@ -20,6 +20,7 @@ import (
 	"github.com/Hucaru/Valhalla/mnet"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -134,6 +135,9 @@ func (server *Server) HandleClientPacket(conn mnet.Client, reader mpacket.Reader
 	case opcode.RecvChannelCharacterInfo:
 		server.playerRequestAvatarInfoWindow(conn, reader)
 	case opcode.RecvChannelLieDetectorResult:
+		server.playerLieDetectorResult(conn, reader)
+	case opcode.RecvChannelCharacterReport:
+		server.playerCharacterReport(conn, reader)
 	case opcode.RecvChannelPartyInfo:
 		server.playerPartyInfo(conn, reader)
 	case opcode.RecvChannelGuildManagement:
@@ -193,46 +197,42 @@ func (server *Server) HandleClientPacket(conn mnet.Client, reader mpacket.Reader
 func (server *Server) playerConnect(conn mnet.Client, reader mpacket.Reader) {
 	charID := reader.ReadInt32()
 
-	// Fetch channelID, migrationID and accountID in a single query
-	var (
-		migrationID byte
-		channelID   int8
-		accountID   int32
-	)
-	err := common.DB.QueryRow(
-		"SELECT channelID, migrationID, accountID FROM characters WHERE ID=?",
-		charID,
-	).Scan(&channelID, &migrationID, &accountID)
+	char, err := common.Repo.Characters.ByID(charID)
 	if err != nil {
 		log.Println("playerConnect query error:", err)
 		return
 	}
 
-	if migrationID != server.id {
+	if char.MigrationID != int32(server.id) {
 		// Not for this server; silently ignore to avoid leaking info
 		return
 	}
 
-	conn.SetAccountID(accountID)
+	if server.draining {
+		log.Println("Refusing character", charID, "whilst draining")
+		server.refuseWhileDraining(conn, char)
+		return
+	}
+
+	conn.SetAccountID(char.AccountID)
 
-	var adminLevel int
-	err = common.DB.QueryRow("SELECT adminLevel FROM accounts WHERE accountID=?", conn.GetAccountID()).Scan(&adminLevel)
+	account, err := common.Repo.Accounts.ByID(conn.GetAccountID())
 
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	conn.SetAdminLevel(adminLevel)
+	conn.SetAdminLevel(account.AdminLevel)
 
-	_, err = common.DB.Exec("UPDATE characters SET migrationID=? WHERE ID=?", -1, charID)
+	err = common.Repo.Characters.SetMigration(charID, -1)
 
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	_, err = common.DB.Exec("UPDATE characters SET channelID=? WHERE ID=?", server.id, charID)
+	err = common.Repo.Characters.SetChannel(charID, int32(server.id))
 
 	if err != nil {
 		log.Println(err)
@@ -241,6 +241,10 @@ func (server *Server) playerConnect(conn mnet.Client, reader mpacket.Reader) {
 
 	plr := LoadPlayerFromID(charID, conn)
 	plr.rates = &server.rates
+	plr.petPotions = &server.petPotions
+	server.loadMute(&plr)
+	server.loadJail(&plr)
+	server.placeInJail(&plr)
 
 	server.players.Add(&plr)
 
@@ -278,38 +282,26 @@ func (server *Server) playerConnect(conn mnet.Client, reader mpacket.Reader) {
 		server.world.Send(internal.PacketChannelPartyUpdateInfo(partyID, playerID, job, level, mapID, name))
 	}
 
-	var guildID sql.NullInt32
-	err = common.DB.QueryRow("SELECT guildID FROM characters WHERE ID=?", newPlr.ID).Scan(&guildID)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if guildID.Valid {
-		if guild, ok := server.guilds[guildID.Int32]; !ok {
-			guild, err = loadGuildFromDb(guildID.Int32, &server.players)
+	if guildID := char.GuildID; guildID != 0 {
+		if guild, ok := server.guilds[guildID]; !ok {
+			guild, err = loadGuildFromDb(guildID, &server.players)
 
 			if err == nil {
-				server.guilds[guildID.Int32] = guild
+				server.guilds[guildID] = guild
 				newPlr.guild = guild
 			}
 		} else {
-			newPlr.guild = server.guilds[guildID.Int32]
+			newPlr.guild = server.guilds[guildID]
 		}
 	} else {
-		var guildID int32
-		var inviter string
-		row, err := common.DB.Query("SELECT guildID, inviter FROM guild_invites WHERE playerID=?", newPlr.ID)
+		invites, err := common.Repo.Guilds.Invites(newPlr.ID)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		defer row.Close()
-
-		for row.Next() { // We should only ever have 1 row
-			row.Scan(&guildID, &inviter)
-			newPlr.Send(packetGuildInviteCard(guildID, inviter))
+		for _, invite := range invites { // We should only ever have 1 invite
+			newPlr.Send(packetGuildInviteCard(invite.GuildID, invite.Inviter))
 		}
 	}
 
@@ -344,44 +336,66 @@ func (server *Server) playerConnect(conn mnet.Client, reader mpacket.Reader) {
 		}
 	}
 
+	server.notifyExpired(newPlr)
+	server.resumeLieDetector(newPlr)
+	server.claimLieDetectorReward(newPlr)
+
 	common.MetricsGauges["player_count"].With(prometheus.Labels{"channel": strconv.Itoa(int(server.id)), "world": server.worldName}).Inc()
 
 	server.world.Send(internal.PacketChannelPopUpdate(server.id, int16(server.players.count())))
 
-	if guildID.Valid {
-		server.world.Send(internal.PacketChannelPlayerConnected(plr.ID, plr.Name, server.id, channelID > -1, newPlr.mapID, guildID.Int32))
-	} else {
-		server.world.Send(internal.PacketChannelPlayerConnected(plr.ID, plr.Name, server.id, channelID > -1, newPlr.mapID, 0))
-	}
+	server.world.Send(internal.PacketChannelPlayerConnected(plr.ID, plr.Name, server.id, char.ChannelID > -1, newPlr.mapID, char.GuildID))
 }
 
 func (server *Server) playerChangeChannel(conn mnet.Client, reader mpacket.Reader) {
 	id := reader.ReadByte()
 
-	server.migrating = append(server.migrating, conn)
 	player, err := server.players.GetFromConn(conn)
 	if err != nil {
 		log.Println("Unable to get Player from connection", conn)
 		return
 	}
 
+	if int(id) < len(server.channels) && server.channels[id].Port == 0 {
+		conn.Send(packetCannotChangeChannel())
+		return
+	}
+
+	if server.jailed(player) {
+		player.Send(packetMessageRedText(jailMessage("You are jailed", *player.jail)))
+		conn.Send(packetCannotChangeChannel())
+		return
+	}
+
+	if server.refuseLieDetector(player) {
+		conn.Send(packetCannotChangeChannel())
+		return
+	}
+
+	server.changeChannel(player, id)
+}
+
+// changeChannel sends the player to another channel, the caller checks the channel is up
+func (server *Server) changeChannel(player *Player, id byte) bool {
+	if int(id) >= len(server.channels) || server.channels[id].Port == 0 {
+		return false
+	}
+
+	if err := common.Repo.Characters.SetMigration(player.ID, int32(id)); err != nil {
+		log.Println(err)
+		return false
+	}
+
+	server.migrating = append(server.migrating, player.Conn)
+
 	// Expire Summon Buffs
 	player.expireSummons()
 	server.removeSummonsFromField(player)
 	player.saveBuffSnapshot()
 
-	if int(id) < len(server.channels) {
-		if server.channels[id].Port == 0 {
-			conn.Send(packetCannotChangeChannel())
-		} else {
-			if _, err := common.DB.Exec("UPDATE characters SET migrationID=? WHERE ID=?", id, player.ID); err != nil {
-				log.Println(err)
-				return
-			}
+	player.Send(packetChangeChannel(server.channels[id].IP, server.channels[id].Port))
 
-			conn.Send(packetChangeChannel(server.channels[id].IP, server.channels[id].Port))
-		}
-	}
+	return true
 }
 
 func (server Server) playerMovement(conn mnet.Client, reader mpacket.Reader) {
@@ -872,24 +886,30 @@ func validateSkillWithJob(jobID int16, baseSkillID int32) bool {
 }
 
 func (server *Server) playerEnterCashShop(conn mnet.Client, reader mpacket.Reader) {
-	server.migrating = append(server.migrating, conn)
 	player, err := server.players.GetFromConn(conn)
 	if err != nil {
 		log.Println("Unable to get Player from connection", conn)
 		return
 	}
 
-	// Expire Summon Buffs
-	player.expireSummons()
-	server.removeSummonsFromField(player)
-	player.saveBuffSnapshot()
+	if server.refuseLieDetector(player) {
+		conn.Send(packetCannotEnterCashShop())
+		return
+	}
 
 	if len(server.cashShop.IP) > 0 || server.cashShop.Port == 0 {
-		if _, err := common.DB.Exec("UPDATE characters SET migrationID=?, previousChannelID=?, inCashShop=1 WHERE ID=?", 50, server.id, player.ID); err != nil {
+		if err := common.Repo.Characters.EnterCashShop(player.ID, 50, int32(server.id)); err != nil {
 			log.Println(err)
 			return
 		}
 
+		server.migrating = append(server.migrating, conn)
+
+		// Expire Summon Buffs
+		player.expireSummons()
+		server.removeSummonsFromField(player)
+		player.saveBuffSnapshot()
+
 		conn.Send(packetChangeChannel(server.cashShop.IP, server.cashShop.Port))
 	} else {
 		conn.Send(packetCannotEnterCashShop())
@@ -970,9 +990,14 @@ func (server *Server) playerUsePortal(conn mnet.Client, reader mpacket.Reader) {
 
 	switch entryType {
 	case constant.PortalDeath:
-		// Death revive to return map
+		// Death revive to return map, or back in jail
 		if plr.hp == 0 {
-			dstFld, ok := server.fields[curField.Data.ReturnMap]
+			returnMap := curField.Data.ReturnMap
+			if server.jailed(plr) {
+				returnMap = server.jailMap
+			}
+
+			dstFld, ok := server.fields[returnMap]
 			if !ok || dstFld == nil {
 				return
 			}
@@ -999,6 +1024,10 @@ func (server *Server) playerUsePortal(conn mnet.Client, reader mpacket.Reader) {
 			return
 		}
 	case constant.PortalNormal:
+		if server.refuseJailed(plr) {
+			return
+		}
+
 		nameLen := reader.ReadInt16()
 		if nameLen <= 0 {
 			conn.Send(packetPlayerNoChange())
@@ -1074,6 +1103,10 @@ func (server Server) playerUseScriptedPortal(conn mnet.Client, reader mpacket.Re
 		return
 	}
 
+	if server.refuseJailed(plr) {
+		return
+	}
+
 	nameLen := reader.ReadInt16()
 
 	if nameLen <= 0 {
@@ -1236,6 +1269,16 @@ func (server Server) playerTeleportRockOperation(conn mnet.Client, reader mpacke
 }
 
 func (server Server) warpPlayer(plr *Player, dstField *field, dstPortal portal, usedPortal bool) error {
+	return server.warpPlayerToInstance(plr, dstField, plr.inst.id, dstPortal, usedPortal)
+}
+
+// warpPlayerToInstance moves the player to an instance of the field, the first instance is used instead when it does
+// not exist or is held by a boss raid the player is not taking part in
+func (server Server) warpPlayerToInstance(plr *Player, dstField *field, instID int, dstPortal portal, usedPortal bool) error {
+	if server.jailed(plr) && dstField.id != server.jailMap {
+		return errJailed
+	}
+
 	srcField, ok := server.fields[plr.mapID]
 	if !ok {
 		return fmt.Errorf("Error in map ID %d", plr.mapID)
@@ -1246,8 +1289,8 @@ func (server Server) warpPlayer(plr *Player, dstField *field, dstPortal portal,
 		return err
 	}
 
-	dstInst, err := dstField.getInstance(plr.inst.id)
-	if err != nil {
+	dstInst, err := dstField.getInstance(instID)
+	if err != nil || (dstInst.raid != nil && dstInst.raid != plr.raid) {
 		if dstInst, err = dstField.getInstance(0); err != nil {
 			return err
 		}
@@ -1280,6 +1323,10 @@ func (server Server) warpPlayer(plr *Player, dstField *field, dstPortal portal,
 		)
 	}
 
+	if plr.raid != nil && plr.raid.started && dstInst.raid != plr.raid {
+		plr.raid.removePlayer(plr)
+	}
+
 	if keptSummon != nil && plr.shouldKeepSummonOnTransfer(keptSummon) {
 		snapped := dstInst.fhHist.getFinalPosition(newPos(plr.pos.x, plr.pos.y, 0))
 		keptSummon.Pos = snapped
@@ -1298,10 +1345,10 @@ func (server Server) warpPlayer(plr *Player, dstField *field, dstPortal portal,
 		}
 	}
 
-	if plr.petCashID != 0 && plr.pet.spawned {
-		plr.pet.pos = plr.pos
-		plr.pet.pos.y -= 15
-		dstInst.send(packetPetSpawn(plr.ID, plr.pet))
+	if pt := plr.pet; pt != nil {
+		pt.pos = plr.pos
+		pt.pos.y -= 15
+		dstInst.send(packetPetSpawn(plr.ID, pt))
 	}
 
 	return nil
@@ -1371,6 +1418,11 @@ func (server Server) playerUseInventoryItem(conn mnet.Client, reader mpacket.Rea
 	slot := reader.ReadInt16()
 	itemid := reader.ReadInt32()
 
+	if itemid/10000 == constant.PetFoodItemType {
+		server.feedPet(plr, slot, itemid)
+		return
+	}
+
 	item, err := plr.takeItem(itemid, slot, 1, 2)
 	if err != nil {
 		log.Println(err)
@@ -1405,6 +1457,10 @@ func (server *Server) playerUseReturnScroll(conn mnet.Client, reader mpacket.Rea
 		return
 	}
 
+	if server.refuseJailed(plr) {
+		return
+	}
+
 	meta, err := nx.GetItem(itemID)
 	if err != nil {
 		// Missing NX data
@@ -1651,6 +1707,10 @@ func (server *Server) playerUseCash(conn mnet.Client, reader mpacket.Reader) {
 		}
 
 	case constant.ItemVIPTeleportRock, constant.ItemRegTeleportRock:
+		if server.refuseJailed(plr) {
+			return
+		}
+
 		mode := reader.ReadByte()
 
 		if mode == constant.TeleportToName {
@@ -1720,7 +1780,12 @@ func (server *Server) playerUseCash(conn mnet.Client, reader mpacket.Reader) {
 	case constant.ItemPetNameTag:
 		newName := reader.ReadString(reader.ReadInt16())
 
-		if plr.pet != nil && plr.pet.spawned {
+		if !server.allowName(plr, newName) {
+			plr.Send(packetPlayerNoChange())
+			return
+		}
+
+		if plr.pet != nil {
 			plr.pet.name = newName
 
 			plr.MarkDirty(DirtyPet, time.Millisecond*300)
@@ -1733,22 +1798,65 @@ func (server *Server) playerUseCash(conn mnet.Client, reader mpacket.Reader) {
 		}
 
 	case constant.ItemWaterOfLife:
-		log.Printf("Water of Life not fully implemented")
+		used = server.revivePet(plr, reader.ReadInt64())
+
+	case constant.ItemMegaphone, constant.ItemSuperMegaphone, constant.ItemHeartSMegaphone, constant.ItemSkullSMegaphone,
+		constant.ItemItemMegaphone:
+		if wait := time.Until(plr.lastMegaphone.Add(constant.MegaphoneCooldownSecs * time.Second)); wait > 0 {
+			plr.Send(packetMessageRedText(fmt.Sprintf("You can use a megaphone again in %d seconds", int(wait.Seconds())+1)))
+			plr.Send(packetPlayerNoChange())
+			return
+		}
 
-	case constant.ItemMegaphone, constant.ItemSuperMegaphone:
 		msg := reader.ReadString(reader.ReadInt16())
 
+		if !server.allowChat(plr, msg) {
+			plr.Send(packetPlayerNoChange())
+			return
+		}
+
 		if itemID == constant.ItemMegaphone {
 			server.players.broadcast(packetMessageBroadcastChannel(plr.Name, msg, server.id, false))
 		} else {
 			whisper := reader.ReadBool()
-			server.world.Send(internal.PacketChatMegaphone(plr.Name, msg, whisper))
+			mode := constant.BroadcastSuperMegaphone
+			var item []byte
+
+			switch itemID {
+			case constant.ItemHeartSMegaphone:
+				mode = constant.BroadcastHeartMegaphone
+			case constant.ItemSkullSMegaphone:
+				mode = constant.BroadcastSkullMegaphone
+			case constant.ItemItemMegaphone:
+				mode = constant.BroadcastItemMegaphone
+
+				if reader.ReadBool() {
+					invID := byte(reader.ReadInt32())
+					shown, err := plr.getItem(invID, int16(reader.ReadInt32()))
+					if err != nil {
+						plr.Send(packetPlayerNoChange())
+						return
+					}
+
+					item = shown.StorageBytes()
+				}
+			}
+
+			server.world.Send(internal.PacketChatMegaphone(mode, plr.Name, msg, server.id, whisper, item))
 		}
+
+		server.logChat(plr, chatlog.KindMegaphone, "", msg)
+		plr.lastMegaphone = time.Now()
 		used = true
 	case constant.ItemWeatherCandy, constant.ItemWeatherFlower, constant.ItemWeatherFireworks, constant.ItemWeatherSoap, constant.ItemWeatherSnow, constant.ItemWeatherSnowFlakes,
 		constant.ItemWeatherPresents, constant.ItemWeatherLeaves, constant.ItemWeatherChocolate, constant.ItemWeatherFlowers:
 		msg := reader.ReadString(reader.ReadInt16())
 
+		if !server.allowChat(plr, msg) {
+			plr.Send(packetPlayerNoChange())
+			return
+		}
+
 		if plr.inst != nil {
 			if plr.inst.startWeatherEffect(itemID, msg) {
 				used = true
@@ -1795,9 +1903,7 @@ func (server Server) playerPickupItem(conn mnet.Client, reader mpacket.Reader) {
 		return
 	}
 
-	if plr.pos.x-pos.x > 800 || plr.pos.y-pos.y > 600 {
-		// Hax
-		log.Printf("Player: %s tried to pickup an Item from far away", plr.Name)
+	if !server.pickupAllowed(plr, pos, drop) {
 		plr.Send(packetDropNotAvailable())
 		plr.Send(packetInventoryDontTake())
 		return
@@ -1888,11 +1994,9 @@ func (server *Server) playerBuddyOperation(conn mnet.Client, reader mpacket.Read
 
 		name := reader.ReadString(reader.ReadInt16())
 
-		var charID int32
-		var accountID int32
-		var buddyListSize int32
-
-		err = common.DB.QueryRow("SELECT ID,accountID,buddyListSize FROM characters WHERE BINARY Name=? and worldID=?", name, conn.GetWorldID()).Scan(&charID, &accountID, &buddyListSize)
+		recepientChar, err := common.Repo.Characters.ByName(name, conn.GetWorldID())
+		charID := recepientChar.ID
+		accountID := recepientChar.AccountID
 
 		if err != nil || accountID == conn.GetAccountID() {
 			conn.Send(packetBuddyNameNotRegistered())
@@ -1904,37 +2008,33 @@ func (server *Server) playerBuddyOperation(conn mnet.Client, reader mpacket.Read
 			return
 		}
 
-		var recepientBuddyCount int32
-		err = common.DB.QueryRow("SELECT COUNT(*) FROM buddy WHERE characterID=1 and accepted=1").Scan(&recepientBuddyCount)
+		recepientBuddyCount, err := common.Repo.Buddies.CountAccepted(charID)
 
 		if err != nil {
 			log.Fatal(err)
 			return
 		}
 
-		if recepientBuddyCount >= buddyListSize {
+		if recepientBuddyCount >= int(recepientChar.BuddyListSize) {
 			conn.Send(packetBuddyOtherFullList())
 			return
 		}
 
 		if conn.GetAdminLevel() == 0 {
-			var gm bool
-			err = common.DB.QueryRow("SELECT adminLevel from accounts where accountID=?", accountID).Scan(&gm)
+			account, err := common.Repo.Accounts.ByID(accountID)
 
 			if err != nil {
 				log.Fatal(err)
 				return
 			}
 
-			if gm {
+			if account.AdminLevel > 0 {
 				conn.Send(packetBuddyIsGM())
 				return
 			}
 		}
 
-		query := "INSERT INTO buddy(characterID,friendID) VALUES(?,?)"
-
-		if _, err = common.DB.Exec(query, charID, plr.ID); err != nil {
+		if err = common.Repo.Buddies.Request(charID, plr.ID); err != nil {
 			log.Fatal(err)
 			return
 		}
@@ -1953,27 +2053,17 @@ func (server *Server) playerBuddyOperation(conn mnet.Client, reader mpacket.Read
 
 		friendID := reader.ReadInt32()
 
-		var friendName string
-		var friendChannel int32
-		var cashShop bool
-
-		err = common.DB.QueryRow("SELECT Name,channelID,inCashShop FROM characters WHERE ID=?", friendID).Scan(&friendName, &friendChannel, &cashShop)
+		friend, err := common.Repo.Characters.ByID(friendID)
 
 		if err != nil {
 			log.Fatal(err)
 			return
 		}
 
-		query := "UPDATE buddy set accepted=1 WHERE characterID=? and friendID=?"
-
-		if _, err := common.DB.Exec(query, plr.ID, friendID); err != nil {
-			log.Fatal(err)
-			return
-		}
-
-		query = "INSERT INTO buddy(characterID,friendID,accepted) VALUES(?,?,?)"
+		friendName := friend.Name
+		friendChannel := friend.ChannelID
 
-		if _, err := common.DB.Exec(query, friendID, plr.ID, 1); err != nil {
+		if err := common.Repo.Buddies.Accept(plr.ID, friendID); err != nil {
 			log.Fatal(err)
 			return
 		}
@@ -2001,9 +2091,7 @@ func (server *Server) playerBuddyOperation(conn mnet.Client, reader mpacket.Read
 
 		id := reader.ReadInt32()
 
-		query := "DELETE FROM buddy WHERE (characterID=? AND friendID=?) OR (characterID=? AND friendID=?)"
-
-		if _, err = common.DB.Exec(query, id, plr.ID, plr.ID, id); err != nil {
+		if err = common.Repo.Buddies.Delete(plr.ID, id); err != nil {
 			log.Fatal(err)
 			return
 		}
@@ -2130,16 +2218,24 @@ func (server Server) chatGroup(conn mnet.Client, reader mpacket.Reader) {
 	}
 
 	op := reader.ReadByte()
+	msg := groupChatMessage(reader)
+
+	if !server.allowChat(plr, msg) {
+		return
+	}
 
 	switch op {
 	case 0: // buddy
 		buffer := reader.GetRestAsBytes()
+		server.logChat(plr, chatlog.KindBuddy, "", msg)
 		server.world.Send(internal.PacketChannelPlayerChat(internal.OpChatBuddy, plr.Name, buffer))
 	case 1: // party
 		buffer := reader.GetRestAsBytes()
+		server.logChat(plr, chatlog.KindParty, "", msg)
 		server.world.Send(internal.PacketChannelPlayerChat(internal.OpChatParty, plr.Name, buffer))
 	case 2: // guild
 		buffer := reader.GetRestAsBytes()
+		server.logChat(plr, chatlog.KindGuild, "", msg)
 		server.world.Send(internal.PacketChannelPlayerChat(internal.OpChatGuild, plr.Name, buffer))
 	default:
 		log.Println("Unknown group chat type:", op, reader)
@@ -2158,41 +2254,37 @@ func (server Server) chatSlashCommand(conn mnet.Client, reader mpacket.Reader) {
 		}
 		name := reader.ReadString(reader.ReadInt16())
 
-		var accountID int32
-		var channelID int8
-		var mapID int32 = -1
-		var inCashShop bool
+		target, err := common.Repo.Characters.ByName(name, conn.GetWorldID())
 
-		err = common.DB.QueryRow("SELECT accountID,channelID,mapID,inCashShop FROM characters WHERE BINARY Name=? AND worldID=?", name, conn.GetWorldID()).Scan(&accountID, &channelID, &mapID, &inCashShop)
-
-		if err != nil || channelID == -1 {
+		if err != nil || target.ChannelID == -1 {
 			plr.Send(packetMessageFindResult(name, false, false, false, -1))
 			return
 		}
 
-		var isGM bool
-
-		err = common.DB.QueryRow("SELECT adminLevel from accounts where accountID=?", accountID).Scan(&isGM)
+		account, err := common.Repo.Accounts.ByID(target.AccountID)
 
 		if err != nil {
 			log.Fatal(err)
 			return
 		}
 
-		if isGM {
-			plr.Send(packetMessageFindResult(name, false, inCashShop, false, mapID))
+		if account.AdminLevel > 0 {
+			plr.Send(packetMessageFindResult(name, false, target.InCashShop, false, target.MapID))
 		} else {
-			plr.Send(packetMessageFindResult(name, true, inCashShop, byte(channelID) == server.id, mapID))
+			plr.Send(packetMessageFindResult(name, true, target.InCashShop, byte(target.ChannelID) == server.id, target.MapID))
 		}
 	case 6: // whispher
 		recepientName := reader.ReadString(reader.ReadInt16())
 		msg := reader.ReadString(reader.ReadInt16())
 
+		if plr, err := server.players.GetFromConn(conn); err != nil || !server.allowChat(plr, msg) {
+			return
+		}
+
 		if receiver, err := server.players.GetFromName(recepientName); err != nil {
-			var online bool
-			err := common.DB.QueryRow("SELECT COUNT(*) FROM characters WHERE BINARY Name=? AND worldID=? AND channelID != -1", recepientName, conn.GetWorldID()).Scan(&online)
+			recipient, err := common.Repo.Characters.ByName(recepientName, conn.GetWorldID())
 
-			if err != nil || !online {
+			if err != nil || recipient.ChannelID == -1 {
 				conn.Send(packetMessageRedText("Incorrect character Name"))
 				return
 			}
@@ -2203,6 +2295,7 @@ func (server Server) chatSlashCommand(conn mnet.Client, reader mpacket.Reader) {
 				return
 			}
 
+			server.logChat(plr, chatlog.KindWhisper, recepientName, msg)
 			plr.Send(packetMessageWhisper(plr.Name, msg, server.id))
 			server.world.Send(internal.PacketChannelWhispherChat(recepientName, plr.Name, msg, server.id))
 		} else {
@@ -2212,6 +2305,7 @@ func (server Server) chatSlashCommand(conn mnet.Client, reader mpacket.Reader) {
 				return
 			}
 
+			server.logChat(plr, chatlog.KindWhisper, receiver.Name, msg)
 			plr.Send(packetMessageWhisper(plr.Name, msg, server.id))
 			receiver.Send(packetMessageWhisper(plr.Name, msg, server.id))
 		}
@@ -2224,6 +2318,10 @@ func (server *Server) chatSendAll(conn mnet.Client, reader mpacket.Reader) {
 	msg := reader.ReadString(reader.ReadInt16())
 
 	if strings.Index(msg, "/") == 0 && conn.GetAdminLevel() > 0 {
+		if gm, err := server.players.GetFromConn(conn); err == nil {
+			server.logChat(gm, chatlog.KindCommand, "", msg)
+		}
+
 		server.gmCommand(conn, msg)
 	} else {
 		player, err := server.players.GetFromConn(conn)
@@ -2238,6 +2336,12 @@ func (server *Server) chatSendAll(conn mnet.Client, reader mpacket.Reader) {
 			return
 		}
 
+		if !server.allowChat(player, msg) {
+			return
+		}
+
+		server.logChat(player, chatlog.KindMap, "", msg)
+		inst.recordChat(player.Name, msg)
 		inst.send(packetMessageAllChat(player.ID, conn.GetAdminLevel() > 0, msg))
 	}
 }
@@ -2483,7 +2587,6 @@ func (server Server) playerMeleeSkill(conn mnet.Client, reader mpacket.Reader) {
 	}
 
 	if server.ac != nil && server.ac.LogAttackSpeedViolation(plr.accountID) {
-		server.ac.IssueBan(plr.accountID, 24, "Attack speed hack detected", "", "")
 		return
 	}
 
@@ -2564,7 +2667,6 @@ func (server Server) playerRangedSkill(conn mnet.Client, reader mpacket.Reader)
 	}
 
 	if server.ac != nil && server.ac.LogAttackSpeedViolation(plr.accountID) {
-		server.ac.IssueBan(plr.accountID, 24, "Attack speed hack detected", "", "")
 		return
 	}
 
@@ -3305,6 +3407,10 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 
 			boardType := reader.ReadByte()
 
+			if !server.allowName(plr, name) {
+				return
+			}
+
 			r := newOmokRoom(inst.nextID(), name, password, boardType)
 
 			if r.addPlayer(plr) {
@@ -3324,6 +3430,10 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 
 			boardType := reader.ReadByte()
 
+			if !server.allowName(plr, name) {
+				return
+			}
+
 			r := newMemoryRoom(inst.nextID(), name, password, boardType)
 
 			if r.addPlayer(plr) {
@@ -3349,6 +3459,15 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 			_ = reader.ReadInt16() // type of shop?
 			objID := reader.ReadInt32()
 
+			if !server.allowName(plr, title) {
+				return
+			}
+
+			if objID/10000 == constant.HiredMerchantItemType {
+				server.createMerchant(plr, inst, title, objID)
+				return
+			}
+
 			r := newShopRoom(objID, title, isPrivate)
 
 			if r.addPlayer(plr) {
@@ -3358,6 +3477,17 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 					log.Println(err)
 				}
 			}
+		case constant.MiniRoomTypeEntrustedShop:
+			title := reader.ReadString(reader.ReadInt16())
+			_ = reader.ReadBool()
+			_ = reader.ReadInt16()
+			permitID := reader.ReadInt32()
+
+			if !server.allowName(plr, title) {
+				return
+			}
+
+			server.createMerchant(plr, inst, title, permitID)
 		default:
 			log.Println("Unknown room type", roomType)
 		}
@@ -3415,7 +3545,8 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 
 		r.addPlayer(plr)
 
-		if _, valid := r.(gameRoomer); valid {
+		switch r.(type) {
+		case gameRoomer, *merchantRoom:
 			pool.updateGameBox(r)
 		}
 	case constant.MiniRoomChat:
@@ -3428,6 +3559,11 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 				return
 			}
 
+			if !server.allowChat(plr, msg) {
+				return
+			}
+
+			server.logChat(plr, chatlog.KindRoom, "", msg)
 			r.chatMsg(plr, msg)
 		}
 	case constant.MiniRoomOpen:
@@ -3442,6 +3578,15 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 			pool.updateGameBox(r)
 		}
 
+		// Opening a hired merchant hands it over to trade on its own
+		if merchant, valid := r.(*merchantRoom); valid && merchant.ownerID() == plr.ID {
+			if merchant.removePlayer(plr) {
+				server.closeMerchant(merchant)
+			} else {
+				pool.updateGameBox(r)
+			}
+		}
+
 	case constant.MiniRoomLeave:
 		r, err := pool.getPlayerRoom(plr.ID)
 
@@ -3486,6 +3631,13 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 					log.Println(err)
 				}
 			}
+
+		case *merchantRoom:
+			if room.removePlayer(plr) {
+				server.closeMerchant(room)
+			} else {
+				pool.updateGameBox(r)
+			}
 		}
 
 	case constant.MiniRoomTradePutItem:
@@ -3760,6 +3912,16 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 		if err != nil {
 			return
 		}
+
+		if merchant, valid := r.(*merchantRoom); valid {
+			if merchant.ownerID() == plr.ID && merchant.addItem(plr, invType, invSlot, bundles, bundleAmount, price) {
+				merchant.send(packetRoomMerchantRefresh(merchant))
+			}
+
+			plr.Send(packetPlayerNoChange())
+			return
+		}
+
 		shop, valid := r.(*shopRoom)
 		if !valid || shop.ownerID() != plr.ID {
 			return
@@ -3786,6 +3948,24 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 			return
 		}
 
+		if merchant, valid := r.(*merchantRoom); valid {
+			if merchant.ownerID() == plr.ID {
+				return
+			}
+
+			if errMsg := merchant.buyItem(plr, shopSlot, quantity); errMsg != 0 {
+				plr.Send(packetShopItemResult(errMsg))
+				return
+			}
+
+			merchant.send(packetRoomMerchantRefresh(merchant))
+
+			if len(merchant.items) == 0 {
+				server.closeMerchant(merchant)
+			}
+			return
+		}
+
 		shop, valid := r.(*shopRoom)
 		if !valid {
 			return
@@ -3824,6 +4004,15 @@ func (server Server) roomWindow(conn mnet.Client, reader mpacket.Reader) {
 		if err != nil {
 			return
 		}
+
+		if merchant, valid := r.(*merchantRoom); valid {
+			if merchant.ownerID() == plr.ID && merchant.removeItem(plr, shopSlot) {
+				merchant.send(packetRoomShopRemoveItem(0, int16(shopSlot)))
+				merchant.send(packetRoomMerchantRefresh(merchant))
+			}
+			return
+		}
+
 		shop, valid := r.(*shopRoom)
 		if !valid || shop.ownerID() != plr.ID {
 			return
@@ -3851,18 +4040,23 @@ func (server *Server) guildManagement(conn mnet.Client, reader mpacket.Reader) {
 			return
 		}
 
-		guildCount := 0
-		err := common.DB.QueryRow("SELECT count(*) FROM guilds where Name=? AND worldID=?", guildName, conn.GetWorldID()).Scan(&guildCount)
+		taken, err := common.Repo.Guilds.NameTaken(guildName, int32(conn.GetWorldID()))
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		if guildCount > 0 {
+		if taken {
 			conn.Send(packetGuildNameInUse())
 			return
 		}
 
+		if !server.filter.Clean(guildName) {
+			conn.Send(packetMessageRedText("That name contains a word that is not allowed"))
+			conn.Send(packetGuildProblemOccurred())
+			return
+		}
+
 		plr, err := server.players.GetFromConn(conn)
 
 		if err != nil {
@@ -3883,59 +4077,40 @@ func (server *Server) guildManagement(conn mnet.Client, reader mpacket.Reader) {
 	case constant.GuildInvite:
 		invitee := reader.ReadString(reader.ReadInt16())
 
-		var playerID int32
-		var guildID sql.NullInt32
-		var worldID byte
-		var channelID int8
-
-		query := "SELECT ID, guildID, worldID, channelID FROM characters WHERE Name=?"
-		row, err := common.DB.Query(query, invitee)
+		plr, err := server.players.GetFromConn(conn)
 
 		if err != nil {
-			log.Fatal(err)
+			return
 		}
 
-		defer row.Close()
-
-		for row.Next() {
-			row.Scan(&playerID, &guildID, &worldID, &channelID)
+		if plr.guild == nil {
+			return // cannot invite someone if not in guild
 		}
 
-		plr, err := server.players.GetFromConn(conn)
+		target, err := common.Repo.Characters.ByName(invitee, plr.worldID)
 
 		if err != nil {
+			plr.Send(packetMessageRedText("Could not find Player"))
 			return
 		}
 
-		if plr.guild == nil {
-			return // cannot invite someone if not in guild
-		}
-
-		if guildID.Valid {
+		if target.GuildID != 0 {
 			plr.Send(packetGuildAlreadyJoined())
 			return
 		}
 
-		count := 0
-		query = "SELECT count(*) FROM guild_invites WHERE playerID=?"
-		err = common.DB.QueryRow(query, playerID).Scan(&count)
+		invites, err := common.Repo.Guilds.Invites(target.ID)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		if count != 0 {
+		if len(invites) != 0 {
 			plr.Send(packetGuildInviteeHasAnother(invitee))
 			return
 		}
 
-		if worldID != plr.worldID {
-			plr.Send(packetMessageRedText("Could not find Player"))
-			return
-		}
-
-		query = "INSERT INTO guild_invites (playerID, guildID, inviter) VALUES (?, ?, ?)"
-		_, err = common.DB.Exec(query, playerID, plr.guild.id, plr.Name)
+		err = common.Repo.Guilds.Invite(repository.GuildInvite{CharacterID: target.ID, GuildID: plr.guild.id, Inviter: plr.Name})
 
 		if err != nil {
 			log.Fatal(err)
@@ -3956,9 +4131,7 @@ func (server *Server) guildManagement(conn mnet.Client, reader mpacket.Reader) {
 			return // cannot join the guild on someone else's behalf
 		}
 
-		query := "DELETE FROM guild_invites WHERE playerID=? AND guildID=?"
-
-		if _, err := common.DB.Exec(query, playerID, guildID); err != nil {
+		if err := common.Repo.Guilds.DeleteInvite(playerID, guildID); err != nil {
 			log.Fatal(err)
 		}
 
@@ -3997,6 +4170,10 @@ func (server *Server) guildManagement(conn mnet.Client, reader mpacket.Reader) {
 			return
 		}
 
+		if !server.allowName(plr, notice) {
+			return
+		}
+
 		server.world.Send(internal.PacketGuildUpdateNotice(plr.guild.id, notice))
 	case constant.GuildUpdateTitleNames:
 		master := reader.ReadString(reader.ReadInt16())
@@ -4011,6 +4188,10 @@ func (server *Server) guildManagement(conn mnet.Client, reader mpacket.Reader) {
 			return
 		}
 
+		if !server.allowName(plr, strings.Join([]string{master, jrMaster, member1, member2, member3}, " ")) {
+			return
+		}
+
 		if plr.guild == nil {
 			return
 		}
@@ -5034,6 +5215,12 @@ func (server *Server) playerHandleMessenger(conn mnet.Client, reader mpacket.Rea
 		server.world.Send(p)
 	case constant.MessengerChat:
 		message := reader.ReadString(reader.ReadInt16())
+
+		if !server.allowChat(plr, message) {
+			return
+		}
+
+		server.logChat(plr, chatlog.KindMessenger, "", message)
 		p := internal.PacketMessengerChat(plr.ID, server.id, plr.Name, message)
 		server.world.Send(p)
 	case constant.MessengerAvatar:
@@ -5121,34 +5308,37 @@ func (server *Server) playerPetSpawn(conn mnet.Client, reader mpacket.Reader) {
 		sn, _ := nx.GetCommoditySNByItemID(petItem.ID)
 		petItem.petData = newPet(petItem.ID, sn, petItem.dbID)
 		savePet(&petItem)
+		plr.updateItem(petItem)
 	}
 
-	petEquipped := plr.petCashID != 0
-	changePet := petEquipped && plr.petCashID == int64(petItem.petData.sn)
+	pt := petItem.petData
 
-	if petEquipped {
-		plr.inst.send(packetPetRemove(plr.ID, constant.PetRemoveNone))
-		plr.petCashID = 0
+	// Using the pet that is out sends it home
+	if plr.pet == pt {
+		plr.despawnPet(constant.PetRemoveNone)
+		plr.Send(packetPlayerNoChange())
+		return
 	}
 
-	if !changePet {
-		plr.petCashID = int64(petItem.petData.sn)
+	if pt.dead() {
+		plr.Send(packetMessageRedText("Your pet has passed away, it can be revived with Water of Life"))
+		plr.Send(packetPlayerNoChange())
+		return
+	}
 
-		if plr.pet == nil || plr.pet.sn != petItem.petData.sn {
-			plr.pet = petItem.petData
-		}
+	// Summoning another pet swaps it with the one out
+	plr.despawnPet(constant.PetRemoveNone)
 
-		plr.pet.pos = plr.pos
-		plr.inst.send(packetPetSpawn(plr.ID, plr.pet))
+	if !plr.spawnPet(pt) {
+		plr.Send(packetPlayerNoChange())
+		return
+	}
 
-		if plr.pet.spawnDate == 0 {
-			plr.Send(packetPlayerPetUpdate(plr.pet.sn))
-		}
-		plr.pet.spawnDate = time.Now().Unix()
-		plr.pet.spawned = true
+	if pt.spawnDate == 0 {
+		plr.Send(packetPlayerPetUpdate(pt.sn))
 	}
+	pt.spawnDate = time.Now().Unix()
 
-	plr.MarkDirty(DirtyPet, time.Millisecond*300)
 	plr.Send(packetPlayerNoChange())
 }
 
@@ -5158,11 +5348,16 @@ func (server *Server) playerPetMove(conn mnet.Client, reader mpacket.Reader) {
 		return
 	}
 
+	pt := plr.pet
+	if pt == nil {
+		return
+	}
+
 	moveData, finalData, valid := parseMovement(reader)
 
 	moveBytes := generateMovementBytes(moveData)
 
-	plr.pet.updateMovement(finalData)
+	pt.updateMovement(finalData)
 
 	field, ok := server.fields[plr.mapID]
 
@@ -5189,6 +5384,10 @@ func (server *Server) playerPetAction(conn mnet.Client, reader mpacket.Reader) {
 		return
 	}
 
+	if plr.pet == nil {
+		return
+	}
+
 	actType := reader.ReadByte()
 	act := reader.ReadByte()
 	msg := reader.ReadRestAsString()
@@ -5202,21 +5401,75 @@ func (server *Server) playerPetInteraction(conn mnet.Client, reader mpacket.Read
 		return
 	}
 
-	if plr.pet == nil || !plr.pet.spawned {
+	pt := plr.pet
+	if pt == nil {
 		return
 	}
 
 	doMultiplier := reader.ReadByte()
 	interactionID := reader.ReadByte()
 
-	petItem := plr.pet
-	success := handlePetInteraction(plr, petItem, interactionID, doMultiplier == 1)
+	success := handlePetInteraction(plr, pt, interactionID, doMultiplier == 1)
 	plr.Send(packetPetInteraction(plr.ID, interactionID, success, false))
 }
 
+// feedPet uses the pet food in slot on the player's spawned pet
+func (server *Server) feedPet(plr *Player, slot int16, itemID int32) {
+	if plr.pet == nil {
+		plr.Send(packetPlayerNoChange())
+		return
+	}
+
+	if _, err := plr.takeItem(itemID, slot, 1, constant.InventoryUse); err != nil {
+		log.Println(err)
+		if server.ac != nil {
+			server.ac.LogInvalidItemViolation(plr.accountID)
+		}
+		return
+	}
+
+	inc := constant.PetFoodFullness
+	if itm, err := nx.GetItem(itemID); err == nil && itm.Inc > 0 {
+		inc = int(itm.Inc)
+	}
+
+	success := plr.pet.feed(plr, inc)
+	plr.Send(packetPetInteraction(plr.ID, 0, success, true))
+}
+
+// revivePet brings the dead pet with the cash ID back to life with Water of Life, it reports whether one was revived
+func (server *Server) revivePet(plr *Player, cashID int64) bool {
+	for _, item := range plr.cash {
+		if !item.pet || item.petData == nil || (int64(item.cashSN) != cashID && int64(item.petData.sn) != cashID) {
+			continue
+		}
+
+		if !item.petData.dead() {
+			return false
+		}
+
+		item.petData.revive()
+		if err := savePet(&item); err != nil {
+			log.Println(err)
+		}
+
+		plr.Send(packetInventoryRemoveItem(item))
+		plr.Send(packetInventoryAddItem(item, true))
+
+		return true
+	}
+
+	return false
+}
+
 func (server *Server) playerPetLoot(conn mnet.Client, reader mpacket.Reader) {
 	plr, err := server.players.GetFromConn(conn)
-	if err != nil || plr.pet == nil || !plr.pet.spawned {
+	if err != nil {
+		return
+	}
+
+	pt := plr.pet
+	if pt == nil {
 		return
 	}
 
@@ -5229,9 +5482,7 @@ func (server *Server) playerPetLoot(conn mnet.Client, reader mpacket.Reader) {
 		return
 	}
 
-	if plr.pet.pos.x-drop.finalPos.x > 800 || plr.pet.pos.y-drop.finalPos.y > 600 {
-		// Hax
-		log.Printf("Player: %s pet tried to pickup an item from far away", plr.Name)
+	if !server.petPickupAllowed(plr, pt, drop) {
 		plr.Send(packetDropNotAvailable())
 		plr.Send(packetInventoryDontTake())
 		return
@@ -69,38 +69,27 @@ func (server *Server) handleNewChannelOK(conn mnet.Server, reader mpacket.Reader
 	log.Printf("Registered as channel %d on world %s with rates: Exp - x%.2f, Drop - x%.2f, Mesos - x%.2f",
 		server.id+1, server.worldName, server.rates.exp, server.rates.drop, server.rates.mesos)
 
+	server.registerAdmin()
+
 	server.players.broadcast(packetMessageNotice("Re-connected to world server as channel " + strconv.Itoa(int(server.id+1))))
 
-	accountIDs, err := common.DB.Query("SELECT accountID from characters where channelID = ? and migrationID = -1", server.id)
+	accountIDs, err := common.Repo.Characters.ClearChannel(int32(server.id))
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer accountIDs.Close()
-
-	for accountIDs.Next() {
-		var accountID int
-		err := accountIDs.Scan(&accountID)
-
-		if err != nil {
-			continue
-		}
-
-		_, err = common.DB.Exec("UPDATE accounts SET isLogedIn=? WHERE accountID=?", 0, accountID)
+	for _, accountID := range accountIDs {
+		err = common.Repo.Accounts.SetLoggedIn(accountID, false)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
-	_, err = common.DB.Exec("UPDATE characters SET channelID=? WHERE channelID=?", -1, server.id)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	log.Println("Logged out any accounts still connected to this channel")
+
+	server.loadMerchants()
 }
 
 func (server *Server) handleChannelConnectionInfo(conn mnet.Server, reader mpacket.Reader) {
@@ -349,6 +338,12 @@ func (server Server) handleChatEvent(conn mnet.Server, reader mpacket.Reader) {
 
 		plr.Send(packetMessageWhisper(fromName, msg, channelID))
 
+	case internal.OpChatMute:
+		server.refreshMute(reader.ReadInt32())
+
+	case internal.OpChatJail:
+		server.refreshJail(reader.ReadInt32())
+
 	case internal.OpChatBuddy:
 		fromName := reader.ReadString(reader.ReadInt16())
 		idCount := reader.ReadByte()
@@ -412,13 +407,18 @@ func (server Server) handleChatEvent(conn mnet.Server, reader mpacket.Reader) {
 
 			plr.Send(packetMessageBubblessChat(2, fromName, msg))
 		}
-	case internal.OpChatMegaphone: // Super megaphone broadcast from world server
+	case internal.OpChatMegaphone: // Super, heart, skull and item megaphone broadcast from world server
+		mode := reader.ReadByte()
 		fromName := reader.ReadString(reader.ReadInt16())
 		msg := reader.ReadString(reader.ReadInt16())
+		channelID := reader.ReadByte()
 		whisper := reader.ReadBool()
+		item := reader.ReadBytes(int(reader.ReadInt16()))
 
 		// Broadcast to all players on this channel
-		server.players.broadcast(packetMessageBroadcastSuper(fromName, msg, server.id, whisper))
+		server.players.broadcast(packetMessageBroadcastWorld(mode, fromName, msg, channelID, whisper, item))
+	case internal.OpChatNotice: // World wide notice from the admin api
+		server.players.broadcast(packetMessageNotice(reader.ReadString(reader.ReadInt16())))
 	default:
 		log.Println("Unknown chat event type:", op)
 	}
@@ -678,25 +678,20 @@ func (server *Server) guildInviteResult(conn mnet.Server, reader mpacket.Reader)
 		inviterName := reader.ReadString(reader.ReadInt16())
 		inviteeName := reader.ReadString(reader.ReadInt16())
 
-		var guildID, playerID int32
-
-		query := "SELECT guildID FROM characters WHERE Name=?"
-		err := common.DB.QueryRow(query, inviterName).Scan(&guildID)
+		invitee, err := server.players.GetFromName(inviteeName)
 
 		if err != nil {
-			log.Fatal(err)
+			return // the invitee rejects from this channel
 		}
 
-		query = "SELECT ID FROM characters WHERE Name=?"
-		err = common.DB.QueryRow(query, inviteeName).Scan(&playerID)
+		inviter, err := common.Repo.Characters.ByName(inviterName, invitee.worldID)
 
 		if err != nil {
-			log.Fatal(err)
+			log.Println(err)
+			return
 		}
 
-		query = "DELETE FROM guild_invites WHERE playerID=? AND guildID=?"
-
-		if _, err = common.DB.Exec(query, playerID, guildID); err != nil {
+		if err = common.Repo.Guilds.DeleteInvite(invitee.ID, inviter.GuildID); err != nil {
 			log.Fatal(err)
 		}
 
@@ -2,11 +2,9 @@ package channel
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
 	mathrand "math/rand"
@@ -17,6 +15,7 @@ import (
 	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 	"github.com/google/uuid"
 )
 
@@ -29,22 +28,60 @@ type dropTableEntry struct {
 	Chance  int64 `json:"chance"`
 }
 
-// DropTable is the global lookup table for drops
+// DropTable is the global lookup table for drops, it is replaced under dataMu when the file is reloaded
 var dropTable map[int32][]dropTableEntry
 
 // PopulateDropTable from json file
 func populateDropTable(dropJSON string) error {
-	jsonFile, err := os.Open(dropJSON)
+	table, err := loadDropTable(dropJSON)
 
 	if err != nil {
 		return err
 	}
 
-	defer jsonFile.Close()
+	dataMu.Lock()
+	dropTable = table
+	dataMu.Unlock()
 
-	jsonBytes, _ := ioutil.ReadAll(jsonFile)
+	return nil
+}
+
+// loadDropTable parses and validates a drop table without installing it
+func loadDropTable(dropJSON string) (map[int32][]dropTableEntry, error) {
+	jsonBytes, err := os.ReadFile(dropJSON)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var table map[int32][]dropTableEntry
+
+	if err := json.Unmarshal(jsonBytes, &table); err != nil {
+		return nil, err
+	}
+
+	for mobID, entries := range table {
+		for i, entry := range entries {
+			switch {
+			case !entry.IsMesos && entry.ItemID == 0:
+				return nil, fmt.Errorf("mob %d drop %d: missing itemId", mobID, i)
+			case entry.Min > entry.Max:
+				return nil, fmt.Errorf("mob %d drop %d: min %d is greater than max %d", mobID, i, entry.Min, entry.Max)
+			case entry.Chance < 0:
+				return nil, fmt.Errorf("mob %d drop %d: negative chance", mobID, i)
+			}
+		}
+	}
+
+	return table, nil
+}
 
-	return json.Unmarshal(jsonBytes, &dropTable)
+func mobDrops(mobID int32) ([]dropTableEntry, bool) {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+
+	entries, ok := dropTable[mobID]
+	return entries, ok
 }
 
 type Item struct {
@@ -98,6 +135,11 @@ type Item struct {
 
 const neverExpire int64 = 150842304000000000
 
+// fileTime converts t to the FILETIME the client uses for item expiry and pet dates
+func fileTime(t time.Time) int64 {
+	return t.UnixMilli()*10000 + 116444592000000000
+}
+
 // GenerateCashID generates a unique cash ID using crypto/rand
 func GenerateCashID() int64 {
 	var b [8]byte
@@ -114,8 +156,7 @@ func GenerateCashID() int64 {
 }
 
 func loadInventoryFromDb(charID int32) ([]Item, []Item, []Item, []Item, []Item) {
-	filter := "ID,inventoryID,itemID,slotNumber,amount,flag,upgradeSlots,level,str,dex,intt,luk,hp,mp,watk,matk,wdef,mdef,accuracy,avoid,hands,speed,jump,expireTime,creatorName,cashID,cashSN"
-	row, err := common.DB.Query("SELECT "+filter+" FROM items WHERE characterID=?", charID)
+	rows, err := common.Repo.Items.ByCharacter(charID)
 
 	if err != nil {
 		panic(err)
@@ -127,53 +168,9 @@ func loadInventoryFromDb(charID int32) ([]Item, []Item, []Item, []Item, []Item)
 	etc := []Item{}
 	cash := []Item{}
 
-	defer row.Close()
-
-	for row.Next() {
-
-		item := Item{uuid: uuid.New()}
-		var cashIDNullable sql.NullInt64
-		var cashSNNullable sql.NullInt32
-
-		err := row.Scan(&item.dbID,
-			&item.invID,
-			&item.ID,
-			&item.slotID,
-			&item.amount,
-			&item.flag,
-			&item.upgradeSlots,
-			&item.scrollLevel,
-			&item.str,
-			&item.dex,
-			&item.intt,
-			&item.luk,
-			&item.hp,
-			&item.mp,
-			&item.watk,
-			&item.matk,
-			&item.wdef,
-			&item.mdef,
-			&item.accuracy,
-			&item.avoid,
-			&item.hands,
-			&item.speed,
-			&item.jump,
-			&item.expireTime,
-			&item.creatorName,
-			&cashIDNullable,
-			&cashSNNullable)
-
-		if err != nil {
-			log.Println(err)
-			continue
-		}
-
-		if cashIDNullable.Valid {
-			item.cashID = cashIDNullable.Int64
-		}
-		if cashSNNullable.Valid {
-			item.cashSN = cashSNNullable.Int32
-		}
+	for _, row := range rows {
+		item := itemFromRow(row)
+		item.uuid = uuid.New()
 
 		if nxInfo, err := nx.GetItem(item.ID); err == nil {
 			item.cash = nxInfo.Cash
@@ -190,38 +187,7 @@ func loadInventoryFromDb(charID int32) ([]Item, []Item, []Item, []Item, []Item)
 
 			item.pet = nxInfo.Pet
 			if item.pet {
-				petRow := common.DB.QueryRow(`
-					SELECT name, sn, level, closeness, fullness,
-						   deadDate, spawnDate, lastInteraction
-					FROM pets WHERE parentID=?`, item.dbID)
-
-				petData := pet{
-					itemID:   item.ID,
-					itemDBID: item.dbID,
-				}
-
-				if err := petRow.Scan(
-					&petData.name,
-					&petData.sn,
-					&petData.level,
-					&petData.closeness,
-					&petData.fullness,
-					&petData.deadDate,
-					&petData.spawnDate,
-					&petData.lastInteraction,
-				); err == nil {
-					item.petData = &petData
-				} else if err == sql.ErrNoRows {
-					sn, _ := nx.GetCommoditySNByItemID(item.ID)
-					item.petData = newPet(item.ID, sn, item.dbID)
-				} else {
-					log.Println("error loading pet:", err)
-				}
-
-				err := savePet(&item)
-				if err != nil {
-					log.Println(err)
-				}
+				loadPet(&item)
 			}
 			item.buffTime = nxInfo.Time
 			item.spawnMobs = nxInfo.SpawnMobs
@@ -418,6 +384,14 @@ func (v Item) GetAmount() int16 { return v.amount }
 
 func (v Item) GetExpireTime() int64 { return v.expireTime }
 
+// Expired reports whether a time-limited item has run out, pets do not expire as items, they die instead
+func (v Item) Expired() bool {
+	return !v.pet && v.expireTime != 0 && v.expireTime != neverExpire && v.expireTime <= fileTime(time.Now())
+}
+
+// IsCash reports whether the item belongs in the cash shop locker rather than a regular inventory
+func (v Item) IsCash() bool { return v.cash }
+
 func (v Item) isRechargeable() bool {
 	return float64(v.ID/10000) == 207 // Taken from client
 }
@@ -427,43 +401,11 @@ func (v Item) shield() bool {
 }
 
 func (v *Item) save(charID int32) (bool, error) {
-	if v.dbID == 0 {
-		props := `characterID,inventoryID,itemID,slotNumber,amount,flag,upgradeSlots,level,
-				str,dex,intt,luk,hp,mp,watk,matk,wdef,mdef,accuracy,avoid,hands,speed,jump,
-				expireTime,creatorName,cashID,cashSN`
-
-		query := "INSERT into items (" + props + ") VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
-
-		res, err := common.DB.Exec(query,
-			charID, v.invID, v.ID, v.slotID, v.amount, v.flag, v.upgradeSlots, v.scrollLevel,
-			v.str, v.dex, v.intt, v.luk, v.hp, v.mp, v.watk, v.matk, v.wdef, v.mdef, v.accuracy, v.avoid, v.hands, v.speed, v.jump,
-			v.expireTime, v.creatorName, sql.NullInt64{Int64: v.cashID, Valid: v.cashID != 0}, sql.NullInt32{Int32: v.cashSN, Valid: v.cashSN != 0})
-
-		if err != nil {
-			return false, err
-		}
-
-		v.dbID, err = res.LastInsertId()
-
-		if err != nil {
-			return false, err
-		}
-	} else {
-		props := `slotNumber=?,amount=?,flag=?,upgradeSlots=?,level=?,
-			str=?,dex=?,intt=?,luk=?,hp=?,mp=?,watk=?,matk=?,wdef=?,mdef=?,accuracy=?,avoid=?,hands=?,speed=?,jump=?,
-			expireTime=?,cashID=?,cashSN=?`
-
-		query := "UPDATE items SET " + props + " WHERE ID=?"
-
-		_, err := common.DB.Exec(query,
-			v.slotID, v.amount, v.flag, v.upgradeSlots, v.scrollLevel,
-			v.str, v.dex, v.intt, v.luk, v.hp, v.mp, v.watk, v.matk, v.wdef, v.mdef, v.accuracy, v.avoid, v.hands, v.speed, v.jump,
-			v.expireTime, sql.NullInt64{Int64: v.cashID, Valid: v.cashID != 0}, sql.NullInt32{Int32: v.cashSN, Valid: v.cashSN != 0}, v.dbID)
-
-		if err != nil {
-			return false, err
-		}
+	row := v.Row()
+	if err := common.Repo.Items.Save(charID, &row); err != nil {
+		return false, err
 	}
+	v.dbID = row.ID
 
 	if v.pet {
 		err := savePet(v)
@@ -475,35 +417,73 @@ func (v *Item) save(charID int32) (bool, error) {
 	return true, nil
 }
 
-func (v Item) SaveToCashShopStorage(tx *sql.Tx, accountID int32, slotNumber int16) error {
-	const ins = `
-		INSERT INTO account_cashshop_storage_items(
-			accountID, itemID, cashID, sn, slotNumber, amount, flag, upgradeSlots, level,
-			str, dex, intt, luk, hp, mp, watk, matk, wdef, mdef, accuracy, avoid, hands,
-			speed, jump, expireTime, creatorName
-		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
-	_, err := tx.Exec(
-		ins,
-		accountID, v.ID, v.cashID, v.cashSN, slotNumber, v.amount,
-		v.flag, v.upgradeSlots, v.scrollLevel,
-		v.str, v.dex, v.intt, v.luk,
-		v.hp, v.mp, v.watk, v.matk,
-		v.wdef, v.mdef, v.accuracy, v.avoid,
-		v.hands, v.speed, v.jump,
-		v.expireTime, v.creatorName,
-	)
-	return err
+// Row is the persisted form of the item
+func (v Item) Row() repository.Item {
+	return repository.Item{
+		ID:           v.dbID,
+		InventoryID:  v.invID,
+		ItemID:       v.ID,
+		Slot:         v.slotID,
+		Amount:       v.amount,
+		Flag:         v.flag,
+		UpgradeSlots: v.upgradeSlots,
+		Level:        v.scrollLevel,
+		Str:          v.str,
+		Dex:          v.dex,
+		Intt:         v.intt,
+		Luk:          v.luk,
+		HP:           v.hp,
+		MP:           v.mp,
+		Watk:         v.watk,
+		Matk:         v.matk,
+		Wdef:         v.wdef,
+		Mdef:         v.mdef,
+		Accuracy:     v.accuracy,
+		Avoid:        v.avoid,
+		Hands:        v.hands,
+		Speed:        v.speed,
+		Jump:         v.jump,
+		ExpireTime:   v.expireTime,
+		CreatorName:  v.creatorName,
+		CashID:       v.cashID,
+		CashSN:       v.cashSN,
+	}
 }
 
-func (v Item) delete() error {
-	query := "DELETE FROM `items` WHERE ID=?"
-	_, err := common.DB.Exec(query, v.dbID)
-
-	if err != nil {
-		return err
+func itemFromRow(row repository.Item) Item {
+	return Item{
+		dbID:         row.ID,
+		invID:        row.InventoryID,
+		ID:           row.ItemID,
+		slotID:       row.Slot,
+		amount:       row.Amount,
+		flag:         row.Flag,
+		upgradeSlots: row.UpgradeSlots,
+		scrollLevel:  row.Level,
+		str:          row.Str,
+		dex:          row.Dex,
+		intt:         row.Intt,
+		luk:          row.Luk,
+		hp:           row.HP,
+		mp:           row.MP,
+		watk:         row.Watk,
+		matk:         row.Matk,
+		wdef:         row.Wdef,
+		mdef:         row.Mdef,
+		accuracy:     row.Accuracy,
+		avoid:        row.Avoid,
+		hands:        row.Hands,
+		speed:        row.Speed,
+		jump:         row.Jump,
+		expireTime:   row.ExpireTime,
+		creatorName:  row.CreatorName,
+		cashID:       row.CashID,
+		cashSN:       row.CashSN,
 	}
+}
 
-	return nil
+func (v Item) delete() error {
+	return common.Repo.Items.Delete(v.dbID)
 }
 
 // InventoryBytes to display in character inventory window
@@ -0,0 +1,271 @@
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/internal"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+var errJailed = errors.New("player is jailed")
+
+// SetJail sets the map jailed players are held on and the map they are sent to once released, 0 keeps the default
+func (server *Server) SetJail(mapID, releaseMapID int32) error {
+	if mapID == 0 {
+		mapID = constant.MapJail
+	}
+
+	if releaseMapID == 0 {
+		releaseMapID = constant.MapJailRelease
+	}
+
+	for _, id := range []int32{mapID, releaseMapID} {
+		if _, ok := server.fields[id]; !ok {
+			return fmt.Errorf("jail map %d does not exist", id)
+		}
+	}
+
+	server.jailMap = mapID
+	server.jailReleaseMap = releaseMapID
+
+	return nil
+}
+
+// jailMessage tells a player how long their sentence has left and why, prefix is e.g. "You are jailed"
+func jailMessage(prefix string, jail repository.Jail) string {
+	minutes := int(math.Ceil(time.Until(jail.End).Minutes()))
+
+	return fmt.Sprintf("%s for %d minutes: %s", prefix, minutes, jail.Reason)
+}
+
+// jailed reports whether the player is serving a sentence
+func (server *Server) jailed(plr *Player) bool {
+	return plr.jail != nil && plr.jail.ActiveAt(time.Now())
+}
+
+// loadJail looks up the sentence the player's account is serving and schedules their release
+func (server *Server) loadJail(plr *Player) {
+	if server.ac == nil {
+		return
+	}
+
+	jail, found, err := server.ac.ActiveJail(plr.accountID)
+	if err != nil {
+		log.Println("Failed to load jail for", plr.Name, ":", err)
+		return
+	}
+
+	plr.jail = nil
+	if found {
+		plr.jail = &jail
+	}
+
+	server.scheduleRelease(plr)
+}
+
+// placeInJail moves a jailed player who is logging in to the jail map, and a player left on the jail map without a
+// sentence, e.g. one released whilst offline, to the release map. Runs before they are added to a field.
+func (server *Server) placeInJail(plr *Player) {
+	mapID, move := jailDestination(server.jailed(plr), plr.Conn.GetAdminLevel() > 0, plr.mapID, server.jailMap, server.jailReleaseMap)
+	if !move {
+		return
+	}
+
+	inst, err := server.fields[mapID].getInstance(0)
+	if err != nil {
+		log.Println("Jail:", err)
+		return
+	}
+
+	portal, err := inst.getRandomSpawnPortal()
+	if err != nil {
+		log.Println("Jail:", err)
+		return
+	}
+
+	plr.mapID = mapID
+	plr.mapPos = portal.id
+	plr.pos = portal.pos
+	plr.MarkDirty(DirtyMap, 500*time.Millisecond)
+}
+
+// jailDestination is the map a player logging in on mapID has to be moved to, GMs may stay on the jail map
+func jailDestination(jailed, gm bool, mapID, jailMap, releaseMap int32) (int32, bool) {
+	switch {
+	case jailed:
+		return jailMap, mapID != jailMap
+	case mapID == jailMap && !gm:
+		return releaseMap, true
+	}
+
+	return mapID, false
+}
+
+// scheduleRelease lets the player out once their sentence ends
+func (server *Server) scheduleRelease(plr *Player) {
+	if timer, ok := server.jailTimers[plr.ID]; ok {
+		timer.Stop()
+		delete(server.jailTimers, plr.ID)
+	}
+
+	if plr.jail == nil {
+		return
+	}
+
+	id, jailID := plr.ID, plr.jail.ID
+	server.jailTimers[plr.ID] = time.AfterFunc(time.Until(plr.jail.End), func() {
+		server.dispatch <- func() {
+			plr, err := server.players.GetFromID(id)
+			if err != nil || plr.jail == nil || plr.jail.ID != jailID {
+				return
+			}
+
+			server.releasePlayer(plr, "Your jail sentence is over")
+		}
+	})
+}
+
+// moveToMap warps the player to a spawn portal of the map
+func (server *Server) moveToMap(plr *Player, mapID int32) error {
+	field, ok := server.fields[mapID]
+	if !ok {
+		return fmt.Errorf("map %d does not exist", mapID)
+	}
+
+	inst, err := field.getInstance(0)
+	if err != nil {
+		return err
+	}
+
+	portal, err := inst.getRandomSpawnPortal()
+	if err != nil {
+		return err
+	}
+
+	return server.warpPlayerToInstance(plr, field, 0, portal, false)
+}
+
+// jailPlayer stores a sentence of d on the player's account, moves them to the jail map and lets every channel know, by
+// names the GM or system that issued it
+func (server *Server) jailPlayer(plr *Player, d time.Duration, reason, by string) error {
+	if server.ac == nil {
+		return nil
+	}
+
+	jail, err := server.ac.Jail(plr.accountID, d, reason, by)
+
+	// Hold the player on this channel even if the sentence could not be stored
+	plr.jail = &jail
+	plr.Send(packetMessageRedText(jailMessage("You have been jailed", jail)))
+
+	if plr.mapID != server.jailMap {
+		if err := server.moveToMap(plr, server.jailMap); err != nil {
+			log.Println("Failed to move", plr.Name, "to jail:", err)
+		}
+	}
+
+	server.scheduleRelease(plr)
+
+	if err != nil {
+		return err
+	}
+
+	log.Println(by, "jailed", plr.Name, "for", d, ":", reason)
+
+	server.world.Send(internal.PacketChannelJailChanged(plr.accountID))
+
+	return nil
+}
+
+// jailAccount is the anti-cheat jail action, it jails the account's character on this channel or stores the sentence
+// for when they next log in
+func (server *Server) jailAccount(accountID int32, d time.Duration, reason string) {
+	var online *Player
+	server.players.observe(func(plr *Player) {
+		if plr.accountID == accountID {
+			online = plr
+		}
+	})
+
+	if online != nil {
+		if err := server.jailPlayer(online, d, reason, "Anti-cheat"); err != nil {
+			log.Println("Anti-cheat: failed to store jail for account", accountID, ":", err)
+		}
+
+		return
+	}
+
+	if _, err := server.ac.Jail(accountID, d, reason, "Anti-cheat"); err != nil {
+		log.Println("Anti-cheat: failed to jail account", accountID, ":", err)
+		return
+	}
+
+	server.world.Send(internal.PacketChannelJailChanged(accountID))
+}
+
+// releasePlayer ends the player's sentence on this channel and sends them to the release map
+func (server *Server) releasePlayer(plr *Player, msg string) {
+	plr.jail = nil
+	server.scheduleRelease(plr)
+
+	plr.Send(packetMessageNotice(msg))
+
+	if plr.mapID != server.jailMap {
+		return
+	}
+
+	if err := server.moveToMap(plr, server.jailReleaseMap); err != nil {
+		log.Println("Failed to release", plr.Name, "from jail:", err)
+	}
+}
+
+// refreshJail reloads the sentence of any player on this channel from the account, after a GM or another channel
+// changed it
+func (server *Server) refreshJail(accountID int32) {
+	server.players.observe(func(plr *Player) {
+		if plr.accountID != accountID {
+			return
+		}
+
+		old := plr.jail
+		server.loadJail(plr)
+
+		switch {
+		case plr.jail != nil && (old == nil || old.ID != plr.jail.ID):
+			plr.Send(packetMessageRedText(jailMessage("You have been jailed", *plr.jail)))
+
+			if plr.mapID != server.jailMap {
+				if err := server.moveToMap(plr, server.jailMap); err != nil {
+					log.Println("Failed to move", plr.Name, "to jail:", err)
+				}
+			}
+		case plr.jail == nil && old != nil:
+			server.releasePlayer(plr, "You have been released from jail")
+		}
+	})
+}
+
+// jailDisconnect stops the release timer of a player leaving the channel, the sentence is picked up again on login
+func (server *Server) jailDisconnect(plr *Player) {
+	if timer, ok := server.jailTimers[plr.ID]; ok {
+		timer.Stop()
+		delete(server.jailTimers, plr.ID)
+	}
+}
+
+// refuseJailed tells a jailed player they cannot leave and reports whether they were refused
+func (server *Server) refuseJailed(plr *Player) bool {
+	if !server.jailed(plr) {
+		return false
+	}
+
+	plr.Send(packetMessageRedText(jailMessage("You are jailed", *plr.jail)))
+	plr.Send(packetPlayerNoChange())
+
+	return true
+}
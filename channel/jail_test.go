@@ -0,0 +1,31 @@
+package channel
+
+import "testing"
+
+func TestJailDestination(t *testing.T) {
+	const jail, release, elsewhere = 180000001, 100000000, 104000000
+
+	tests := []struct {
+		name   string
+		jailed bool
+		gm     bool
+		mapID  int32
+		want   int32
+		move   bool
+	}{
+		{"jailed elsewhere", true, false, elsewhere, jail, true},
+		{"jailed in jail", true, false, jail, jail, false},
+		{"released whilst offline", false, false, jail, release, true},
+		{"gm in jail", false, true, jail, jail, false},
+		{"free elsewhere", false, false, elsewhere, elsewhere, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, move := jailDestination(tt.jailed, tt.gm, tt.mapID, jail, release)
+			if move != tt.move || (move && got != tt.want) {
+				t.Errorf("jailDestination() = %d, %v, want %d, %v", got, move, tt.want, tt.move)
+			}
+		})
+	}
+}
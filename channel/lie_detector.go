@@ -0,0 +1,329 @@
+package channel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/common/opcode"
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/mnet"
+	"github.com/Hucaru/Valhalla/mpacket"
+)
+
+// lieDetectorCharset leaves out characters that are easy to confuse with each other
+const lieDetectorCharset = "ACDEFHJKLMNPRTUVWXY34679"
+
+const lieDetectorAnswerLength = 6
+
+type lieDetectorTest struct {
+	answer     string
+	attempt    byte
+	reporterID int32
+	timer      *time.Timer
+}
+
+// startLieDetector sends a test to the player, reporterID is rewarded if the player turns out to be a bot and can be 0
+func (server *Server) startLieDetector(plr *Player, reporterID int32) error {
+	if _, ok := server.lieDetectors[plr.ID]; ok {
+		return errors.New("player is already being tested")
+	}
+
+	for id, until := range server.lieDetectorCooldown {
+		if time.Now().After(until) {
+			delete(server.lieDetectorCooldown, id)
+		}
+	}
+
+	if _, ok := server.lieDetectorCooldown[plr.ID]; ok {
+		return errors.New("player has passed a test recently")
+	}
+
+	if plr.Conn.GetAdminLevel() > 0 {
+		return errors.New("cannot test a GM")
+	}
+
+	test := &lieDetectorTest{reporterID: reporterID}
+	server.lieDetectors[plr.ID] = test
+
+	return server.sendLieDetectorAttempt(plr, test)
+}
+
+func (server *Server) sendLieDetectorAttempt(plr *Player, test *lieDetectorTest) error {
+	answer := make([]byte, lieDetectorAnswerLength)
+	for i := range answer {
+		answer[i] = lieDetectorCharset[rand.Intn(len(lieDetectorCharset))]
+	}
+
+	img, err := lieDetectorImage(string(answer))
+	if err != nil {
+		delete(server.lieDetectors, plr.ID)
+		return err
+	}
+
+	test.answer = string(answer)
+	test.attempt++
+
+	plr.Send(packetLieDetectorTest(test.attempt, img))
+	plr.Send(packetMessageRedText(fmt.Sprintf("Lie detector: type the characters in the picture within %d seconds (attempt %d of %d)",
+		constant.LieDetectorSeconds, test.attempt, constant.LieDetectorAttempts)))
+
+	id := plr.ID
+	test.timer = time.AfterFunc(constant.LieDetectorSeconds*time.Second, func() {
+		server.dispatch <- func() {
+			if server.lieDetectors[id] != test {
+				return
+			}
+
+			plr, err := server.players.GetFromID(id)
+			if err != nil {
+				return
+			}
+
+			server.lieDetectorWrong(plr, test, "timed out")
+		}
+	})
+
+	return nil
+}
+
+func (server *Server) playerLieDetectorResult(conn mnet.Client, reader mpacket.Reader) {
+	plr, err := server.players.GetFromConn(conn)
+	if err != nil {
+		return
+	}
+
+	test, ok := server.lieDetectors[plr.ID]
+	if !ok {
+		return
+	}
+
+	answer := strings.ToUpper(strings.TrimSpace(reader.ReadString(reader.ReadInt16())))
+
+	if answer != test.answer {
+		server.lieDetectorWrong(plr, test, "wrong answer")
+		return
+	}
+
+	test.timer.Stop()
+	delete(server.lieDetectors, plr.ID)
+	server.lieDetectorCooldown[plr.ID] = time.Now().Add(constant.LieDetectorCooldownMins * time.Minute)
+
+	plr.Send(packetMessageNotice("Lie detector passed, thank you for your cooperation"))
+	log.Println("Lie detector:", plr.Name, "passed")
+}
+
+func (server *Server) lieDetectorWrong(plr *Player, test *lieDetectorTest, reason string) {
+	test.timer.Stop()
+
+	if test.attempt < constant.LieDetectorAttempts {
+		plr.Send(packetMessageRedText("Lie detector: " + reason + ", please try again"))
+
+		if err := server.sendLieDetectorAttempt(plr, test); err != nil {
+			log.Println("Lie detector:", err)
+		}
+
+		return
+	}
+
+	server.lieDetectorFailed(plr, test, reason)
+}
+
+// lieDetectorFailed bans the player through the anti-cheat and rewards whoever reported them
+func (server *Server) lieDetectorFailed(plr *Player, test *lieDetectorTest, reason string) {
+	test.timer.Stop()
+	delete(server.lieDetectors, plr.ID)
+
+	log.Println("Lie detector:", plr.Name, "failed,", reason)
+
+	if reporter, err := server.players.GetFromID(test.reporterID); err == nil {
+		reporter.giveMesos(constant.LieDetectorReporterReward)
+		reporter.Send(packetMessageNotice(fmt.Sprintf("%s failed the lie detector test, you have been rewarded %d mesos for your report",
+			plr.Name, constant.LieDetectorReporterReward)))
+	} else if test.reporterID != 0 {
+		// The reporter left or is on another channel, whose copy of their mesos would overwrite a direct update
+		if err := common.Repo.LieTests.Reward(test.reporterID, constant.LieDetectorReporterReward); err != nil {
+			log.Println("Lie detector: unable to keep the reward of reporter", test.reporterID, ":", err)
+		}
+	}
+
+	if server.ac == nil {
+		plr.Kick()
+		return
+	}
+
+	if err := server.ac.IssueBan(plr.accountID, constant.LieDetectorBanHours, "Failed lie detector test", "", ""); err != nil {
+		log.Println("Lie detector ban failed:", err)
+		plr.Kick()
+	}
+}
+
+// lieDetectorDisconnect keeps the test of a player leaving during it pending, it is run again at their next login
+func (server *Server) lieDetectorDisconnect(plr *Player) {
+	test, ok := server.lieDetectors[plr.ID]
+	if !ok {
+		return
+	}
+
+	test.timer.Stop()
+	delete(server.lieDetectors, plr.ID)
+
+	if err := common.Repo.LieTests.Pend(plr.ID, test.reporterID); err != nil {
+		log.Println("Lie detector: unable to keep the test of", plr.Name, "pending:", err)
+		return
+	}
+
+	log.Println("Lie detector:", plr.Name, "left during the test, it will be run again at their next login")
+}
+
+// resumeLieDetector runs the test a player left during again
+func (server *Server) resumeLieDetector(plr *Player) {
+	reporterID, ok, err := common.Repo.LieTests.Take(plr.ID)
+	if err != nil {
+		log.Println("Lie detector:", err)
+		return
+	}
+
+	if !ok {
+		return
+	}
+
+	if err := server.startLieDetector(plr, reporterID); err != nil {
+		log.Println("Lie detector: unable to resume the test of", plr.Name+":", err)
+	}
+}
+
+// claimLieDetectorReward gives a player logging in the rewards for their reports that failed while they were away
+func (server *Server) claimLieDetectorReward(plr *Player) {
+	mesos, err := common.Repo.LieTests.TakeReward(plr.ID)
+	if err != nil {
+		log.Println("Lie detector:", err)
+		return
+	}
+
+	if mesos == 0 {
+		return
+	}
+
+	plr.giveMesos(mesos)
+	plr.Send(packetMessageNotice(fmt.Sprintf("A player you reported failed the lie detector test, you have been rewarded %d mesos for your report", mesos)))
+}
+
+// refuseLieDetector tells a player being tested they must finish the test first and reports whether they were refused
+func (server *Server) refuseLieDetector(plr *Player) bool {
+	if _, ok := server.lieDetectors[plr.ID]; !ok {
+		return false
+	}
+
+	plr.Send(packetMessageRedText("Lie detector: you must finish the test first"))
+
+	return true
+}
+
+var lieDetectorGlyphs = map[byte][7]string{
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'H': {"#...#", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'J': {"..###", "...#.", "...#.", "...#.", "...#.", "#..#.", ".##.."},
+	'K': {"#...#", "#..#.", "#.#..", "##...", "#.#..", "#..#.", "#...#"},
+	'L': {"#....", "#....", "#....", "#....", "#....", "#....", "#####"},
+	'M': {"#...#", "##.##", "#.#.#", "#.#.#", "#...#", "#...#", "#...#"},
+	'N': {"#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#", "#...#"},
+	'P': {"####.", "#...#", "#...#", "####.", "#....", "#....", "#...."},
+	'R': {"####.", "#...#", "#...#", "####.", "#.#..", "#..#.", "#...#"},
+	'T': {"#####", "..#..", "..#..", "..#..", "..#..", "..#..", "..#.."},
+	'U': {"#...#", "#...#", "#...#", "#...#", "#...#", "#...#", ".###."},
+	'V': {"#...#", "#...#", "#...#", "#...#", "#...#", ".#.#.", "..#.."},
+	'W': {"#...#", "#...#", "#...#", "#.#.#", "#.#.#", "##.##", "#...#"},
+	'X': {"#...#", "#...#", ".#.#.", "..#..", ".#.#.", "#...#", "#...#"},
+	'Y': {"#...#", "#...#", ".#.#.", "..#..", "..#..", "..#..", "..#.."},
+	'3': {"####.", "....#", "....#", ".###.", "....#", "....#", "####."},
+	'4': {"...#.", "..##.", ".#.#.", "#..#.", "#####", "...#.", "...#."},
+	'6': {".###.", "#....", "#....", "####.", "#...#", "#...#", ".###."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+	'9': {".###.", "#...#", "#...#", ".####", "....#", "....#", ".###."},
+}
+
+// lieDetectorImage draws the answer as a noisy JPEG so it cannot simply be read out of the packet
+func lieDetectorImage(answer string) ([]byte, error) {
+	const (
+		scale  = 4
+		gap    = 8
+		margin = 12
+	)
+
+	width := margin*2 + len(answer)*(5*scale+gap) - gap
+	height := margin*2 + 7*scale
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			shade := uint8(200 + rand.Intn(56))
+			img.Set(x, y, color.RGBA{shade, shade, uint8(200 + rand.Intn(56)), 255})
+		}
+	}
+
+	for i := 0; i < len(answer); i++ {
+		glyph, ok := lieDetectorGlyphs[answer[i]]
+		if !ok {
+			return nil, fmt.Errorf("no glyph for %q", answer[i])
+		}
+
+		ink := color.RGBA{uint8(rand.Intn(90)), uint8(rand.Intn(90)), uint8(rand.Intn(120)), 255}
+		left := margin + i*(5*scale+gap) + rand.Intn(5) - 2
+		top := margin + rand.Intn(9) - 4
+
+		for row, line := range glyph {
+			for col := 0; col < len(line); col++ {
+				if line[col] != '#' {
+					continue
+				}
+
+				for dx := 0; dx < scale; dx++ {
+					for dy := 0; dy < scale; dy++ {
+						img.Set(left+col*scale+dx, top+row*scale+dy, ink)
+					}
+				}
+			}
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		x0, y0 := rand.Intn(width), rand.Intn(height)
+		x1, y1 := rand.Intn(width), rand.Intn(height)
+		ink := color.RGBA{uint8(rand.Intn(160)), uint8(rand.Intn(160)), uint8(rand.Intn(160)), 255}
+
+		for s := 0; s <= width; s++ {
+			img.Set(x0+(x1-x0)*s/width, y0+(y1-y0)*s/width, ink)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 70}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func packetLieDetectorTest(attempt byte, img []byte) mpacket.Packet {
+	p := mpacket.CreateWithOpcode(opcode.SendChannelLieDetectorTest)
+	p.WriteByte(6) // question
+	p.WriteByte(4)
+	p.WriteByte(attempt)
+	p.WriteInt32(int32(len(img)))
+	p.WriteBytes(img)
+
+	return p
+}
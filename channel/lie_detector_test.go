@@ -0,0 +1,40 @@
+package channel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+func TestLieDetectorRewardWaitsForReporter(t *testing.T) {
+	saved := common.Repo
+	common.Repo = repository.NewMemory().Repositories()
+	t.Cleanup(func() { common.Repo = saved })
+
+	server := &Server{players: NewPlayers(), lieDetectors: make(map[int32]*lieDetectorTest)}
+	suspect := &Player{ID: 1, Name: "Farmer"}
+	test := &lieDetectorTest{reporterID: 2, timer: time.NewTimer(time.Hour)}
+	server.lieDetectors[suspect.ID] = test
+
+	// The reporter is not on the channel when the test is failed
+	server.lieDetectorFailed(suspect, test, "timed out")
+
+	reporter := &Player{ID: 2, mesos: 100}
+	server.claimLieDetectorReward(reporter)
+
+	// Save the mesos now rather than once the repository has been put back
+	defer reporter.FlushNow()
+
+	if want := int32(100 + constant.LieDetectorReporterReward); reporter.mesos != want {
+		t.Errorf("reporter has %d mesos after logging in, want %d", reporter.mesos, want)
+	}
+
+	server.claimLieDetectorReward(reporter)
+
+	if want := int32(100 + constant.LieDetectorReporterReward); reporter.mesos != want {
+		t.Errorf("reporter has %d mesos after logging in again, want the reward once", reporter.mesos)
+	}
+}
@@ -143,9 +143,10 @@ func packetMessageBroadcastChannel(senderName string, msg string, channel byte,
 	return p
 }
 
-func packetMessageBroadcastSuper(senderName string, msg string, channel byte, ear bool) mpacket.Packet {
+// packetMessageBroadcastWorld - super, heart, skull and item megaphones, item is only written for item megaphones
+func packetMessageBroadcastWorld(mode byte, senderName string, msg string, channel byte, ear bool, item []byte) mpacket.Packet {
 	p := mpacket.CreateWithOpcode(opcode.SendChannelBroadcastMessage)
-	p.WriteByte(constant.BroadcastSuperMegaphone)
+	p.WriteByte(mode)
 	p.WriteString(senderName + " : " + msg)
 	p.WriteByte(channel)
 	if ear {
@@ -154,6 +155,11 @@ func packetMessageBroadcastSuper(senderName string, msg string, channel byte, ea
 		p.WriteByte(0x00)
 	}
 
+	if mode == constant.BroadcastItemMegaphone {
+		p.WriteBool(len(item) > 0)
+		p.WriteBytes(item)
+	}
+
 	return p
 }
 
@@ -3,6 +3,7 @@ package channel
 import (
 	"fmt"
 
+	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/mpacket"
 )
 
@@ -216,12 +217,34 @@ func (data movement) validateChar(player *Player) bool {
 	return true
 }
 
-type mob interface {
-}
+// validateMob movement from the mob's controller, describing the problem if the mob has been moved further than it can
+// travel in one update or, when it cannot fly, away from the footholds
+func (data movement) validateMob(mob *monster, fh fhHistogram) string {
+	start := pos{x: data.origX, y: data.origY}
+	final := start
+
+	for _, frag := range data.frags {
+		if frag.posSet {
+			final.x = frag.x
+			final.y = frag.y
+		}
+	}
 
-// ValidateMob movement
-func (data movement) validateMob(mob mob) bool {
-	// run through the movement data and make sure monsters are not moving too fast
+	if dx, dy := mob.pos.offset(start); dx > constant.MobMoveLimit || dy > constant.MobMoveLimit {
+		return fmt.Sprintf("started %d, %d pixels from where it was", dx, dy)
+	}
 
-	return true
+	if dx, dy := start.offset(final); dx > constant.MobMoveLimit || dy > constant.MobMoveLimit {
+		return fmt.Sprintf("moved %d, %d pixels in one update", dx, dy)
+	}
+
+	if mob.flySpeed > 0 || len(fh.bins) == 0 {
+		return ""
+	}
+
+	if _, dy := final.offset(fh.getFinalPosition(final)); dy > constant.MobAirLimit {
+		return fmt.Sprintf("ended %d pixels away from the footholds", dy)
+	}
+
+	return ""
 }
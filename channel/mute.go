@@ -0,0 +1,83 @@
+package channel
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Hucaru/Valhalla/internal"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+// muteMessage tells a player how long mute has left and why, prefix is e.g. "You are muted"
+func muteMessage(prefix string, mute repository.Mute) string {
+	if mute.Permanent() {
+		return fmt.Sprintf("%s: %s", prefix, mute.Reason)
+	}
+
+	minutes := int(math.Ceil(time.Until(mute.End).Minutes()))
+
+	return fmt.Sprintf("%s for %d minutes: %s", prefix, minutes, mute.Reason)
+}
+
+// loadMute looks up the mute in force on the player's account
+func (server *Server) loadMute(plr *Player) {
+	if server.ac == nil {
+		return
+	}
+
+	mute, found, err := server.ac.ActiveMute(plr.accountID)
+	if err != nil {
+		log.Println("Failed to load mute for", plr.Name, ":", err)
+		return
+	}
+
+	plr.mute = nil
+	if found {
+		plr.mute = &mute
+	}
+}
+
+// refreshMute reloads the mute on any player on this channel from the account, after a GM or another channel changed it
+func (server *Server) refreshMute(accountID int32) {
+	server.players.observe(func(plr *Player) {
+		if plr.accountID != accountID {
+			return
+		}
+
+		old := plr.mute
+		server.loadMute(plr)
+
+		switch {
+		case plr.mute != nil && (old == nil || old.ID != plr.mute.ID):
+			plr.Send(packetMessageRedText(muteMessage("You have been muted", *plr.mute)))
+		case plr.mute == nil && old != nil && old.ActiveAt(time.Now()):
+			plr.Send(packetMessageNotice("You are no longer muted"))
+		}
+	})
+}
+
+// mutePlayer stores a mute of d (0 for permanent) on the player's account and lets every channel know, by names the GM
+// or system that issued it
+func (server *Server) mutePlayer(plr *Player, d time.Duration, reason, by string) error {
+	if server.ac == nil {
+		return nil
+	}
+
+	mute, err := server.ac.Mute(plr.accountID, d, reason, by)
+
+	// Block chat on this channel even if the mute could not be stored
+	plr.mute = &mute
+	plr.Send(packetMessageRedText(muteMessage("You have been muted", mute)))
+
+	if err != nil {
+		return err
+	}
+
+	log.Println(by, "muted", plr.Name, "for", d, ":", reason)
+
+	server.world.Send(internal.PacketChannelMuteChanged(plr.accountID))
+
+	return nil
+}
@@ -1,14 +1,18 @@
 package channel
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"math/rand"
 	"time"
 
 	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/common/opcode"
+	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 type pet struct {
@@ -23,6 +27,11 @@ type pet struct {
 	spawnDate       int64
 	lastInteraction int64
 
+	// Equipment the pet wears by slot, the zero Item for an empty slot. It stays with the pet while it is home and is
+	// shown in the equip window while the pet is out, where the inventory's copy is the current one.
+	equips      [constant.PetEquipSlotCount]Item
+	equipsDirty bool // equips changed since they were last queued to be saved
+
 	pos    pos
 	stance byte
 
@@ -43,8 +52,8 @@ func newPet(itemID, sn int32, dbID int64) *pet {
 		stance:          0,
 		level:           1,
 		closeness:       0,
-		fullness:        100,
-		deadDate:        (time.Now().UnixMilli()*10000 + 116444592000000000 + (time.Hour.Milliseconds() * 24 * 90)),
+		fullness:        constant.PetFullnessMax,
+		deadDate:        fileTime(time.Now().AddDate(0, 0, constant.PetLifeDays)),
 		spawnDate:       0,
 		lastInteraction: 0,
 	}
@@ -57,32 +66,192 @@ func savePet(item *Item) error {
 		item.petData = newPet(item.ID, sn, item.dbID)
 	}
 
-	p := item.petData
-
-	_, err := common.DB.Exec(`
-		INSERT INTO pets (
-			parentID, name, sn, level, closeness, fullness,
-			deadDate, spawnDate, lastInteraction
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-		ON DUPLICATE KEY UPDATE
-			name = VALUES(name),
-			level = VALUES(level),
-			closeness = VALUES(closeness),
-			fullness = VALUES(fullness),
-			deadDate = VALUES(deadDate),
-			spawnDate = VALUES(spawnDate),
-			lastInteraction = VALUES(lastInteraction)
-	`, item.dbID,
-		p.name,
-		p.sn,
-		p.level,
-		p.closeness,
-		p.fullness,
-		p.deadDate,
-		p.spawnDate,
-		p.lastInteraction,
-	)
-	return err
+	return common.Repo.Pets.Save(item.petData.row())
+}
+
+// loadPet reads the pet item's pet and the equipment it wears, a pet item without a pet row gets a new pet
+func loadPet(item *Item) {
+	row, err := common.Repo.Pets.ByItem(item.dbID)
+
+	switch {
+	case err == nil:
+		item.petData = &pet{
+			name:            row.Name,
+			itemID:          item.ID,
+			sn:              row.SN,
+			itemDBID:        item.dbID,
+			level:           row.Level,
+			closeness:       row.Closeness,
+			fullness:        row.Fullness,
+			deadDate:        row.DeadDate,
+			spawnDate:       row.SpawnDate,
+			lastInteraction: row.LastInteraction,
+		}
+
+		if err := loadPetEquips(item.petData); err != nil {
+			log.Println("error loading pet equipment:", err)
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		sn, _ := nx.GetCommoditySNByItemID(item.ID)
+		item.petData = newPet(item.ID, sn, item.dbID)
+	default:
+		log.Println("error loading pet:", err)
+	}
+
+	if err := savePet(item); err != nil {
+		log.Println(err)
+	}
+}
+
+// row is the persisted form of the pet
+func (p *pet) row() repository.Pet {
+	return repository.Pet{
+		ItemID:          p.itemDBID,
+		Name:            p.name,
+		SN:              p.sn,
+		Level:           p.level,
+		Closeness:       p.closeness,
+		Fullness:        p.fullness,
+		DeadDate:        p.deadDate,
+		SpawnDate:       p.spawnDate,
+		LastInteraction: p.lastInteraction,
+	}
+}
+
+// petPotions the auto potion pouches drink and the HP and MP percentages they drink below, an item ID of 0 drinks the
+// first potion in the use inventory that restores HP or MP and a percentage of 0 keeps the default
+type petPotions struct {
+	hpItem, mpItem       int32
+	hpPercent, mpPercent int
+}
+
+// SetPetPotions sets the potions the auto HP and MP pouches drink and the percentages they drink below
+func (server *Server) SetPetPotions(hpItem, mpItem int32, hpPercent, mpPercent int) error {
+	for _, id := range []int32{hpItem, mpItem} {
+		if id == 0 {
+			continue
+		}
+
+		itm, err := nx.GetItem(id)
+		if err != nil || (itm.HP <= 0 && itm.MP <= 0 && itm.HPR <= 0 && itm.MPR <= 0) {
+			return fmt.Errorf("pet auto potion %d does not restore HP or MP", id)
+		}
+	}
+
+	for _, percent := range []int{hpPercent, mpPercent} {
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("pet auto potion percentage %d is not between 0 and 100", percent)
+		}
+	}
+
+	server.petPotions = petPotions{hpItem: hpItem, mpItem: mpItem, hpPercent: hpPercent, mpPercent: mpPercent}
+
+	return nil
+}
+
+// loadPetEquips reads which items the pet wears, only their database IDs are known until Player.takePetEquips finds
+// the items in the inventory loaded with them
+func loadPetEquips(p *pet) error {
+	equips, err := common.Repo.PetEquips.ByPet(p.itemDBID)
+	if err != nil {
+		return err
+	}
+
+	for slot, itemID := range equips {
+		if int(slot) < len(p.equips) {
+			p.equips[slot] = Item{dbID: itemID}
+		}
+	}
+
+	return nil
+}
+
+// equipIDs are the database IDs of the items the pet wears by equipment slot
+func (p *pet) equipIDs() map[byte]int64 {
+	equips := make(map[byte]int64)
+
+	for slot, item := range p.equips {
+		if item.dbID != 0 {
+			equips[byte(slot)] = item.dbID
+		}
+	}
+
+	return equips
+}
+
+// Pet abilities given by the equipment a pet wears
+const (
+	petPickupMeso = 1 << iota
+	petPickupItem
+	petPickupAll
+	petLongRange
+	petConsumeHP
+	petConsumeMP
+)
+
+// petEquipAbilities reads the abilities of a pet equipment item from nx
+func petEquipAbilities(itemID int32) int {
+	itm, err := nx.GetItem(itemID)
+	if err != nil {
+		return 0
+	}
+
+	abilities := 0
+
+	if itm.PickupMeso > 0 || itemID == constant.ItemMesoMagnet {
+		abilities |= petPickupMeso
+	}
+	if itm.PickupItem > 0 || itemID == constant.ItemItemPouch {
+		abilities |= petPickupItem
+	}
+	if itm.PickupAll > 0 {
+		abilities |= petPickupAll
+	}
+	if itm.LongRange > 0 {
+		abilities |= petLongRange
+	}
+	if itm.ConsumeHP > 0 {
+		abilities |= petConsumeHP
+	}
+	if itm.ConsumeMP > 0 {
+		abilities |= petConsumeMP
+	}
+
+	return abilities
+}
+
+// abilities of all the equipment the pet wears
+func (p *pet) abilities() int {
+	abilities := 0
+
+	for _, item := range p.equips {
+		if item.ID != 0 {
+			abilities |= petEquipAbilities(item.ID)
+		}
+	}
+
+	return abilities
+}
+
+// isPetEquip reports whether the item is worn by pets rather than players
+func isPetEquip(itemID int32) bool {
+	t := getItemType(itemID)
+	return t == 180 || t == 181
+}
+
+// petEquipSlot finds the pet equipment slot an equip window slot belongs to
+func petEquipSlot(slot int16) (int, bool) {
+	off := int(constant.PetEquipSlotFirst) - int(slot)
+	if off < 0 || off >= constant.PetEquipSlotCount {
+		return 0, false
+	}
+
+	return off, true
+}
+
+// petEquipSlotID is the equip window slot of the pet's equipment slot
+func petEquipSlotID(equipSlot int) int16 {
+	return int16(constant.PetEquipSlotFirst - equipSlot)
 }
 
 func (p *pet) updateMovement(frag movementFrag) {
@@ -103,7 +272,7 @@ func handlePetInteraction(plr *Player, pet *pet, interactionID byte, multiplier
 	}
 
 	now := time.Now().UnixMilli()
-	if now < pet.lastInteraction+15_000 || pet.level < react.LevelMin || pet.level > react.LevelMax || pet.fullness < 50 {
+	if now < pet.lastInteraction+15_000 || pet.level < react.LevelMin || pet.level > react.LevelMax {
 		return false
 	}
 
@@ -111,6 +280,12 @@ func handlePetInteraction(plr *Player, pet *pet, interactionID byte, multiplier
 	pet.lastInteraction = now
 	plr.MarkDirty(DirtyPet, time.Millisecond*300)
 
+	// A hungry pet ignores its owner and likes them less for asking
+	if pet.fullness < constant.PetHungryFullness {
+		pet.changeCloseness(plr, -constant.PetNeglectCloseness)
+		return false
+	}
+
 	mult := 1.0
 	if multiplier && pet.name != "" {
 		mult = 1.5
@@ -118,19 +293,94 @@ func handlePetInteraction(plr *Player, pet *pet, interactionID byte, multiplier
 	successProb := float64(react.Prob) * ((elapsed/10_000.0)*0.01 + 1) * mult
 	success := float64(rand.Intn(100)) < successProb
 	if success {
-		pet.closeness += int16(react.Inc)
-		if pet.closeness < 0 {
-			pet.closeness = 0
-		}
-		if pet.closeness > 30_000 {
-			pet.closeness = 30_000
-		}
-		pet.level = petLevelFromCloseness(pet.closeness)
-		plr.updatePet()
+		pet.changeCloseness(plr, int(react.Inc))
 	}
 	return success
 }
 
+// changeCloseness adds inc, which can be negative, to the pet's closeness and moves its level to match. The owner and
+// everyone on the map see the effect when it levels up.
+func (p *pet) changeCloseness(plr *Player, inc int) {
+	closeness := int(p.closeness) + inc
+	if closeness < 0 {
+		closeness = 0
+	}
+	if closeness > constant.PetClosenessMax {
+		closeness = constant.PetClosenessMax
+	}
+	p.closeness = int16(closeness)
+
+	level := petLevelFromCloseness(p.closeness)
+	if level > p.level && p.spawned {
+		plr.Send(packetPlayerEffectPetLevelUp())
+		plr.inst.sendExcept(packetPlayerPetLevelUpAnimation(plr.ID), plr.Conn)
+	}
+	p.level = level
+
+	plr.updatePet(p)
+}
+
+// feed the pet food restoring inc fullness, it reports whether the pet was hungry. Feeding a full pet costs closeness.
+func (p *pet) feed(plr *Player, inc int) bool {
+	if p.fullness >= constant.PetFullnessMax {
+		p.changeCloseness(plr, -constant.PetNeglectCloseness)
+		return false
+	}
+
+	fullness := int(p.fullness) + inc
+	if fullness > constant.PetFullnessMax {
+		fullness = constant.PetFullnessMax
+	}
+	p.fullness = byte(fullness)
+
+	p.changeCloseness(plr, constant.PetFoodCloseness)
+
+	return true
+}
+
+// hunger makes the pet hungrier by its hungry value, a starving pet goes home
+func (p *pet) hunger(plr *Player) {
+	dec := 1
+	if itm, err := nx.GetItem(p.itemID); err == nil && itm.Hungry > 0 {
+		dec = int(itm.Hungry)
+	}
+
+	fullness := int(p.fullness) - dec
+	if fullness <= constant.PetStarvingFullness {
+		p.fullness = constant.PetStarvedFullness
+		plr.despawnPet(constant.PetRemoveHungry)
+		return
+	}
+
+	p.fullness = byte(fullness)
+	plr.updatePet(p)
+}
+
+// dead reports whether the pet's life has run out, it cannot be summoned until it is revived with Water of Life
+func (p *pet) dead() bool {
+	return p.deadDate != 0 && p.deadDate <= fileTime(time.Now())
+}
+
+// revive a dead pet for another constant.PetLifeDays
+func (p *pet) revive() {
+	p.deadDate = fileTime(time.Now().AddDate(0, 0, constant.PetLifeDays))
+}
+
+func schedulePetHunger(server *Server) {
+	ticker := time.NewTicker(constant.PetHungerTickSecs * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		server.dispatch <- func() {
+			server.players.observe(func(plr *Player) {
+				if plr.pet != nil {
+					plr.pet.hunger(plr)
+				}
+			})
+		}
+	}
+}
+
 var thresholds = []int16{0, 1, 100, 300, 600, 1000, 1800, 3100, 5000, 8000, 12000, 17000, 22000, 28000}
 
 func petLevelFromCloseness(c int16) byte {
@@ -191,6 +441,21 @@ func packetPetSpawn(charID int32, petData *pet) mpacket.Packet {
 	return p
 }
 
+func packetPlayerEffectPetLevelUp() mpacket.Packet {
+	p := mpacket.CreateWithOpcode(opcode.SendChannelPlayerEffect)
+	p.WriteByte(constant.PlayerEffectPet)
+	p.WriteByte(constant.PetEffectLevelUp)
+	return p
+}
+
+func packetPlayerPetLevelUpAnimation(charID int32) mpacket.Packet {
+	p := mpacket.CreateWithOpcode(opcode.SendChannelPlayerAnimation)
+	p.WriteInt32(charID)
+	p.WriteByte(constant.PlayerEffectPet)
+	p.WriteByte(constant.PetEffectLevelUp)
+	return p
+}
+
 func packetPetRemove(charID int32, reason byte) mpacket.Packet {
 	p := mpacket.CreateWithOpcode(opcode.SendChannelPetSpawn)
 	p.WriteInt32(charID)
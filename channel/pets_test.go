@@ -0,0 +1,205 @@
+package channel
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/mpacket"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+func TestPetPacketsHaveNoSlot(t *testing.T) {
+	pt := &pet{itemID: 5000000, name: "Brown Kitty", sn: 7, stance: 2, pos: pos{x: 10, y: -20, foothold: 3}}
+
+	want := mpacket.NewPacket()
+	want.WriteInt32(1)
+	want.WriteBool(true)
+	want.WriteInt32(pt.itemID)
+	want.WriteString(pt.name)
+	want.WriteUint64(uint64(pt.sn))
+	want.WriteInt16(pt.pos.x)
+	want.WriteInt16(pt.pos.y)
+	want.WriteByte(pt.stance)
+	want.WriteInt16(pt.pos.foothold)
+
+	// Skip the length and opcode written by CreateWithOpcode
+	if got := packetPetSpawn(1, pt)[5:]; !bytes.Equal(got, want) {
+		t.Errorf("packetPetSpawn() = % x, want % x", got, want)
+	}
+
+	want = mpacket.NewPacket()
+	want.WriteInt32(1)
+	want.WriteBool(false)
+	want.WriteByte(constant.PetRemoveHungry)
+
+	if got := packetPetRemove(1, constant.PetRemoveHungry)[5:]; !bytes.Equal(got, want) {
+		t.Errorf("packetPetRemove() = % x, want % x", got, want)
+	}
+}
+
+func TestPetCashID(t *testing.T) {
+	plr := &Player{}
+	if plr.petCashID() != 0 {
+		t.Fatalf("petCashID() = %d without a pet out, want 0", plr.petCashID())
+	}
+
+	plr.pet = &pet{sn: 7}
+	if plr.petCashID() != 7 {
+		t.Errorf("petCashID() = %d, want 7", plr.petCashID())
+	}
+}
+
+func TestPetCanTakeDrop(t *testing.T) {
+	plr := &Player{equip: []Item{{ID: constant.ItemMesoMagnet, amount: 1, slotID: petEquipSlotID(0)}}}
+	mesos := fieldDrop{mesos: 10}
+
+	if plr.petCanTakeDrop(mesos) {
+		t.Error("petCanTakeDrop() = true without a pet out")
+	}
+
+	plr.pet = &pet{}
+
+	if !plr.petCanTakeDrop(mesos) {
+		t.Error("petCanTakeDrop() = false for mesos with a meso magnet worn")
+	}
+
+	if plr.petCanTakeDrop(fieldDrop{item: Item{ID: 2000000}}) {
+		t.Error("petCanTakeDrop() = true for an item without an item pouch worn")
+	}
+}
+
+func TestPetEquipSlot(t *testing.T) {
+	for equipSlot := 0; equipSlot < constant.PetEquipSlotCount; equipSlot++ {
+		slotID := petEquipSlotID(equipSlot)
+
+		if got, ok := petEquipSlot(slotID); !ok || got != equipSlot {
+			t.Errorf("petEquipSlot(%d) = %d, %v, want %d, true", slotID, got, ok, equipSlot)
+		}
+	}
+
+	last := int16(constant.PetEquipSlotFirst - constant.PetEquipSlotCount)
+	for _, slotID := range []int16{-1, 1, constant.PetEquipSlotFirst + 1, last} {
+		if _, ok := petEquipSlot(slotID); ok {
+			t.Errorf("petEquipSlot(%d) is a pet equip slot", slotID)
+		}
+	}
+}
+
+func TestFileTime(t *testing.T) {
+	epoch := time.Unix(0, 0)
+
+	if got := fileTime(epoch); got != 116444592000000000 {
+		t.Errorf("fileTime(epoch) = %d, want 116444592000000000", got)
+	}
+
+	// FILETIME counts 100 nanosecond intervals
+	if got := fileTime(epoch.Add(time.Millisecond)) - fileTime(epoch); got != 10000 {
+		t.Errorf("a millisecond is %d FILETIME intervals, want 10000", got)
+	}
+}
+
+func TestItemExpired(t *testing.T) {
+	past := fileTime(time.Now().Add(-time.Minute))
+	future := fileTime(time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name string
+		item Item
+		want bool
+	}{
+		{"no expiry", Item{}, false},
+		{"never expires", Item{expireTime: neverExpire}, false},
+		{"ran out", Item{expireTime: past}, true},
+		{"still going", Item{expireTime: future}, false},
+		{"pet", Item{expireTime: past, pet: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.Expired(); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPetDead(t *testing.T) {
+	pt := &pet{}
+	if pt.dead() {
+		t.Error("dead() = true for a pet without a life span")
+	}
+
+	pt.deadDate = fileTime(time.Now().Add(-time.Minute))
+	if !pt.dead() {
+		t.Error("dead() = false for a pet past its life span")
+	}
+
+	pt.revive()
+	if pt.dead() {
+		t.Error("dead() = true for a revived pet")
+	}
+}
+
+func TestTakePetEquips(t *testing.T) {
+	saved := common.Repo
+	common.Repo = repository.NewMemory().Repositories()
+	t.Cleanup(func() { common.Repo = saved })
+
+	pt := &pet{itemDBID: 1}
+	pt.equips[0] = Item{dbID: 10}
+	pt.equips[1] = Item{dbID: 11} // gone since the pet last wore it
+
+	worn := Item{dbID: 10, ID: constant.ItemMesoMagnet, invID: constant.InventoryEquip, slotID: petEquipSlotID(0)}
+	orphan := Item{dbID: 12, ID: constant.ItemItemPouch, invID: constant.InventoryEquip, slotID: petEquipSlotID(2)}
+	hat := Item{dbID: 13, ID: 1002140, invID: constant.InventoryEquip, slotID: -1}
+
+	plr := &Player{
+		equipSlotSize: 24,
+		equip:         []Item{worn, orphan, hat},
+		cash:          []Item{{dbID: 1, pet: true, petData: pt}},
+	}
+
+	plr.takePetEquips()
+
+	if pt.equips[0].ID != constant.ItemMesoMagnet || pt.equips[1].dbID != 0 || !pt.equipsDirty {
+		t.Errorf("pet equips = %v, %v, dirty %v, want the magnet and the missing item cleared", pt.equips[0].ID, pt.equips[1].dbID, pt.equipsDirty)
+	}
+
+	if len(plr.equip) != 2 {
+		t.Fatalf("equip inventory has %d items, want the hat and the pouch", len(plr.equip))
+	}
+
+	for _, item := range plr.equip {
+		if item.dbID == orphan.dbID && item.slotID != 1 {
+			t.Errorf("unworn pet equipment moved to slot %d, want 1", item.slotID)
+		}
+	}
+
+	snap := snapshotFromPlayer(plr)
+	if got := snap.PetEquips[1]; len(got) != 1 || got[0] != 10 {
+		t.Errorf("snapshot pet equips = %v, want the magnet in slot 0", got)
+	}
+
+	plr.petEquipsQueued(snap)
+	if pt.equipsDirty || snapshotFromPlayer(plr).PetEquips != nil {
+		t.Error("pet equipment queued to be saved is still marked as changed")
+	}
+}
+
+func TestSnapshotCopiesPets(t *testing.T) {
+	pt := &pet{itemDBID: 1, fullness: 100, closeness: 5}
+	plr := &Player{cash: []Item{{dbID: 1, pet: true, petData: pt}}}
+
+	snap := snapshotFromPlayer(plr)
+
+	// The hunger tick and feeding change the pet on the game thread whilst the saver writes the snapshot
+	pt.fullness = 40
+	pt.closeness = 6
+
+	if len(snap.Pets) != 1 || snap.Pets[0].ItemID != 1 || snap.Pets[0].Fullness != 100 || snap.Pets[0].Closeness != 5 {
+		t.Errorf("snapshot pets = %+v, want the pet as it was when the snapshot was taken", snap.Pets)
+	}
+}
@@ -0,0 +1,54 @@
+package channel
+
+import (
+	"log"
+	"time"
+
+	"github.com/Hucaru/Valhalla/constant"
+)
+
+// pickupAllowed checks a drop the player is picking up by hand, from the position their client reported, is within
+// reach and that they are not picking up drops faster than a player can. Anything else is reported to the anti-cheat
+// and the pickup is only refused if its policy removed the player.
+func (server *Server) pickupAllowed(plr *Player, reported pos, drop fieldDrop) bool {
+	dx, dy := plr.pos.offset(reported)
+
+	if ex, ey := plr.pos.offset(drop.finalPos); ex+ey > dx+dy {
+		dx, dy = ex, ey
+	}
+
+	if dx > constant.PickupRangeX || dy > constant.PickupRangeY {
+		log.Printf("Player: %s tried to pickup an Item from far away", plr.Name)
+
+		return server.ac == nil || !server.ac.LogPickupRangeViolation(plr.accountID, drop.ID, dx, dy, false)
+	}
+
+	window := time.Duration(constant.PickupRateWindowMs) * time.Millisecond
+
+	if server.ac != nil && server.ac.Track(plr.accountID, "pickup", constant.PickupRateLimit+1, window) {
+		log.Printf("Player: %s is picking up items too quickly", plr.Name)
+
+		return !server.ac.LogPickupRateViolation(plr.accountID, constant.PickupRateLimit, window)
+	}
+
+	return true
+}
+
+// petPickupAllowed checks a drop the player's pet is looting is within its reach, which a long range pet
+// equip doubles. Like pickupAllowed the pickup is only refused if the anti-cheat removed the player.
+func (server *Server) petPickupAllowed(plr *Player, pt *pet, drop fieldDrop) bool {
+	dx, dy := pt.pos.offset(drop.finalPos)
+
+	rangeX, rangeY := constant.PetPickupRangeX, constant.PetPickupRangeY
+	if pt.abilities()&petLongRange != 0 {
+		rangeX, rangeY = rangeX*2, rangeY*2
+	}
+
+	if dx > rangeX || dy > rangeY {
+		log.Printf("Player: %s pet tried to pickup an item from far away", plr.Name)
+
+		return server.ac == nil || !server.ac.LogPickupRangeViolation(plr.accountID, drop.ID, dx, dy, true)
+	}
+
+	return true
+}
@@ -0,0 +1,31 @@
+package channel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hucaru/Valhalla/anticheat"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+func TestPickupAllowed(t *testing.T) {
+	server := &Server{ac: anticheat.New(repository.NewMemory().Repositories(), nil)}
+	plr := &Player{accountID: 1}
+	far := fieldDrop{ID: 1, finalPos: pos{x: 1000}}
+
+	if !server.pickupAllowed(plr, plr.pos, far) {
+		t.Error("pickupAllowed() refused a far pickup under a log only policy")
+	}
+
+	kicked := false
+	server.ac.SetOnKick(func(int32) { kicked = true })
+	server.ac.SetPolicy(anticheat.ViolationPickupRange, anticheat.Policy{Threshold: 1, Window: time.Minute, Action: anticheat.ActionKick})
+
+	if server.pickupAllowed(plr, plr.pos, far) || !kicked {
+		t.Error("pickupAllowed() allowed a far pickup that got the player kicked")
+	}
+
+	if !server.pickupAllowed(plr, plr.pos, fieldDrop{ID: 2}) {
+		t.Error("pickupAllowed() refused a pickup within reach")
+	}
+}
@@ -2,7 +2,6 @@ package channel
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -20,6 +19,7 @@ import (
 	"github.com/Hucaru/Valhalla/mnet"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 type buddy struct {
@@ -60,20 +60,14 @@ func createPlayerSkillFromData(ID int32, level byte) (playerSkill, error) {
 func getSkillsFromCharID(id int32) []playerSkill {
 	skills := []playerSkill{}
 
-	const filter = "skillID, level, cooldown"
-	row, err := common.DB.Query("SELECT "+filter+" FROM skills WHERE characterID=?", id)
+	rows, err := common.Repo.Skills.ByCharacter(id)
 	if err != nil {
 		log.Printf("getSkillsFromCharID: query failed for character %d: %v", id, err)
 		return skills
 	}
-	defer row.Close()
 
-	for row.Next() {
-		var ps playerSkill
-		if err := row.Scan(&ps.ID, &ps.Level, &ps.Cooldown); err != nil {
-			log.Printf("getSkillsFromCharID: scan failed for character %d: %v", id, err)
-			continue
-		}
+	for _, row := range rows {
+		ps := playerSkill{ID: row.ID, Level: row.Level, Cooldown: row.Cooldown}
 
 		skillData, err := nx.GetPlayerSkill(ps.ID)
 		if err != nil {
@@ -89,10 +83,6 @@ func getSkillsFromCharID(id int32) []playerSkill {
 		skills = append(skills, ps)
 	}
 
-	if err := row.Err(); err != nil {
-		log.Printf("getSkillsFromCharID: rows error for character %d: %v", id, err)
-	}
-
 	return skills
 }
 
@@ -161,6 +151,16 @@ func (p Players) GetFromName(name string) (*Player, error) {
 	return nil, fmt.Errorf("Player not found for Name: %s", name)
 }
 
+func (p Players) GetFromAccountID(accountID int32) (*Player, error) {
+	for _, v := range p.conn {
+		if v.accountID == accountID {
+			return v, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Player not found for account ID: %d", accountID)
+}
+
 func (p *Players) RemoveFromConn(conn mnet.Client) error {
 	if plr, ok := p.conn[conn]; ok {
 		delete(p.id, plr.ID)
@@ -224,15 +224,14 @@ type Player struct {
 	totalMatk     int16
 	totalAccuracy int16
 
-	Name      string
-	gender    byte
-	skin      byte
-	face      int32
-	hair      int32
-	chairID   int32
-	petCashID int64
-	stance    byte
-	pos       pos
+	Name    string
+	gender  byte
+	skin    byte
+	face    int32
+	hair    int32
+	chairID int32
+	stance  byte
+	pos     pos
 
 	equipSlotSize byte
 	useSlotSize   byte
@@ -251,6 +250,7 @@ type Player struct {
 	maplepoints int32
 
 	storageInventory *storage
+	expiredItems     []int32 // time-limited items that ran out while the player was away, told about once in game
 
 	skills map[int32]playerSkill
 
@@ -269,14 +269,15 @@ type Player struct {
 
 	UpdatePartyInfo updatePartyInfoFunc
 
-	rates *rates
+	rates      *rates
+	petPotions *petPotions
 
 	buffs *CharacterBuffs
 
 	quests quests
 
 	summons *summonState
-	pet     *pet
+	pet     *pet // the spawned pet, nil while it is home
 
 	// Mystic Door tracking
 	doorMapID       int32
@@ -297,7 +298,15 @@ type Player struct {
 	// Safety charm flag - prevents exp loss on death
 	hasSafetyCharm bool
 
+	// Last time any megaphone was used, for the shared cooldown
+	lastMegaphone time.Time
+
+	// Mute on the account, nil if there is none. It may have run out since it was loaded.
+	mute *repository.Mute
+	jail *repository.Jail
+
 	event *event
+	raid  *raid // boss raid the player has signed up to or is taking part in
 }
 
 // Helper: mark dirty and schedule debounced save.
@@ -681,6 +690,10 @@ func (d *Player) GetAccountName() string {
 	return d.accountName
 }
 
+func (d *Player) GetWorldID() byte {
+	return d.worldID
+}
+
 func (d *Player) setLevel(amount byte) {
 	d.level = amount
 	d.Send(packetPlayerStatChange(false, constant.LevelID, int32(amount)))
@@ -918,8 +931,7 @@ func (d *Player) setFame(amount int16) {
 	d.fame = amount
 	d.Send(packetPlayerStatChange(true, constant.FameID, int32(amount)))
 
-	_, err := common.DB.Exec("UPDATE characters SET fame=? WHERE ID=?", d.fame, d.ID)
-	if err != nil {
+	if err := common.Repo.Characters.Update(d.characterData(), repository.CharacterFame); err != nil {
 		log.Printf("setFame: failed to save fame for character %d: %v", d.ID, err)
 	}
 }
@@ -940,33 +952,25 @@ func (d *Player) takeMesos(amount int32) {
 }
 
 func (d *Player) saveMesos() error {
-	query := "UPDATE characters SET mesos=? WHERE accountID=? and Name=?"
-	_, err := common.DB.Exec(query, d.mesos, d.accountID, d.Name)
-	return err
+	return common.Repo.Characters.Update(d.characterData(), repository.CharacterMesos)
 }
 
 func (d *Player) setHair(id int32) error {
-	query := "UPDATE characters SET hair=? WHERE ID=?"
-	_, err := common.DB.Exec(query, id, d.ID)
 	d.hair = id
 	d.Send(packetPlayerStatChange(true, constant.HairID, id))
-	return err
+	return common.Repo.Characters.Update(d.characterData(), repository.CharacterHair)
 }
 
 func (d *Player) setFace(id int32) error {
-	query := "UPDATE characters SET face=? WHERE ID=?"
-	_, err := common.DB.Exec(query, id, d.ID)
 	d.face = id
 	d.Send(packetPlayerStatChange(true, constant.FaceID, id))
-	return err
+	return common.Repo.Characters.Update(d.characterData(), repository.CharacterFace)
 }
 
 func (d *Player) setSkin(id byte) error {
-	query := "UPDATE characters SET skin=? WHERE ID=?"
-	_, err := common.DB.Exec(query, id, d.ID)
 	d.skin = id
 	d.Send(packetPlayerStatChange(true, constant.SkinID, int32(id)))
-	return err
+	return common.Repo.Characters.Update(d.characterData(), repository.CharacterSkin)
 }
 
 // UpdateMovement - update Data from position data
@@ -1045,6 +1049,14 @@ func (d *Player) SetMaplePoints(points int32) {
 	d.MarkDirty(DirtyMaplePoints, 300*time.Millisecond)
 }
 
+func (d *Player) GetMesos() int32 {
+	return d.mesos
+}
+
+func (d *Player) GiveMesos(amount int32) {
+	d.giveMesos(amount)
+}
+
 // addStackableItemToInventory handles adding stackable items to a specific inventory type
 // It merges with existing stacks when possible and creates new slots as needed
 // Note: If inventory becomes full mid-operation, already-added items remain (potential partial reward issue)
@@ -1120,6 +1132,30 @@ func (d *Player) addStackableItemToInventory(newItem Item, items *[]Item, slotSi
 	return nil
 }
 
+// findFirstEmptySlot is the first inventory slot no item is in
+func findFirstEmptySlot(items []Item, size byte) (int16, error) {
+	slotsUsed := make([]bool, size)
+	for _, v := range items {
+		if v.slotID > 0 {
+			slotsUsed[v.slotID-1] = true
+		}
+	}
+	slot := 0
+	for i, v := range slotsUsed {
+		if !v {
+			slot = i + 1
+			break
+		}
+	}
+	if slot == 0 {
+		slot = len(slotsUsed) + 1
+	}
+	if byte(slot) > size {
+		return 0, fmt.Errorf("No empty Item slot left")
+	}
+	return int16(slot), nil
+}
+
 // GiveItem grants the given item to a player and returns the item
 func (d *Player) GiveItem(newItem Item) (error, Item) { // TODO: Refactor
 	isRechargeable := func(itemID int32) bool {
@@ -1129,29 +1165,6 @@ func (d *Player) GiveItem(newItem Item) (error, Item) { // TODO: Refactor
 
 	newItem.dbID = 0
 
-	findFirstEmptySlot := func(items []Item, size byte) (int16, error) {
-		slotsUsed := make([]bool, size)
-		for _, v := range items {
-			if v.slotID > 0 {
-				slotsUsed[v.slotID-1] = true
-			}
-		}
-		slot := 0
-		for i, v := range slotsUsed {
-			if !v {
-				slot = i + 1
-				break
-			}
-		}
-		if slot == 0 {
-			slot = len(slotsUsed) + 1
-		}
-		if byte(slot) > size {
-			return 0, fmt.Errorf("No empty Item slot left")
-		}
-		return int16(slot), nil
-	}
-
 	switch newItem.invID {
 	case constant.InventoryEquip: // Equip
 		slotID, err := findFirstEmptySlot(d.equip, d.equipSlotSize)
@@ -1419,6 +1432,7 @@ func (d *Player) removeItem(item Item, fromStorage bool) {
 				break
 			}
 		}
+		d.unequipPet(item.slotID)
 	case constant.InventoryUse:
 		for i, v := range d.use {
 			if v.dbID == item.dbID {
@@ -1481,6 +1495,10 @@ func (d *Player) moveItem(start, end, amount int16, invID byte) error {
 		return base == 207
 	}
 
+	if end >= 0 {
+		d.unequipPet(start)
+	}
+
 	if end == 0 { // drop item
 		item, err := d.getItem(invID, start)
 		if err != nil {
@@ -1535,11 +1553,18 @@ func (d *Player) moveItem(start, end, amount int16, invID byte) error {
 			}
 		}
 
+		if _, ok := petEquipSlot(end); ok && !d.equipPet(item1, end) {
+			d.Send(packetInventoryNoChange())
+			return nil
+		}
+		d.unequipPet(start)
+
 		item2, err := d.getItem(invID, end)
 		if err == nil {
 			item2.slotID = start
 			item2.save(d.ID)
 			d.updateItem(item2)
+			d.equipPet(item2, start)
 		}
 
 		item1.slotID = end
@@ -1629,6 +1654,7 @@ func (d *Player) useSkill(id int32, level byte, projectileID int32) error {
 	// Resource costs
 	if si.MpCon > 0 {
 		d.giveMP(-int16(si.MpCon))
+		d.petAutoPotion(petConsumeMP)
 	}
 	if si.HpCon > 0 {
 		d.giveHP(-int16(si.HpCon))
@@ -1758,12 +1784,60 @@ func (d *Player) Kick() {
 	_ = d.Conn.Close()
 }
 
+// characterData is the player's row as the repository stores it
+func (d *Player) characterData() repository.CharacterData {
+	return repository.CharacterData{
+		Character: repository.Character{
+			ID:            d.ID,
+			AccountID:     d.accountID,
+			WorldID:       d.worldID,
+			Name:          d.Name,
+			Gender:        d.gender,
+			Skin:          d.skin,
+			Hair:          d.hair,
+			Face:          d.face,
+			Level:         d.level,
+			Job:           d.job,
+			MapID:         d.mapID,
+			BuddyListSize: d.buddyListSize,
+		},
+		Str:              d.str,
+		Dex:              d.dex,
+		Intt:             d.intt,
+		Luk:              d.luk,
+		HP:               d.hp,
+		MaxHP:            d.maxHP,
+		MP:               d.mp,
+		MaxMP:            d.maxMP,
+		AP:               d.ap,
+		SP:               d.sp,
+		Exp:              d.exp,
+		Fame:             d.fame,
+		MapPos:           d.mapPos,
+		PreviousMapID:    d.previousMap,
+		Mesos:            d.mesos,
+		EquipSlotSize:    d.equipSlotSize,
+		UseSlotSize:      d.useSlotSize,
+		SetupSlotSize:    d.setupSlotSize,
+		EtcSlotSize:      d.etcSlotSize,
+		CashSlotSize:     d.cashSlotSize,
+		MiniGameWins:     d.miniGameWins,
+		MiniGameDraw:     d.miniGameDraw,
+		MiniGameLoss:     d.miniGameLoss,
+		MiniGamePoints:   d.miniGamePoints,
+		RegTeleportRocks: serializeTeleportRocks(d.regTeleportRocks),
+		VIPTeleportRocks: serializeTeleportRocks(d.vipTeleportRocks),
+	}
+}
+
 // Save data - this needs to be split to occur at relevant points in time
 func (d Player) save() error {
-	query := `UPDATE characters set skin=?, hair=?, face=?, level=?,
-	job=?, str=?, dex=?, intt=?, luk=?, hp=?, maxHP=?, mp=?, maxMP=?,
-	ap=?, sp=?, exp=?, fame=?, mapID=?, mapPos=?, mesos=?, miniGameWins=?,
-	miniGameDraw=?, miniGameLoss=?, miniGamePoints=?, buddyListSize=? WHERE ID=?`
+	const fields = repository.CharacterSkin | repository.CharacterHair | repository.CharacterFace |
+		repository.CharacterLevel | repository.CharacterJob | repository.CharacterStr | repository.CharacterDex |
+		repository.CharacterInt | repository.CharacterLuk | repository.CharacterHP | repository.CharacterMaxHP |
+		repository.CharacterMP | repository.CharacterMaxMP | repository.CharacterAP | repository.CharacterSP |
+		repository.CharacterExp | repository.CharacterFame | repository.CharacterMap | repository.CharacterMesos |
+		repository.CharacterMiniGame | repository.CharacterBuddyListSize
 
 	var mapPos byte
 	var err error
@@ -1783,19 +1857,12 @@ func (d Player) save() error {
 
 	d.mapPos = mapPos
 
-	_, err = common.DB.Exec(query,
-		d.skin, d.hair, d.face, d.level, d.job, d.str, d.dex, d.intt, d.luk, d.hp, d.maxHP, d.mp,
-		d.maxMP, d.ap, d.sp, d.exp, d.fame, d.mapID, d.mapPos, d.mesos, d.miniGameWins,
-		d.miniGameDraw, d.miniGameLoss, d.miniGamePoints, d.buddyListSize, d.ID)
-	if err != nil {
+	if err := common.Repo.Characters.Update(d.characterData(), fields); err != nil {
 		return err
 	}
 
-	query = `INSERT INTO skills(characterID,skillID,level,cooldown)
-	         VALUES(?,?,?,?)
-	         ON DUPLICATE KEY UPDATE level=VALUES(level), cooldown=VALUES(cooldown)`
 	for skillID, skill := range d.skills {
-		if _, err := common.DB.Exec(query, d.ID, skillID, skill.Level, skill.Cooldown); err != nil {
+		if err := common.Repo.Skills.Save(d.ID, repository.Skill{ID: skillID, Level: skill.Level, Cooldown: skill.Cooldown}); err != nil {
 			return err
 		}
 	}
@@ -1830,6 +1897,7 @@ func (d *Player) damagePlayer(damage int16) {
 	}
 
 	d.setHP(newHP)
+	d.petAutoPotion(petConsumeHP)
 }
 
 func (d *Player) setInventorySlotSizes(equip, use, setup, etc, cash byte) {
@@ -2033,29 +2101,27 @@ func (d *Player) findEquipBySlot(slot int16) *Item {
 
 func LoadPlayerFromID(id int32, conn mnet.Client) Player {
 	c := Player{}
-	filter := "ID,accountID,worldID,Name,gender,skin,hair,face,level,job,str,dex,intt," +
-		"luk,hp,maxHP,mp,maxMP,ap,sp, exp,fame,mapID,mapPos,previousMapID,mesos," +
-		"equipSlotSize,useSlotSize,setupSlotSize,etcSlotSize,cashSlotSize,miniGameWins," +
-		"miniGameDraw,miniGameLoss,miniGamePoints,buddyListSize,regTeleportRocks,vipTeleportRocks"
-
-	var regTeleportRocksStr, vipTeleportRocksStr sql.NullString
-	err := common.DB.QueryRow("SELECT "+filter+" FROM characters where ID=?", id).Scan(&c.ID,
-		&c.accountID, &c.worldID, &c.Name, &c.gender, &c.skin, &c.hair, &c.face,
-		&c.level, &c.job, &c.str, &c.dex, &c.intt, &c.luk, &c.hp, &c.maxHP, &c.mp,
-		&c.maxMP, &c.ap, &c.sp, &c.exp, &c.fame, &c.mapID, &c.mapPos,
-		&c.previousMap, &c.mesos, &c.equipSlotSize, &c.useSlotSize, &c.setupSlotSize,
-		&c.etcSlotSize, &c.cashSlotSize, &c.miniGameWins, &c.miniGameDraw, &c.miniGameLoss,
-		&c.miniGamePoints, &c.buddyListSize, &regTeleportRocksStr, &vipTeleportRocksStr)
 
+	row, err := common.Repo.Characters.Load(id)
 	if err != nil {
 		log.Println(err)
 		return c
 	}
 
-	c.petCashID = 0
+	c.ID, c.accountID, c.worldID, c.Name, c.gender = row.ID, row.AccountID, row.WorldID, row.Name, row.Gender
+	c.skin, c.hair, c.face, c.level, c.job = row.Skin, row.Hair, row.Face, row.Level, row.Job
+	c.str, c.dex, c.intt, c.luk = row.Str, row.Dex, row.Intt, row.Luk
+	c.hp, c.maxHP, c.mp, c.maxMP, c.ap, c.sp, c.exp, c.fame = row.HP, row.MaxHP, row.MP, row.MaxMP, row.AP, row.SP, row.Exp, row.Fame
+	c.mapID, c.mapPos, c.previousMap, c.mesos = row.MapID, row.MapPos, row.PreviousMapID, row.Mesos
+	c.equipSlotSize, c.useSlotSize, c.setupSlotSize = row.EquipSlotSize, row.UseSlotSize, row.SetupSlotSize
+	c.etcSlotSize, c.cashSlotSize = row.EtcSlotSize, row.CashSlotSize
+	c.miniGameWins, c.miniGameDraw, c.miniGameLoss, c.miniGamePoints = row.MiniGameWins, row.MiniGameDraw, row.MiniGameLoss, row.MiniGamePoints
+	c.buddyListSize = row.BuddyListSize
 
-	if err := common.DB.QueryRow("SELECT username, nx, maplepoints FROM accounts WHERE accountID=?", c.accountID).Scan(&c.accountName, &c.nx, &c.maplepoints); err != nil {
+	if account, err := common.Repo.Accounts.ByID(c.accountID); err != nil {
 		log.Printf("loadPlayerFromID: failed to fetch accountName for accountID=%d: %v", c.accountID, err)
+	} else {
+		c.accountName, c.nx, c.maplepoints = account.Username, account.NX, account.MaplePoints
 	}
 
 	c.skills = make(map[int32]playerSkill)
@@ -2081,7 +2147,7 @@ func LoadPlayerFromID(id int32, conn mnet.Client) Player {
 	if int(c.mapPos) < 0 || int(c.mapPos) >= len(nxMap.Portals) {
 		c.mapPos = 0
 
-		if _, healErr := common.DB.Exec("UPDATE characters SET mapPos=? WHERE ID=?", c.mapPos, c.ID); healErr != nil {
+		if healErr := common.Repo.Characters.Update(c.characterData(), repository.CharacterMap); healErr != nil {
 			log.Printf("LoadPlayerFromID: failed to heal mapPos in DB for char %d: %v", c.ID, healErr)
 		}
 	}
@@ -2090,23 +2156,17 @@ func LoadPlayerFromID(id int32, conn mnet.Client) Player {
 	c.pos.y = nxMap.Portals[c.mapPos].Y
 
 	c.equip, c.use, c.setUp, c.etc, c.cash = loadInventoryFromDb(c.ID)
+	c.expiredItems, _ = c.removeExpiredItems()
+	c.takePetEquips()
 
 	// Calculate total stats including equipment bonuses
 	c.RecalculateTotalStats()
 
 	c.buddyList = getBuddyList(c.ID, c.buddyListSize)
 
-	// Initialize teleport rocks - handle NULL values from database
-	regRocksStr := ""
-	if regTeleportRocksStr.Valid {
-		regRocksStr = regTeleportRocksStr.String
-	}
-	vipRocksStr := ""
-	if vipTeleportRocksStr.Valid {
-		vipRocksStr = vipTeleportRocksStr.String
-	}
-	c.regTeleportRocks = parseTeleportRocks(regRocksStr, constant.TeleportRockRegSlots)
-	c.vipTeleportRocks = parseTeleportRocks(vipRocksStr, constant.TeleportRockVIPSlots)
+	// NULL rock columns load as empty strings
+	c.regTeleportRocks = parseTeleportRocks(row.RegTeleportRocks, constant.TeleportRockRegSlots)
+	c.vipTeleportRocks = parseTeleportRocks(row.VIPTeleportRocks, constant.TeleportRockVIPSlots)
 
 	c.quests = loadQuestsFromDB(c.ID)
 	c.quests.init()
@@ -2121,6 +2181,8 @@ func LoadPlayerFromID(id int32, conn mnet.Client) Player {
 		log.Printf("loadPlayerFromID: failed to load storage inventory for accountID=%d: %v", c.accountID, err)
 	}
 
+	c.expiredItems = append(c.expiredItems, c.storageInventory.removeExpired(c.accountID)...)
+
 	c.Conn = conn
 
 	return c
@@ -2128,32 +2190,21 @@ func LoadPlayerFromID(id int32, conn mnet.Client) Player {
 
 func getBuddyList(playerID int32, buddySize byte) []buddy {
 	buddies := make([]buddy, 0, buddySize)
-	filter := "friendID,accepted"
-	rows, err := common.DB.Query("SELECT "+filter+" FROM buddy where characterID=?", playerID)
+	rows, err := common.Repo.Buddies.ByCharacter(playerID)
 
 	if err != nil {
 		log.Fatal(err)
 		return buddies
 	}
 
-	defer rows.Close()
-
-	i := 0
-	for rows.Next() {
-		newBuddy := buddy{}
+	for _, row := range rows {
+		newBuddy := buddy{id: row.FriendID, name: row.Name, channelID: row.ChannelID}
 
-		var accepted bool
-		rows.Scan(&newBuddy.id, &accepted)
-
-		filter := "channelID,Name,inCashShop"
-		err := common.DB.QueryRow("SELECT "+filter+" FROM characters where ID=?", newBuddy.id).Scan(&newBuddy.channelID, &newBuddy.name, &newBuddy.cashShop)
-
-		if err != nil {
-			log.Fatal(err)
-			return buddies
+		if row.InCashShop {
+			newBuddy.cashShop = 1
 		}
 
-		if !accepted {
+		if !row.Accepted {
 			newBuddy.status = 1 // pending buddy request
 		} else if newBuddy.channelID == -1 {
 			newBuddy.status = 2 // offline
@@ -2162,8 +2213,6 @@ func getBuddyList(playerID int32, buddySize byte) []buddy {
 		}
 
 		buddies = append(buddies, newBuddy)
-
-		i++
 	}
 
 	return buddies
@@ -2242,55 +2291,33 @@ func (d *Player) saveBuffSnapshot() {
 
 	snaps := d.buffs.Snapshot()
 	if len(snaps) == 0 {
-		_, _ = common.DB.Exec("DELETE FROM character_buffs WHERE characterID=?", d.ID)
+		_ = common.Repo.Buffs.Delete(d.ID)
 		return
 	}
 
-	tx, err := common.DB.Begin()
-	if err != nil {
-		log.Println("saveBuffSnapshot: begin tx:", err)
-		return
-	}
-	defer func() { _ = tx.Commit() }()
-
-	if _, err := tx.Exec("DELETE FROM character_buffs WHERE characterID=?", d.ID); err != nil {
-		log.Println("saveBuffSnapshot: clear rows:", err)
-		return
-	}
-
-	stmt, err := tx.Prepare("INSERT INTO character_buffs(characterID, sourceID, level, expiresAtMs) VALUES(?,?,?,?)")
-	if err != nil {
-		log.Println("saveBuffSnapshot: prepare:", err)
-		return
+	buffs := make([]repository.Buff, 0, len(snaps))
+	for _, s := range snaps {
+		buffs = append(buffs, repository.Buff{SourceID: s.SourceID, Level: s.Level, ExpiresAtMs: s.ExpiresAtMs})
 	}
-	defer stmt.Close()
 
-	for _, s := range snaps {
-		if _, err := stmt.Exec(d.ID, s.SourceID, s.Level, s.ExpiresAtMs); err != nil {
-			log.Println("saveBuffSnapshot: insert:", err)
-			return
-		}
+	if err := common.Repo.Buffs.Save(d.ID, buffs); err != nil {
+		log.Println("saveBuffSnapshot:", err)
 	}
 }
 
 func (d *Player) loadAndApplyBuffSnapshot() {
-	rows, err := common.DB.Query("SELECT sourceID, level, expiresAtMs FROM character_buffs WHERE characterID=?", d.ID)
+	saved, err := common.Repo.Buffs.ByCharacter(d.ID)
 	if err != nil {
 		log.Println("loadBuffSnapshot:", err)
 		return
 	}
-	defer rows.Close()
 
-	snaps := make([]BuffSnapshot, 0, 8)
-	toDelete := make([]int32, 0, 8)
+	snaps := make([]BuffSnapshot, 0, len(saved))
+	toDelete := make([]int32, 0, len(saved))
 
 	now := time.Now().UnixMilli()
-	for rows.Next() {
-		var s BuffSnapshot
-		if err := rows.Scan(&s.SourceID, &s.Level, &s.ExpiresAtMs); err != nil {
-			log.Println("loadBuffSnapshot scan:", err)
-			return
-		}
+	for _, b := range saved {
+		s := BuffSnapshot{SourceID: b.SourceID, Level: b.Level, ExpiresAtMs: b.ExpiresAtMs}
 
 		if s.ExpiresAtMs == 0 {
 			toDelete = append(toDelete, s.SourceID)
@@ -2310,28 +2337,9 @@ func (d *Player) loadAndApplyBuffSnapshot() {
 		s.ExpiresAtMs = normalized
 		snaps = append(snaps, s)
 	}
-	if err := rows.Err(); err != nil {
-		log.Println("loadBuffSnapshot rows:", err)
-		return
-	}
 
 	if len(toDelete) > 0 {
-		var b strings.Builder
-		b.WriteString("DELETE FROM character_buffs WHERE characterID=? AND sourceID IN (")
-		for i := range toDelete {
-			if i > 0 {
-				b.WriteByte(',')
-			}
-			b.WriteByte('?')
-		}
-		b.WriteByte(')')
-
-		args := make([]interface{}, 0, 1+len(toDelete))
-		args = append(args, d.ID)
-		for _, sid := range toDelete {
-			args = append(args, sid)
-		}
-		if _, err := common.DB.Exec(b.String(), args...); err != nil {
+		if err := common.Repo.Buffs.Delete(d.ID, toDelete...); err != nil {
 			log.Println("loadBuffSnapshot delete expired:", err)
 		}
 	}
@@ -2796,27 +2804,267 @@ func (p *Player) hasActiveBuff(skillID int32) bool {
 	return ok && lvl > 0
 }
 
-func (p *Player) updatePet() {
+func (p *Player) updatePet(pt *pet) {
 	p.MarkDirty(DirtyPet, time.Millisecond*300)
-	p.inst.send(packetPlayerPetUpdate(p.pet.sn))
+	p.Send(packetPlayerPetUpdate(pt.sn))
 }
 
-func (p *Player) petCanTakeDrop(drop fieldDrop) bool {
+// petCashID of the spawned pet, 0 without a pet out
+func (p *Player) petCashID() int64 {
 	if p.pet == nil {
+		return 0
+	}
+
+	return int64(p.pet.sn)
+}
+
+// spawnPet brings the pet out with its equipment, the player can only have one pet out
+func (p *Player) spawnPet(pt *pet) bool {
+	if p.pet != nil {
 		return false
 	}
 
-	if drop.mesos > 0 {
-		if p.hasEquipped(constant.ItemMesoMagnet) {
-			return true
+	pt.pos = p.pos
+	pt.spawned = true
+	p.pet = pt
+
+	p.inst.send(packetPetSpawn(p.ID, pt))
+	p.showPetEquips()
+	p.MarkDirty(DirtyPet, time.Millisecond*300)
+
+	return true
+}
+
+// despawnPet sends the spawned pet home, reason is shown to the owner e.g. constant.PetRemoveHungry
+func (p *Player) despawnPet(reason byte) {
+	if p.pet == nil {
+		return
+	}
+
+	p.hidePetEquips()
+	p.inst.send(packetPetRemove(p.ID, reason))
+
+	p.pet.spawned = false
+	p.pet = nil
+
+	p.MarkDirty(DirtyPet, time.Millisecond*300)
+}
+
+// showPetEquips puts the equipment the spawned pet wears into the pet equip slots, equipment that ran out while the
+// pet was home is thrown away
+func (p *Player) showPetEquips() {
+	pt := p.pet
+
+	for equipSlot, item := range pt.equips {
+		if item.dbID == 0 {
+			continue
 		}
+
+		if item.Expired() {
+			if err := item.delete(); err != nil {
+				log.Println(err)
+			}
+
+			pt.equips[equipSlot] = Item{}
+			pt.equipsDirty = true
+			p.Send(packetMessageItemExpired(item.ID))
+			continue
+		}
+
+		if slotID := petEquipSlotID(equipSlot); item.slotID != slotID {
+			item.slotID = slotID
+			item.save(p.ID)
+			pt.equips[equipSlot] = item
+		}
+
+		p.equip = append(p.equip, item)
+		p.Send(packetInventoryAddItem(item, true))
+	}
+}
+
+// hidePetEquips takes the spawned pet's equipment out of the pet equip slots, the pet keeps it while it is home. The
+// items keep their rows.
+func (p *Player) hidePetEquips() {
+	kept := p.equip[:0]
+
+	for _, item := range p.equip {
+		equipSlot, ok := petEquipSlot(item.slotID)
+		if !ok {
+			kept = append(kept, item)
+			continue
+		}
+
+		p.pet.equips[equipSlot] = item
+		p.Send(packetInventoryRemoveItem(item))
+	}
+
+	p.equip = kept
+}
+
+// equipPet records the item moved into a pet equip slot as worn by the spawned pet, it reports whether there is a pet
+// out to wear it and the item is pet equipment
+func (p *Player) equipPet(item Item, slotID int16) bool {
+	equipSlot, ok := petEquipSlot(slotID)
+	if !ok || p.pet == nil || !isPetEquip(item.ID) {
 		return false
-	} else {
-		if p.hasEquipped(constant.ItemItemPouch) {
-			return true
+	}
+
+	item.slotID = slotID
+	p.pet.equips[equipSlot] = item
+	p.pet.equipsDirty = true
+	p.MarkDirty(DirtyPet, time.Millisecond*300)
+
+	return true
+}
+
+// unequipPet forgets the item in a pet equip slot once it is moved out of it
+func (p *Player) unequipPet(slotID int16) {
+	equipSlot, ok := petEquipSlot(slotID)
+	if !ok || p.pet == nil || p.pet.equips[equipSlot].dbID == 0 {
+		return
+	}
+
+	p.pet.equips[equipSlot] = Item{}
+	p.pet.equipsDirty = true
+	p.MarkDirty(DirtyPet, time.Millisecond*300)
+}
+
+// takePetEquips takes the equipment of the pets, which are all home at login, out of the pet equip slots the last
+// session left it in. An item there that no pet wears goes back to the equip inventory if it has room.
+func (p *Player) takePetEquips() {
+	inSlots := make(map[int64]Item)
+	kept := p.equip[:0]
+
+	for _, item := range p.equip {
+		if _, ok := petEquipSlot(item.slotID); ok {
+			inSlots[item.dbID] = item
+		} else {
+			kept = append(kept, item)
 		}
+	}
+	p.equip = kept
+
+	for _, pt := range p.ownedPets() {
+		for equipSlot, worn := range pt.equips {
+			if worn.dbID == 0 {
+				continue
+			}
+
+			if item, ok := inSlots[worn.dbID]; ok {
+				pt.equips[equipSlot] = item
+				delete(inSlots, worn.dbID)
+			} else {
+				// The item is gone, e.g. it expired while the player was away
+				pt.equips[equipSlot] = Item{}
+				pt.equipsDirty = true
+			}
+		}
+	}
+
+	for _, item := range inSlots {
+		if slotID, err := findFirstEmptySlot(p.equip, p.equipSlotSize); err == nil {
+			item.slotID = slotID
+			item.save(p.ID)
+		}
+
+		p.equip = append(p.equip, item)
+	}
+}
+
+// petAutoPotion drinks a potion from the use inventory when the spawned pet wears an auto potion pouch of the ability
+// and the player's HP or MP has fallen below the configured percentage
+func (p *Player) petAutoPotion(ability int) {
+	if p.hp < 1 || p.petPotions == nil || p.pet == nil || p.pet.abilities()&ability == 0 {
+		return
+	}
+
+	cur, max, itemID, percent := p.hp, p.effectiveMaxHP(), p.petPotions.hpItem, p.petPotions.hpPercent
+	if ability == petConsumeMP {
+		cur, max, itemID, percent = p.mp, p.effectiveMaxMP(), p.petPotions.mpItem, p.petPotions.mpPercent
+	}
+
+	if percent == 0 {
+		percent = constant.PetAutoPotionPercent
+	}
+
+	if int(cur)*100 >= int(max)*percent {
+		return
+	}
+
+	potion, ok := p.petPotion(itemID, ability == petConsumeHP)
+	if !ok {
+		return
+	}
+
+	if _, err := p.takeItem(potion.ID, potion.slotID, 1, constant.InventoryUse); err != nil {
+		log.Println("Pet auto potion:", err)
+		return
+	}
+
+	potion.use(p)
+}
+
+// petPotion finds the potion an auto potion pouch drinks, the configured item or else the first potion in the use
+// inventory that restores HP, or MP when hp is false
+func (p *Player) petPotion(itemID int32, hp bool) (Item, bool) {
+	var found Item
+	ok := false
+
+	for _, item := range p.use {
+		if item.amount < 1 || (ok && item.slotID > found.slotID) {
+			continue
+		}
+
+		if itemID != 0 {
+			if item.ID != itemID {
+				continue
+			}
+		} else {
+			itm, err := nx.GetItem(item.ID)
+			if err != nil || (hp && itm.HP <= 0 && itm.HPR <= 0) || (!hp && itm.MP <= 0 && itm.MPR <= 0) {
+				continue
+			}
+		}
+
+		found, ok = item, true
+	}
+
+	return found, ok
+}
+
+// ownedPets are the pets in the player's cash inventory, spawned or not
+func (p *Player) ownedPets() []*pet {
+	var pets []*pet
+
+	for _, item := range p.cash {
+		if item.pet && item.petData != nil {
+			pets = append(pets, item.petData)
+		}
+	}
+
+	return pets
+}
+
+// petCanTakeDrop reports whether the spawned pet can loot the drop, by the equipment it or its owner wears
+func (p *Player) petCanTakeDrop(drop fieldDrop) bool {
+	if p.pet == nil {
 		return false
 	}
+
+	abilities := p.pet.abilities()
+
+	if p.hasEquipped(constant.ItemMesoMagnet) {
+		abilities |= petPickupMeso
+	}
+	if p.hasEquipped(constant.ItemItemPouch) {
+		abilities |= petPickupItem
+	}
+
+	if drop.mesos > 0 {
+		return abilities&petPickupMeso != 0
+	}
+
+	return abilities&petPickupItem != 0
 }
 
 func (p *Player) hasEquipped(itemID int32) bool {
@@ -3057,7 +3305,7 @@ func packetPlayerEnterGame(plr Player, channelID int32) mpacket.Packet {
 	p.WriteInt32(plr.face)
 	p.WriteInt32(plr.hair)
 
-	p.WriteInt64(plr.petCashID)
+	p.WriteInt64(plr.petCashID())
 
 	p.WriteByte(plr.level)
 	p.WriteInt16(plr.job)
@@ -3367,13 +3615,13 @@ func packetPlayerAvatarSummaryWindow(charID int32, plr Player) mpacket.Packet {
 		p.WriteString("")
 	}
 
-	if plr.petCashID != 0 {
+	if pt := plr.pet; pt != nil {
 		p.WriteBool(true)
-		p.WriteInt32(plr.pet.itemID)
-		p.WriteString(plr.pet.name)
-		p.WriteByte(plr.pet.level)
-		p.WriteInt16(plr.pet.closeness)
-		p.WriteByte(plr.pet.fullness)
+		p.WriteInt32(pt.itemID)
+		p.WriteString(pt.name)
+		p.WriteByte(pt.level)
+		p.WriteInt16(pt.closeness)
+		p.WriteByte(pt.fullness)
 		p.WriteInt32(0) // equipped items
 	} else {
 		p.WriteBool(false)
@@ -3487,7 +3735,7 @@ func (plr *Player) WriteCharacterInfoPacket(p *mpacket.Packet) {
 	p.WriteByte(plr.skin)
 	p.WriteInt32(plr.face)
 	p.WriteInt32(plr.hair)
-	p.WriteInt64(plr.petCashID) // Pet Cash ID
+	p.WriteInt64(plr.petCashID()) // Pet Cash ID
 
 	p.WriteByte(plr.level)
 	p.WriteInt16(plr.job)
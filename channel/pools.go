@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"math/rand"
@@ -248,11 +247,24 @@ func (pool *lifePool) mobAcknowledge(poolID int32, plr *Player, moveID int16, sk
 			// Choose next skill
 			skillID, skillLevel = mob.chooseNextSkill()
 
-			if !moveData.validateMob(v) {
-				return
+			// The move is only dropped if the anti-cheat removed the controller, the ack is always sent so the client
+			// keeps control of the mob
+			dropMove := false
+
+			if problem := moveData.validateMob(v, pool.instance.fhHist); problem != "" {
+				log.Printf("Player: %s moved mob %d (%d) illegally: %s", plr.Name, v.spawnID, v.id, problem)
+
+				if pool.instance.server != nil && pool.instance.server.ac != nil {
+					dropMove = pool.instance.server.ac.LogMobVacViolation(plr.accountID, v.id, problem)
+				}
 			}
 
 			pool.mobs[i].acknowledgeController(moveID, finalData, skillPossible, skillID, skillLevel)
+
+			if dropMove {
+				return
+			}
+
 			pool.instance.sendExcept(packetMobMove(poolID, skillPossible, action, skillData, moveBytes), v.controller.Conn)
 
 		}
@@ -437,7 +449,7 @@ func (pool *lifePool) mobDamaged(poolID int32, damager *Player, dmg ...int32) {
 				pool.removeMob(v.spawnID, 0x1)
 
 				if killer != nil {
-					if dropEntry, ok := dropTable[v.id]; ok {
+					if dropEntry, ok := mobDrops(v.id); ok {
 						var mesos int32
 						drops := make([]Item, 0, len(dropEntry))
 						for _, entry := range dropEntry {
@@ -483,6 +495,10 @@ func (pool *lifePool) mobDamaged(poolID int32, damager *Player, dmg ...int32) {
 						}
 					}
 				}
+
+				if pool.instance != nil && pool.instance.raid != nil {
+					pool.instance.raid.mobDeath(v.id)
+				}
 			}
 			break
 		}
@@ -816,9 +832,14 @@ func (pool *roomPool) nextID() (int32, error) {
 
 func (pool *roomPool) playerShowRooms(plr *Player) {
 	for _, r := range pool.rooms {
+		if m, ok := r.(*merchantRoom); ok && m.ownerID() != plr.ID {
+			if _, err := pool.instance.getPlayerFromID(m.ownerID()); err != nil {
+				plr.Send(packetMapMerchantAvatar(m.avatar))
+			}
+		}
+
 		if b, ok := r.(boxDisplayer); ok {
 			plr.Send(packetMapShowGameBox(b.displayBytes()))
-			return
 		}
 	}
 }
@@ -917,6 +938,12 @@ func (pool *roomPool) removePlayer(plr *Player) {
 		} else {
 			pool.updateGameBox(r)
 		}
+	case *merchantRoom:
+		if v.removePlayer(plr) {
+			pool.instance.server.closeMerchant(v)
+		} else {
+			pool.updateGameBox(r)
+		}
 
 	case gameRoomer:
 		v.kickPlayer(plr, 0x0)
@@ -1269,17 +1296,38 @@ type reactorTableEntry map[string]interface{}
 var reactorTable map[string]map[string]reactorTableEntry
 
 func populateReactorTable(reactorJSON string) error {
-	f, err := os.Open(reactorJSON)
+	table, err := loadReactorTable(reactorJSON)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	b, err := io.ReadAll(f)
+	dataMu.Lock()
+	reactorTable = table
+	dataMu.Unlock()
+
+	return nil
+}
+
+func loadReactorTable(reactorJSON string) (map[string]map[string]reactorTableEntry, error) {
+	b, err := os.ReadFile(reactorJSON)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return json.Unmarshal(b, &reactorTable)
+
+	var table map[string]map[string]reactorTableEntry
+	if err := json.Unmarshal(b, &table); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+func reactorGroup(name string) (map[string]reactorTableEntry, bool) {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+
+	group, ok := reactorTable[name]
+	return group, ok
 }
 
 type reactorDrops struct {
@@ -1290,37 +1338,65 @@ type reactorDrops struct {
 var reactorDropTable map[string]reactorDrops
 
 func populateReactorDropTable(reactorJSON string) error {
-	b, err := os.ReadFile(reactorJSON)
+	table, err := loadReactorDropTable(reactorJSON)
 	if err != nil {
 		return err
 	}
 
+	dataMu.Lock()
+	reactorDropTable = table
+	dataMu.Unlock()
+
+	return nil
+}
+
+func loadReactorDropTable(reactorJSON string) (map[string]reactorDrops, error) {
+	b, err := os.ReadFile(reactorJSON)
+	if err != nil {
+		return nil, err
+	}
+
 	var raw map[string]map[string]map[string]interface{}
 	if err := json.Unmarshal(b, &raw); err != nil {
-		return err
+		return nil, err
 	}
 
-	reactorDropTable = make(map[string]reactorDrops, len(raw))
+	table := make(map[string]reactorDrops, len(raw))
 	for rid, slots := range raw {
 		rd := reactorDrops{}
 
-		for _, slot := range slots {
+		for slotID, slot := range slots {
 			if v, ok := slot["item"].(string); ok {
-				if id, _ := strconv.Atoi(v); id != 0 {
+				id, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("reactor %s slot %s: invalid item %q", rid, slotID, v)
+				}
+				if id != 0 {
 					rd.items = append(rd.items, id)
 				}
 			}
 			if v, ok := slot["money"].(string); ok {
-				if m, _ := strconv.Atoi(v); m != 0 {
+				m, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("reactor %s slot %s: invalid money %q", rid, slotID, v)
+				}
+				if m != 0 {
 					rd.money = m
 				}
 			}
 		}
 
-		reactorDropTable[rid] = rd
+		table[rid] = rd
 	}
 
-	return nil
+	return table, nil
+}
+
+func reactorDropsFor(reactorID string) reactorDrops {
+	dataMu.RLock()
+	defer dataMu.RUnlock()
+
+	return reactorDropTable[reactorID]
 }
 
 type rect struct{ left, top, right, bottom int16 }
@@ -1438,7 +1514,7 @@ func entriesForReactor(r *fieldReactor) []reactorTableEntry {
 	if groupName == "" {
 		groupName = strings.TrimSpace(r.name)
 	}
-	group, ok := reactorTable[groupName]
+	group, ok := reactorGroup(groupName)
 	if !ok {
 		return nil
 	}
@@ -1581,13 +1657,13 @@ func (pool *reactorPool) processStateSideEffects(r *fieldReactor, plr *Player) {
 			for i := 0; i < count; i++ {
 				pool.instance.lifePool.spawnMobFromID(int32(mobID), spawnPos, false, true, true, constant.MobSummonTypeInstant, 0)
 
-				if summonRequiresBossHandler(int32(mobID)) {
+				if summonRequiresBossHandler(int32(mobID)) && pool.instance.raid == nil {
 					go manageSummonedBoss(pool.instance, int32(mobID), pool.server)
 				}
 			}
 		case constant.ReactorDrop:
 			reactorID := strconv.Itoa(int(r.info.ID))
-			reactorDrops := reactorDropTable[reactorID]
+			reactorDrops := reactorDropsFor(reactorID)
 			var items []Item
 			for _, val := range reactorDrops.items {
 				newItem, err := CreateItemFromID(int32(val), 1)
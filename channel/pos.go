@@ -25,6 +25,22 @@ func (d pos) calcDistanceSquare(v pos) int {
 	return (difx * difx) + (dify * dify)
 }
 
+// offset of v from d along each axis
+func (d pos) offset(v pos) (dx, dy int) {
+	dx = int(d.x) - int(v.x)
+	dy = int(d.y) - int(v.y)
+
+	if dx < 0 {
+		dx = -dx
+	}
+
+	if dy < 0 {
+		dy = -dy
+	}
+
+	return dx, dy
+}
+
 func (d pos) CalcDistance(v pos) float64 {
 	difx := int(d.x - v.x)
 	dify := int(d.y - v.y)
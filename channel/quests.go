@@ -58,27 +58,16 @@ func loadQuestsFromDB(charID int32) quests {
 	var q quests
 	q.init() // ensure maps are ready
 
-	rows, err := common.DB.Query(
-		"SELECT questID, record, completed, completedAt FROM character_quests WHERE characterID=?",
-		charID,
-	)
+	rows, err := common.Repo.Quests.ByCharacter(charID)
 	if err != nil {
 		return q
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var id int16
-		var record string
-		var completed bool
-		var completedAt int64
-		if err := rows.Scan(&id, &record, &completed, &completedAt); err != nil {
-			continue
-		}
-		if completed {
-			q.completed[id] = quest{id: id, completedAt: completedAt}
+	for _, row := range rows {
+		if row.Completed {
+			q.completed[row.ID] = quest{id: row.ID, completedAt: row.CompletedAt}
 		} else {
-			q.inProgress[id] = quest{id: id, name: record}
+			q.inProgress[row.ID] = quest{id: row.ID, name: row.Record}
 		}
 	}
 	return q
@@ -87,63 +76,38 @@ func loadQuestsFromDB(charID int32) quests {
 func loadQuestMobKillsFromDB(charID int32) map[int16]map[int32]int32 {
 	out := make(map[int16]map[int32]int32, 16)
 
-	rows, err := common.DB.Query(
-		"SELECT questID, mobID, kills FROM character_quest_kills WHERE characterID=?",
-		charID,
-	)
+	rows, err := common.Repo.Quests.MobKills(charID)
 	if err != nil {
 		return out
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var qid int16
-		var mobID int32
-		var kills int32
-		if err := rows.Scan(&qid, &mobID, &kills); err != nil {
-			continue
-		}
-		if _, ok := out[qid]; !ok {
-			out[qid] = make(map[int32]int32, 4)
+	for _, row := range rows {
+		if _, ok := out[row.QuestID]; !ok {
+			out[row.QuestID] = make(map[int32]int32, 4)
 		}
-		out[qid][mobID] = kills
+		out[row.QuestID][row.MobID] = row.Kills
 	}
 	return out
 }
 
 func upsertQuestRecord(charID int32, questID int16, record string) {
-	_, _ = common.DB.Exec(
-		"INSERT INTO character_quests(characterID, questID, record, completed, completedAt) "+
-			"VALUES(?,?,?,?,?) ON DUPLICATE KEY UPDATE record=VALUES(record), completed=0, completedAt=0",
-		charID, questID, record, 0, 0,
-	)
+	_ = common.Repo.Quests.SetRecord(charID, questID, record)
 }
 
 func setQuestCompleted(charID int32, questID int16, completedAtMs int64) {
-	_, _ = common.DB.Exec(
-		"INSERT INTO character_quests(characterID, questID, record, completed, completedAt) "+
-			"VALUES(?,?,?,?,?) ON DUPLICATE KEY UPDATE completed=1, completedAt=VALUES(completedAt)",
-		charID, questID, "", 1, completedAtMs,
-	)
+	_ = common.Repo.Quests.SetCompleted(charID, questID, completedAtMs)
 }
 
 func deleteQuest(charID int32, questID int16) {
-	_, _ = common.DB.Exec("DELETE FROM character_quests WHERE characterID=? AND questID=?", charID, questID)
+	_ = common.Repo.Quests.Delete(charID, questID)
 }
 
 func upsertQuestMobKill(charID int32, questID int16, mobID int32, delta int32) {
-	_, _ = common.DB.Exec(
-		"INSERT INTO character_quest_kills(characterID, questID, mobID, kills) VALUES(?,?,?,?) "+
-			"ON DUPLICATE KEY UPDATE kills = kills + VALUES(kills)",
-		charID, questID, mobID, delta,
-	)
+	_ = common.Repo.Quests.AddMobKill(charID, questID, mobID, delta)
 }
 
 func clearQuestMobKills(charID int32, questID int16) {
-	_, _ = common.DB.Exec(
-		"DELETE FROM character_quest_kills WHERE characterID=? AND questID=?",
-		charID, questID,
-	)
+	_ = common.Repo.Quests.ClearMobKills(charID, questID)
 }
 
 // In-memory helpers
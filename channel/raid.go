@@ -0,0 +1,606 @@
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/mpacket"
+	"github.com/dop251/goja"
+)
+
+var errRaidStarted = errors.New("The raid has already started")
+
+// raidConfig is read from the config object at the top of a raid script
+type raidConfig struct {
+	Name       string // shown to players
+	Map        int32  // boss map the raid is fought on
+	Exit       int32  // map players are sent to once the raid is over
+	MinLevel   int
+	MaxPlayers int // capped at constant.RaidMaxPlayers
+	Entries    int // raids a character can go into each day, 0 for no limit
+}
+
+// raid is a boss fight for a signup list of players in an instance of the boss map of its own. It starts out as the
+// signup list the leader opened and is run by a goja script in the same way as an event.
+type raid struct {
+	id       int32
+	leaderID int32
+	script   string
+	config   raidConfig
+
+	members []int32 // signed up characters with the leader first, once started only those still taking part
+	started bool
+	cleared bool
+	done    bool
+
+	inst    *fieldInstance
+	endTime time.Time
+	timer   *time.Timer
+
+	server *Server
+	vm     *goja.Runtime
+
+	startCallback       func()
+	mobDeathCallback    func(mobID int32)
+	rewardCallback      func(plr scriptPlayerWrapper)
+	playerLeaveCallback func(plr scriptPlayerWrapper)
+}
+
+func createRaid(id int32, leader *Player, script string, server *Server, program *goja.Program) (*raid, error) {
+	r := &raid{
+		id:       id,
+		leaderID: leader.ID,
+		script:   script,
+		members:  []int32{leader.ID},
+		server:   server,
+		vm:       goja.New(),
+	}
+
+	r.vm.SetFieldNameMapper(goja.UncapFieldNameMapper())
+	_ = r.vm.Set("ctrl", r)
+
+	_, err := r.vm.RunProgram(program)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := r.vm.Get("config")
+
+	if config == nil {
+		return nil, fmt.Errorf("raid script %s has no config", script)
+	}
+
+	err = r.vm.ExportTo(config, &r.config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if r.config.MaxPlayers <= 0 || r.config.MaxPlayers > constant.RaidMaxPlayers {
+		r.config.MaxPlayers = constant.RaidMaxPlayers
+	}
+
+	for _, id := range []int32{r.config.Map, r.config.Exit} {
+		if _, ok := server.fields[id]; !ok {
+			return nil, fmt.Errorf("raid script %s uses unknown map %d", script, id)
+		}
+	}
+
+	callbacks := []struct {
+		name     string
+		target   any
+		required bool
+	}{
+		{"start", &r.startCallback, true},
+		{"mobDeath", &r.mobDeathCallback, true},
+		{"reward", &r.rewardCallback, true},
+		{"playerLeave", &r.playerLeaveCallback, false},
+	}
+
+	for _, c := range callbacks {
+		fn := r.vm.Get(c.name)
+
+		if fn == nil || goja.IsUndefined(fn) {
+			if c.required {
+				return nil, fmt.Errorf("raid script %s has no %s function", script, c.name)
+			}
+
+			continue
+		}
+
+		err = r.vm.ExportTo(fn, c.target)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// raidConfig reads the config of the raid script without opening a raid
+func (server *Server) raidConfig(plr *Player, script string) (raidConfig, error) {
+	program, ok := server.raidScriptStore.scripts[script]
+
+	if !ok {
+		return raidConfig{}, fmt.Errorf("there is no raid script %s", script)
+	}
+
+	r, err := createRaid(0, plr, script, server, program)
+
+	if err != nil {
+		return raidConfig{}, err
+	}
+
+	return r.config, nil
+}
+
+// raidEntriesLeft returns how many more times the character can go into the raid today, -1 when there is no limit
+func raidEntriesLeft(plr *Player, script string, limit int) (int, error) {
+	if limit <= 0 {
+		return -1, nil
+	}
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	used, err := common.Repo.Raids.CountSince(plr.ID, script, midnight)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return max(limit-used, 0), nil
+}
+
+// canEnter checks the player meets the raid's level and has an entry left today
+func (r *raid) canEnter(plr *Player) error {
+	if int(plr.level) < r.config.MinLevel {
+		return fmt.Errorf("%s must be level %d or higher to enter the %s raid", plr.Name, r.config.MinLevel, r.config.Name)
+	}
+
+	left, err := raidEntriesLeft(plr, r.script, r.config.Entries)
+
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("Could not look up the raid entries of %s", plr.Name)
+	}
+
+	if left == 0 {
+		return fmt.Errorf("%s has no %s raid entries left today", plr.Name, r.config.Name)
+	}
+
+	return nil
+}
+
+// openRaid makes the player the leader of a new signup list for the raid script
+func (server *Server) openRaid(plr *Player, script string) error {
+	if plr.raid != nil {
+		return fmt.Errorf("You are already signed up to a raid")
+	}
+
+	program, ok := server.raidScriptStore.scripts[script]
+
+	if !ok {
+		return fmt.Errorf("There is no raid called %s", script)
+	}
+
+	server.nextRaidID++
+
+	r, err := createRaid(server.nextRaidID, plr, script, server, program)
+
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("Could not open the raid")
+	}
+
+	if err = r.canEnter(plr); err != nil {
+		return err
+	}
+
+	plr.raid = r
+	server.raids[r.id] = r
+
+	return nil
+}
+
+// raidSignups returns the signup lists of the raid script that are still open, oldest first
+func (server *Server) raidSignups(script string) []*raid {
+	signups := []*raid{}
+
+	for _, r := range server.raids {
+		if r.script == script && !r.started {
+			signups = append(signups, r)
+		}
+	}
+
+	slices.SortFunc(signups, func(a, b *raid) int {
+		return int(a.id - b.id)
+	})
+
+	return signups
+}
+
+// raidDisconnect takes a player leaving the channel off the signup list or out of the raid they are in, they must
+// already have been removed from their instance
+func (server *Server) raidDisconnect(plr *Player) {
+	switch {
+	case plr.raid == nil:
+	case plr.raid.started:
+		plr.raid.removePlayer(plr)
+	default:
+		plr.raid.leave(plr)
+	}
+}
+
+// raidInstance returns an instance of the field for a raid to hold, reusing one a finished raid handed back before
+// creating another. Instances are kept rather than deleted as deleting one shifts the IDs of those after it.
+func (server *Server) raidInstance(f *field) (*fieldInstance, error) {
+	if free := server.raidInstances[f.id]; len(free) > 0 {
+		inst := free[len(free)-1]
+		server.raidInstances[f.id] = free[:len(free)-1]
+
+		return inst, nil
+	}
+
+	return f.getInstance(f.createInstance(&server.rates, server))
+}
+
+// releaseRaidInstance clears an instance a raid has finished with and keeps it for the next raid on the map
+func (server *Server) releaseRaidInstance(inst *fieldInstance) {
+	inst.raid = nil
+	inst.lifePool.eraseMobs()
+	inst.dropPool.eraseDrops()
+	inst.reactorPool.reset(false)
+
+	for k := range inst.properties {
+		delete(inst.properties, k)
+	}
+
+	server.raidInstances[inst.fieldID] = append(server.raidInstances[inst.fieldID], inst)
+}
+
+// participants returns the members online on the channel
+func (r *raid) participants() []*Player {
+	players := make([]*Player, 0, len(r.members))
+
+	for _, id := range r.members {
+		if plr, err := r.server.players.GetFromID(id); err == nil {
+			players = append(players, plr)
+		}
+	}
+
+	return players
+}
+
+func (r *raid) send(p mpacket.Packet) {
+	for _, plr := range r.participants() {
+		plr.Send(p)
+	}
+}
+
+// join adds the player to the signup list
+func (r *raid) join(plr *Player) error {
+	if plr.raid != nil {
+		return fmt.Errorf("You are already signed up to a raid")
+	}
+
+	if r.started {
+		return errRaidStarted
+	}
+
+	if len(r.members) >= r.config.MaxPlayers {
+		return fmt.Errorf("The raid is full")
+	}
+
+	if err := r.canEnter(plr); err != nil {
+		return err
+	}
+
+	r.members = append(r.members, plr.ID)
+	plr.raid = r
+
+	r.send(packetMessageNotice(fmt.Sprintf("%s has signed up to the %s raid (%d/%d)", plr.Name, r.config.Name,
+		len(r.members), r.config.MaxPlayers)))
+
+	return nil
+}
+
+// leave takes the player off the signup list, or out of the raid once it has started. The signup list is closed when
+// the leader leaves it.
+func (r *raid) leave(plr *Player) {
+	if r.started {
+		r.removePlayer(plr)
+
+		// The instance has been handed back and the player moved out already if they were the last one left
+		if plr.inst == r.inst && !r.done {
+			if err := r.server.moveToMap(plr, r.config.Exit); err != nil {
+				log.Println(err)
+			}
+		}
+
+		return
+	}
+
+	if plr.ID == r.leaderID {
+		r.disband(fmt.Sprintf("The %s raid signup list has been closed by its leader", r.config.Name))
+		return
+	}
+
+	r.members = slices.DeleteFunc(r.members, func(id int32) bool { return id == plr.ID })
+	plr.raid = nil
+
+	r.send(packetMessageNotice(fmt.Sprintf("%s has left the %s raid (%d/%d)", plr.Name, r.config.Name,
+		len(r.members), r.config.MaxPlayers)))
+}
+
+// disband closes a signup list that has not started
+func (r *raid) disband(msg string) {
+	for _, plr := range r.participants() {
+		plr.raid = nil
+		plr.Send(packetMessageRedText(msg))
+	}
+
+	r.members = nil
+	delete(r.server.raids, r.id)
+}
+
+// start takes the signed up players standing with the leader into an instance of the boss map, those elsewhere or no
+// longer able to enter are dropped from the raid
+func (r *raid) start(leader *Player) error {
+	if leader.ID != r.leaderID {
+		return fmt.Errorf("Only the raid leader can start the raid")
+	}
+
+	if r.started {
+		return errRaidStarted
+	}
+
+	if err := r.canEnter(leader); err != nil {
+		return err
+	}
+
+	field := r.server.fields[r.config.Map]
+	inst, err := r.server.raidInstance(field)
+
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("Could not create the raid map")
+	}
+
+	portal, err := inst.getRandomSpawnPortal()
+
+	if err != nil {
+		r.server.releaseRaidInstance(inst)
+		log.Println(err)
+		return fmt.Errorf("Could not create the raid map")
+	}
+
+	entering := []*Player{}
+
+	for _, plr := range r.participants() {
+		if plr.ID != leader.ID && (plr.mapID != leader.mapID || plr.inst != leader.inst) {
+			plr.raid = nil
+			plr.Send(packetMessageRedText(fmt.Sprintf("The %s raid has started without you as you were not with its leader", r.config.Name)))
+			continue
+		}
+
+		if err := r.canEnter(plr); err != nil {
+			plr.raid = nil
+			plr.Send(packetMessageRedText(err.Error()))
+			continue
+		}
+
+		entering = append(entering, plr)
+	}
+
+	r.started = true
+	r.inst = inst
+	r.members = make([]int32, 0, len(entering))
+	inst.raid = r
+
+	for _, plr := range entering {
+		if err := r.server.warpPlayerToInstance(plr, field, inst.id, portal, false); err != nil {
+			log.Println(err)
+			plr.raid = nil
+			continue
+		}
+
+		// Only an entry that took the player in is used up
+		if err := common.Repo.Raids.Add(plr.ID, r.script); err != nil {
+			log.Println(err)
+		}
+
+		r.members = append(r.members, plr.ID)
+	}
+
+	if len(r.members) == 0 {
+		r.finish()
+		return fmt.Errorf("Could not take anyone into the raid")
+	}
+
+	r.startCallback()
+
+	if r.timer == nil && !r.done {
+		r.setDuration(constant.RaidDefaultMins * time.Minute)
+	}
+
+	return nil
+}
+
+// removePlayer takes the player out of a running raid, it is over once nobody is left taking part
+func (r *raid) removePlayer(plr *Player) {
+	r.members = slices.DeleteFunc(r.members, func(id int32) bool { return id == plr.ID })
+	plr.raid = nil
+	plr.Send(packetHideCountdown())
+
+	if r.playerLeaveCallback != nil && !r.done {
+		r.playerLeaveCallback(scriptPlayerWrapper{plr: plr, server: r.server})
+	}
+
+	if len(r.members) == 0 {
+		r.finish()
+	}
+}
+
+func (r *raid) mobDeath(mobID int32) {
+	if !r.done {
+		r.mobDeathCallback(mobID)
+	}
+}
+
+func (r *raid) setDuration(d time.Duration) {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	r.endTime = time.Now().Add(d)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		r.server.dispatch <- func() {
+			if r.done || r.timer != timer {
+				return
+			}
+
+			if r.cleared {
+				r.finish()
+			} else {
+				r.Fail("Time has run out")
+			}
+		}
+	})
+	r.timer = timer
+
+	r.send(packetShowCountdown(int32(d.Seconds())))
+}
+
+// finish sends everyone left in the raid's instance to the exit map and hands the instance back
+func (r *raid) finish() {
+	if r.done {
+		return
+	}
+
+	r.done = true
+
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+
+	for _, plr := range r.participants() {
+		plr.raid = nil
+		plr.Send(packetHideCountdown())
+	}
+
+	r.members = nil
+
+	if r.inst != nil {
+		for _, plr := range slices.Clone(r.inst.players) {
+			if err := r.server.moveToMap(plr, r.config.Exit); err != nil {
+				log.Println(err)
+			}
+		}
+
+		r.server.releaseRaidInstance(r.inst)
+	}
+
+	delete(r.server.raids, r.id)
+}
+
+func (r *raid) Log(msg string) {
+	log.Println(msg)
+}
+
+func (r *raid) Name() string {
+	return r.config.Name
+}
+
+func (r *raid) RemainingTime() int32 {
+	return int32(time.Until(r.endTime).Seconds())
+}
+
+// SetDuration restarts the raid's timer, the raid fails when it runs out unless it has been cleared
+func (r *raid) SetDuration(duration string) {
+	d, err := time.ParseDuration(duration)
+
+	if err != nil {
+		d = time.Second * 10
+	}
+
+	r.setDuration(d)
+}
+
+func (r *raid) PlayerCount() int {
+	return len(r.members)
+}
+
+func (r *raid) Players() []scriptPlayerWrapper {
+	players := r.participants()
+	wrappers := make([]scriptPlayerWrapper, len(players))
+
+	for i, plr := range players {
+		wrappers[i] = scriptPlayerWrapper{plr: plr, server: r.server}
+	}
+
+	return wrappers
+}
+
+func (r *raid) GetMap() scriptMapWrapper {
+	if r.inst == nil {
+		return scriptMapWrapper{}
+	}
+
+	return scriptMapWrapper{inst: r.inst, server: r.server}
+}
+
+func (r *raid) SpawnMob(id int32, x, y int16) {
+	if r.inst == nil {
+		return
+	}
+
+	spawnPos := r.inst.calculateFinalDropPos(pos{x: x, y: y})
+
+	if err := r.inst.lifePool.spawnMobFromID(id, spawnPos, false, true, true, constant.MobSummonTypeInstant, 0); err != nil {
+		log.Println(err)
+	}
+}
+
+func (r *raid) Notice(msg string) {
+	r.send(packetMessageNotice(msg))
+}
+
+func (r *raid) Cleared() bool {
+	return r.cleared
+}
+
+// Clear marks the boss as beaten and rewards everyone in the raid's instance, the raid ends when its timer next runs
+// out
+func (r *raid) Clear() {
+	if r.cleared || r.done {
+		return
+	}
+
+	r.cleared = true
+
+	for _, plr := range r.participants() {
+		if plr.inst == r.inst {
+			r.rewardCallback(scriptPlayerWrapper{plr: plr, server: r.server})
+		}
+	}
+}
+
+// Fail ends the raid, telling those taking part why
+func (r *raid) Fail(msg string) {
+	if r.done {
+		return
+	}
+
+	r.send(packetMessageRedText(fmt.Sprintf("The %s raid has failed: %s", r.config.Name, msg)))
+	r.finish()
+}
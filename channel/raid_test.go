@@ -0,0 +1,56 @@
+package channel
+
+import (
+	"testing"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/repository"
+	"github.com/dop251/goja"
+)
+
+func TestRaidEntriesLeft(t *testing.T) {
+	saved := common.Repo
+	common.Repo = repository.NewMemory().Repositories()
+	t.Cleanup(func() { common.Repo = saved })
+
+	plr := &Player{ID: 1}
+
+	if left, err := raidEntriesLeft(plr, "zakum", 0); err != nil || left != -1 {
+		t.Errorf("raidEntriesLeft() without a limit = %d, %v, want -1", left, err)
+	}
+
+	_ = common.Repo.Raids.Add(plr.ID, "zakum")
+	_ = common.Repo.Raids.Add(plr.ID, "pianus")
+
+	if left, err := raidEntriesLeft(plr, "zakum", 2); err != nil || left != 1 {
+		t.Errorf("raidEntriesLeft() = %d, %v, want 1", left, err)
+	}
+
+	_ = common.Repo.Raids.Add(plr.ID, "zakum")
+	_ = common.Repo.Raids.Add(plr.ID, "zakum")
+
+	if left, _ := raidEntriesLeft(plr, "zakum", 2); left != 0 {
+		t.Errorf("raidEntriesLeft() past the limit = %d, want 0", left)
+	}
+}
+
+func TestNpcIncludeRaidMenu(t *testing.T) {
+	store := createScriptStore("../scripts/npc", nil)
+	if err := store.loadScripts(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctrl := &npcChatController{vm: goja.New(), scripts: store}
+
+	if !ctrl.Include("raid_menu") {
+		t.Fatal("Include(raid_menu) failed")
+	}
+
+	if _, ok := goja.AssertFunction(ctrl.vm.Get("raidMenu")); !ok {
+		t.Error("raid_menu.js does not declare raidMenu")
+	}
+
+	if ctrl.Include("no_such_script") {
+		t.Error("Include() of a missing script succeeded")
+	}
+}
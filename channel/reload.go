@@ -0,0 +1,171 @@
+package channel
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// dataMu guards the drop and reactor tables, channels in the same process share them
+var dataMu sync.RWMutex
+
+var dataMonitorOnce sync.Once
+
+const dataReloadDelay = 500 * time.Millisecond
+
+const (
+	dataDrops        = "drops"
+	dataReactors     = "reactors"
+	dataReactorDrops = "reactorDrops"
+)
+
+type dataFiles struct {
+	drops        string
+	reactors     string
+	reactorDrops string
+}
+
+func (f dataFiles) path(kind string) string {
+	switch kind {
+	case dataDrops:
+		return f.drops
+	case dataReactors:
+		return f.reactors
+	case dataReactorDrops:
+		return f.reactorDrops
+	}
+
+	return ""
+}
+
+// loadDataFile parses and validates one of the data files, the returned func installs it
+func loadDataFile(kind, path string) (func(), error) {
+	switch kind {
+	case dataDrops:
+		table, err := loadDropTable(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { dropTable = table }, nil
+	case dataReactors:
+		table, err := loadReactorTable(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { reactorTable = table }, nil
+	case dataReactorDrops:
+		table, err := loadReactorDropTable(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() { reactorDropTable = table }, nil
+	}
+
+	return nil, fmt.Errorf("unknown data file %q", kind)
+}
+
+// reloadData parses the files off the dispatch goroutine and swaps the tables in on it. A file that fails to parse
+// leaves the current table in place. done is called on the dispatch goroutine for each file.
+func (server *Server) reloadData(kinds []string, done func(kind string, err error)) {
+	go func() {
+		for _, kind := range kinds {
+			path := server.dataFiles.path(kind)
+			start := time.Now()
+			install, err := loadDataFile(kind, path)
+			elapsed := time.Since(start)
+
+			server.dispatch <- func() {
+				if err == nil {
+					dataMu.Lock()
+					install()
+					dataMu.Unlock()
+
+					log.Println("Reloaded", path, "in", elapsed)
+				} else {
+					log.Println("Reloading", path, "failed:", err)
+				}
+
+				if done != nil {
+					done(kind, err)
+				}
+			}
+		}
+	}()
+}
+
+// monitorData reloads the data files when they change on disk, the directories are watched rather than the files so
+// editors that save by replacing the file are picked up
+func (server *Server) monitorData() {
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	defer watcher.Close()
+
+	kinds := make(map[string]string)
+
+	for _, kind := range []string{dataDrops, dataReactors, dataReactorDrops} {
+		path, err := filepath.Abs(server.dataFiles.path(kind))
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		kinds[path] = kind
+
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			log.Println(err)
+		}
+	}
+
+	// Saving a file produces several events, wait for them to settle before parsing
+	pending := make(map[string]*time.Timer)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			path, err := filepath.Abs(event.Name)
+			if err != nil {
+				continue
+			}
+
+			kind, ok := kinds[path]
+			if !ok {
+				continue
+			}
+
+			if t, ok := pending[kind]; ok {
+				t.Reset(dataReloadDelay)
+				continue
+			}
+
+			pending[kind] = time.AfterFunc(dataReloadDelay, func() {
+				server.reloadData([]string{kind}, nil)
+			})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package channel
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/mnet"
+	"github.com/Hucaru/Valhalla/mpacket"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+const reportDescriptionLength = 255
+
+var reportReasons = []string{"hacking", "botting", "scamming", "harassment", "advertising"}
+
+func reportReason(reason byte) string {
+	if int(reason) < len(reportReasons) {
+		return reportReasons[reason]
+	}
+
+	return fmt.Sprintf("other (%d)", reason)
+}
+
+func reportStatus(rep repository.Report) string {
+	switch rep.Status {
+	case repository.ReportClaimed:
+		return "claimed by " + rep.ClaimedBy
+	case repository.ReportResolved:
+		return "resolved by " + rep.ClaimedBy
+	}
+
+	return "open"
+}
+
+// recordChat keeps the last few lines of map chat so they can be attached to a report
+func (inst *fieldInstance) recordChat(name, msg string) {
+	if len(inst.chatLog) == constant.ReportChatLines {
+		inst.chatLog = append(inst.chatLog[:0], inst.chatLog[1:]...)
+	}
+
+	inst.chatLog = append(inst.chatLog, time.Now().Format("15:04:05")+" "+name+": "+msg)
+}
+
+func (server *Server) playerCharacterReport(conn mnet.Client, reader mpacket.Reader) {
+	plr, err := server.players.GetFromConn(conn)
+	if err != nil {
+		return
+	}
+
+	name := reader.ReadString(reader.ReadInt16())
+	reason := reader.ReadByte()
+	description := reader.ReadString(reader.ReadInt16())
+
+	if len(description) > reportDescriptionLength {
+		description = description[:reportDescriptionLength]
+	}
+
+	rep := repository.Report{
+		ReporterID:        plr.ID,
+		ReporterAccountID: plr.accountID,
+		ReporterName:      plr.Name,
+		Reason:            reason,
+		Description:       description,
+		MapID:             plr.mapID,
+	}
+
+	if target, err := server.players.GetFromName(name); err == nil {
+		rep.TargetID, rep.TargetAccountID, rep.TargetName = target.ID, target.accountID, target.Name
+	} else if char, err := common.Repo.Characters.ByName(name, conn.GetWorldID()); err == nil {
+		rep.TargetID, rep.TargetAccountID, rep.TargetName = char.ID, char.AccountID, char.Name
+	} else {
+		plr.Send(packetMessageRedText("Could not find a character named " + name))
+		return
+	}
+
+	if rep.TargetID == plr.ID {
+		plr.Send(packetMessageRedText("You cannot report yourself"))
+		return
+	}
+
+	count, err := common.Repo.Reports.CountSince(plr.accountID, time.Now().Add(-constant.ReportWindowMins*time.Minute))
+	if err != nil {
+		log.Println("Character report:", err)
+		plr.Send(packetMessageRedText("Your report could not be sent, please try again later"))
+		return
+	}
+
+	if count >= constant.ReportLimit {
+		plr.Send(packetMessageRedText("You have sent too many reports, please try again later"))
+		return
+	}
+
+	if inst, err := server.fields[plr.mapID].getInstance(plr.inst.id); err == nil {
+		rep.ChatLog = strings.Join(inst.chatLog, "\n")
+	}
+
+	if err := common.Repo.Reports.Create(&rep); err != nil {
+		log.Println("Character report:", err)
+		plr.Send(packetMessageRedText("Your report could not be sent, please try again later"))
+		return
+	}
+
+	log.Printf("Report #%d: %s reported %s for %s", rep.ID, rep.ReporterName, rep.TargetName, reportReason(rep.Reason))
+	plr.Send(packetMessageNotice("Thank you, your report against " + rep.TargetName + " has been sent to the GMs"))
+
+	server.players.observe(func(gm *Player) {
+		if gm.admin() {
+			gm.Send(packetMessageNotice(fmt.Sprintf("New report #%d: %s reported %s for %s",
+				rep.ID, rep.ReporterName, rep.TargetName, reportReason(rep.Reason))))
+		}
+	})
+}
+
+// reportError turns the repository errors from claiming and resolving into something a GM can act on
+func reportError(reportID int32, err error) string {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return fmt.Sprintf("Report #%d does not exist or has already been resolved", reportID)
+	case errors.Is(err, repository.ErrDuplicate):
+		return fmt.Sprintf("Report #%d has been claimed by another GM", reportID)
+	}
+
+	return err.Error()
+}
+
+// sendReport shows a report and its chat excerpt to a GM
+func sendReport(conn mnet.Client, rep repository.Report) {
+	conn.Send(packetMessageNotice(fmt.Sprintf("Report #%d (%s) at %s: %s reported %s for %s on map %d",
+		rep.ID, reportStatus(rep), rep.CreatedAt.Format("2006-01-02 15:04"), rep.ReporterName, rep.TargetName,
+		reportReason(rep.Reason), rep.MapID)))
+
+	if rep.Description != "" {
+		conn.Send(packetMessageNotice("Description: " + rep.Description))
+	}
+
+	if rep.ChatLog == "" {
+		conn.Send(packetMessageNotice("No chat was recorded"))
+		return
+	}
+
+	for _, line := range strings.Split(rep.ChatLog, "\n") {
+		conn.Send(packetMessageNotice(line))
+	}
+}
+
+// resolveReport closes the report, banning the reported account first if hours is not negative (0 is permanent)
+func (server *Server) resolveReport(gm string, reportID int32, hours int, note string) error {
+	rep, err := common.Repo.Reports.ByID(reportID)
+	if err != nil {
+		return err
+	}
+
+	if rep.Status == repository.ReportResolved {
+		return repository.ErrNotFound
+	}
+
+	if rep.Status == repository.ReportClaimed && rep.ClaimedBy != gm {
+		return repository.ErrDuplicate
+	}
+
+	resolution := "dismissed"
+
+	if hours >= 0 {
+		if server.ac == nil {
+			return errors.New("anti-cheat is not running, cannot ban")
+		}
+
+		if hours == 0 {
+			resolution = "banned permanently"
+		} else {
+			resolution = fmt.Sprintf("banned for %d hours", hours)
+		}
+	}
+
+	if note != "" {
+		resolution += ": " + note
+	}
+
+	// Resolve first, only the GM whose resolve went through goes on to ban
+	if err := common.Repo.Reports.Resolve(rep.ID, gm, resolution); err != nil {
+		return err
+	}
+
+	if hours >= 0 {
+		reason := fmt.Sprintf("Report #%d: %s", rep.ID, reportReason(rep.Reason))
+		if note != "" {
+			reason += " - " + note
+		}
+
+		if err := server.ac.IssueBan(rep.TargetAccountID, hours, reason, "", ""); err != nil {
+			log.Printf("Report #%d resolved by %s but the ban failed: %v", rep.ID, gm, err)
+			return fmt.Errorf("report #%d was resolved but the ban failed, ban %s with /ban: %w", rep.ID, rep.TargetName, err)
+		}
+	}
+
+	log.Printf("Report #%d resolved by %s, %s", rep.ID, gm, resolution)
+
+	if reporter, err := server.players.GetFromID(rep.ReporterID); err == nil {
+		reporter.Send(packetMessageNotice("Your report against " + rep.TargetName + " has been reviewed, thank you"))
+	}
+
+	return nil
+}
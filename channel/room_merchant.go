@@ -0,0 +1,669 @@
+package channel
+
+import (
+	"errors"
+	"log"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/common/opcode"
+	"github.com/Hucaru/Valhalla/constant"
+	"github.com/Hucaru/Valhalla/mpacket"
+	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+type merchantItem struct {
+	item         Item
+	price        int32
+	bundles      int16
+	bundleAmount int16
+}
+
+// merchantRoom is a player shop that keeps trading while its owner is away. The listed items and earnings live on the
+// merchant, not in the owner's inventory, and every change is written through to the database.
+type merchantRoom struct {
+	room
+	dbID      int32
+	title     string
+	open      bool
+	shut      bool
+	items     []*merchantItem
+	mesos     int32
+	expiresAt int64
+
+	owner  *Player // set whilst the owner is managing the merchant
+	avatar *Player // appearance of the owner, stands in for them whilst they are not in the field
+	inst   *fieldInstance
+}
+
+func newMerchantRoom(row repository.Merchant, avatar *Player, inst *fieldInstance) *merchantRoom {
+	r := &merchantRoom{
+		room:      room{ownerPlayerID: row.CharacterID, roomType: constant.MiniRoomTypePlayerShop},
+		dbID:      row.ID,
+		title:     row.Title,
+		open:      len(row.Items) > 0,
+		shut:      row.Closed,
+		items:     make([]*merchantItem, 0, len(row.Items)),
+		mesos:     row.Mesos,
+		expiresAt: row.ExpiresAt,
+		avatar:    avatar,
+		inst:      inst,
+	}
+
+	for _, v := range row.Items {
+		r.items = append(r.items, &merchantItem{
+			item:         merchantItemFromRow(v.Item),
+			price:        v.Price,
+			bundles:      v.Bundles,
+			bundleAmount: v.Item.Amount,
+		})
+	}
+
+	return r
+}
+
+func merchantItemFromRow(row repository.Item) Item {
+	item := itemFromRow(row)
+
+	if nxInfo, err := nx.GetItem(item.ID); err == nil {
+		item.cash = nxInfo.Cash
+	}
+
+	return item
+}
+
+// newMerchantAvatar copies the appearance of the owner as they set up the merchant
+func newMerchantAvatar(plr *Player) *Player {
+	avatar := &Player{
+		ID:     plr.ID,
+		Name:   plr.Name,
+		gender: plr.gender,
+		skin:   plr.skin,
+		face:   plr.face,
+		hair:   plr.hair,
+		stance: plr.stance,
+		pos:    plr.pos,
+	}
+
+	for _, v := range plr.equip {
+		if v.slotID < 0 {
+			avatar.equip = append(avatar.equip, v)
+		}
+	}
+
+	return avatar
+}
+
+func loadMerchantAvatar(row repository.Merchant) (*Player, error) {
+	char, err := common.Repo.Characters.ByID(row.CharacterID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := common.Repo.Items.ByCharacter(row.CharacterID)
+	if err != nil {
+		return nil, err
+	}
+
+	avatar := &Player{
+		ID:     char.ID,
+		Name:   char.Name,
+		gender: char.Gender,
+		skin:   char.Skin,
+		face:   char.Face,
+		hair:   char.Hair,
+		pos:    newPos(row.X, row.Y, row.Foothold),
+	}
+
+	for _, v := range items {
+		if v.Slot < 0 && v.InventoryID == constant.InventoryEquip {
+			avatar.equip = append(avatar.equip, itemFromRow(v))
+		}
+	}
+
+	return avatar, nil
+}
+
+func (r *merchantRoom) row() repository.Merchant {
+	row := repository.Merchant{
+		ID:          r.dbID,
+		CharacterID: r.ownerPlayerID,
+		Title:       r.title,
+		Mesos:       r.mesos,
+		Closed:      r.shut,
+		ExpiresAt:   r.expiresAt,
+		Items:       make([]repository.MerchantItem, 0, len(r.items)),
+	}
+
+	for _, v := range r.items {
+		row.Items = append(row.Items, repository.MerchantItem{Item: v.item.Row(), Bundles: v.bundles, Price: v.price})
+	}
+
+	return row
+}
+
+func (r *merchantRoom) save() error {
+	err := common.Repo.Merchants.Save(r.row())
+
+	if err != nil {
+		log.Printf("Merchant(%d) save failed: %v", r.dbID, err)
+	}
+
+	return err
+}
+
+func (r *merchantRoom) closed() bool {
+	return r.shut
+}
+
+func (r *merchantRoom) present(id int32) bool {
+	return (r.owner != nil && r.owner.ID == id) || r.room.present(id)
+}
+
+func (r *merchantRoom) send(p mpacket.Packet) {
+	r.owner.Send(p)
+	r.room.send(p)
+}
+
+func (r *merchantRoom) chatMsg(plr *Player, msg string) {
+	if r.owner != nil && r.owner.ID == plr.ID {
+		r.send(packetRoomChat(plr.Name, msg, constant.RoomOwnerSlot))
+		return
+	}
+
+	for i, v := range r.players {
+		if v.ID == plr.ID {
+			r.send(packetRoomChat(plr.Name, msg, byte(i+1)))
+		}
+	}
+}
+
+// windowPlayers returns the room occupants in slot order, the owner's slot is taken by the avatar whilst they are away
+func (r *merchantRoom) windowPlayers() []*Player {
+	owner := r.avatar
+	if r.owner != nil {
+		owner = r.owner
+	}
+
+	return append([]*Player{owner}, r.players...)
+}
+
+func (r *merchantRoom) addPlayer(plr *Player) bool {
+	if r.shut {
+		plr.Send(packetRoomClosed())
+		return false
+	}
+
+	if plr.ID == r.ownerPlayerID {
+		if r.owner != nil {
+			return false
+		}
+
+		// The owner puts the merchant into maintenance, visitors are sent away so stock cannot change under them
+		for i, v := range r.players {
+			v.Send(packetRoomLeave(byte(i+1), constant.MiniRoomClosed))
+		}
+
+		r.players = []*Player{}
+		r.owner = plr
+		r.open = false
+
+		plr.Send(packetRoomShowWindow(r.roomType, constant.MiniRoomTypePlayerShop, byte(constant.ShopMaxPlayers), constant.RoomOwnerSlot, r.title, r.windowPlayers()))
+		plr.Send(packetRoomMerchantRefresh(r))
+
+		return true
+	}
+
+	if !r.open {
+		plr.Send(packetRoomStoreMaintenance())
+		return false
+	}
+
+	if len(r.players)+1 >= constant.ShopMaxPlayers {
+		plr.Send(packetRoomFull())
+		return false
+	}
+
+	if r.room.present(plr.ID) {
+		return false
+	}
+
+	r.players = append(r.players, plr)
+	slot := byte(len(r.players))
+
+	plr.Send(packetRoomShowWindow(r.roomType, constant.MiniRoomTypePlayerShop, byte(constant.ShopMaxPlayers), slot, r.title, r.windowPlayers()))
+	plr.Send(packetRoomMerchantRefresh(r))
+	r.room.sendExcept(packetRoomJoin(r.roomType, slot, plr), plr)
+
+	return true
+}
+
+// removePlayer takes the player out of the room, it reports true when the owner has left a merchant with nothing
+// left to sell which should then be closed
+func (r *merchantRoom) removePlayer(plr *Player) bool {
+	if r.owner != nil && r.owner.ID == plr.ID {
+		r.owner = nil
+		plr.Send(packetRoomLeave(constant.RoomOwnerSlot, constant.MiniRoomLeaveReason))
+
+		if len(r.items) == 0 {
+			return true
+		}
+
+		r.open = true
+		return false
+	}
+
+	for i, v := range r.players {
+		if v.ID == plr.ID {
+			r.players = append(r.players[:i], r.players[i+1:]...)
+			plr.Send(packetRoomLeave(byte(i+1), constant.MiniRoomLeaveReason))
+			r.send(packetRoomLeave(byte(i+1), constant.MiniRoomLeaveReason))
+			break
+		}
+	}
+
+	return false
+}
+
+func (r *merchantRoom) kickAll(reason byte) {
+	if r.owner != nil {
+		r.owner.Send(packetRoomLeave(constant.RoomOwnerSlot, reason))
+		r.owner = nil
+	}
+
+	for i, v := range r.players {
+		v.Send(packetRoomLeave(byte(i+1), reason))
+	}
+
+	r.players = []*Player{}
+}
+
+// addItem moves the items from the owner's inventory onto the merchant
+func (r *merchantRoom) addItem(owner *Player, invID byte, slotID int16, bundles, bundleAmount int16, price int32) bool {
+	if len(r.items) >= constant.HiredMerchantMaxItems || bundles <= 0 || bundleAmount <= 0 || price < 0 {
+		return false
+	}
+
+	cur, err := owner.getItem(invID, slotID)
+	if err != nil || cur.pet {
+		return false
+	}
+
+	if cur.isRechargeable() {
+		bundles = 1
+		bundleAmount = cur.amount
+	}
+
+	need := int32(bundles) * int32(bundleAmount)
+	if need > int32(cur.amount) {
+		return false
+	}
+
+	listed := cur
+	listed.amount = bundleAmount
+	listed.dbID = 0
+	listed.slotID = 0
+
+	r.items = append(r.items, &merchantItem{item: listed, price: price, bundles: bundles, bundleAmount: bundleAmount})
+
+	// Record the listing before the item leaves the inventory so a failed write cannot lose it
+	if r.save() != nil {
+		r.items = r.items[:len(r.items)-1]
+		return false
+	}
+
+	if cur.isRechargeable() {
+		owner.removeItem(cur, false)
+	} else if _, err := owner.takeItem(cur.ID, cur.slotID, int16(need), cur.invID); err != nil {
+		r.items = r.items[:len(r.items)-1]
+		_ = r.save()
+		return false
+	}
+
+	return true
+}
+
+// removeItem gives the remaining bundles of a listing back to the owner
+func (r *merchantRoom) removeItem(owner *Player, slot byte) bool {
+	if int(slot) >= len(r.items) {
+		return false
+	}
+
+	mi := r.items[slot]
+
+	returned := mi.item
+	returned.amount = mi.bundles * mi.bundleAmount
+
+	if !owner.canReceiveItems([]Item{returned}) {
+		owner.Send(packetMessageRedText("Please make room in your inventory first."))
+		return false
+	}
+
+	r.items = slices.Delete(r.items, int(slot), int(slot)+1)
+
+	if r.save() != nil {
+		r.items = slices.Insert(r.items, int(slot), mi)
+		return false
+	}
+
+	if err, _ := owner.GiveItem(returned); err != nil {
+		log.Printf("Merchant(%d) could not return item %d to owner: %v", r.dbID, returned.ID, err)
+	}
+
+	return true
+}
+
+func (r *merchantRoom) buyItem(buyer *Player, slot byte, quantity int16) byte {
+	if int(slot) >= len(r.items) {
+		return constant.PlayerShopNotEnoughInStock
+	}
+
+	mi := r.items[slot]
+
+	if quantity <= 0 || mi.bundles < quantity {
+		return constant.PlayerShopNotEnoughInStock
+	}
+
+	totalCost := int64(mi.price) * int64(quantity)
+	if totalCost > math.MaxInt32 || int64(r.mesos)+totalCost > math.MaxInt32 {
+		return constant.PlayerShopPriceTooHighForTrade
+	}
+
+	if int64(buyer.mesos) < totalCost {
+		return constant.PlayerShopBuyerNotEnoughMoney
+	}
+
+	purchased := mi.item
+	purchased.amount = quantity * mi.bundleAmount
+
+	if !buyer.canReceiveItems([]Item{purchased}) {
+		return constant.PlayerShopInventoryFull
+	}
+
+	mi.bundles -= quantity
+	r.mesos += int32(totalCost)
+
+	if mi.bundles == 0 {
+		r.items = slices.Delete(r.items, int(slot), int(slot)+1)
+	}
+
+	if r.save() != nil {
+		if mi.bundles == 0 {
+			r.items = slices.Insert(r.items, int(slot), mi)
+		}
+
+		mi.bundles += quantity
+		r.mesos -= int32(totalCost)
+
+		return constant.PlayerShopNotEnoughInStock
+	}
+
+	buyer.takeMesos(int32(totalCost))
+
+	if err, _ := buyer.GiveItem(purchased); err != nil {
+		log.Printf("Merchant(%d) could not give item %d to buyer %d: %v", r.dbID, purchased.ID, buyer.ID, err)
+	}
+
+	return 0
+}
+
+func (r *merchantRoom) displayBytes() []byte {
+	p := mpacket.NewPacket()
+
+	p.WriteInt32(r.ownerPlayerID)
+	p.WriteByte(r.roomType)
+	p.WriteInt32(r.roomID)
+	p.WriteString(r.title)
+	p.WriteBool(false)
+	p.WriteByte(0)
+	p.WriteByte(byte(len(r.windowPlayers())))
+	p.WriteByte(constant.ShopMaxPlayers)
+	p.WriteBool(r.open)
+
+	return p
+}
+
+// merchantOf returns the hired merchant in the pool owned by the character
+func (pool roomPool) merchantOf(charID int32) *merchantRoom {
+	for _, r := range pool.rooms {
+		if m, ok := r.(*merchantRoom); ok && m.ownerID() == charID {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func (server *Server) createMerchant(plr *Player, inst *fieldInstance, title string, permitID int32) {
+	if plr.mapID < constant.MapFreeMarketRoomFirst || plr.mapID > constant.MapFreeMarketRoomLast {
+		plr.Send(packetRoomPersonalStoreFMOnly())
+		return
+	}
+
+	if permitID/10000 != constant.HiredMerchantItemType || plr.countItem(permitID) < 1 {
+		plr.Send(packetRoomThisCharacterNotAllowed())
+		return
+	}
+
+	if _, err := inst.roomPool.getPlayerRoom(plr.ID); err == nil {
+		plr.Send(packetRoomBusy())
+		return
+	}
+
+	if _, err := common.Repo.Merchants.ByCharacter(plr.ID); err == nil {
+		plr.Send(packetMessageRedText("Please collect your goods from Fredrick before opening another hired merchant."))
+		return
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		log.Println("Merchant lookup failed:", err)
+		return
+	}
+
+	row := repository.Merchant{
+		CharacterID: plr.ID,
+		ChannelID:   server.id,
+		MapID:       plr.mapID,
+		X:           plr.pos.x,
+		Y:           plr.pos.y,
+		Foothold:    plr.pos.foothold,
+		Title:       title,
+		ExpiresAt:   time.Now().Add(constant.HiredMerchantHours * time.Hour).UnixMilli(),
+	}
+
+	if err := common.Repo.Merchants.Create(&row); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			plr.Send(packetMessageRedText("Please collect your goods from Fredrick before opening another hired merchant."))
+		} else {
+			log.Println("Merchant create failed:", err)
+		}
+		return
+	}
+
+	r := newMerchantRoom(row, newMerchantAvatar(plr), inst)
+
+	if !r.addPlayer(plr) {
+		return
+	}
+
+	if err := inst.roomPool.addRoom(r); err != nil {
+		log.Println(err)
+		return
+	}
+
+	server.merchants[plr.ID] = r
+}
+
+// closeMerchant stops the merchant trading and leaves anything unsold with Fredrick
+func (server *Server) closeMerchant(r *merchantRoom) {
+	r.kickAll(constant.MiniRoomClosed)
+	r.shut = true
+	r.open = false
+
+	if len(r.items) == 0 && r.mesos == 0 {
+		if err := common.Repo.Merchants.Delete(r.dbID); err != nil {
+			log.Printf("Merchant(%d) delete failed: %v", r.dbID, err)
+		}
+	} else {
+		_ = r.save()
+
+		if owner, err := server.players.GetFromID(r.ownerID()); err == nil {
+			owner.Send(packetMessageRedText("Your hired merchant has closed, visit Fredrick to collect your goods."))
+		}
+	}
+
+	if err := r.inst.roomPool.removeRoom(r.id()); err != nil {
+		log.Println(err)
+	}
+
+	if _, err := r.inst.getPlayerFromID(r.ownerID()); err != nil {
+		r.inst.send(packetMapPlayerLeft(r.ownerID()))
+	}
+
+	delete(server.merchants, r.ownerID())
+}
+
+// loadMerchants places the merchants that were trading on this channel back into their fields
+func (server *Server) loadMerchants() {
+	rows, err := common.Repo.Merchants.Trading(server.id)
+	if err != nil {
+		log.Println("Failed to load hired merchants:", err)
+		return
+	}
+
+	for _, row := range rows {
+		if _, ok := server.merchants[row.CharacterID]; ok {
+			continue
+		}
+
+		field, ok := server.fields[row.MapID]
+		if !ok {
+			continue
+		}
+
+		inst, err := field.getInstance(0)
+		if err != nil {
+			continue
+		}
+
+		avatar, err := loadMerchantAvatar(row)
+		if err != nil {
+			log.Printf("Merchant(%d) owner could not be loaded: %v", row.ID, err)
+			continue
+		}
+
+		r := newMerchantRoom(row, avatar, inst)
+
+		if err := inst.roomPool.addRoom(r); err != nil {
+			log.Println(err)
+			continue
+		}
+
+		inst.send(packetMapMerchantAvatar(avatar))
+		inst.roomPool.updateGameBox(r)
+
+		server.merchants[row.CharacterID] = r
+	}
+
+	log.Println("Loaded", len(rows), "hired merchants")
+}
+
+func (server *Server) closeExpiredMerchants() {
+	now := time.Now().UnixMilli()
+
+	for _, r := range server.merchants {
+		if r.expiresAt <= now {
+			server.closeMerchant(r)
+		}
+	}
+}
+
+func scheduleMerchantExpiry(server *Server) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		server.dispatch <- func() {
+			server.closeExpiredMerchants()
+		}
+	}
+}
+
+// collectMerchant hands the unsold items and earnings of a closed merchant to its owner
+func (server *Server) collectMerchant(plr *Player) bool {
+	row, err := common.Repo.Merchants.ByCharacter(plr.ID)
+	if err != nil || !row.Closed {
+		return false
+	}
+
+	items := make([]Item, 0, len(row.Items))
+
+	for _, v := range row.Items {
+		item := merchantItemFromRow(v.Item)
+		item.amount = v.Bundles * v.Item.Amount
+		items = append(items, item)
+	}
+
+	if !plr.canReceiveItems(items) || int64(plr.mesos)+int64(row.Mesos) > math.MaxInt32 {
+		return false
+	}
+
+	if err := common.Repo.Merchants.Delete(row.ID); err != nil {
+		log.Printf("Merchant(%d) delete failed: %v", row.ID, err)
+		return false
+	}
+
+	for _, item := range items {
+		if err, _ := plr.GiveItem(item); err != nil {
+			log.Printf("Merchant(%d) could not return item %d to owner: %v", row.ID, item.ID, err)
+		}
+	}
+
+	if row.Mesos > 0 {
+		plr.giveMesos(row.Mesos)
+	}
+
+	return true
+}
+
+func packetRoomMerchantRefresh(r *merchantRoom) mpacket.Packet {
+	p := mpacket.CreateWithOpcode(opcode.SendChannelRoom)
+	p.WriteByte(constant.RoomShopRefresh)
+	p.WriteByte(byte(len(r.items)))
+
+	for _, v := range r.items {
+		p.WriteInt16(v.bundles)
+		p.WriteInt16(v.bundleAmount)
+		p.WriteInt32(v.price)
+		p.Append(v.item.StorageBytes())
+	}
+
+	return p
+}
+
+// packetMapMerchantAvatar shows the owner's avatar for the merchant game box to attach to whilst they are away
+func packetMapMerchantAvatar(avatar *Player) mpacket.Packet {
+	p := mpacket.CreateWithOpcode(opcode.SendChannelCharacterEnterField)
+	p.WriteInt32(avatar.ID)
+	p.WriteString(avatar.Name)
+	p.WriteInt32(0) // guild
+	p.WriteInt32(0)
+	p.WriteInt32(0)
+	p.WriteInt32(0)
+
+	avatar.encodeDisplayBytes(&p)
+
+	p.WriteInt32(0) // Active Item ID
+	p.WriteInt32(0) // Choco count
+	p.WriteInt32(0) // chair
+
+	p.WriteInt16(avatar.pos.x)
+	p.WriteInt16(avatar.pos.y)
+	p.WriteByte(avatar.stance)
+	p.WriteInt16(avatar.pos.foothold)
+	p.WriteBool(false) // hidden
+	p.WriteInt32(0)
+
+	return p
+}
@@ -2,10 +2,10 @@ package channel
 
 import (
 	"log"
-	"strings"
 	"time"
 
 	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 // DirtyBits mark which character columns need persisting.
@@ -72,7 +72,10 @@ type snapshot struct {
 
 	Skills map[int32]playerSkill
 
-	Pet *pet
+	// Pets are copies of the pet rows, the pets themselves keep changing on the game thread
+	Pets []repository.Pet
+	// PetEquips of the pets whose equipment changed, by pet
+	PetEquips map[int64]map[byte]int64
 	
 	RegTeleportRocks []int32
 	VipTeleportRocks []int32
@@ -159,7 +162,14 @@ func snapshotFromPlayer(p *Player) snapshot {
 		MiniGameLoss:   p.miniGameLoss,
 		MiniGamePoints: p.miniGamePoints,
 		BuddyListSize:  p.buddyListSize,
-		Pet:            p.pet,
+	}
+
+	for _, pt := range p.ownedPets() {
+		s.Pets = append(s.Pets, pt.row())
+
+		if pt.equipsDirty {
+			s.addPetEquips(map[int64]map[byte]int64{pt.itemDBID: pt.equipIDs()})
+		}
 	}
 
 	if p.dirty&DirtySkills != 0 {
@@ -210,6 +220,7 @@ func scheduleSave(p *Player, delay time.Duration) {
 	}
 	select {
 	case saverInst.schedCh <- req:
+		p.petEquipsQueued(req.snap)
 	default:
 		// drop under extreme pressure
 	}
@@ -236,10 +247,14 @@ func flushNow(p *Player) {
 	select {
 	case saverInst.flushCh <- req:
 		<-done
+		if snap != nil {
+			p.petEquipsQueued(*snap)
+		}
 	default:
 		// channel saturated: fallback to direct sync persist
 		job := pendingSave{bits: bits, snap: snapshotFromPlayer(p)}
 		saverInst.persist(job)
+		p.petEquipsQueued(job.snap)
 	}
 }
 
@@ -274,8 +289,14 @@ func (s *saver) loop() {
 				job = pendingSave{bits: 0, snap: snapshot{ID: req.id}}
 			}
 			if req.overrideSnap != nil {
+				// Pet equipment is only in the snapshot taken after it changed, so what is pending is kept
+				pending := job.snap.PetEquips
 				job.snap = *req.overrideSnap
 				job.bits |= req.overrideBits
+				newer := job.snap.PetEquips
+				job.snap.PetEquips = nil
+				job.snap.addPetEquips(pending)
+				job.snap.addPetEquips(newer)
 			}
 			s.persist(job)
 			if req.done != nil {
@@ -325,9 +346,10 @@ func mergeSnapshot(lhs *snapshot, rhs snapshot) {
 
 	lhs.BuddyListSize = rhs.BuddyListSize
 
-	if rhs.Pet != nil {
-		lhs.Pet = rhs.Pet
+	if rhs.Pets != nil {
+		lhs.Pets = rhs.Pets
 	}
+	lhs.addPetEquips(rhs.PetEquips)
 
 	if rhs.Skills != nil {
 		lhs.Skills = rhs.Skills
@@ -335,129 +357,141 @@ func mergeSnapshot(lhs *snapshot, rhs snapshot) {
 
 }
 
-func (s *saver) persist(job pendingSave) bool {
-	if job.bits == 0 {
-		return true
+// addPetEquips adds the pets' equipment to the snapshot, replacing what it had for the same pets
+func (snap *snapshot) addPetEquips(equips map[int64]map[byte]int64) {
+	if len(equips) == 0 {
+		return
 	}
 
-	cols := make([]string, 0, 24)
-	args := make([]any, 0, 24)
-
-	if job.bits&DirtyAP != 0 {
-		cols = append(cols, "ap=?")
-		args = append(args, job.snap.AP)
-	}
-	if job.bits&DirtySP != 0 {
-		cols = append(cols, "sp=?")
-		args = append(args, job.snap.SP)
-	}
-	if job.bits&DirtyMesos != 0 {
-		cols = append(cols, "mesos=?")
-		args = append(args, job.snap.Mesos)
-	}
-	if job.bits&DirtyHP != 0 {
-		cols = append(cols, "hp=?")
-		args = append(args, job.snap.HP)
-	}
-	if job.bits&DirtyMaxHP != 0 {
-		cols = append(cols, "maxHP=?")
-		args = append(args, job.snap.MaxHP)
-	}
-	if job.bits&DirtyMP != 0 {
-		cols = append(cols, "mp=?")
-		args = append(args, job.snap.MP)
-	}
-	if job.bits&DirtyMaxMP != 0 {
-		cols = append(cols, "maxMP=?")
-		args = append(args, job.snap.MaxMP)
-	}
-	if job.bits&DirtyEXP != 0 {
-		cols = append(cols, "exp=?")
-		args = append(args, job.snap.EXP)
-	}
-	if job.bits&DirtyMap != 0 {
-		cols = append(cols, "mapID=?, mapPos=?")
-		args = append(args, job.snap.MapID, job.snap.MapPos)
-	}
-	if job.bits&DirtyPrevMap != 0 {
-		cols = append(cols, "previousMapID=?")
-		args = append(args, job.snap.PrevMapID)
-	}
-	if job.bits&DirtyJob != 0 {
-		cols = append(cols, "job=?")
-		args = append(args, job.snap.Job)
-	}
-	if job.bits&DirtyLevel != 0 {
-		cols = append(cols, "level=?")
-		args = append(args, job.snap.Level)
-	}
-	if job.bits&DirtyStr != 0 {
-		cols = append(cols, "str=?")
-		args = append(args, job.snap.Str)
+	if snap.PetEquips == nil {
+		snap.PetEquips = make(map[int64]map[byte]int64, len(equips))
 	}
-	if job.bits&DirtyDex != 0 {
-		cols = append(cols, "dex=?")
-		args = append(args, job.snap.Dex)
-	}
-	if job.bits&DirtyInt != 0 {
-		cols = append(cols, "intt=?")
-		args = append(args, job.snap.Intt)
-	}
-	if job.bits&DirtyLuk != 0 {
-		cols = append(cols, "luk=?")
-		args = append(args, job.snap.Luk)
-	}
-	if job.bits&DirtyFame != 0 {
-		cols = append(cols, "fame=?")
-		args = append(args, job.snap.Fame)
+
+	for petID, e := range equips {
+		snap.PetEquips[petID] = e
 	}
-	if job.bits&DirtyInvSlotSizes != 0 {
-		cols = append(cols, "equipSlotSize=?,useSlotSize=?,setupSlotSize=?,etcSlotSize=?,cashSlotSize=?")
-		args = append(args, job.snap.EquipSlotSize, job.snap.UseSlotSize, job.snap.SetupSlotSize, job.snap.EtcSlotSize, job.snap.CashSlotSize)
+}
+
+// petEquipsQueued clears the changed flag of the pets whose equipment is in a snapshot queued to be saved
+func (p *Player) petEquipsQueued(snap snapshot) {
+	for _, pt := range p.ownedPets() {
+		if _, ok := snap.PetEquips[pt.itemDBID]; ok {
+			pt.equipsDirty = false
+		}
 	}
-	if job.bits&DirtyMiniGame != 0 {
-		cols = append(cols, "miniGameWins=?,miniGameDraw=?,miniGameLoss=?,miniGamePoints=?")
-		args = append(args, job.snap.MiniGameWins, job.snap.MiniGameDraw, job.snap.MiniGameLoss, job.snap.MiniGamePoints)
+}
+
+// characterColumns maps the dirty bits stored on the characters table to the repository's columns
+var characterColumns = []struct {
+	dirty DirtyBits
+	field repository.CharacterFields
+}{
+	{DirtyAP, repository.CharacterAP},
+	{DirtySP, repository.CharacterSP},
+	{DirtyMesos, repository.CharacterMesos},
+	{DirtyHP, repository.CharacterHP},
+	{DirtyMaxHP, repository.CharacterMaxHP},
+	{DirtyMP, repository.CharacterMP},
+	{DirtyMaxMP, repository.CharacterMaxMP},
+	{DirtyEXP, repository.CharacterExp},
+	{DirtyMap, repository.CharacterMap},
+	{DirtyPrevMap, repository.CharacterPreviousMap},
+	{DirtyJob, repository.CharacterJob},
+	{DirtyLevel, repository.CharacterLevel},
+	{DirtyStr, repository.CharacterStr},
+	{DirtyDex, repository.CharacterDex},
+	{DirtyInt, repository.CharacterInt},
+	{DirtyLuk, repository.CharacterLuk},
+	{DirtyFame, repository.CharacterFame},
+	{DirtyInvSlotSizes, repository.CharacterSlotSizes},
+	{DirtyMiniGame, repository.CharacterMiniGame},
+	{DirtyBuddySize, repository.CharacterBuddyListSize},
+	{DirtyTeleportRocks, repository.CharacterTeleportRocks},
+}
+
+// characterData holds the snapshot's columns of the characters table
+func (snap snapshot) characterData() repository.CharacterData {
+	return repository.CharacterData{
+		Character: repository.Character{
+			ID:            snap.ID,
+			AccountID:     snap.AccountID,
+			Level:         snap.Level,
+			Job:           snap.Job,
+			MapID:         snap.MapID,
+			BuddyListSize: snap.BuddyListSize,
+		},
+		Str:              snap.Str,
+		Dex:              snap.Dex,
+		Intt:             snap.Intt,
+		Luk:              snap.Luk,
+		HP:               snap.HP,
+		MaxHP:            snap.MaxHP,
+		MP:               snap.MP,
+		MaxMP:            snap.MaxMP,
+		AP:               snap.AP,
+		SP:               snap.SP,
+		Exp:              snap.EXP,
+		Fame:             snap.Fame,
+		MapPos:           snap.MapPos,
+		PreviousMapID:    snap.PrevMapID,
+		Mesos:            snap.Mesos,
+		EquipSlotSize:    snap.EquipSlotSize,
+		UseSlotSize:      snap.UseSlotSize,
+		SetupSlotSize:    snap.SetupSlotSize,
+		EtcSlotSize:      snap.EtcSlotSize,
+		CashSlotSize:     snap.CashSlotSize,
+		MiniGameWins:     snap.MiniGameWins,
+		MiniGameDraw:     snap.MiniGameDraw,
+		MiniGameLoss:     snap.MiniGameLoss,
+		MiniGamePoints:   snap.MiniGamePoints,
+		RegTeleportRocks: serializeTeleportRocks(snap.RegTeleportRocks),
+		VIPTeleportRocks: serializeTeleportRocks(snap.VipTeleportRocks),
 	}
-	if job.bits&DirtyBuddySize != 0 {
-		cols = append(cols, "buddyListSize=?")
-		args = append(args, job.snap.BuddyListSize)
+}
+
+func (s *saver) persist(job pendingSave) bool {
+	if job.bits == 0 {
+		return true
 	}
-	if job.bits&DirtyTeleportRocks != 0 {
-		cols = append(cols, "regTeleportRocks=?, vipTeleportRocks=?")
-		args = append(args, serializeTeleportRocks(job.snap.RegTeleportRocks), serializeTeleportRocks(job.snap.VipTeleportRocks))
+
+	var fields repository.CharacterFields
+	for _, c := range characterColumns {
+		if job.bits&c.dirty != 0 {
+			fields |= c.field
+		}
 	}
 
-	if len(cols) > 0 {
-		query := "UPDATE characters SET " + strings.Join(cols, ",") + " WHERE ID=?"
-		args = append(args, job.snap.ID)
-		if _, err := common.DB.Exec(query, args...); err != nil {
+	if fields != 0 {
+		if err := common.Repo.Characters.Update(job.snap.characterData(), fields); err != nil {
 			log.Printf("saver.persist: UPDATE characters (ID=%d) failed: %v", job.snap.ID, err)
 		}
 	}
 
 	if job.bits&DirtySkills != 0 && len(job.snap.Skills) > 0 {
-		upsert := `INSERT INTO skills(characterID,skillID,level,cooldown)
-		           VALUES(?,?,?,?)
-		           ON DUPLICATE KEY UPDATE level=VALUES(level), cooldown=VALUES(cooldown)`
 		for sid, srec := range job.snap.Skills {
-			if _, err := common.DB.Exec(upsert, job.snap.ID, sid, srec.Level, srec.Cooldown); err != nil {
+			if err := common.Repo.Skills.Save(job.snap.ID, repository.Skill{ID: sid, Level: srec.Level, Cooldown: srec.Cooldown}); err != nil {
 				log.Printf("saver.persist: upsert skill %d for char %d failed: %v", sid, job.snap.ID, err)
 			}
 		}
 	}
 
 	if job.bits&DirtyNX != 0 || job.bits&DirtyMaplePoints != 0 {
-		query := "UPDATE accounts SET nx=?, maplepoints=? WHERE accountID=?"
-		if _, err := common.DB.Exec(query, job.snap.NX, job.snap.MaplePoints, job.snap.AccountID); err != nil {
+		if err := common.Repo.Accounts.SetCash(job.snap.AccountID, job.snap.NX, job.snap.MaplePoints); err != nil {
 			log.Printf("saver.persist: UPDATE accounts (ID=%d) failed: %v", job.snap.AccountID, err)
 		}
 	}
 
 	if job.bits&DirtyPet != 0 {
-		query := "UPDATE pets SET name=?, sn=?, level=?, closeness=?, fullness=?, deadDate=?, spawnDate=?, lastInteraction=? WHERE parentID=?"
-		if _, err := common.DB.Exec(query, job.snap.Pet.name, job.snap.Pet.sn, job.snap.Pet.level, job.snap.Pet.closeness, job.snap.Pet.fullness, job.snap.Pet.deadDate, job.snap.Pet.spawnDate, job.snap.Pet.lastInteraction, job.snap.Pet.itemDBID); err != nil {
-			log.Printf("saver.persist: UPDATE pets (itemID=%d) failed: %v", job.snap.Pet.itemDBID, err)
+		for _, pet := range job.snap.Pets {
+			if err := common.Repo.Pets.Update(pet); err != nil {
+				log.Printf("saver.persist: UPDATE pets (itemID=%d) failed: %v", pet.ItemID, err)
+			}
+		}
+
+		for petID, equips := range job.snap.PetEquips {
+			if err := common.Repo.PetEquips.Save(petID, equips); err != nil {
+				log.Printf("saver.persist: save pet equips (itemID=%d) failed: %v", petID, err)
+			}
 		}
 	}
 
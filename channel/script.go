@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/internal"
 	"github.com/Hucaru/Valhalla/mnet"
@@ -478,6 +479,60 @@ func (ctrl *scriptPlayerWrapper) SetMaplePoints(points int32) {
 	ctrl.plr.SetMaplePoints(points)
 }
 
+func (ctrl *scriptPlayerWrapper) HasMerchant() bool {
+	_, err := common.Repo.Merchants.ByCharacter(ctrl.plr.ID)
+	return err == nil
+}
+
+func (ctrl *scriptPlayerWrapper) MerchantOpen() bool {
+	row, err := common.Repo.Merchants.ByCharacter(ctrl.plr.ID)
+	return err == nil && !row.Closed
+}
+
+// MerchantChannel is the channel number shown to players that the merchant trades on
+func (ctrl *scriptPlayerWrapper) MerchantChannel() int {
+	row, err := common.Repo.Merchants.ByCharacter(ctrl.plr.ID)
+	if err != nil {
+		return 0
+	}
+
+	return int(row.ChannelID) + 1
+}
+
+func (ctrl *scriptPlayerWrapper) MerchantItemCount() int {
+	row, err := common.Repo.Merchants.ByCharacter(ctrl.plr.ID)
+	if err != nil {
+		return 0
+	}
+
+	return len(row.Items)
+}
+
+func (ctrl *scriptPlayerWrapper) MerchantMesos() int32 {
+	row, err := common.Repo.Merchants.ByCharacter(ctrl.plr.ID)
+	if err != nil {
+		return 0
+	}
+
+	return row.Mesos
+}
+
+// CloseMerchant closes the player's merchant, only merchants trading on this channel can be reached
+func (ctrl *scriptPlayerWrapper) CloseMerchant() bool {
+	r, ok := ctrl.server.merchants[ctrl.plr.ID]
+	if !ok {
+		return false
+	}
+
+	ctrl.server.closeMerchant(r)
+
+	return true
+}
+
+func (ctrl *scriptPlayerWrapper) CollectMerchant() bool {
+	return ctrl.server.collectMerchant(ctrl.plr)
+}
+
 func (ctrl *scriptPlayerWrapper) SetFame(value int16) {
 	ctrl.plr.setFame(value)
 }
@@ -662,6 +717,133 @@ func (ctrl *scriptPlayerWrapper) LeavePartyQuest() {
 	}
 }
 
+// OpenRaid opens a signup list for the raid script with the player as its leader
+func (ctrl *scriptPlayerWrapper) OpenRaid(name string) bool {
+	if err := ctrl.server.openRaid(ctrl.plr, name); err != nil {
+		ctrl.plr.Send(packetMessageRedText(err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// JoinRaid signs the player up to the open signup list of the raid script led by the named character
+func (ctrl *scriptPlayerWrapper) JoinRaid(name, leader string) bool {
+	for _, r := range ctrl.server.raidSignups(name) {
+		if plr, err := ctrl.server.players.GetFromID(r.leaderID); err != nil || plr.Name != leader {
+			continue
+		}
+
+		if err := r.join(ctrl.plr); err != nil {
+			ctrl.plr.Send(packetMessageRedText(err.Error()))
+			return false
+		}
+
+		return true
+	}
+
+	ctrl.plr.Send(packetMessageRedText("That raid signup list is no longer open"))
+
+	return false
+}
+
+func (ctrl *scriptPlayerWrapper) LeaveRaid() {
+	if ctrl.plr.raid != nil {
+		ctrl.plr.raid.leave(ctrl.plr)
+	}
+}
+
+// StartRaid takes the leader's signup list into the raid
+func (ctrl *scriptPlayerWrapper) StartRaid() bool {
+	if ctrl.plr.raid == nil {
+		return false
+	}
+
+	if err := ctrl.plr.raid.start(ctrl.plr); err != nil {
+		ctrl.plr.Send(packetMessageRedText(err.Error()))
+		return false
+	}
+
+	return true
+}
+
+func (ctrl *scriptPlayerWrapper) InRaid() bool {
+	return ctrl.plr.raid != nil
+}
+
+// RaidName is the script name of the raid the player has signed up to
+func (ctrl *scriptPlayerWrapper) RaidName() string {
+	if ctrl.plr.raid == nil {
+		return ""
+	}
+
+	return ctrl.plr.raid.script
+}
+
+func (ctrl *scriptPlayerWrapper) IsRaidLeader() bool {
+	return ctrl.plr.raid != nil && ctrl.plr.raid.leaderID == ctrl.plr.ID
+}
+
+// RaidMembers returns the names of those on the player's raid who are online on the channel
+func (ctrl *scriptPlayerWrapper) RaidMembers() []string {
+	if ctrl.plr.raid == nil {
+		return []string{}
+	}
+
+	players := ctrl.plr.raid.participants()
+	names := make([]string, len(players))
+
+	for i, plr := range players {
+		names[i] = plr.Name
+	}
+
+	return names
+}
+
+// RaidSignups returns the names of the leaders of the raid script's open signup lists
+func (ctrl *scriptPlayerWrapper) RaidSignups(name string) []string {
+	leaders := []string{}
+
+	for _, r := range ctrl.server.raidSignups(name) {
+		if plr, err := ctrl.server.players.GetFromID(r.leaderID); err == nil {
+			leaders = append(leaders, plr.Name)
+		}
+	}
+
+	return leaders
+}
+
+// RaidConfig returns the config of the raid script, null when there is no such raid
+func (ctrl *scriptPlayerWrapper) RaidConfig(name string) *raidConfig {
+	config, err := ctrl.server.raidConfig(ctrl.plr, name)
+
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	return &config
+}
+
+// RaidEntriesLeft returns how many more times the player can go into the raid today, -1 when there is no limit
+func (ctrl *scriptPlayerWrapper) RaidEntriesLeft(name string) int {
+	config, err := ctrl.server.raidConfig(ctrl.plr, name)
+
+	if err != nil {
+		log.Println(err)
+		return 0
+	}
+
+	left, err := raidEntriesLeft(ctrl.plr, name, config.Entries)
+
+	if err != nil {
+		log.Println(err)
+		return 0
+	}
+
+	return left
+}
+
 type scriptMapWrapper struct {
 	inst   *fieldInstance
 	server *Server
@@ -780,6 +962,7 @@ type npcChatController struct {
 
 	vm      *goja.Runtime
 	program *goja.Program
+	scripts *scriptStore
 
 	selectionCalls int
 }
@@ -790,6 +973,7 @@ func createNpcChatController(npcID int32, conn mnet.Client, program *goja.Progra
 		conn:    conn,
 		vm:      goja.New(),
 		program: program,
+		scripts: server.npcScriptStore,
 	}
 
 	plrCtrl := &scriptPlayerWrapper{
@@ -814,6 +998,27 @@ func (ctrl *npcChatController) Id() int32 {
 	return ctrl.npcID
 }
 
+// Include runs another script from the npc script folder in the conversation, so NPCs can share the functions it
+// declares e.g. raidMenu from raid_menu.js
+func (ctrl *npcChatController) Include(name string) bool {
+	if ctrl.scripts == nil {
+		return false
+	}
+
+	program, ok := ctrl.scripts.scripts[name]
+	if !ok {
+		log.Println("npc script include: could not find", name)
+		return false
+	}
+
+	if _, err := ctrl.vm.RunProgram(program); err != nil {
+		log.Println("npc script include:", name, err)
+		return false
+	}
+
+	return true
+}
+
 // SendNext simple next packet to Player
 func (ctrl *npcChatController) SendNext(text string) int {
 	if ctrl.stateTracker.performInterrupt() {
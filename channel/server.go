@@ -11,12 +11,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/Hucaru/Valhalla/anticheat"
+	"github.com/Hucaru/Valhalla/chatfilter"
+	"github.com/Hucaru/Valhalla/chatlog"
 	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/common/opcode"
 	"github.com/Hucaru/Valhalla/internal"
 	"github.com/Hucaru/Valhalla/mnet"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 type rates struct {
@@ -46,12 +49,32 @@ type Server struct {
 	parties          map[int32]*party
 	guilds           map[int32]*guild
 	events           map[int32]*event
+	merchants        map[int32]*merchantRoom
 	rates            rates
 	ac               *anticheat.AntiCheat
+	draining         bool
+	dataFiles        dataFiles
+	chatLog          *chatlog.Logger
+	violations       *common.BatchWriter[repository.Violation]
+	filter           *chatfilter.Filter
+
+	lieDetectors        map[int32]*lieDetectorTest
+	lieDetectorCooldown map[int32]time.Time
+
+	jailMap        int32
+	jailReleaseMap int32
+	jailTimers     map[int32]*time.Timer
+
+	petPotions petPotions
+
+	raidScriptStore *scriptStore
+	raids           map[int32]*raid // signups and running raids by raid ID
+	nextRaidID      int32
+	raidInstances   map[int32][]*fieldInstance // instances of boss maps left over from finished raids
 }
 
 // Initialise the server
-func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress, dbport, dbdatabase, dropsJson, reactorJson, reactorDropsJson string) {
+func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress, dbport, dbdatabase, dropsJson, reactorJson, reactorDropsJson, chatFilterJson string) {
 	server.dispatch = work
 
 	if err := common.ConnectToDB(dbuser, dbpassword, dbaddress, dbport, dbdatabase); err != nil {
@@ -89,6 +112,15 @@ func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress
 	elapsed = time.Since(start)
 	log.Println("Loaded and parsed reactor drop data in", elapsed)
 
+	filter, err := chatfilter.Load(chatFilterJson)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server.filter = filter
+
+	server.dataFiles = dataFiles{drops: dropsJson, reactors: reactorJson, reactorDrops: reactorDropsJson}
+	dataMonitorOnce.Do(func() { go server.monitorData() })
+
 	for fieldID, nxMap := range nx.GetMaps() {
 		server.fields[fieldID] = &field{
 			id:       fieldID,
@@ -151,6 +183,15 @@ func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress
 		prometheus.MustRegister(common.MetricsGauges["party_count"])
 	}
 
+	if _, ok := common.MetricsCounters["anticheat_violations_total"]; !ok {
+		common.MetricsCounters["anticheat_violations_total"] = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anticheat_violations_total",
+			Help: "Total number of anti-cheat violations by type",
+		}, []string{"channel", "world", "type"})
+		prometheus.MustRegister(common.MetricsCounters["anticheat_violations_total"])
+	}
+
+	registerAdminHandlers()
 	common.StartMetrics()
 	log.Println("Started serving metrics on :" + common.MetricsPort)
 
@@ -160,16 +201,29 @@ func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress
 	server.parties = make(map[int32]*party)
 	server.guilds = make(map[int32]*guild)
 	server.events = make(map[int32]*event)
+	server.merchants = make(map[int32]*merchantRoom)
+	server.lieDetectors = make(map[int32]*lieDetectorTest)
+	server.lieDetectorCooldown = make(map[int32]time.Time)
+	server.jailTimers = make(map[int32]*time.Timer)
+	server.raids = make(map[int32]*raid)
+	server.raidInstances = make(map[int32][]*fieldInstance)
 
 	// Initialize anti-cheat
-	server.ac = anticheat.New(common.DB, server.dispatch)
+	server.violations = newViolationWriter(common.Repo.Violations)
+	server.ac = anticheat.New(common.Repo, server.dispatch)
 	server.ac.StartCleanup()
 	server.ac.SetOnBan(func(accountID int32) {
 		server.KickAccount(accountID)
 	})
+	server.ac.SetOnKick(server.KickAccount)
+	server.ac.SetOnViolation(server.recordViolation)
+	server.ac.SetOnJail(server.jailAccount)
 	log.Println("Anti-cheat initialized")
 
 	go scheduleBoats(server)
+	go scheduleMerchantExpiry(server)
+	go schedulePetHunger(server)
+	go scheduleItemExpiry(server)
 }
 
 func (server *Server) loadScripts() {
@@ -189,6 +243,29 @@ func (server *Server) loadScripts() {
 	log.Println("Loaded event scripts in", elapsed)
 
 	go server.eventScriptStore.monitor(func(name string, program *goja.Program) {})
+
+	server.raidScriptStore = createScriptStore("scripts/raid", server.dispatch) // make folder a config param
+	start = time.Now()
+	_ = server.raidScriptStore.loadScripts()
+	elapsed = time.Since(start)
+	log.Println("Loaded raid scripts in", elapsed)
+
+	go server.raidScriptStore.monitor(func(name string, program *goja.Program) {})
+}
+
+// SetChatLog where player chat is recorded, nil stops recording
+func (server *Server) SetChatLog(logger *chatlog.Logger) {
+	server.chatLog = logger
+}
+
+// SetAntiCheatPolicies overrides the default policy of each violation type given, shadow only logs what any policy
+// would have done
+func (server *Server) SetAntiCheatPolicies(policies map[string]anticheat.Policy, shadow bool) {
+	for violation, p := range policies {
+		server.ac.SetPolicy(violation, p)
+	}
+
+	server.ac.SetShadow(shadow)
 }
 
 // SendCountdownToPlayers - Send a countdown to players that appears as a clock
@@ -272,6 +349,10 @@ func (server *Server) ClientDisconnected(conn mnet.Client) {
 		removeMysticDoor(plr)
 	}
 
+	server.lieDetectorDisconnect(plr)
+	server.jailDisconnect(plr)
+	server.filter.Forget(plr.ID)
+
 	if field, ok := server.fields[plr.mapID]; ok {
 		if inst, ierr := field.getInstance(plr.inst.id); ierr == nil {
 			if remErr := inst.removePlayer(plr, true); remErr != nil {
@@ -280,6 +361,8 @@ func (server *Server) ClientDisconnected(conn mnet.Client) {
 		}
 	}
 
+	server.raidDisconnect(plr)
+
 	plr.Logout()
 
 	if _, ok := server.npcChat[conn]; ok {
@@ -308,10 +391,10 @@ func (server *Server) ClientDisconnected(conn mnet.Client) {
 	}
 	server.world.Send(internal.PacketChannelPlayerDisconnect(plr.ID, plr.Name, guildID))
 
-	if _, dbErr := common.DB.Exec("UPDATE characters SET channelID=? WHERE ID=?", -1, plr.ID); dbErr != nil {
+	if dbErr := common.Repo.Characters.SetChannel(plr.ID, -1); dbErr != nil {
 		log.Println(dbErr)
 	}
-	if _, dbErr := common.DB.Exec("UPDATE accounts SET isLogedIn=0 WHERE accountID=?", conn.GetAccountID()); dbErr != nil {
+	if dbErr := common.Repo.Accounts.SetLoggedIn(conn.GetAccountID(), false); dbErr != nil {
 		log.Println("Unable to complete logout for ", conn.GetAccountID())
 	}
 }
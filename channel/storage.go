@@ -1,11 +1,12 @@
 package channel
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
-	"sort"
+	"log"
 
 	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 // Storage capacity bounds
@@ -43,16 +44,10 @@ func (s *storage) ensureCapacity() {
 }
 
 func (s *storage) load(accountID int32) error {
-	var slots, mesos sql.NullInt64
-	if err := common.DB.QueryRow(
-		"SELECT slots, mesos FROM account_storage WHERE accountID=?",
-		accountID,
-	).Scan(&slots, &mesos); err != nil {
-		if err == sql.ErrNoRows {
-			if _, ierr := common.DB.Exec(
-				"INSERT INTO account_storage(accountID, slots, mesos) VALUES(?,?,?)",
-				accountID, storageMinSlots, 0,
-			); ierr != nil {
+	contents, err := common.Repo.Storage.Load(accountID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			if ierr := common.Repo.Storage.Create(accountID, storageMinSlots); ierr != nil {
 				return fmt.Errorf("couldn't initialize storage for account %d: %w", accountID, ierr)
 			}
 			s.maxSlots = storageMinSlots
@@ -61,49 +56,17 @@ func (s *storage) load(accountID int32) error {
 			s.totalSlotsUsed = 0
 			return nil
 		}
-		return fmt.Errorf("failed to load storage header for account %d: %w", accountID, err)
+		return fmt.Errorf("failed to load storage for account %d: %w", accountID, err)
 	}
 
-	if slots.Valid {
-		s.maxSlots = clampByte(byte(slots.Int64), storageMinSlots, storageMaxSlots)
-	} else {
-		s.maxSlots = storageMinSlots
-	}
-	if mesos.Valid {
-		s.mesos = int32(mesos.Int64)
-	}
+	s.maxSlots = clampByte(contents.Slots, storageMinSlots, storageMaxSlots)
+	s.mesos = contents.Mesos
 
 	s.ensureCapacity()
 	s.totalSlotsUsed = 0
 
-	rows, qerr := common.DB.Query(`
-		SELECT 
-			id, itemID, inventoryID, slotNumber, amount,
-			flag, upgradeSlots, level, str, dex, intt, luk, hp, mp,
-			watk, matk, wdef, mdef, accuracy, avoid, hands, speed, jump,
-			expireTime, creatorName
-		FROM account_storage_items
-		WHERE accountID=?
-		ORDER BY slotNumber ASC`, accountID)
-	if qerr != nil {
-		return fmt.Errorf("failed to load storage items for account %d: %w", accountID, qerr)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var it Item
-		var creator sql.NullString
-		if err := rows.Scan(
-			&it.dbID, &it.ID, &it.invID, &it.slotID, &it.amount,
-			&it.flag, &it.upgradeSlots, &it.scrollLevel, &it.str, &it.dex, &it.intt, &it.luk, &it.hp, &it.mp,
-			&it.watk, &it.matk, &it.wdef, &it.mdef, &it.accuracy, &it.avoid, &it.hands, &it.speed, &it.jump,
-			&it.expireTime, &creator,
-		); err != nil {
-			continue
-		}
-		if creator.Valid {
-			it.creatorName = creator.String
-		}
+	for _, row := range contents.Items {
+		it := itemFromRow(row)
 
 		if it.slotID <= 0 || int(it.slotID) > len(s.items) {
 			continue
@@ -114,86 +77,24 @@ func (s *storage) load(accountID int32) error {
 			s.totalSlotsUsed++
 		}
 	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error while reading storage items for account %d: %w", accountID, err)
-	}
 
 	return nil
 }
 
-func (s *storage) save(accountID int32) (err error) {
-	tx, terr := common.DB.Begin()
-	if terr != nil {
-		return fmt.Errorf("couldn't open transaction to save storage (acct %d): %w", accountID, terr)
-	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		}
-	}()
-
-	if _, uerr := tx.Exec(
-		"UPDATE account_storage SET slots=?, mesos=? WHERE accountID=?",
-		s.maxSlots, s.mesos, accountID,
-	); uerr != nil {
-		err = fmt.Errorf("failed to update storage header (acct %d): %w", accountID, uerr)
-		return
-	}
-
-	if _, derr := tx.Exec(
-		"DELETE FROM account_storage_items WHERE accountID=?",
-		accountID,
-	); derr != nil {
-		err = fmt.Errorf("failed to clear storage items (acct %d): %w", accountID, derr)
-		return
-	}
-
-	const ins = `
-		INSERT INTO account_storage_items(
-			accountID, itemID, inventoryID, slotNumber, amount, flag, upgradeSlots, level,
-			str, dex, intt, luk, hp, mp, watk, matk, wdef, mdef, accuracy, avoid, hands,
-			speed, jump, expireTime, creatorName
-		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
-	`
-	stmt, perr := tx.Prepare(ins)
-	if perr != nil {
-		err = fmt.Errorf("failed to prepare item insert (acct %d): %w", accountID, perr)
-		return
-	}
-	defer stmt.Close()
-
-	type rowKey struct{ slot int }
-	keys := make([]rowKey, 0, len(s.items))
-	for i := range s.items {
-		keys = append(keys, rowKey{slot: i})
-	}
-	sort.Slice(keys, func(i, j int) bool { return keys[i].slot < keys[j].slot })
+func (s *storage) save(accountID int32) error {
+	contents := repository.StorageContents{Slots: s.maxSlots, Mesos: s.mesos}
 
-	written := 0
-	for _, k := range keys {
-		it := s.items[k.slot]
+	for i, it := range s.items {
 		if it.ID == 0 || it.amount == 0 {
 			continue
 		}
 
-		slotNumber := int16(k.slot + 1)
-		if _, ierr := stmt.Exec(
-			accountID, it.ID, it.invID, slotNumber, it.amount, it.flag, it.upgradeSlots, it.scrollLevel,
-			it.str, it.dex, it.intt, it.luk, it.hp, it.mp, it.watk, it.matk, it.wdef, it.mdef, it.accuracy, it.avoid, it.hands,
-			it.speed, it.jump, it.expireTime, it.creatorName,
-		); ierr != nil {
-			err = fmt.Errorf("failed inserting item %d (acct %d, slot %d): %w", it.ID, accountID, slotNumber, ierr)
-			return
-		}
-		written++
+		row := it.Row()
+		row.Slot = int16(i + 1)
+		contents.Items = append(contents.Items, row)
 	}
 
-	if cerr := tx.Commit(); cerr != nil {
-		err = fmt.Errorf("failed to commit storage save (acct %d): %w", accountID, cerr)
-		return
-	}
-
-	return nil
+	return common.Repo.Storage.Save(accountID, contents)
 }
 
 func (s *storage) addItem(it Item) bool {
@@ -245,6 +146,26 @@ func (s *storage) removeAt(idx byte) {
 	}
 }
 
+// removeExpired takes the time-limited items that have run out out of storage and saves it, it returns their IDs
+func (s *storage) removeExpired(accountID int32) []int32 {
+	var ids []int32
+
+	for i := len(s.items) - 1; i >= 0; i-- {
+		if s.items[i].ID != 0 && s.items[i].Expired() {
+			ids = append(ids, s.items[i].ID)
+			s.removeAt(byte(i))
+		}
+	}
+
+	if len(ids) > 0 {
+		if err := s.save(accountID); err != nil {
+			log.Printf("storage: failed to save account %d after removing expired items: %v", accountID, err)
+		}
+	}
+
+	return ids
+}
+
 func (s *storage) slotsAvailable() bool {
 	return s.totalSlotsUsed < s.maxSlots
 }
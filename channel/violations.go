@@ -0,0 +1,61 @@
+package channel
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/repository"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// detailLength matches the violations.detail column
+const detailLength = 255
+
+const (
+	violationBufferSize    = 1024
+	violationBatchSize     = 128
+	violationFlushInterval = time.Second
+)
+
+// recordViolation stores an anti-cheat violation against the account's character on this channel and counts it
+func (server *Server) recordViolation(accountID int32, violation, detail string) {
+	common.MetricsCounters["anticheat_violations_total"].With(prometheus.Labels{
+		"channel": strconv.Itoa(int(server.id)),
+		"world":   server.worldName,
+		"type":    violation,
+	}).Inc()
+
+	if len(detail) > detailLength {
+		detail = detail[:detailLength]
+	}
+
+	v := repository.Violation{AccountID: accountID, ChannelID: server.id, Type: violation, Detail: detail, CreatedAt: time.Now()}
+
+	server.players.observe(func(plr *Player) {
+		if plr.accountID == accountID {
+			v.CharacterID = plr.ID
+			v.CharacterName = plr.Name
+			v.MapID = plr.mapID
+		}
+	})
+
+	server.violations.Write(v)
+}
+
+// CloseViolations writes any violations still buffered, later ones are dropped
+func (server *Server) CloseViolations() {
+	server.violations.Close()
+}
+
+// newViolationWriter stores violations in batches from its own goroutine, so a burst of violations never holds up the
+// dispatch loop on the database. When its buffer is full violations are dropped, the metric still counts them.
+func newViolationWriter(repo repository.Violations) *common.BatchWriter[repository.Violation] {
+	return common.NewBatchWriter("Violations", violationBufferSize, violationBatchSize, violationFlushInterval, repo.Insert)
+}
+
+func formatViolation(v repository.Violation) string {
+	return fmt.Sprintf("[%s ch%d %d] %s: %s - %s", v.CreatedAt.Format("01-02 15:04:05"), v.ChannelID+1, v.MapID,
+		v.CharacterName, v.Type, v.Detail)
+}
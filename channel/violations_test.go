@@ -0,0 +1,35 @@
+package channel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+func TestViolationWriter(t *testing.T) {
+	repo := repository.NewMemory().Repositories().Violations
+	w := newViolationWriter(repo)
+
+	for i := 0; i < violationBatchSize+3; i++ {
+		w.Write(repository.Violation{AccountID: 1, Type: "pickup_range", CreatedAt: time.Now()})
+	}
+
+	w.Close()
+	w.Write(repository.Violation{AccountID: 1, Type: "after_close"})
+
+	got, err := repo.ByAccount(1, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != violationBatchSize+3 {
+		t.Errorf("stored %d violations, want %d", len(got), violationBatchSize+3)
+	}
+
+	for _, v := range got {
+		if v.Type == "after_close" {
+			t.Error("violation written after close was stored")
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package chatfilter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Config as read from chat_filter.json, zero values fall back to the defaults
+type Config struct {
+	// Words that may not appear in chat or names. Case, leetspeak, punctuation and stretched letters are ignored so
+	// "B4dw00rd" and "b.a.d.w.o.r.d" both match "badword". Words shorter than 5 letters only match on their own or with
+	// a plain ending such as "s" or "ing", longer ones also match inside other words. Compounds of a short word, e.g.
+	// "dumbass", need their own entry.
+	Words []string `json:"words"`
+	// Allowed words are never treated as banned, for innocent words that contain a longer banned one
+	Allowed []string `json:"allowed"`
+
+	RepeatLimit      int `json:"repeatLimit"`      // identical messages in a row before the next is spam
+	RateLimit        int `json:"rateLimit"`        // messages allowed within the rate window
+	RateWindowMs     int `json:"rateWindowMs"`     // rate window
+	StrikeLimit      int `json:"strikeLimit"`      // blocked messages within the strike window before a mute
+	StrikeWindowSecs int `json:"strikeWindowSecs"` // strike window
+	MuteSeconds      int `json:"muteSeconds"`      // length of the automatic mute
+}
+
+var defaults = Config{
+	RepeatLimit:      3,
+	RateLimit:        5,
+	RateWindowMs:     3000,
+	StrikeLimit:      5,
+	StrikeWindowSecs: 300,
+	MuteSeconds:      600,
+}
+
+// leet maps the common substitutions back to letters
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'i', '+': 't', '(': 'c',
+}
+
+type history struct {
+	last    string
+	repeats int
+	times   []time.Time
+}
+
+// Filter checks text against the word list and tracks how fast each player is chatting
+type Filter struct {
+	Config
+
+	words   []string
+	runs    [][]run
+	allowed []string
+
+	mu      sync.Mutex
+	history map[int32]*history
+}
+
+// New filter from cfg
+func New(cfg Config) *Filter {
+	f := &Filter{Config: cfg, history: make(map[int32]*history)}
+
+	if f.RepeatLimit <= 0 {
+		f.RepeatLimit = defaults.RepeatLimit
+	}
+	if f.RateLimit <= 0 {
+		f.RateLimit = defaults.RateLimit
+	}
+	if f.RateWindowMs <= 0 {
+		f.RateWindowMs = defaults.RateWindowMs
+	}
+	if f.StrikeLimit <= 0 {
+		f.StrikeLimit = defaults.StrikeLimit
+	}
+	if f.StrikeWindowSecs <= 0 {
+		f.StrikeWindowSecs = defaults.StrikeWindowSecs
+	}
+	if f.MuteSeconds <= 0 {
+		f.MuteSeconds = defaults.MuteSeconds
+	}
+
+	for _, w := range cfg.Words {
+		if n := normalise(w); n != "" {
+			f.words = append(f.words, n)
+			f.runs = append(f.runs, runs(n))
+		}
+	}
+
+	for _, w := range cfg.Allowed {
+		if n := normalise(w); n != "" {
+			f.allowed = append(f.allowed, n)
+		}
+	}
+
+	return f
+}
+
+// Load the filter from a JSON file, a missing file gives a filter with no banned words and the default limits
+func Load(path string) (*Filter, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Chat filter %s not found, no words will be filtered", path)
+		return New(Config{}), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return New(cfg), nil
+}
+
+// normalise lower cases a word, undoes leetspeak and drops anything that is not a letter, so "B4d.W0rd" becomes
+// "badword"
+func normalise(word string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(word) {
+		if l, ok := leet[r]; ok {
+			r = l
+		}
+
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+type run struct {
+	r rune
+	n int
+}
+
+// runs splits a word into runs of the same letter, so stretched out words like "baaadword" can be matched
+func runs(word string) []run {
+	var rs []run
+
+	for _, r := range word {
+		if len(rs) > 0 && rs[len(rs)-1].r == r {
+			rs[len(rs)-1].n++
+		} else {
+			rs = append(rs, run{r, 1})
+		}
+	}
+
+	return rs
+}
+
+// matchAt reports whether banned appears in word starting at run i, any letter may be repeated more than in banned
+func matchAt(word, banned []run, i int) bool {
+	for j, b := range banned {
+		if w := word[i+j]; w.r != b.r || w.n < b.n {
+			return false
+		}
+	}
+
+	return true
+}
+
+// shortWord banned words only match a whole word, longer ones match anywhere in a word. Most innocent words that
+// contain a banned one, e.g. "peacock" and "swank", contain one of 4 letters or fewer.
+const shortWord = 5
+
+// suffixes a short banned word may still be followed by, so "dicks" and "wanker" match but "dickens" does not
+var suffixes = [][]run{runs("s"), runs("es"), runs("ed"), runs("er"), runs("ers"), runs("ing")}
+
+// wholeWord reports whether word is banned, optionally followed by one of the suffixes
+func wholeWord(word, banned []run) bool {
+	if len(word) < len(banned) || !matchAt(word, banned, 0) {
+		return false
+	}
+
+	rest := word[len(banned):]
+	if len(rest) == 0 {
+		return true
+	}
+
+	for _, s := range suffixes {
+		if len(rest) == len(s) && matchAt(rest, s, 0) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *Filter) banned(word string) bool {
+	for _, a := range f.allowed {
+		if word == a {
+			return false
+		}
+	}
+
+	rs := runs(word)
+
+	for i, w := range f.words {
+		banned := f.runs[i]
+
+		if len([]rune(w)) < shortWord {
+			if wholeWord(rs, banned) {
+				return true
+			}
+
+			continue
+		}
+
+		for j := 0; j+len(banned) <= len(rs); j++ {
+			if matchAt(rs, banned, j) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Clean reports whether text is free of banned words. Runs of single letters, e.g. "b a d w o r d", are checked as a
+// word as well.
+func (f *Filter) Clean(text string) bool {
+	if len(f.words) == 0 {
+		return true
+	}
+
+	var spaced strings.Builder
+
+	for _, field := range strings.Fields(text) {
+		word := normalise(field)
+
+		if len([]rune(word)) == 1 {
+			spaced.WriteString(word)
+			continue
+		}
+
+		if spaced.Len() > 1 && f.banned(spaced.String()) {
+			return false
+		}
+		spaced.Reset()
+
+		if word != "" && f.banned(word) {
+			return false
+		}
+	}
+
+	return spaced.Len() < 2 || !f.banned(spaced.String())
+}
+
+// Flooding records a message from the player and reports whether it is a repeat of their last few messages or goes
+// over the rate limit
+func (f *Filter) Flooding(playerID int32, msg string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h, ok := f.history[playerID]
+	if !ok {
+		h = &history{}
+		f.history[playerID] = h
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-time.Duration(f.RateWindowMs) * time.Millisecond)
+
+	times := h.times[:0]
+	for _, t := range h.times {
+		if t.After(cutoff) {
+			times = append(times, t)
+		}
+	}
+	h.times = append(times, now)
+
+	msg = strings.ToLower(strings.TrimSpace(msg))
+	if msg == h.last {
+		h.repeats++
+	} else {
+		h.last = msg
+		h.repeats = 1
+	}
+
+	return h.repeats > f.RepeatLimit || len(h.times) > f.RateLimit
+}
+
+// Forget the player's chat history, e.g. when they leave the channel
+func (f *Filter) Forget(playerID int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.history, playerID)
+}
+
+// StrikeWindow blocked messages are counted over
+func (f *Filter) StrikeWindow() time.Duration {
+	return time.Duration(f.StrikeWindowSecs) * time.Second
+}
+
+// MuteDuration of an automatic mute
+func (f *Filter) MuteDuration() time.Duration {
+	return time.Duration(f.MuteSeconds) * time.Second
+}
@@ -0,0 +1,116 @@
+package chatfilter
+
+import "testing"
+
+func TestClean(t *testing.T) {
+	f := New(Config{
+		Words:   []string{"ass", "cock", "shit", "wank", "bitch", "nigga"},
+		Allowed: []string{"niggard"},
+	})
+
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"hello there", true},
+		{"", true},
+
+		// Short words match whole words and plain endings
+		{"you ass", false},
+		{"cock", false},
+		{"cocks", false},
+		{"shitting", false},
+		{"shits", false},
+		{"wanker", false},
+		{"asses", false},
+
+		// but not inside other words
+		{"look a peacock", true},
+		{"Hancock", true},
+		{"cockroach", true},
+		{"swank", true},
+		{"class", true},
+		{"assassin", true},
+		{"shitake", true},
+
+		// Longer words match anywhere
+		{"bitch", false},
+		{"sonofabitch", false},
+		{"bitches", false},
+
+		// Allowed words are never blocked
+		{"niggard", true},
+		{"niggardly", false},
+
+		// Case, leetspeak, punctuation and stretched letters are ignored
+		{"C0CK", false},
+		{"$h!t", false},
+		{"c.o.c.k", false},
+		{"cooock", false},
+		{"b1tchhh", false},
+
+		// Spaced out letters are checked as a word
+		{"c o c k", false},
+		{"a b c", true},
+		{"w a n k s", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := f.Clean(tt.text); got != tt.want {
+				t.Errorf("Clean(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanNoWords(t *testing.T) {
+	if !New(Config{}).Clean("anything goes") {
+		t.Error("Clean() = false with no banned words")
+	}
+}
+
+func TestFlooding(t *testing.T) {
+	f := New(Config{RepeatLimit: 2, RateLimit: 10})
+
+	for i, want := range []bool{false, false, true} {
+		if got := f.Flooding(1, "Buying scrolls"); got != want {
+			t.Errorf("repeat %d Flooding() = %v, want %v", i+1, got, want)
+		}
+	}
+
+	if f.Flooding(2, "Buying scrolls") {
+		t.Error("Flooding() = true for another player")
+	}
+
+	f.Forget(1)
+
+	if f.Flooding(1, "Buying scrolls") {
+		t.Error("Flooding() = true after Forget")
+	}
+}
+
+// TestShippedWords checks the compounds of the short banned words in chat_filter.json, which only match on their own
+func TestShippedWords(t *testing.T) {
+	f, err := Load("../chat_filter.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocked := []string{"bullshit", "motherfucker", "dumbass", "fuckoff", "f u c k o f f", "dumbasses", "shithead",
+		"jackass", "dickhead", "cocksucker", "bullsh1tting"}
+	clean := []string{"class", "assassin", "passion", "peacock", "cockpit", "Hitchcock", "Dickens", "shiitake",
+		"grasshopper", "Scunthorpe", "embarrassed", "massive"}
+
+	for _, text := range blocked {
+		if f.Clean(text) {
+			t.Errorf("Clean(%q) = true, want it blocked", text)
+		}
+	}
+
+	for _, text := range clean {
+		if !f.Clean(text) {
+			t.Errorf("Clean(%q) = false, want it allowed", text)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package chatlog
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Hucaru/Valhalla/common"
+)
+
+// Kinds of chat
+const (
+	KindMap       = "map"
+	KindBuddy     = "buddy"
+	KindParty     = "party"
+	KindGuild     = "guild"
+	KindWhisper   = "whisper"
+	KindMegaphone = "megaphone"
+	KindRoom      = "room"
+	KindMessenger = "messenger"
+	KindCommand   = "command"
+)
+
+const (
+	bufferSize    = 4096
+	batchSize     = 256
+	flushInterval = time.Second
+)
+
+// ErrNoSearch is returned by Recent when none of the sinks can look lines back up
+var ErrNoSearch = errors.New("no chat log sink supports searching")
+
+// Entry is a single chat line, Target is set for whispers
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Channel  byte      `json:"channel"`
+	MapID    int32     `json:"mapID"`
+	SenderID int32     `json:"senderID"`
+	Sender   string    `json:"sender"`
+	Target   string    `json:"target,omitempty"`
+	Message  string    `json:"message"`
+}
+
+// Sink records chat lines, Write is called from the game loop and must not block
+type Sink interface {
+	Write(entry Entry)
+	// Close flushes anything buffered, later writes are dropped
+	Close() error
+}
+
+// Searcher is implemented by sinks that can look lines back up
+type Searcher interface {
+	// Recent returns the newest lines sent by or whispered to the named character, newest first
+	Recent(name string, limit int) ([]Entry, error)
+}
+
+// Logger fans chat out to a set of sinks, a nil Logger discards everything
+type Logger struct {
+	sinks []Sink
+}
+
+// New logger writing to the given sinks
+func New(sinks ...Sink) *Logger {
+	return &Logger{sinks: sinks}
+}
+
+// Log sends the entry to every sink, the time is filled in if not set
+func (l *Logger) Log(entry Entry) {
+	if l == nil {
+		return
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	for _, s := range l.sinks {
+		s.Write(entry)
+	}
+}
+
+// Recent lines for the named character from the first sink that supports searching
+func (l *Logger) Recent(name string, limit int) ([]Entry, error) {
+	if l != nil {
+		for _, s := range l.sinks {
+			if searcher, ok := s.(Searcher); ok {
+				return searcher.Recent(name, limit)
+			}
+		}
+	}
+
+	return nil, ErrNoSearch
+}
+
+// Close every sink
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, s := range l.sinks {
+		errs = append(errs, s.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// async buffers entries and hands them to flush in batches from its own goroutine. When the buffer is full entries are
+// dropped rather than stalling the game loop.
+type async struct {
+	*common.BatchWriter[Entry]
+	closer func() error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newAsync(name string, flush func([]Entry) error, closer func() error) *async {
+	return &async{
+		BatchWriter: common.NewBatchWriter("Chat log "+name, bufferSize, batchSize, flushInterval, flush),
+		closer:      closer,
+	}
+}
+
+func (a *async) Close() error {
+	a.BatchWriter.Close()
+
+	a.closeOnce.Do(func() {
+		if a.closer != nil {
+			a.closeErr = a.closer()
+		}
+	})
+
+	return a.closeErr
+}
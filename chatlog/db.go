@@ -0,0 +1,70 @@
+package chatlog
+
+import (
+	"github.com/Hucaru/Valhalla/repository"
+)
+
+// messageLength matches the chat_logs.message column
+const messageLength = 255
+
+// DBSink writes batches of lines through the chat log repository
+type DBSink struct {
+	*async
+	repo repository.ChatLogs
+}
+
+// NewDBSink writing to repo
+func NewDBSink(repo repository.ChatLogs) *DBSink {
+	s := &DBSink{repo: repo}
+	s.async = newAsync("db", s.insert, nil)
+
+	return s
+}
+
+func (s *DBSink) insert(entries []Entry) error {
+	lines := make([]repository.ChatLog, len(entries))
+
+	for i, e := range entries {
+		msg := e.Message
+		if len(msg) > messageLength {
+			msg = msg[:messageLength]
+		}
+
+		lines[i] = repository.ChatLog{
+			Kind:      e.Kind,
+			ChannelID: e.Channel,
+			MapID:     e.MapID,
+			SenderID:  e.SenderID,
+			Sender:    e.Sender,
+			Target:    e.Target,
+			Message:   msg,
+			CreatedAt: e.Time,
+		}
+	}
+
+	return s.repo.Insert(lines)
+}
+
+// Recent lines from the database, lines still waiting to be written are not included
+func (s *DBSink) Recent(name string, limit int) ([]Entry, error) {
+	lines, err := s.repo.Recent(name, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, len(lines))
+	for i, l := range lines {
+		entries[i] = Entry{
+			Time:     l.CreatedAt,
+			Kind:     l.Kind,
+			Channel:  l.ChannelID,
+			MapID:    l.MapID,
+			SenderID: l.SenderID,
+			Sender:   l.Sender,
+			Target:   l.Target,
+			Message:  l.Message,
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,123 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// NewFileSink writes JSON lines to dir/chat-2006-01-02.jsonl. A new file is started each day and whenever the current
+// one would grow past maxBytes (0 for no limit), the extra files for a day are chat-2006-01-02.1.jsonl and so on.
+func NewFileSink(dir string, maxBytes int64) (Sink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	f := &rotatingFile{dir: dir, maxBytes: maxBytes}
+
+	return newAsync("file", f.write, f.close), nil
+}
+
+type rotatingFile struct {
+	dir      string
+	maxBytes int64
+
+	day   string
+	index int
+	file  *os.File
+	size  int64
+}
+
+func (f *rotatingFile) path() string {
+	if f.index == 0 {
+		return filepath.Join(f.dir, fmt.Sprintf("chat-%s.jsonl", f.day))
+	}
+
+	return filepath.Join(f.dir, fmt.Sprintf("chat-%s.%d.jsonl", f.day, f.index))
+}
+
+func (f *rotatingFile) write(entries []Entry) error {
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		line = append(line, '\n')
+
+		if err := f.rotate(e.Time.Format("2006-01-02"), int64(len(line))); err != nil {
+			return err
+		}
+
+		n, err := f.file.Write(line)
+		f.size += int64(n)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// full reports whether adding next bytes to a file of size would take it past the limit, an empty file always takes
+// at least one line
+func (f *rotatingFile) full(size, next int64) bool {
+	return f.maxBytes > 0 && size > 0 && size+next > f.maxBytes
+}
+
+// rotate makes sure the open file is for day and has room for next bytes
+func (f *rotatingFile) rotate(day string, next int64) error {
+	if f.file != nil && f.day == day && !f.full(f.size, next) {
+		return nil
+	}
+
+	if err := f.close(); err != nil {
+		return err
+	}
+
+	if f.day != day {
+		f.day = day
+		f.index = 0
+	} else {
+		f.index++
+	}
+
+	// Carry on from where a previous run left off
+	for {
+		info, err := os.Stat(f.path())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err == nil && f.full(info.Size(), next) {
+			f.index++
+			continue
+		}
+
+		file, err := os.OpenFile(f.path(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		f.file = file
+		f.size = 0
+		if info != nil {
+			f.size = info.Size()
+		}
+
+		return nil
+	}
+}
+
+func (f *rotatingFile) close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
@@ -0,0 +1,107 @@
+package common
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdminToken is the bearer token required by the admin API, the API is disabled whilst it is empty
+var AdminToken string
+
+// ErrAdminTimeout is returned when a server did not run admin work within adminDispatchTimeout
+var ErrAdminTimeout = errors.New("server did not respond in time")
+
+const adminDispatchTimeout = 5 * time.Second
+
+var (
+	adminMu       sync.Mutex
+	adminPatterns = make(map[string]bool)
+)
+
+// HandleAdmin registers an authenticated endpoint on the metrics server. Servers that share a process (dev mode)
+// register the same patterns, only the first registration is kept so handlers must work out which server a request
+// is meant for themselves.
+func HandleAdmin(pattern string, handler http.HandlerFunc) {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+
+	if adminPatterns[pattern] {
+		return
+	}
+
+	adminPatterns[pattern] = true
+	metricsMux.Handle(pattern, requireAdminToken(handler))
+}
+
+func requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if AdminToken == "" {
+			WriteJSONError(w, http.StatusNotFound, "admin api is disabled")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(AdminToken)) != 1 {
+			WriteJSONError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			log.Printf("Admin API: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminDispatch runs fn on a server's dispatch loop and waits for it, game state must only be touched from there
+func AdminDispatch(dispatch chan func(), fn func()) error {
+	done := make(chan struct{})
+	timeout := time.NewTimer(adminDispatchTimeout)
+	defer timeout.Stop()
+
+	select {
+	case dispatch <- func() {
+		defer close(done)
+		fn()
+	}:
+	case <-timeout.C:
+		return ErrAdminTimeout
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-timeout.C:
+		return ErrAdminTimeout
+	}
+}
+
+// ReadJSON decodes the request body into v
+func ReadJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+
+	return decoder.Decode(v)
+}
+
+// WriteJSON encodes v as the response body
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Admin API response write failed:", err)
+	}
+}
+
+// WriteJSONError responds with {"error": msg}
+func WriteJSONError(w http.ResponseWriter, status int, msg string) {
+	WriteJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// BatchWriter buffers values and hands them to flush in batches from its own goroutine, so a burst of writes never
+// holds up a dispatch loop on the database or disk. When the buffer is full values are dropped rather than blocking.
+// A nil BatchWriter discards everything.
+type BatchWriter[T any] struct {
+	name      string
+	batchSize int
+	interval  time.Duration
+	flush     func([]T) error
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int
+	pending chan T
+	done    chan struct{}
+}
+
+// NewBatchWriter starts a writer that flushes whenever batchSize values are waiting or interval has passed, name
+// prefixes its log lines
+func NewBatchWriter[T any](name string, bufferSize, batchSize int, interval time.Duration, flush func([]T) error) *BatchWriter[T] {
+	w := &BatchWriter[T]{
+		name:      name,
+		batchSize: batchSize,
+		interval:  interval,
+		flush:     flush,
+		pending:   make(chan T, bufferSize),
+		done:      make(chan struct{}),
+	}
+
+	go w.run()
+
+	return w
+}
+
+// Write queues v, it never blocks
+func (w *BatchWriter[T]) Write(v T) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.pending <- v:
+	default:
+		w.dropped++
+		if w.dropped == 1 || w.dropped%1000 == 0 {
+			log.Printf("%s: buffer full, %d dropped", w.name, w.dropped)
+		}
+	}
+}
+
+// Close flushes whatever is buffered and waits for it to be written, later writes are dropped
+func (w *BatchWriter[T]) Close() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.pending)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+func (w *BatchWriter[T]) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, w.batchSize)
+
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := w.flush(batch); err != nil {
+			log.Printf("%s: %d lost: %v", w.name, len(batch), err)
+		}
+
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case v, ok := <-w.pending:
+			if !ok {
+				write()
+				return
+			}
+
+			batch = append(batch, v)
+			if len(batch) == w.batchSize {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatchWriter(t *testing.T) {
+	var batches [][]int
+
+	w := NewBatchWriter("test", 16, 4, time.Hour, func(batch []int) error {
+		batches = append(batches, append([]int(nil), batch...))
+		return nil
+	})
+
+	for i := 0; i < 10; i++ {
+		w.Write(i)
+	}
+
+	w.Close()
+	w.Write(10)
+
+	if len(batches) != 3 || len(batches[0]) != 4 || len(batches[1]) != 4 || len(batches[2]) != 2 {
+		t.Fatalf("batches = %v, want two full batches and the remainder flushed on close", batches)
+	}
+
+	if last := batches[2][1]; last != 9 {
+		t.Errorf("last value written = %d, want 9 and nothing after close", last)
+	}
+}
+
+func TestBatchWriterFlushesOnInterval(t *testing.T) {
+	flushed := make(chan []string, 1)
+
+	w := NewBatchWriter("test", 16, 4, 10*time.Millisecond, func(batch []string) error {
+		flushed <- append([]string(nil), batch...)
+		return nil
+	})
+	defer w.Close()
+
+	w.Write("a")
+
+	select {
+	case got := <-flushed:
+		if len(got) != 1 || got[0] != "a" {
+			t.Errorf("flushed %v, want [a]", got)
+		}
+	case <-time.After(time.Second):
+		t.Error("a partial batch was not flushed on the interval")
+	}
+}
+
+func TestBatchWriterDropsWhenFull(t *testing.T) {
+	started := make(chan struct{}, 1)
+	block := make(chan struct{})
+	var written int
+
+	w := NewBatchWriter("test", 2, 1, time.Hour, func(batch []int) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+
+		<-block
+		written += len(batch)
+		return errors.New("ignored")
+	})
+
+	// The writer blocks on the first value, two more fill the buffer and the rest are dropped
+	w.Write(0)
+	<-started
+
+	for i := 1; i < 10; i++ {
+		w.Write(i)
+	}
+
+	close(block)
+	w.Close()
+
+	if written != 3 {
+		t.Errorf("wrote %d values, want 3 with the rest dropped", written)
+	}
+}
+
+func TestBatchWriterNil(t *testing.T) {
+	var w *BatchWriter[int]
+	w.Write(1)
+	w.Close()
+}
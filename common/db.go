@@ -1,10 +1,18 @@
 package common
 
-import "database/sql"
+import (
+	"database/sql"
+
+	"github.com/Hucaru/Valhalla/repository"
+)
 
 // DB object used for queries
 var DB *sql.DB
 
+// Repo is the persistence layer used by the servers, backed by DB once connected. Tests can replace it with
+// repository.NewMemory().Repositories() to run without MySQL.
+var Repo repository.Repositories
+
 // ConnectToDB - connect to a MySQL instance
 func ConnectToDB(user, password, address, port, database string) error {
 	var err error
@@ -22,5 +30,7 @@ func ConnectToDB(user, password, address, port, database string) error {
 
 	DB.SetMaxIdleConns(10)
 
+	Repo = repository.NewMySQL(DB)
+
 	return nil
 }
@@ -26,21 +26,23 @@ var (
 	
 	// metricsServer stores the HTTP server instance for shutdown
 	metricsServer *http.Server
+
+	// metricsMux serves /metrics and the admin API
+	metricsMux = http.NewServeMux()
 )
 
 // StartMetrics initializes and handles metrics Prometheus endpoint
 // This function is safe to call multiple times - it will only start the server once
 func StartMetrics() {
 	metricsStarted.Do(func() {
-		mux := http.NewServeMux()
-		mux.Handle("/metrics", promhttp.HandlerFor(
+		metricsMux.Handle("/metrics", promhttp.HandlerFor(
 			prometheus.DefaultGatherer,
 			promhttp.HandlerOpts{},
 		))
 		
 		metricsServer = &http.Server{
 			Addr:    "0.0.0.0:" + MetricsPort,
-			Handler: mux,
+			Handler: metricsMux,
 		}
 		
 		go func() {
@@ -11,6 +11,7 @@ const (
 	RecvCashShopBuyPackage     byte = 0x19
 	RecvCashShopGiftPackage    byte = 0x1A
 	RecvCashShopBuyQuestItem   byte = 0x1B
+	RecvCashShopRedeemCoupon   byte = 0x1C
 
 	SendCashShopLoadLockerDone      byte = 31
 	SendCashShopLoadLockerFailed    byte = 32
@@ -210,6 +210,8 @@ const (
 	PetRemoveNone   byte = 0
 	PetRemoveHungry byte = 1
 	PetRemoveExpire byte = 2
+
+	PetEffectLevelUp byte = 0
 )
 
 const (
@@ -332,6 +334,10 @@ const (
 	RoomMaxPlayers = 2
 	ShopMaxPlayers = 4
 
+	HiredMerchantItemType = 503 // item ID / 10000 of the hired merchant permits
+	HiredMerchantMaxItems = 16
+	HiredMerchantHours    = 24
+
 	OmokBoardSize = 15
 
 	MatchCardsPairsSmall  = 6
@@ -392,6 +398,64 @@ const (
 	RoomSelectCard            byte = 60
 )
 
+const (
+	LieDetectorAttempts       = 3    // wrong answers or timeouts before the test is failed
+	LieDetectorSeconds        = 60   // time to answer each attempt
+	LieDetectorCooldownMins   = 30   // a player that passed cannot be tested again until this passes
+	LieDetectorBanHours       = 72   // ban for failing, repeat failures escalate through the anti-cheat
+	LieDetectorReporterReward = 5000 // mesos for the player that reported a confirmed bot
+)
+
+const (
+	ReportLimit      = 5  // reports an account can file within ReportWindowMins
+	ReportWindowMins = 60 // window for ReportLimit
+	ReportChatLines  = 20 // lines of map chat kept with a report
+)
+
+// Pet hunger and closeness
+const (
+	PetFoodItemType     = 212   // item ID / 10000 of pet food
+	PetFoodFullness     = 30    // fullness restored by pet food without an inc value
+	PetHungerTickSecs   = 180   // how often a spawned pet gets hungrier, by its hungry value
+	PetFullnessMax      = 100   // a pet fed at this fullness likes its owner less
+	PetHungryFullness   = 50    // below this the pet ignores commands and likes its owner less for each one
+	PetStarvingFullness = 5     // at or below this the pet goes home
+	PetStarvedFullness  = 15    // fullness a pet that went home hungry is left with
+	PetClosenessMax     = 30000 // closeness of a max level pet
+	PetFoodCloseness    = 1     // closeness gained from feeding a pet that is not full
+	PetNeglectCloseness = 1     // closeness lost overfeeding a pet or commanding a hungry one
+	PetLifeDays         = 90    // days a new pet lives, and how long Water of Life brings a dead pet back for
+)
+
+// Pet equipment, the v28 client has a single pet slot so there is one set of pet equip slots
+const (
+	PetEquipSlotFirst    = -114 // equip window slot of the pet's first equipment slot
+	PetEquipSlotCount    = 8    // equipment slots of the pet, counting down from PetEquipSlotFirst
+	PetAutoPotionPercent = 50   // HP or MP percentage below which an auto potion pouch drinks by default
+)
+
+const ItemExpiryCheckSecs = 60 // how often online players are checked for time-limited items and pets that ran out
+
+const MegaphoneCooldownSecs = 15 // shared by every kind of megaphone, the item is not used while cooling down
+
+// Boss raids
+const (
+	RaidMaxPlayers  = 30 // size a raid signup list can grow to, a raid script can set a lower limit
+	RaidDefaultMins = 60 // time limit of a raid whose script does not set one when it starts
+)
+
+// Drop pickup and mob movement limits, going over them is reported to the anti-cheat
+const (
+	PickupRangeX       = 200  // furthest a drop can be from the player's last known position, allowing for lag
+	PickupRangeY       = 150  // as PickupRangeX, vertically
+	PetPickupRangeX    = 300  // furthest a drop can be from the looting pet
+	PetPickupRangeY    = 200  // as PetPickupRangeX, vertically
+	PickupRateLimit    = 8    // manual pickups allowed within PickupRateWindowMs
+	PickupRateWindowMs = 1000 // window for PickupRateLimit
+	MobMoveLimit       = 600  // furthest a mob can be moved by one update from its controller
+	MobAirLimit        = 200  // furthest a mob that cannot fly can be from the footholds
+)
+
 const (
 	// Broadcast message types
 	BroadcastNotice         byte = 0x00 // Blue text no highlight
@@ -401,6 +465,9 @@ const (
 	BroadcastHeader         byte = 0x04 // Scrolling header
 	BroadcastRedText        byte = 0x05 // Red text with no highlight
 	BroadcastBlue           byte = 0x06 // Blue text without [Notice]
+	BroadcastItemMegaphone  byte = 0x08 // Super megaphone with an item that can be hovered over
+	BroadcastHeartMegaphone byte = 0x09 // Super megaphone with a heart border
+	BroadcastSkullMegaphone byte = 0x0A // Super megaphone with a skull border
 )
 
 const (
@@ -416,6 +483,7 @@ const (
 	ItemSuperMegaphone    = 5072000
 	ItemHeartSMegaphone   = 5073000
 	ItemSkullSMegaphone   = 5074000
+	ItemItemMegaphone     = 5076000
 	ItemWeatherSnow       = 5120000
 	ItemWeatherFlowers    = 5120001
 	ItemWeatherSoap       = 5120002
@@ -523,6 +591,8 @@ const (
 
 const (
 	MapFreeMarket          int32 = 910000000
+	MapFreeMarketRoomFirst int32 = 910000001
+	MapFreeMarketRoomLast  int32 = 910000022
 	MapBossPapulatus       int32 = 220080001
 	MapBossPapulatusReturn int32 = 220080000
 	MapBossPianus          int32 = 230040420
@@ -531,6 +601,8 @@ const (
 	MapZakumPQ             int32 = 280010000 // Zakum Party Quest (Stage 1 - Dead Mine)
 	MapKerningPQ           int32 = 103000800
 	MapLudiPQ              int32 = 922010100
+	MapJail                int32 = 180000000 // GM map, there is no way out without a GM
+	MapJailRelease         int32 = 100000000 // Henesys
 
 	// Invalid map ID used for portal removal
 	InvalidMap int32 = 999999999
@@ -573,6 +645,11 @@ const (
 	CashShopNX          byte = 0x00
 	CashShopMaplePoints byte = 0x01
 
+	CashShopGiftMessageMaxLength = 73
+	CashShopWishlistSize         = 10
+	CashShopCouponCodeLength     = 16
+	CashShopCouponCodeMaxLength  = 32
+
 	CashShopErrorUnknown                            byte = 0x00
 	CashShopErrorUnknownDC1                         byte = 80
 	CashShopErrorTimeRanOutTryAgain                 byte = 81
@@ -583,6 +660,8 @@ const (
 	CashShopErrorExceededNumberOfCashItems          byte = 86
 	CashShopErrorCheckNameOrGenderRestrictions      byte = 87
 	CashShopErrorCheckCouponNumber                  byte = 88
+	CashShopErrorCouponExpired                      byte = 89
+	CashShopErrorCouponAlreadyUsed                  byte = 90
 	CashShopErrorRegisterCouponAtWebsite            byte = 91
 	CashShopErrorGenderRestrictionCoupon            byte = 92
 	CashShopErrorCouponOnlyForRegularItemsNoGifting byte = 93
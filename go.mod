@@ -8,6 +8,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/prometheus/client_golang v1.11.1
 	github.com/spf13/viper v1.21.0
+	golang.org/x/crypto v0.32.0
 )
 
 require (
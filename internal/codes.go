@@ -6,6 +6,9 @@ const (
 	OpChatParty     = 0x02
 	OpChatGuild     = 0x03
 	OpChatMegaphone = 0x04
+	OpChatNotice    = 0x05
+	OpChatMute      = 0x06
+	OpChatJail      = 0x07
 
 	OpPartyCreate     = 0x01
 	OpPartyLeaveExpel = 0x02
@@ -17,6 +17,14 @@ func PacketChannelPopUpdate(id byte, pop int16) mpacket.Packet {
 	return p
 }
 
+func PacketChannelDraining(id byte) mpacket.Packet {
+	p := mpacket.CreateInternal(opcode.ChannelInfo)
+	p.WriteByte(id)
+	p.WriteByte(1) // 1 is draining
+
+	return p
+}
+
 func PacketChannelPlayerConnected(playerID int32, name string, channelID byte, channelChange bool, mapID, guildID int32) mpacket.Packet {
 	p := mpacket.CreateInternal(opcode.ChannelPlayerConnect)
 	p.WriteInt32(playerID)
@@ -72,6 +80,24 @@ func PacketChannelWhispherChat(recepientName, fromName, msg string, channelID by
 	return p
 }
 
+// PacketChannelMuteChanged tells every channel to reload the mute on the account
+func PacketChannelMuteChanged(accountID int32) mpacket.Packet {
+	p := mpacket.CreateInternal(opcode.ChannelPlayerChatEvent)
+	p.WriteByte(OpChatMute)
+	p.WriteInt32(accountID)
+
+	return p
+}
+
+// PacketChannelJailChanged tells every channel to reload the jail sentence on the account
+func PacketChannelJailChanged(accountID int32) mpacket.Packet {
+	p := mpacket.CreateInternal(opcode.ChannelPlayerChatEvent)
+	p.WriteByte(OpChatJail)
+	p.WriteInt32(accountID)
+
+	return p
+}
+
 func PacketChannelPlayerChat(code byte, fromName string, buffer []byte) mpacket.Packet {
 	p := mpacket.CreateInternal(opcode.ChannelPlayerChatEvent)
 	p.WriteByte(code) // 1 buddy, 2 party, 3 guild
@@ -519,12 +545,25 @@ func PacketCashShopRequestChannelInfo() mpacket.Packet {
 	return p
 }
 
-func PacketChatMegaphone(chrName, msg string, whisper bool) mpacket.Packet {
+// PacketChatMegaphone for the world wide megaphones, mode is the broadcast type and item is only set for item megaphones
+func PacketChatMegaphone(mode byte, chrName, msg string, channelID byte, whisper bool, item []byte) mpacket.Packet {
 	p := mpacket.CreateInternal(opcode.ChannelPlayerChatEvent)
 	p.WriteByte(OpChatMegaphone)
+	p.WriteByte(mode)
 	p.WriteString(chrName)
 	p.WriteString(msg)
+	p.WriteByte(channelID)
 	p.WriteBool(whisper)
+	p.WriteInt16(int16(len(item)))
+	p.WriteBytes(item)
+
+	return p
+}
+
+func PacketChatNotice(msg string) mpacket.Packet {
+	p := mpacket.CreateInternal(opcode.ChannelPlayerChatEvent)
+	p.WriteByte(OpChatNotice)
+	p.WriteString(msg)
 
 	return p
 }
@@ -0,0 +1,91 @@
+package login
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Hucaru/Valhalla/anticheat"
+	"github.com/Hucaru/Valhalla/common"
+)
+
+type adminBanRequest struct {
+	Name   string `json:"name"`
+	Hours  *int   `json:"hours"` // defaults to a week, 0 is permanent
+	Reason string `json:"reason"`
+}
+
+// registerAdminHandlers adds the ban endpoints to the admin API, bans go straight to the database so the player does
+// not need to be online
+func (server *Server) registerAdminHandlers() {
+	common.HandleAdmin("POST /admin/bans", server.adminBan)
+	common.HandleAdmin("GET /admin/bans/{name}", server.adminBanHistory)
+	common.HandleAdmin("DELETE /admin/bans/{name}", server.adminUnban)
+}
+
+func writeBanError(w http.ResponseWriter, err error) {
+	if errors.Is(err, anticheat.ErrPlayerNotFound) {
+		common.WriteJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	common.WriteJSONError(w, http.StatusInternalServerError, err.Error())
+}
+
+func (server *Server) adminBan(w http.ResponseWriter, r *http.Request) {
+	var req adminBanRequest
+	if err := common.ReadJSON(r, &req); err != nil {
+		common.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Name == "" {
+		common.WriteJSONError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	hours := 168
+	if req.Hours != nil {
+		hours = *req.Hours
+	}
+
+	if hours < 0 {
+		common.WriteJSONError(w, http.StatusBadRequest, "hours must not be negative")
+		return
+	}
+
+	if req.Reason == "" {
+		req.Reason = "Banned by admin"
+	}
+
+	if err := server.ac.BanByName(req.Name, hours, req.Reason); err != nil {
+		writeBanError(w, err)
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, map[string]any{"banned": req.Name, "hours": hours})
+}
+
+func (server *Server) adminBanHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := server.ac.GetBanHistory(r.PathValue("name"), 10)
+	if err != nil {
+		writeBanError(w, err)
+		return
+	}
+
+	if history == nil {
+		history = []string{}
+	}
+
+	common.WriteJSON(w, http.StatusOK, history)
+}
+
+func (server *Server) adminUnban(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	if err := server.ac.Unban(name); err != nil {
+		writeBanError(w, err)
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, map[string]string{"unbanned": name})
+}
@@ -1,8 +1,6 @@
 package login
 
 import (
-	"crypto/sha512"
-	"database/sql"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -16,6 +14,7 @@ import (
 	"github.com/Hucaru/Valhalla/internal"
 	"github.com/Hucaru/Valhalla/mnet"
 	"github.com/Hucaru/Valhalla/mpacket"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 // HandleClientPacket data
@@ -64,31 +63,53 @@ func (server *Server) handleLoginRequest(conn mnet.Client, reader mpacket.Reader
 		ip = conn.String()
 	}
 
-	// hash the password
-	hasher := sha512.New()
-	hasher.Write([]byte(password))
-	hashedPassword := hex.EncodeToString(hasher.Sum(nil))
+	account, err := common.Repo.Accounts.ByUsername(username)
 
-	var accountID int32
-	var user string
-	var databasePassword string
-	var gender byte
-	var isLogedIn bool
-	var isBanned int
-	var isLocked int
-	var lastHwid sql.NullString
-	var adminLevel int
-	var eula byte
+	// bcrypt is slow by design, compare and hash on the password workers and finish the login back on the event loop
+	var check passwordCheck
+	server.checkPasswordAsync(func() {
+		if err == nil {
+			check.match, check.rehash = checkPassword(password, account.Password, server.passwordCost)
+			if check.match && check.rehash {
+				check.hash, check.hashErr = hashPassword(password, server.passwordCost)
+			}
+		} else if server.autoRegister {
+			check.hash, check.hashErr = hashPassword(password, server.passwordCost)
+		}
+	}, func() {
+		server.finishLoginRequest(conn, username, hwid, ip, account, err, check)
+	})
+}
+
+// passwordCheck is the outcome of the bcrypt work done for a login request
+type passwordCheck struct {
+	match   bool
+	rehash  bool
+	hash    string
+	hashErr error
+}
+
+// checkPasswordAsync runs work on a goroutine, at most one per CPU at a time, then hands done to the event loop
+func (server *Server) checkPasswordAsync(work, done func()) {
+	go func() {
+		server.passwords <- struct{}{}
+		work()
+		<-server.passwords
+
+		server.dispatch <- done
+	}()
+}
 
-	err := common.DB.QueryRow("SELECT accountID, username, password, gender, isLogedIn, isBanned, isLocked, hwid, adminLevel, eula FROM accounts WHERE username=?", username).
-		Scan(&accountID, &user, &databasePassword, &gender, &isLogedIn, &isBanned, &isLocked, &lastHwid, &adminLevel, &eula)
+func (server *Server) finishLoginRequest(conn mnet.Client, username, hwid, ip string, account repository.Account, err error, check passwordCheck) {
+	passwordMatch := check.match
 
 	result := constant.LoginResultSuccess
 
-	lastHwidStr := ""
-	if lastHwid.Valid {
-		lastHwidStr = lastHwid.String
-	}
+	accountID := account.ID
+	gender := account.Gender
+	adminLevel := account.AdminLevel
+	eula := account.EULA
+	isBanned := account.Banned
 
 	if server.ac != nil {
 		banned, _, endEpoch, err := server.ac.IsBanned(accountID, ip, hwid)
@@ -104,39 +125,49 @@ func (server *Server) handleLoginRequest(conn mnet.Client, reader mpacket.Reader
 	if err != nil {
 		log.Println(err)
 		if server.autoRegister {
-			res, insertErr := common.DB.Exec("INSERT INTO accounts (username, password, pin, isLogedIn, adminLevel, isBanned, gender, dob, eula, nx, maplepoints, hwid) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-				username, hashedPassword, constant.AutoRegisterDefaultPIN, constant.AutoRegisterDefaultIsLoggedIn,
-				constant.AutoRegisterDefaultAdminLevel, constant.AutoRegisterDefaultIsBanned, constant.AutoRegisterDefaultGender,
-				constant.AutoRegisterDefaultDOB, constant.AutoRegisterDefaultEULA, constant.AutoRegisterDefaultNX,
-				constant.AutoRegisterDefaultMaplePoints, hwid)
+			var id int32
+			hashedPassword, insertErr := check.hash, check.hashErr
+			if insertErr == nil {
+				id, insertErr = common.Repo.Accounts.Create(repository.Account{
+					Username:    username,
+					Password:    hashedPassword,
+					Pin:         constant.AutoRegisterDefaultPIN,
+					LoggedIn:    constant.AutoRegisterDefaultIsLoggedIn != 0,
+					AdminLevel:  constant.AutoRegisterDefaultAdminLevel,
+					Banned:      constant.AutoRegisterDefaultIsBanned,
+					Gender:      constant.AutoRegisterDefaultGender,
+					DOB:         constant.AutoRegisterDefaultDOB,
+					EULA:        constant.AutoRegisterDefaultEULA,
+					NX:          int32(constant.AutoRegisterDefaultNX),
+					MaplePoints: int32(constant.AutoRegisterDefaultMaplePoints),
+					HWID:        hwid,
+				})
+			}
 
 			if insertErr != nil {
-				log.Println("Failed to create new account", err)
+				log.Println("Failed to create new account", insertErr)
 				result = constant.LoginResultNotRegistered
-			} else if id, err := res.LastInsertId(); err == nil {
-				accountID = int32(id)
+			} else {
+				accountID = id
 				gender = constant.AutoRegisterDefaultGender
 				adminLevel = constant.AutoRegisterDefaultAdminLevel
 				eula = constant.AutoRegisterDefaultEULA
 				log.Println("Auto-registered new account:", username, "with ID:", accountID)
 				result = constant.LoginResultSuccess
-			} else {
-				log.Println("Failed to get new account ID:", err)
-				result = constant.LoginResultSystemError
 			}
 		} else {
 			result = constant.LoginResultNotRegistered
 		}
-	} else if isLocked > 0 {
+	} else if account.Locked > 0 {
 		result = constant.LoginResultDeletedOrBlocked
-	} else if hashedPassword != databasePassword {
+	} else if !passwordMatch {
 		if server.ac != nil {
 			ipKey := fmt.Sprintf("ip:%s", ip)
 			userKey := fmt.Sprintf("user:%s", username)
 			hwidKey := fmt.Sprintf("hwid:%s", hwid)
 
 			exceeded := server.ac.TrackFailedAuth(ipKey) || server.ac.TrackFailedAuth(hwidKey) || server.ac.TrackFailedAuth(userKey)
-			if exceeded && strings.Compare(hwid, lastHwidStr) != 0 {
+			if exceeded && strings.Compare(hwid, account.HWID) != 0 {
 				// Lock Account
 				err := server.ac.LockAccount(accountID)
 				if err != nil {
@@ -146,13 +177,22 @@ func (server *Server) handleLoginRequest(conn mnet.Client, reader mpacket.Reader
 		}
 
 		result = constant.LoginResultInvalidPassword
-	} else if isLogedIn {
+	} else if account.LoggedIn {
 		result = constant.LoginResultAlreadyOnline
 	} else if isBanned > 0 {
 		result = constant.LoginResultBanned
 	} else if eula == 0 {
 		result = constant.LoginResultEULA
 	}
+
+	// Upgrade legacy SHA-512 hashes, and bcrypt hashes from a previous cost setting, now that the password is known
+	if passwordMatch && check.rehash {
+		if check.hashErr != nil {
+			log.Println("Failed to rehash password for account", accountID, check.hashErr)
+		} else if err := common.Repo.Accounts.SetPassword(accountID, check.hash); err != nil {
+			log.Println("Failed to store rehashed password for account", accountID, err)
+		}
+	}
 	// Banned = 2, Deleted or Blocked = 3, Invalid Password = 4, Not Registered = 5, Sys Error = 6,
 	// Already online = 7, System error = 9, Too many requests = 10, Older than 20 = 11, valid login = 12, Master cannot login on this IP = 13,
 	// wrong gateway korean text = 14, still processing request korean text = 15, verify email = 16, gateway english text = 17,
@@ -166,7 +206,7 @@ func (server *Server) handleLoginRequest(conn mnet.Client, reader mpacket.Reader
 
 		// Update HWID and clear failed attempts on successful login
 		if hwid != "" {
-			common.DB.Exec("UPDATE accounts SET hwid = ? WHERE accountID = ?", hwid, accountID)
+			common.Repo.Accounts.SetHWID(accountID, hwid)
 		}
 		if server.ac != nil {
 			ip := ""
@@ -192,7 +232,7 @@ func (server *Server) handleEULA(conn mnet.Client, reader mpacket.Reader) {
 	accept := reader.ReadBool()
 
 	if accept {
-		_, err := common.DB.Exec("UPDATE accounts SET eula=? WHERE accountID=?", 1, conn.GetAccountID())
+		err := common.Repo.Accounts.SetEULA(conn.GetAccountID(), true)
 
 		if err != nil {
 			log.Println("Could not set EULA signed", err)
@@ -214,7 +254,7 @@ func (server *Server) handlePinRegistration(conn mnet.Client, reader mpacket.Rea
 	accountID := conn.GetAccountID()
 	pin := string(reader.GetRestAsBytes())
 
-	_, err := common.DB.Exec("UPDATE accounts SET pin=? WHERE accountID=?", pin, accountID)
+	err := common.Repo.Accounts.SetPin(accountID, pin)
 	if err != nil {
 		log.Println("handlePinRegistration database pin update issue for accountID:", accountID, err)
 	}
@@ -228,11 +268,10 @@ func (server *Server) handleGoodLogin(conn mnet.Client, reader mpacket.Reader) {
 	accountID := conn.GetAccountID()
 
 	if server.withPin {
-		var pinDB string
 		var authDone bool
 
-		err := common.DB.QueryRow("SELECT pin FROM accounts WHERE accountID=?", accountID).
-			Scan(&pinDB)
+		account, err := common.Repo.Accounts.ByID(accountID)
+		pinDB := account.Pin
 
 		if err != nil {
 			log.Println("handleCheckLogin database retrieval issue for accountID:", accountID, err)
@@ -272,7 +311,7 @@ func (server *Server) handleGoodLogin(conn mnet.Client, reader mpacket.Reader) {
 	}
 
 	conn.SetLogedIn(true)
-	_, err := common.DB.Exec("UPDATE accounts set isLogedIn=1 WHERE accountID=?", accountID)
+	err := common.Repo.Accounts.SetLoggedIn(accountID, true)
 
 	if err != nil {
 		log.Println("Database error with approving login of accountID", accountID, err)
@@ -332,17 +371,24 @@ func (server *Server) handleChannelSelect(conn mnet.Client, reader mpacket.Reade
 func (server *Server) handleNameCheck(conn mnet.Client, reader mpacket.Reader) {
 	newCharName := reader.ReadString(reader.ReadInt16())
 
-	var nameFound int
-	err := common.DB.QueryRow("SELECT count(*) name FROM characters WHERE name=?", newCharName).
-		Scan(&nameFound)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	if !server.filter.Clean(newCharName) {
+		conn.Send(packetLoginNameCheck(newCharName, 1))
+		return
+	}
+
+	taken, err := common.Repo.Characters.NameTaken(newCharName)
+	if err != nil {
 		log.Println(err)
 		// Default to name found just in-case
 		conn.Send(packetLoginNameCheck(newCharName, 1))
 		return
 	}
 
-	conn.Send(packetLoginNameCheck(newCharName, nameFound))
+	if taken {
+		conn.Send(packetLoginNameCheck(newCharName, 1))
+	} else {
+		conn.Send(packetLoginNameCheck(newCharName, 0))
+	}
 }
 
 func (server *Server) handleNewCharacter(conn mnet.Client, reader mpacket.Reader) {
@@ -363,10 +409,8 @@ func (server *Server) handleNewCharacter(conn mnet.Client, reader mpacket.Reader
 
 	// Add str, dex, int, luk validation (check to see if client generates a constant sum)
 
-	var counter int
-
-	err := common.DB.QueryRow("SELECT count(*) FROM characters where name=? and worldID=?", name, conn.GetWorldID()).Scan(&counter)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	taken, err := common.Repo.Characters.NameTaken(name)
+	if err != nil {
 		log.Println(err)
 		return
 	}
@@ -391,7 +435,7 @@ func (server *Server) handleNewCharacter(conn mnet.Client, reader mpacket.Reader
 
 	valid := inSlice(face, allowedEyes) && inSlice(hair, allowedHair) && inSlice(hairColour, allowedHairColour) &&
 		inSlice(bottom, allowedBottom) && inSlice(top, allowedTop) && inSlice(shoes, allowedShoes) &&
-		inSlice(weapon, allowedWeapons) && inSlice(skin, allowedSkinColour) && (counter == 0)
+		inSlice(weapon, allowedWeapons) && inSlice(skin, allowedSkinColour) && !taken
 
 	newCharacter := player{}
 
@@ -399,22 +443,35 @@ func (server *Server) handleNewCharacter(conn mnet.Client, reader mpacket.Reader
 		name = "[GM]" + name
 	} else if strings.ContainsAny(name, "[]") {
 		valid = false // hacked client or packet editting
+	} else if !server.filter.Clean(name) {
+		valid = false // name check is client side only
 	}
 
 	if valid {
-		res, err := common.DB.Exec("INSERT INTO characters (name, accountID, worldID, face, hair, skin, gender, str, dex, intt, luk) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-			name, conn.GetAccountID(), conn.GetWorldID(), face, hair+hairColour, skin, conn.GetGender(), str, dex, intelligence, luk)
-
-		if err != nil {
-			log.Println(err)
+		row := repository.CharacterData{
+			Character: repository.Character{
+				Name:      name,
+				AccountID: conn.GetAccountID(),
+				WorldID:   conn.GetWorldID(),
+				Face:      face,
+				Hair:      hair + hairColour,
+				Skin:      byte(skin),
+				Gender:    conn.GetGender(),
+			},
+			Str:  int16(str),
+			Dex:  int16(dex),
+			Intt: int16(intelligence),
+			Luk:  int16(luk),
 		}
 
-		characterID, err := res.LastInsertId()
-
-		if err != nil {
+		if err := common.Repo.Characters.Create(&row); err != nil {
 			log.Println(err)
+			conn.Send(packetLoginCreatedCharacter(false, newCharacter))
+			return
 		}
 
+		characterID := row.ID
+
 		char := loadPlayerFromID(int32(characterID))
 
 		if conn.GetAdminLevel() > 0 {
@@ -478,20 +535,22 @@ func (server *Server) handleDeleteCharacter(conn mnet.Client, reader mpacket.Rea
 	dob := reader.ReadInt32()
 	charID := reader.ReadInt32()
 
-	var storedDob int32
-	var charCount int
-
-	err := common.DB.QueryRow("SELECT dob FROM accounts where accountID=?", conn.GetAccountID()).Scan(&storedDob)
-	err = common.DB.QueryRow("SELECT count(*) FROM characters where accountID=? AND id=?", conn.GetAccountID(), charID).Scan(&charCount)
+	account, err := common.Repo.Accounts.ByID(conn.GetAccountID())
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
+	char, err := common.Repo.Characters.ByID(charID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		log.Println(err)
+		return
+	}
+
 	hacking := false
 	deleted := false
 
-	if charCount != 1 {
+	if err != nil || char.AccountID != account.ID {
 		if server.ac != nil {
 			err = server.ac.IssueBan(0, 24, "Attempted to delete character not associated with account", conn.String(), conn.GetHWID())
 			if err != nil {
@@ -505,12 +564,8 @@ func (server *Server) handleDeleteCharacter(conn mnet.Client, reader mpacket.Rea
 		return
 	}
 
-	if dob == storedDob {
-		records, err := common.DB.Query("DELETE FROM characters where id=?", charID)
-
-		defer records.Close()
-
-		if err != nil {
+	if int(dob) == account.DOB {
+		if err := common.Repo.Characters.Delete(charID); err != nil {
 			log.Println(err)
 			return
 		}
@@ -528,10 +583,8 @@ func (server *Server) handleDeleteCharacter(conn mnet.Client, reader mpacket.Rea
 func (server *Server) handleSelectCharacter(conn mnet.Client, reader mpacket.Reader) {
 	charID := reader.ReadInt32()
 
-	var charCount int
-
-	err := common.DB.QueryRow("SELECT count(*) FROM characters where accountID=? AND id=?", conn.GetAccountID(), charID).Scan(&charCount)
-	if err != nil {
+	char, err := common.Repo.Characters.ByID(charID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
 		log.Println(err)
 		if server.ac != nil {
 			err = server.ac.IssueBan(0, 24, "Attempted to select character not associated with account", conn.String(), conn.GetHWID())
@@ -542,23 +595,24 @@ func (server *Server) handleSelectCharacter(conn mnet.Client, reader mpacket.Rea
 		return
 	}
 
-	if charCount == 1 {
-		channel := server.worlds[conn.GetWorldID()].Channels[conn.GetChannelID()]
-		_, err := common.DB.Exec("UPDATE characters SET migrationID=? WHERE id=?", conn.GetChannelID(), charID)
-
-		if err != nil {
-			log.Println(err)
-			return
-		}
+	if err != nil || char.AccountID != conn.GetAccountID() {
+		return
+	}
 
-		server.migrating[conn] = true
+	channel := server.worlds[conn.GetWorldID()].Channels[conn.GetChannelID()]
 
-		conn.Send(packetLoginMigrateClient(channel.IP, channel.Port, charID))
+	if err := common.Repo.Characters.SetMigration(charID, int32(conn.GetChannelID())); err != nil {
+		log.Println(err)
+		return
 	}
+
+	server.migrating[conn] = true
+
+	conn.Send(packetLoginMigrateClient(channel.IP, channel.Port, charID))
 }
 
 func (server *Server) addCharacterItem(characterID int64, itemID int32, slot int32, creatorName string) {
-	_, err := common.DB.Exec("INSERT INTO items (characterID, itemID, slotNumber, creatorName) VALUES (?, ?, ?, ?)", characterID, itemID, slot, creatorName)
+	err := common.Repo.Items.Save(int32(characterID), &repository.Item{InventoryID: 1, ItemID: itemID, Slot: int16(slot), Amount: 1, CreatorName: creatorName})
 
 	if err != nil {
 		log.Println(err)
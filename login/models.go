@@ -7,6 +7,7 @@ import (
 	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/mpacket"
 	"github.com/Hucaru/Valhalla/nx"
+	"github.com/Hucaru/Valhalla/repository"
 )
 
 type player struct {
@@ -81,74 +82,95 @@ func (d player) displayBytes() []byte {
 }
 
 func (d player) save() error {
-	query := `UPDATE characters set skin=?, hair=?, face=?, level=?,
-	job=?, str=?, dex=?, intt=?, luk=?, hp=?, maxHP=?, mp=?, maxMP=?,
-	ap=?, sp=?, exp=?, fame=?, mapID=?, mapPos=? WHERE id=?`
+	const fields = repository.CharacterSkin | repository.CharacterHair | repository.CharacterFace |
+		repository.CharacterLevel | repository.CharacterJob | repository.CharacterStr | repository.CharacterDex |
+		repository.CharacterInt | repository.CharacterLuk | repository.CharacterHP | repository.CharacterMaxHP |
+		repository.CharacterMP | repository.CharacterMaxMP | repository.CharacterAP | repository.CharacterSP |
+		repository.CharacterExp | repository.CharacterFame | repository.CharacterMap
 
 	d.mapPos = 0
 
-	_, err := common.DB.Exec(query,
-		d.skin, d.hair, d.face, d.level, d.job, d.str, d.dex, d.intt, d.luk, d.hp, d.maxHP, d.mp,
-		d.maxMP, d.ap, d.sp, d.exp, d.fame, d.mapID, d.mapPos, d.id)
+	return common.Repo.Characters.Update(repository.CharacterData{
+		Character: repository.Character{
+			ID:    d.id,
+			Skin:  d.skin,
+			Hair:  d.hair,
+			Face:  d.face,
+			Level: d.level,
+			Job:   d.job,
+			MapID: d.mapID,
+		},
+		Str:    d.str,
+		Dex:    d.dex,
+		Intt:   d.intt,
+		Luk:    d.luk,
+		HP:     d.hp,
+		MaxHP:  d.maxHP,
+		MP:     d.mp,
+		MaxMP:  d.maxMP,
+		AP:     d.ap,
+		SP:     d.sp,
+		Exp:    d.exp,
+		Fame:   d.fame,
+		MapPos: d.mapPos,
+	}, fields)
+}
 
-	if err != nil {
-		return err
+func playerFromRow(row repository.CharacterData) player {
+	return player{
+		id:        row.ID,
+		accountID: row.AccountID,
+		worldID:   row.WorldID,
+		name:      row.Name,
+		gender:    row.Gender,
+		skin:      row.Skin,
+		hair:      row.Hair,
+		face:      row.Face,
+		level:     row.Level,
+		job:       row.Job,
+		str:       row.Str,
+		dex:       row.Dex,
+		intt:      row.Intt,
+		luk:       row.Luk,
+		hp:        row.HP,
+		maxHP:     row.MaxHP,
+		mp:        row.MP,
+		maxMP:     row.MaxMP,
+		ap:        row.AP,
+		sp:        row.SP,
+		exp:       row.Exp,
+		fame:      row.Fame,
+		mapID:     row.MapID,
+		mapPos:    row.MapPos,
+		equip:     loadEquipsFromDb(row.ID),
 	}
-
-	return err
 }
 
 func getCharactersFromAccountWorldID(accountID int32, worldID byte) []player {
 	c := []player{}
 
-	filter := "id,accountID,worldID,name,gender,skin,hair,face,level,job,str,dex,intt," +
-		"luk,hp,maxHP,mp,maxMP,ap,sp,exp,fame,mapID,mapPos"
-
-	chars, err := common.DB.Query("SELECT "+filter+" FROM characters WHERE accountID=? AND worldID=?", accountID, worldID)
+	rows, err := common.Repo.Characters.ByAccount(accountID, worldID)
 
 	if err != nil {
 		log.Println(err)
 	}
 
-	defer chars.Close()
-
-	for chars.Next() {
-		var char player
-
-		err = chars.Scan(&char.id, &char.accountID, &char.worldID, &char.name, &char.gender, &char.skin, &char.hair,
-			&char.face, &char.level, &char.job, &char.str, &char.dex, &char.intt, &char.luk, &char.hp, &char.maxHP,
-			&char.mp, &char.maxMP, &char.ap, &char.sp, &char.exp, &char.fame, &char.mapID, &char.mapPos)
-
-		if err != nil {
-			log.Println(err)
-		}
-
-		char.equip = loadEquipsFromDb(char.id)
-
-		c = append(c, char)
+	for _, row := range rows {
+		c = append(c, playerFromRow(row))
 	}
 
 	return c
 }
 
 func loadPlayerFromID(id int32) player {
-	c := player{}
-	filter := "id,accountID,worldID,name,gender,skin,hair,face,level,job,str,dex,intt," +
-		"luk,hp,maxHP,mp,maxMP,ap,sp,exp,fame,mapID,mapPos"
-
-	err := common.DB.QueryRow("SELECT "+filter+" FROM characters where id=?", id).Scan(&c.id,
-		&c.accountID, &c.worldID, &c.name, &c.gender, &c.skin, &c.hair, &c.face,
-		&c.level, &c.job, &c.str, &c.dex, &c.intt, &c.luk, &c.hp, &c.maxHP, &c.mp,
-		&c.maxMP, &c.ap, &c.sp, &c.exp, &c.fame, &c.mapID, &c.mapPos)
+	row, err := common.Repo.Characters.Load(id)
 
 	if err != nil {
 		log.Println(err)
-		return c
+		return player{}
 	}
 
-	c.equip = loadEquipsFromDb(c.id)
-
-	return c
+	return playerFromRow(row)
 }
 
 type item struct {
@@ -225,16 +247,32 @@ func newBeginnerItem(id int32) item {
 }
 
 func (v item) save(charID int32) (bool, error) {
-	props := `characterID,inventoryID,itemID,slotNumber,amount,flag,upgradeSlots,level,
-				str,dex,intt,luk,hp,mp,watk,matk,wdef,mdef,accuracy,avoid,hands,speed,jump,
-				expireTime,creatorName`
-
-	query := "INSERT into items (" + props + ") VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
-
-	_, err := common.DB.Exec(query,
-		charID, v.invID, v.id, v.slotID, v.amount, v.flag, v.upgradeSlots, v.scrollLevel,
-		v.str, v.dex, v.intt, v.luk, v.hp, v.mp, v.watk, v.matk, v.wdef, v.mdef, v.accuracy, v.avoid, v.hands, v.speed, v.jump,
-		v.expireTime, v.creatorName)
+	err := common.Repo.Items.Save(charID, &repository.Item{
+		InventoryID:  v.invID,
+		ItemID:       v.id,
+		Slot:         v.slotID,
+		Amount:       v.amount,
+		Flag:         v.flag,
+		UpgradeSlots: v.upgradeSlots,
+		Level:        v.scrollLevel,
+		Str:          v.str,
+		Dex:          v.dex,
+		Intt:         v.intt,
+		Luk:          v.luk,
+		HP:           v.hp,
+		MP:           v.mp,
+		Watk:         v.watk,
+		Matk:         v.matk,
+		Wdef:         v.wdef,
+		Mdef:         v.mdef,
+		Accuracy:     v.accuracy,
+		Avoid:        v.avoid,
+		Hands:        v.hands,
+		Speed:        v.speed,
+		Jump:         v.jump,
+		ExpireTime:   v.expireTime,
+		CreatorName:  v.creatorName,
+	})
 
 	if err != nil {
 		return false, err
@@ -244,8 +282,7 @@ func (v item) save(charID int32) (bool, error) {
 }
 
 func loadEquipsFromDb(charID int32) []item {
-	filter := "inventoryID,itemID,slotNumber,amount,flag,upgradeSlots,level,str,dex,intt,luk,hp,mp,watk,matk,wdef,mdef,accuracy,avoid,hands,speed,jump,expireTime,creatorName"
-	row, err := common.DB.Query("SELECT "+filter+" FROM items WHERE characterID=?", charID)
+	rows, err := common.Repo.Items.ByCharacter(charID)
 
 	if err != nil {
 		panic(err)
@@ -253,38 +290,33 @@ func loadEquipsFromDb(charID int32) []item {
 
 	equip := []item{}
 
-	defer row.Close()
-
-	for row.Next() {
-
-		item := item{}
-
-		row.Scan(&item.invID,
-			&item.id,
-			&item.slotID,
-			&item.amount,
-			&item.flag,
-			&item.upgradeSlots,
-			&item.scrollLevel,
-			&item.str,
-			&item.dex,
-			&item.intt,
-			&item.luk,
-			&item.hp,
-			&item.mp,
-			&item.watk,
-			&item.matk,
-			&item.wdef,
-			&item.mdef,
-			&item.accuracy,
-			&item.avoid,
-			&item.hands,
-			&item.speed,
-			&item.jump,
-			&item.expireTime,
-			&item.creatorName)
-
-		equip = append(equip, item)
+	for _, row := range rows {
+		equip = append(equip, item{
+			invID:        row.InventoryID,
+			id:           row.ItemID,
+			slotID:       row.Slot,
+			amount:       row.Amount,
+			flag:         row.Flag,
+			upgradeSlots: row.UpgradeSlots,
+			scrollLevel:  row.Level,
+			str:          row.Str,
+			dex:          row.Dex,
+			intt:         row.Intt,
+			luk:          row.Luk,
+			hp:           row.HP,
+			mp:           row.MP,
+			watk:         row.Watk,
+			matk:         row.Matk,
+			wdef:         row.Wdef,
+			mdef:         row.Mdef,
+			accuracy:     row.Accuracy,
+			avoid:        row.Avoid,
+			hands:        row.Hands,
+			speed:        row.Speed,
+			jump:         row.Jump,
+			expireTime:   row.ExpireTime,
+			creatorName:  row.CreatorName,
+		})
 	}
 
 	return equip
@@ -0,0 +1,64 @@
+package login
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// clampPasswordCost clamps the configured bcrypt cost, zero selects the library default
+func clampPasswordCost(cost int) int {
+	if cost == 0 {
+		return bcrypt.DefaultCost
+	}
+
+	if cost < bcrypt.MinCost {
+		return bcrypt.MinCost
+	}
+
+	if cost > bcrypt.MaxCost {
+		return bcrypt.MaxCost
+	}
+
+	return cost
+}
+
+func hashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func isBcryptHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// checkPassword compares the password against the stored hash. Accounts created before bcrypt was introduced hold an
+// unsalted hex encoded SHA-512 digest, a match against one of those, or a bcrypt hash with a different cost, reports
+// that the stored hash should be replaced.
+func checkPassword(password, stored string, cost int) (match bool, rehash bool) {
+	if isBcryptHash(stored) {
+		if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+			return false, false
+		}
+
+		storedCost, err := bcrypt.Cost([]byte(stored))
+		return true, err != nil || storedCost != cost
+	}
+
+	hasher := sha512.New()
+	hasher.Write([]byte(password))
+	legacy := hex.EncodeToString(hasher.Sum(nil))
+
+	if subtle.ConstantTimeCompare([]byte(legacy), []byte(strings.ToLower(stored))) != 1 {
+		return false, false
+	}
+
+	return true, true
+}
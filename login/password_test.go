@@ -0,0 +1,81 @@
+package login
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestClampPasswordCost(t *testing.T) {
+	tests := []struct {
+		cost int
+		want int
+	}{
+		{0, bcrypt.DefaultCost},
+		{1, bcrypt.MinCost},
+		{12, 12},
+		{99, bcrypt.MaxCost},
+	}
+
+	for _, tt := range tests {
+		if got := clampPasswordCost(tt.cost); got != tt.want {
+			t.Errorf("clampPasswordCost(%d) = %d, want %d", tt.cost, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("secret", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha512.Sum512([]byte("secret"))
+	legacy := hex.EncodeToString(digest[:])
+
+	tests := []struct {
+		name       string
+		password   string
+		stored     string
+		cost       int
+		wantMatch  bool
+		wantRehash bool
+	}{
+		{"bcrypt", "secret", hash, bcrypt.MinCost, true, false},
+		{"bcrypt wrong password", "guess", hash, bcrypt.MinCost, false, false},
+		{"bcrypt old cost", "secret", hash, bcrypt.MinCost + 1, true, true},
+		{"legacy sha-512", "secret", legacy, bcrypt.MinCost, true, true},
+		{"legacy upper case", "secret", strings.ToUpper(legacy), bcrypt.MinCost, true, true},
+		{"legacy wrong password", "guess", legacy, bcrypt.MinCost, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash := checkPassword(tt.password, tt.stored, tt.cost)
+			if match != tt.wantMatch || rehash != tt.wantRehash {
+				t.Errorf("checkPassword() = %v, %v, want %v, %v", match, rehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordAsync(t *testing.T) {
+	server := Server{dispatch: make(chan func()), passwords: make(chan struct{}, 1)}
+
+	ran := false
+	server.checkPasswordAsync(func() { ran = true }, func() {})
+
+	done := <-server.dispatch
+	done()
+
+	if !ran {
+		t.Error("work had not run before done was dispatched")
+	}
+
+	if len(server.passwords) != 0 {
+		t.Error("worker slot not released")
+	}
+}
@@ -2,8 +2,10 @@ package login
 
 import (
 	"log"
+	"runtime"
 
 	"github.com/Hucaru/Valhalla/anticheat"
+	"github.com/Hucaru/Valhalla/chatfilter"
 	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/internal"
 	"github.com/Hucaru/Valhalla/mnet"
@@ -11,19 +13,26 @@ import (
 
 // Server state
 type Server struct {
+	dispatch  chan func()
 	migrating map[mnet.Client]bool
 	// db        *sql.DB
 	worlds       []internal.World
 	withPin      bool
 	autoRegister bool
+	passwordCost int
+	passwords    chan struct{}
 	ac           *anticheat.AntiCheat
+	filter       *chatfilter.Filter
 }
 
 // Initialise the server
-func (server *Server) Initialise(dbuser, dbpassword, dbaddress, dbport, dbdatabase string, withpin bool, autoRegister bool) {
+func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress, dbport, dbdatabase string, withpin bool, autoRegister bool, passwordCost int, chatFilterJson string) {
+	server.dispatch = work
 	server.migrating = make(map[mnet.Client]bool)
 	server.withPin = withpin
 	server.autoRegister = autoRegister
+	server.passwordCost = clampPasswordCost(passwordCost)
+	server.passwords = make(chan struct{}, runtime.NumCPU())
 
 	err := common.ConnectToDB(dbuser, dbpassword, dbaddress, dbport, dbdatabase)
 
@@ -37,19 +46,30 @@ func (server *Server) Initialise(dbuser, dbpassword, dbaddress, dbport, dbdataba
 
 	log.Println("Cleaned up the database")
 
+	server.filter, err = chatfilter.Load(chatFilterJson)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize anti-cheat
-	server.ac = anticheat.New(common.DB, nil)
+	server.ac = anticheat.New(common.Repo, nil)
 	server.ac.StartCleanup()
 	log.Println("Anti-cheat initialized")
+
+	// Login and world only serve the admin api, leave the port free for other servers on the host otherwise
+	if common.AdminToken != "" {
+		server.registerAdminHandlers()
+		common.StartMetrics()
+		log.Println("Started serving metrics and admin api on :" + common.MetricsPort)
+	}
 }
 
 // CleanupDB sets all accounts isLogedIn to 0
 func (server *Server) CleanupDB() {
-	res, err := common.DB.Exec("UPDATE accounts AS a INNER JOIN characters c ON a.accountID = c.accountID SET a.isLogedIn = 0 WHERE isLogedIn = 1 AND a.accountID != ALL (SELECT c.accountID FROM characters c WHERE c.channelID != -1);")
+	amount, err := common.Repo.Accounts.ClearStaleLogins()
 	if err != nil {
 		log.Fatal(err)
 	}
-	amount, _ := res.RowsAffected()
 	log.Printf("Set %d isLogedin rows to 0.", amount)
 }
 
@@ -70,7 +90,7 @@ func (server *Server) ClientDisconnected(conn mnet.Client) {
 	if isMigrating, ok := server.migrating[conn]; ok && isMigrating {
 		delete(server.migrating, conn)
 	} else {
-		_, err := common.DB.Exec("UPDATE accounts SET isLogedIn=0 WHERE accountID=?", conn.GetAccountID())
+		err := common.Repo.Accounts.SetLoggedIn(conn.GetAccountID(), false)
 
 		if err != nil {
 			log.Println("Unable to complete logout for ", conn.GetAccountID())
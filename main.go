@@ -3,23 +3,26 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
 
 	"github.com/Hucaru/Valhalla/common"
 )
 
-var typePtr, configPtr, metricPtr *string
+var typePtr, configPtr, metricPtr, adminTokenPtr *string
 var channelPtr *int
 
 func init() {
-	typePtr = flag.String("type", "", "Denotes what type of server to start: login, world, channel, cashshop, dev")
+	typePtr = flag.String("type", "", "Denotes what type of server to start: login, world, channel, cashshop, dev, bot")
 	configPtr = flag.String("config", "", "config toml file")
 	metricPtr = flag.String("metrics-port", "9000", "Port to serve metrics on")
+	adminTokenPtr = flag.String("admin-token", os.Getenv("VALHALLA_ADMIN_TOKEN"), "Bearer token for the admin API served alongside metrics, disabled when empty")
 	channelPtr = flag.Int("channels", 2, "Defines number of channels to start (only for dev server type)")
 	flag.Parse()
 }
 
 func main() {
 	common.MetricsPort = *metricPtr
+	common.AdminToken = *adminTokenPtr
 
 	switch *typePtr {
 	case "login":
@@ -37,6 +40,8 @@ func main() {
 	case "dev":
 		s := newDevServer(*configPtr)
 		s.run()
+	case "bot":
+		runBot(*configPtr)
 	default:
 		log.Println("Unknown server type:", *typePtr)
 	}
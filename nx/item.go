@@ -32,8 +32,9 @@ type Item struct {
 	NotSale                                                        int64
 	UnitPrice                                                      float64
 	Life, Hungry                                                   int64
-	PickupItem, PickupAll, SweepForDrop                            int64
-	ConsumeHP, LongRange                                           int64
+	Inc                                                            int64 // fullness restored by pet food
+	PickupItem, PickupMeso, PickupAll, SweepForDrop                int64
+	ConsumeHP, ConsumeMP, LongRange                                int64
 	Recovery                                                       float64
 	ReqPOP                                                         int64 // ?
 	NameTag                                                        int64
@@ -350,6 +351,8 @@ func (item *Item) getItem(node *gonx.Node, nodes []gonx.Node, textLookup []strin
 			item.Hungry = gonx.DataToInt64(option.Data)
 		case "pickupItem":
 			item.PickupItem = gonx.DataToInt64(option.Data)
+		case "pickupMeso":
+			item.PickupMeso = gonx.DataToInt64(option.Data)
 		case "pickupAll":
 			item.PickupAll = gonx.DataToInt64(option.Data)
 		case "sweepForDrop":
@@ -358,6 +361,8 @@ func (item *Item) getItem(node *gonx.Node, nodes []gonx.Node, textLookup []strin
 			item.LongRange = gonx.DataToInt64(option.Data)
 		case "consumeHP":
 			item.ConsumeHP = gonx.DataToInt64(option.Data)
+		case "consumeMP":
+			item.ConsumeMP = gonx.DataToInt64(option.Data)
 		case "unitPrice":
 			item.UnitPrice = gonx.DataToFloat64(option.Data)
 		case "timeLimited":
@@ -483,6 +488,7 @@ func (item *Item) getItem(node *gonx.Node, nodes []gonx.Node, textLookup []strin
 				}
 			})
 		case "inc":
+			item.Inc = gonx.DataToInt64(option.Data)
 		case "morph":
 		default:
 			// Consider gating this log behind a verbosity flag to reduce noise in production.
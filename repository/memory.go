@@ -0,0 +1,1907 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process store that satisfies every repository interface, intended for tests and tooling that
+// must run without a MySQL server
+type Memory struct {
+	mu sync.Mutex
+
+	accounts   map[int32]Account
+	characters map[int32]CharacterData
+	items      map[int64]memoryItem
+	skills     map[int32]map[int32]Skill
+	quests     map[int32]map[int16]Quest
+	mobKills   map[int32]map[int16]map[int32]int32
+	guilds     map[int32]Guild
+	buddies    map[int32]map[int32]bool
+	bans       []Ban
+	escalation map[int32]int
+	mutes      []Mute
+	jails      []Jail
+	violations []Violation
+	storage    map[int32]StorageContents
+	cashShop   map[int32]StorageContents
+	gifts      []Gift
+	wishlists  map[int32][]int32
+	coupons    map[string]Coupon
+	redeemed   map[string]map[int32]bool
+	merchants  map[int32]Merchant
+	reports    []Report
+	chatLogs   []ChatLog
+	raids      []memoryRaidEntry
+	invites    []GuildInvite
+	buffs      map[int32][]Buff
+	fame       []memoryFameEntry
+	lieTests   map[int32]int32
+	lieRewards map[int32]int32
+	pets       map[int64]Pet
+	petEquips  map[int64]map[byte]int64
+
+	nextAccountID int32
+	nextCharID    int32
+	nextItemID    int64
+	nextGuildID   int32
+	nextGiftID    int64
+	nextMerchant  int32
+	nextReport    int32
+	nextMute      int32
+	nextJail      int32
+	nextViolation int64
+	nextChatLog   int64
+}
+
+type memoryItem struct {
+	charID int32
+	item   Item
+}
+
+type memoryFameEntry struct {
+	fromID    int32
+	toID      int32
+	createdAt time.Time
+}
+
+type memoryRaidEntry struct {
+	characterID int32
+	raid        string
+	createdAt   time.Time
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		accounts:   make(map[int32]Account),
+		characters: make(map[int32]CharacterData),
+		items:      make(map[int64]memoryItem),
+		skills:     make(map[int32]map[int32]Skill),
+		quests:     make(map[int32]map[int16]Quest),
+		mobKills:   make(map[int32]map[int16]map[int32]int32),
+		guilds:     make(map[int32]Guild),
+		buddies:    make(map[int32]map[int32]bool),
+		escalation: make(map[int32]int),
+		storage:    make(map[int32]StorageContents),
+		cashShop:   make(map[int32]StorageContents),
+		wishlists:  make(map[int32][]int32),
+		coupons:    make(map[string]Coupon),
+		redeemed:   make(map[string]map[int32]bool),
+		merchants:  make(map[int32]Merchant),
+		buffs:      make(map[int32][]Buff),
+		lieTests:   make(map[int32]int32),
+		lieRewards: make(map[int32]int32),
+		pets:       make(map[int64]Pet),
+		petEquips:  make(map[int64]map[byte]int64),
+	}
+}
+
+// Repositories backed by this store
+func (m *Memory) Repositories() Repositories {
+	return Repositories{
+		Accounts:   memoryAccounts{m},
+		Characters: memoryCharacters{m},
+		Items:      memoryItems{m},
+		Skills:     memorySkills{m},
+		Quests:     memoryQuests{m},
+		Guilds:     memoryGuilds{m},
+		Buddies:    memoryBuddies{m},
+		Bans:       memoryBans{m},
+		Mutes:      memoryMutes{m},
+		Jails:      memoryJails{m},
+		Violations: memoryViolations{m},
+		Storage:    memoryStorage{m},
+		Gifts:      memoryGifts{m},
+		Wishlists:  memoryWishlists{m},
+		Coupons:    memoryCoupons{m},
+		Merchants:  memoryMerchants{m},
+		Reports:    memoryReports{m},
+		ChatLogs:   memoryChatLogs{m},
+		Raids:      memoryRaidEntries{m},
+		Buffs:      memoryBuffs{m},
+		Fame:       memoryFame{m},
+		LieTests:   memoryLieDetectors{m},
+		Pets:       memoryPets{m},
+		PetEquips:  memoryPetEquips{m},
+	}
+}
+
+// PutCharacter inserts or replaces a character row with default stats
+func (m *Memory) PutCharacter(c Character) {
+	m.PutCharacterData(CharacterData{Character: c})
+}
+
+// PutCharacterData inserts or replaces a full character row
+func (m *Memory) PutCharacterData(c CharacterData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.characters[c.ID] = c
+	if c.ID > m.nextCharID {
+		m.nextCharID = c.ID
+	}
+}
+
+type memoryAccounts struct {
+	m *Memory
+}
+
+func (r memoryAccounts) ByUsername(username string) (Account, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, a := range r.m.accounts {
+		if a.Username == username {
+			return a, nil
+		}
+	}
+
+	return Account{}, ErrNotFound
+}
+
+func (r memoryAccounts) ByID(accountID int32) (Account, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	a, ok := r.m.accounts[accountID]
+	if !ok {
+		return Account{}, ErrNotFound
+	}
+
+	return a, nil
+}
+
+func (r memoryAccounts) Create(a Account) (int32, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.nextAccountID++
+	a.ID = r.m.nextAccountID
+	r.m.accounts[a.ID] = a
+
+	return a.ID, nil
+}
+
+func (r memoryAccounts) update(accountID int32, fn func(*Account)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	a, ok := r.m.accounts[accountID]
+	if !ok {
+		return nil
+	}
+
+	fn(&a)
+	r.m.accounts[accountID] = a
+
+	return nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (r memoryAccounts) SetHWID(accountID int32, hwid string) error {
+	return r.update(accountID, func(a *Account) { a.HWID = hwid })
+}
+
+func (r memoryAccounts) SetEULA(accountID int32, accepted bool) error {
+	return r.update(accountID, func(a *Account) { a.EULA = byte(boolToInt(accepted)) })
+}
+
+func (r memoryAccounts) SetPin(accountID int32, pin string) error {
+	return r.update(accountID, func(a *Account) { a.Pin = pin })
+}
+
+func (r memoryAccounts) SetPassword(accountID int32, hash string) error {
+	return r.update(accountID, func(a *Account) { a.Password = hash })
+}
+
+func (r memoryAccounts) SetLoggedIn(accountID int32, loggedIn bool) error {
+	return r.update(accountID, func(a *Account) { a.LoggedIn = loggedIn })
+}
+
+func (r memoryAccounts) SetLocked(accountID int32, locked bool) error {
+	return r.update(accountID, func(a *Account) { a.Locked = boolToInt(locked) })
+}
+
+func (r memoryAccounts) SetBanned(accountID int32, banned bool) error {
+	return r.update(accountID, func(a *Account) { a.Banned = boolToInt(banned) })
+}
+
+func (r memoryAccounts) SetCash(accountID, nx, maplePoints int32) error {
+	return r.update(accountID, func(a *Account) { a.NX, a.MaplePoints = nx, maplePoints })
+}
+
+func (r memoryAccounts) ClearStaleLogins() (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	online := make(map[int32]bool)
+	hasCharacter := make(map[int32]bool)
+	for _, c := range r.m.characters {
+		hasCharacter[c.AccountID] = true
+		if c.ChannelID != -1 {
+			online[c.AccountID] = true
+		}
+	}
+
+	var changed int64
+	for id, a := range r.m.accounts {
+		if a.LoggedIn && hasCharacter[id] && !online[id] {
+			a.LoggedIn = false
+			r.m.accounts[id] = a
+			changed++
+		}
+	}
+
+	return changed, nil
+}
+
+type memoryCharacters struct {
+	m *Memory
+}
+
+func (r memoryCharacters) ByID(charID int32) (Character, error) {
+	c, err := r.Load(charID)
+	return c.Character, err
+}
+
+func (r memoryCharacters) ByName(name string, worldID byte) (Character, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, c := range r.m.characters {
+		if c.Name == name && c.WorldID == worldID {
+			return c.Character, nil
+		}
+	}
+
+	return Character{}, ErrNotFound
+}
+
+func (r memoryCharacters) AccountIDByName(name string) (int32, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, c := range r.m.characters {
+		if strings.EqualFold(c.Name, name) {
+			return c.AccountID, nil
+		}
+	}
+
+	return 0, ErrNotFound
+}
+
+func (r memoryCharacters) Load(charID int32) (CharacterData, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	c, ok := r.m.characters[charID]
+	if !ok {
+		return CharacterData{}, ErrNotFound
+	}
+
+	return c, nil
+}
+
+func (r memoryCharacters) ByAccount(accountID int32, worldID byte) ([]CharacterData, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	chars := []CharacterData{}
+	for _, c := range r.m.characters {
+		if c.AccountID == accountID && c.WorldID == worldID {
+			chars = append(chars, c)
+		}
+	}
+
+	sort.Slice(chars, func(i, j int) bool { return chars[i].ID < chars[j].ID })
+
+	return chars, nil
+}
+
+func (r memoryCharacters) NameTaken(name string) (bool, error) {
+	_, err := r.AccountIDByName(name)
+	return err == nil, nil
+}
+
+func (r memoryCharacters) Create(c *CharacterData) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.nextCharID++
+	c.ID = r.m.nextCharID
+
+	// Mirror the column defaults of the characters table
+	row := CharacterData{
+		Character: Character{
+			ID:                c.ID,
+			AccountID:         c.AccountID,
+			WorldID:           c.WorldID,
+			Name:              c.Name,
+			Gender:            c.Gender,
+			Skin:              c.Skin,
+			Hair:              c.Hair,
+			Face:              c.Face,
+			Level:             1,
+			ChannelID:         -1,
+			BuddyListSize:     20,
+			GuildRank:         1,
+			MigrationID:       -1,
+			PreviousChannelID: -1,
+		},
+		Str:            c.Str,
+		Dex:            c.Dex,
+		Intt:           c.Intt,
+		Luk:            c.Luk,
+		HP:             100,
+		MaxHP:          100,
+		MP:             50,
+		MaxMP:          50,
+		EquipSlotSize:  32,
+		UseSlotSize:    32,
+		SetupSlotSize:  32,
+		EtcSlotSize:    32,
+		CashSlotSize:   32,
+		MiniGamePoints: 2000,
+	}
+
+	r.m.characters[c.ID] = row
+
+	return nil
+}
+
+func (r memoryCharacters) update(charID int32, fn func(*CharacterData)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	c, ok := r.m.characters[charID]
+	if !ok {
+		return nil
+	}
+
+	fn(&c)
+	r.m.characters[charID] = c
+
+	return nil
+}
+
+func (r memoryCharacters) Update(c CharacterData, fields CharacterFields) error {
+	return r.update(c.ID, func(d *CharacterData) {
+		set := func(field CharacterFields, fn func()) {
+			if fields&field != 0 {
+				fn()
+			}
+		}
+
+		set(CharacterSkin, func() { d.Skin = c.Skin })
+		set(CharacterHair, func() { d.Hair = c.Hair })
+		set(CharacterFace, func() { d.Face = c.Face })
+		set(CharacterLevel, func() { d.Level = c.Level })
+		set(CharacterJob, func() { d.Job = c.Job })
+		set(CharacterStr, func() { d.Str = c.Str })
+		set(CharacterDex, func() { d.Dex = c.Dex })
+		set(CharacterInt, func() { d.Intt = c.Intt })
+		set(CharacterLuk, func() { d.Luk = c.Luk })
+		set(CharacterHP, func() { d.HP = c.HP })
+		set(CharacterMaxHP, func() { d.MaxHP = c.MaxHP })
+		set(CharacterMP, func() { d.MP = c.MP })
+		set(CharacterMaxMP, func() { d.MaxMP = c.MaxMP })
+		set(CharacterAP, func() { d.AP = c.AP })
+		set(CharacterSP, func() { d.SP = c.SP })
+		set(CharacterExp, func() { d.Exp = c.Exp })
+		set(CharacterFame, func() { d.Fame = c.Fame })
+		set(CharacterMap, func() { d.MapID, d.MapPos = c.MapID, c.MapPos })
+		set(CharacterPreviousMap, func() { d.PreviousMapID = c.PreviousMapID })
+		set(CharacterMesos, func() { d.Mesos = c.Mesos })
+		set(CharacterSlotSizes, func() {
+			d.EquipSlotSize, d.UseSlotSize, d.SetupSlotSize = c.EquipSlotSize, c.UseSlotSize, c.SetupSlotSize
+			d.EtcSlotSize, d.CashSlotSize = c.EtcSlotSize, c.CashSlotSize
+		})
+		set(CharacterMiniGame, func() {
+			d.MiniGameWins, d.MiniGameDraw, d.MiniGameLoss, d.MiniGamePoints = c.MiniGameWins, c.MiniGameDraw, c.MiniGameLoss, c.MiniGamePoints
+		})
+		set(CharacterBuddyListSize, func() { d.BuddyListSize = c.BuddyListSize })
+		set(CharacterTeleportRocks, func() { d.RegTeleportRocks, d.VIPTeleportRocks = c.RegTeleportRocks, c.VIPTeleportRocks })
+	})
+}
+
+func (r memoryCharacters) Delete(charID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.characters, charID)
+
+	return nil
+}
+
+func (r memoryCharacters) SetChannel(charID, channelID int32) error {
+	return r.update(charID, func(c *CharacterData) { c.ChannelID = channelID })
+}
+
+func (r memoryCharacters) SetMigration(charID, migrationID int32) error {
+	return r.update(charID, func(c *CharacterData) { c.MigrationID = migrationID })
+}
+
+func (r memoryCharacters) SetInCashShop(charID int32, inCashShop bool) error {
+	return r.update(charID, func(c *CharacterData) { c.InCashShop = inCashShop })
+}
+
+func (r memoryCharacters) EnterCashShop(charID, migrationID, fromChannelID int32) error {
+	return r.update(charID, func(c *CharacterData) {
+		c.MigrationID, c.PreviousChannelID, c.InCashShop = migrationID, fromChannelID, true
+	})
+}
+
+func (r memoryCharacters) LeaveCashShop(charID, migrationID int32) error {
+	return r.update(charID, func(c *CharacterData) { c.MigrationID, c.InCashShop = migrationID, false })
+}
+
+func (r memoryCharacters) ClearChannel(channelID int32) ([]int32, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var accountIDs []int32
+	for id, c := range r.m.characters {
+		if c.ChannelID != channelID {
+			continue
+		}
+
+		if c.MigrationID == -1 {
+			accountIDs = append(accountIDs, c.AccountID)
+		}
+
+		c.ChannelID = -1
+		r.m.characters[id] = c
+	}
+
+	return accountIDs, nil
+}
+
+type memoryItems struct {
+	m *Memory
+}
+
+func (r memoryItems) ByCharacter(charID int32) ([]Item, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	items := []Item{}
+	for _, v := range r.m.items {
+		if v.charID == charID {
+			items = append(items, v.item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	return items, nil
+}
+
+func (r memoryItems) Save(charID int32, it *Item) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if it.ID == 0 {
+		r.m.nextItemID++
+		it.ID = r.m.nextItemID
+	} else if existing, ok := r.m.items[it.ID]; ok {
+		// Matches the UPDATE statement which never moves an item between characters
+		charID = existing.charID
+	}
+
+	r.m.items[it.ID] = memoryItem{charID: charID, item: *it}
+
+	return nil
+}
+
+func (r memoryItems) Delete(itemID int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.items, itemID)
+
+	// Matches the cascading foreign keys on pets and pet_equips
+	delete(r.m.pets, itemID)
+	delete(r.m.petEquips, itemID)
+	for _, equips := range r.m.petEquips {
+		for slot, id := range equips {
+			if id == itemID {
+				delete(equips, slot)
+			}
+		}
+	}
+
+	return nil
+}
+
+type memorySkills struct {
+	m *Memory
+}
+
+func (r memorySkills) ByCharacter(charID int32) ([]Skill, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	skills := []Skill{}
+	for _, s := range r.m.skills[charID] {
+		skills = append(skills, s)
+	}
+
+	sort.Slice(skills, func(i, j int) bool { return skills[i].ID < skills[j].ID })
+
+	return skills, nil
+}
+
+func (r memorySkills) Save(charID int32, s Skill) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if r.m.skills[charID] == nil {
+		r.m.skills[charID] = make(map[int32]Skill)
+	}
+
+	r.m.skills[charID][s.ID] = s
+
+	return nil
+}
+
+type memoryQuests struct {
+	m *Memory
+}
+
+func (r memoryQuests) ByCharacter(charID int32) ([]Quest, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	quests := []Quest{}
+	for _, q := range r.m.quests[charID] {
+		quests = append(quests, q)
+	}
+
+	sort.Slice(quests, func(i, j int) bool { return quests[i].ID < quests[j].ID })
+
+	return quests, nil
+}
+
+func (r memoryQuests) MobKills(charID int32) ([]QuestMobKill, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	kills := []QuestMobKill{}
+	for questID, mobs := range r.m.mobKills[charID] {
+		for mobID, count := range mobs {
+			kills = append(kills, QuestMobKill{QuestID: questID, MobID: mobID, Kills: count})
+		}
+	}
+
+	return kills, nil
+}
+
+func (r memoryQuests) set(charID int32, questID int16, fn func(*Quest)) {
+	if r.m.quests[charID] == nil {
+		r.m.quests[charID] = make(map[int16]Quest)
+	}
+
+	q, ok := r.m.quests[charID][questID]
+	if !ok {
+		q = Quest{ID: questID}
+	}
+
+	fn(&q)
+	r.m.quests[charID][questID] = q
+}
+
+func (r memoryQuests) SetRecord(charID int32, questID int16, record string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.set(charID, questID, func(q *Quest) {
+		q.Record = record
+		q.Completed = false
+		q.CompletedAt = 0
+	})
+
+	return nil
+}
+
+func (r memoryQuests) SetCompleted(charID int32, questID int16, completedAt int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.set(charID, questID, func(q *Quest) {
+		q.Completed = true
+		q.CompletedAt = completedAt
+	})
+
+	return nil
+}
+
+func (r memoryQuests) Delete(charID int32, questID int16) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.quests[charID], questID)
+
+	return nil
+}
+
+func (r memoryQuests) AddMobKill(charID int32, questID int16, mobID int32, delta int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if r.m.mobKills[charID] == nil {
+		r.m.mobKills[charID] = make(map[int16]map[int32]int32)
+	}
+
+	if r.m.mobKills[charID][questID] == nil {
+		r.m.mobKills[charID][questID] = make(map[int32]int32)
+	}
+
+	r.m.mobKills[charID][questID][mobID] += delta
+
+	return nil
+}
+
+func (r memoryQuests) ClearMobKills(charID int32, questID int16) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.mobKills[charID], questID)
+
+	return nil
+}
+
+type memoryGuilds struct {
+	m *Memory
+}
+
+func (r memoryGuilds) ByID(guildID int32) (Guild, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	g, ok := r.m.guilds[guildID]
+	if !ok {
+		return Guild{}, ErrNotFound
+	}
+
+	return g, nil
+}
+
+func (r memoryGuilds) Members(guildID int32) ([]GuildMember, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	members := []GuildMember{}
+	for _, c := range r.m.characters {
+		if c.GuildID != guildID {
+			continue
+		}
+
+		members = append(members, GuildMember{
+			CharacterID: c.ID,
+			Name:        c.Name,
+			Job:         int32(c.Job),
+			Level:       int32(c.Level),
+			Rank:        c.GuildRank,
+			Online:      c.ChannelID > -1,
+		})
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].CharacterID < members[j].CharacterID })
+
+	return members, nil
+}
+
+func (r memoryGuilds) Create(g Guild) (int32, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, v := range r.m.guilds {
+		if v.Name == g.Name {
+			return 0, ErrDuplicate
+		}
+	}
+
+	r.m.nextGuildID++
+	g.ID = r.m.nextGuildID
+	if g.Capacity == 0 {
+		g.Capacity = 10
+	}
+	r.m.guilds[g.ID] = g
+
+	return g.ID, nil
+}
+
+func (r memoryGuilds) Delete(guildID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.guilds, guildID)
+
+	for id, c := range r.m.characters {
+		if c.GuildID == guildID {
+			c.GuildID = 0
+			c.GuildRank = 0
+			r.m.characters[id] = c
+		}
+	}
+
+	return nil
+}
+
+func (r memoryGuilds) character(charID int32, fn func(*Character)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	c, ok := r.m.characters[charID]
+	if !ok {
+		return nil
+	}
+
+	fn(&c.Character)
+	r.m.characters[charID] = c
+
+	return nil
+}
+
+func (r memoryGuilds) SetMember(charID, guildID int32, rank byte) error {
+	return r.character(charID, func(c *Character) {
+		c.GuildID = guildID
+		c.GuildRank = rank
+	})
+}
+
+func (r memoryGuilds) RemoveMember(charID int32) error {
+	return r.character(charID, func(c *Character) {
+		c.GuildID = 0
+		c.GuildRank = 0
+	})
+}
+
+func (r memoryGuilds) SetRank(charID int32, rank byte) error {
+	return r.character(charID, func(c *Character) { c.GuildRank = rank })
+}
+
+func (r memoryGuilds) guild(guildID int32, fn func(*Guild)) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	g, ok := r.m.guilds[guildID]
+	if !ok {
+		return nil
+	}
+
+	fn(&g)
+	r.m.guilds[guildID] = g
+
+	return nil
+}
+
+func (r memoryGuilds) SetPoints(guildID, points int32) error {
+	return r.guild(guildID, func(g *Guild) { g.Points = points })
+}
+
+func (r memoryGuilds) SetTitles(guildID int32, master, jrMaster, member1, member2, member3 string) error {
+	return r.guild(guildID, func(g *Guild) {
+		g.Master, g.JrMaster, g.Member1, g.Member2, g.Member3 = master, jrMaster, member1, member2, member3
+	})
+}
+
+func (r memoryGuilds) SetNotice(guildID int32, notice string) error {
+	return r.guild(guildID, func(g *Guild) { g.Notice = notice })
+}
+
+func (r memoryGuilds) SetEmblem(guildID int32, logoBg int16, logoBgColour byte, logo int16, logoColour byte) error {
+	return r.guild(guildID, func(g *Guild) {
+		g.LogoBg, g.LogoBgColour, g.Logo, g.LogoColour = logoBg, logoBgColour, logo, logoColour
+	})
+}
+
+func (r memoryGuilds) NameTaken(name string, worldID int32) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, g := range r.m.guilds {
+		if strings.EqualFold(g.Name, name) && g.WorldID == worldID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r memoryGuilds) Invites(charID int32) ([]GuildInvite, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	invites := []GuildInvite{}
+	for _, invite := range r.m.invites {
+		if invite.CharacterID == charID {
+			invites = append(invites, invite)
+		}
+	}
+
+	return invites, nil
+}
+
+func (r memoryGuilds) Invite(invite GuildInvite) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.invites = append(r.m.invites, invite)
+
+	return nil
+}
+
+func (r memoryGuilds) DeleteInvite(charID, guildID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	kept := r.m.invites[:0]
+	for _, invite := range r.m.invites {
+		if invite.CharacterID != charID || invite.GuildID != guildID {
+			kept = append(kept, invite)
+		}
+	}
+	r.m.invites = kept
+
+	return nil
+}
+
+type memoryBuddies struct {
+	m *Memory
+}
+
+func (r memoryBuddies) ByCharacter(charID int32) ([]Buddy, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	buddies := []Buddy{}
+	for friendID, accepted := range r.m.buddies[charID] {
+		c, ok := r.m.characters[friendID]
+		if !ok {
+			continue
+		}
+
+		buddies = append(buddies, Buddy{
+			FriendID:   friendID,
+			Name:       c.Name,
+			ChannelID:  c.ChannelID,
+			InCashShop: c.InCashShop,
+			Accepted:   accepted,
+		})
+	}
+
+	sort.Slice(buddies, func(i, j int) bool { return buddies[i].FriendID < buddies[j].FriendID })
+
+	return buddies, nil
+}
+
+func (r memoryBuddies) CountAccepted(charID int32) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	count := 0
+	for _, accepted := range r.m.buddies[charID] {
+		if accepted {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r memoryBuddies) add(charID, friendID int32, accepted bool) error {
+	if r.m.buddies[charID] == nil {
+		r.m.buddies[charID] = make(map[int32]bool)
+	}
+
+	if _, ok := r.m.buddies[charID][friendID]; ok && !accepted {
+		return ErrDuplicate
+	}
+
+	r.m.buddies[charID][friendID] = accepted
+
+	return nil
+}
+
+func (r memoryBuddies) Request(charID, friendID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	return r.add(charID, friendID, false)
+}
+
+func (r memoryBuddies) Accept(charID, friendID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if err := r.add(charID, friendID, true); err != nil {
+		return err
+	}
+
+	return r.add(friendID, charID, true)
+}
+
+func (r memoryBuddies) Delete(charID, friendID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.buddies[charID], friendID)
+	delete(r.m.buddies[friendID], charID)
+
+	return nil
+}
+
+type memoryBans struct {
+	m *Memory
+}
+
+func (r memoryBans) Create(b Ban) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now()
+	}
+
+	r.m.bans = append(r.m.bans, b)
+
+	return nil
+}
+
+func (r memoryBans) Active(accountID int32, ip, hwid string) (Ban, bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+
+	// Newest first, same as ORDER BY createdAt DESC
+	for i := len(r.m.bans) - 1; i >= 0; i-- {
+		b := r.m.bans[i]
+
+		matches := (b.AccountID != 0 && b.AccountID == accountID) || (b.IP != "" && b.IP == ip) || (b.HWID != "" && b.HWID == hwid)
+		if !matches {
+			continue
+		}
+
+		if b.Permanent() || b.End.After(now) {
+			return b, true, nil
+		}
+	}
+
+	return Ban{}, false, nil
+}
+
+func (r memoryBans) ByAccount(accountID int32, limit int) ([]Ban, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	bans := []Ban{}
+	for i := len(r.m.bans) - 1; i >= 0 && len(bans) < limit; i-- {
+		if r.m.bans[i].AccountID == accountID {
+			bans = append(bans, r.m.bans[i])
+		}
+	}
+
+	return bans, nil
+}
+
+func (r memoryBans) DeleteByAccount(accountID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	kept := r.m.bans[:0]
+	for _, b := range r.m.bans {
+		if b.AccountID != accountID {
+			kept = append(kept, b)
+		}
+	}
+	r.m.bans = kept
+
+	return nil
+}
+
+func (r memoryBans) IncrementEscalation(accountID int32) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.escalation[accountID]++
+
+	return r.m.escalation[accountID], nil
+}
+
+type memoryMutes struct {
+	m *Memory
+}
+
+func (r memoryMutes) Create(m *Mute) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.nextMute++
+	m.ID = r.m.nextMute
+	m.CreatedAt = time.Now()
+
+	r.m.mutes = append(r.m.mutes, *m)
+
+	return nil
+}
+
+func (r memoryMutes) Active(accountID int32) (Mute, bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+
+	var active Mute
+	found := false
+
+	for _, m := range r.m.mutes {
+		if m.AccountID != accountID || !m.ActiveAt(now) {
+			continue
+		}
+
+		if !found || m.Permanent() || (!active.Permanent() && m.End.After(active.End)) {
+			active = m
+			found = true
+		}
+	}
+
+	return active, found, nil
+}
+
+func (r memoryMutes) ByAccount(accountID int32, limit int) ([]Mute, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	mutes := []Mute{}
+	for i := len(r.m.mutes) - 1; i >= 0 && len(mutes) < limit; i-- {
+		if r.m.mutes[i].AccountID == accountID {
+			mutes = append(mutes, r.m.mutes[i])
+		}
+	}
+
+	return mutes, nil
+}
+
+func (r memoryMutes) Lift(accountID int32, by string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	lifted := false
+
+	for i, m := range r.m.mutes {
+		if m.AccountID == accountID && m.ActiveAt(now) {
+			r.m.mutes[i].LiftedBy = by
+			r.m.mutes[i].LiftedAt = now
+			lifted = true
+		}
+	}
+
+	if !lifted {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type memoryJails struct {
+	m *Memory
+}
+
+func (r memoryJails) Create(j *Jail) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.nextJail++
+	j.ID = r.m.nextJail
+	j.CreatedAt = time.Now()
+
+	r.m.jails = append(r.m.jails, *j)
+
+	return nil
+}
+
+func (r memoryJails) Active(accountID int32) (Jail, bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+
+	var active Jail
+	found := false
+
+	for _, j := range r.m.jails {
+		if j.AccountID != accountID || !j.ActiveAt(now) {
+			continue
+		}
+
+		if !found || j.End.After(active.End) {
+			active = j
+			found = true
+		}
+	}
+
+	return active, found, nil
+}
+
+func (r memoryJails) ByAccount(accountID int32, limit int) ([]Jail, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	jails := []Jail{}
+	for i := len(r.m.jails) - 1; i >= 0 && len(jails) < limit; i-- {
+		if r.m.jails[i].AccountID == accountID {
+			jails = append(jails, r.m.jails[i])
+		}
+	}
+
+	return jails, nil
+}
+
+func (r memoryJails) Release(accountID int32, by string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	released := false
+
+	for i, j := range r.m.jails {
+		if j.AccountID == accountID && j.ActiveAt(now) {
+			r.m.jails[i].ReleasedBy = by
+			r.m.jails[i].ReleasedAt = now
+			released = true
+		}
+	}
+
+	if !released {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type memoryViolations struct {
+	m *Memory
+}
+
+func (r memoryViolations) Create(v *Violation) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.nextViolation++
+	v.ID = r.m.nextViolation
+	v.CreatedAt = time.Now()
+
+	r.m.violations = append(r.m.violations, *v)
+
+	return nil
+}
+
+func (r memoryViolations) Insert(vs []Violation) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, v := range vs {
+		r.m.nextViolation++
+		v.ID = r.m.nextViolation
+		r.m.violations = append(r.m.violations, v)
+	}
+
+	return nil
+}
+
+func (r memoryViolations) ByAccount(accountID int32, limit int) ([]Violation, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	violations := []Violation{}
+	for i := len(r.m.violations) - 1; i >= 0 && len(violations) < limit; i-- {
+		if r.m.violations[i].AccountID == accountID {
+			violations = append(violations, r.m.violations[i])
+		}
+	}
+
+	return violations, nil
+}
+
+func (r memoryViolations) TopOffenders(channelID byte, since time.Time, limit int) ([]Offender, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	// Violations are stored oldest first, so the last one seen for an account is its most recent
+	byAccount := make(map[int32]*Offender)
+	var order []*Offender
+
+	for _, v := range r.m.violations {
+		if v.ChannelID != channelID || v.CreatedAt.Before(since) {
+			continue
+		}
+
+		o, ok := byAccount[v.AccountID]
+		if !ok {
+			o = &Offender{AccountID: v.AccountID}
+			byAccount[v.AccountID] = o
+			order = append(order, o)
+		}
+
+		o.CharacterName = v.CharacterName
+		o.Count++
+		o.LastAt = v.CreatedAt
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].Count != order[j].Count {
+			return order[i].Count > order[j].Count
+		}
+		return order[i].LastAt.After(order[j].LastAt)
+	})
+
+	offenders := []Offender{}
+	for i := 0; i < len(order) && i < limit; i++ {
+		offenders = append(offenders, *order[i])
+	}
+
+	return offenders, nil
+}
+
+type memoryStorage struct {
+	m *Memory
+}
+
+func copyContents(c StorageContents) StorageContents {
+	c.Items = append([]Item(nil), c.Items...)
+	return c
+}
+
+func (r memoryStorage) Load(accountID int32) (StorageContents, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	c, ok := r.m.storage[accountID]
+	if !ok {
+		return StorageContents{}, ErrNotFound
+	}
+
+	return copyContents(c), nil
+}
+
+func (r memoryStorage) Create(accountID int32, slots byte) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.storage[accountID]; ok {
+		return ErrDuplicate
+	}
+
+	r.m.storage[accountID] = StorageContents{Slots: slots}
+
+	return nil
+}
+
+func (r memoryStorage) Save(accountID int32, contents StorageContents) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.storage[accountID] = copyContents(contents)
+
+	return nil
+}
+
+func (r memoryStorage) LoadCashShop(accountID int32) (StorageContents, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	c, ok := r.m.cashShop[accountID]
+	if !ok {
+		return StorageContents{}, ErrNotFound
+	}
+
+	return copyContents(c), nil
+}
+
+func (r memoryStorage) CreateCashShop(accountID int32, slots byte) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.cashShop[accountID]; ok {
+		return ErrDuplicate
+	}
+
+	r.m.cashShop[accountID] = StorageContents{Slots: slots}
+
+	return nil
+}
+
+func (r memoryStorage) SaveCashShop(accountID int32, contents StorageContents) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.cashShop[accountID] = copyContents(contents)
+
+	return nil
+}
+
+type memoryGifts struct {
+	m *Memory
+}
+
+func (r memoryGifts) Create(g Gift) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.nextGiftID++
+	g.ID = r.m.nextGiftID
+	r.m.gifts = append(r.m.gifts, g)
+
+	return nil
+}
+
+func (r memoryGifts) ByAccount(accountID int32) ([]Gift, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	gifts := []Gift{}
+	for _, g := range r.m.gifts {
+		if g.AccountID == accountID {
+			gifts = append(gifts, g)
+		}
+	}
+
+	return gifts, nil
+}
+
+func (r memoryGifts) Delete(giftID int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	kept := r.m.gifts[:0]
+	for _, g := range r.m.gifts {
+		if g.ID != giftID {
+			kept = append(kept, g)
+		}
+	}
+	r.m.gifts = kept
+
+	return nil
+}
+
+type memoryWishlists struct {
+	m *Memory
+}
+
+func (r memoryWishlists) ByCharacter(charID int32) ([]int32, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	return append([]int32{}, r.m.wishlists[charID]...), nil
+}
+
+func (r memoryWishlists) Save(charID int32, sns []int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	stored := []int32{}
+	for _, sn := range sns {
+		if sn != 0 {
+			stored = append(stored, sn)
+		}
+	}
+	r.m.wishlists[charID] = stored
+
+	return nil
+}
+
+type memoryCoupons struct {
+	m *Memory
+}
+
+func (r memoryCoupons) Create(c Coupon) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.coupons[c.Code]; ok {
+		return ErrDuplicate
+	}
+
+	c.Uses = 0
+	c.Items = append([]CouponItem{}, c.Items...)
+	r.m.coupons[c.Code] = c
+
+	return nil
+}
+
+func (r memoryCoupons) ByCode(code string) (Coupon, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	c, ok := r.m.coupons[code]
+	if !ok {
+		return Coupon{}, ErrNotFound
+	}
+
+	c.Items = append([]CouponItem{}, c.Items...)
+
+	return c, nil
+}
+
+func (r memoryCoupons) Redeem(code string, accountID, charID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	c, ok := r.m.coupons[code]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if c.Expired(time.Now().UnixMilli()) {
+		return ErrExpired
+	}
+
+	if r.m.redeemed[code][accountID] {
+		return ErrDuplicate
+	}
+
+	if c.MaxUses > 0 && c.Uses >= c.MaxUses {
+		return ErrExhausted
+	}
+
+	if r.m.redeemed[code] == nil {
+		r.m.redeemed[code] = make(map[int32]bool)
+	}
+	r.m.redeemed[code][accountID] = true
+
+	c.Uses++
+	r.m.coupons[code] = c
+
+	return nil
+}
+
+type memoryMerchants struct {
+	m *Memory
+}
+
+func copyMerchant(m Merchant) Merchant {
+	m.Items = append([]MerchantItem{}, m.Items...)
+	return m
+}
+
+func (r memoryMerchants) Create(m *Merchant) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, v := range r.m.merchants {
+		if v.CharacterID == m.CharacterID {
+			return ErrDuplicate
+		}
+	}
+
+	r.m.nextMerchant++
+	m.ID = r.m.nextMerchant
+	r.m.merchants[m.ID] = copyMerchant(*m)
+
+	return nil
+}
+
+func (r memoryMerchants) ByCharacter(charID int32) (Merchant, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, v := range r.m.merchants {
+		if v.CharacterID == charID {
+			return copyMerchant(v), nil
+		}
+	}
+
+	return Merchant{}, ErrNotFound
+}
+
+func (r memoryMerchants) Trading(channelID byte) ([]Merchant, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	merchants := []Merchant{}
+	for _, v := range r.m.merchants {
+		if v.ChannelID == channelID && !v.Closed {
+			merchants = append(merchants, copyMerchant(v))
+		}
+	}
+
+	sort.Slice(merchants, func(i, j int) bool { return merchants[i].ID < merchants[j].ID })
+
+	return merchants, nil
+}
+
+func (r memoryMerchants) Save(m Merchant) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	cur, ok := r.m.merchants[m.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	cur.Title = m.Title
+	cur.Mesos = m.Mesos
+	cur.Closed = m.Closed
+	cur.Items = append([]MerchantItem{}, m.Items...)
+	r.m.merchants[m.ID] = cur
+
+	return nil
+}
+
+func (r memoryMerchants) Delete(merchantID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.merchants, merchantID)
+
+	return nil
+}
+
+type memoryReports struct {
+	m *Memory
+}
+
+func (r memoryReports) Create(rep *Report) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.nextReport++
+	rep.ID = r.m.nextReport
+	rep.Status = ReportOpen
+	rep.CreatedAt = time.Now()
+	r.m.reports = append(r.m.reports, *rep)
+
+	return nil
+}
+
+func (r memoryReports) ByID(reportID int32) (Report, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, v := range r.m.reports {
+		if v.ID == reportID {
+			return v, nil
+		}
+	}
+
+	return Report{}, ErrNotFound
+}
+
+func (r memoryReports) Unresolved(limit int) ([]Report, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	reports := []Report{}
+	for _, v := range r.m.reports {
+		if len(reports) == limit {
+			break
+		}
+
+		if v.Status != ReportResolved {
+			reports = append(reports, v)
+		}
+	}
+
+	return reports, nil
+}
+
+func (r memoryReports) update(reportID int32, gm string, status byte, resolution string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for i, v := range r.m.reports {
+		if v.ID != reportID {
+			continue
+		}
+
+		if v.Status == ReportResolved {
+			return ErrNotFound
+		}
+
+		if v.Status == ReportClaimed && v.ClaimedBy != gm {
+			return ErrDuplicate
+		}
+
+		r.m.reports[i].Status = status
+		r.m.reports[i].ClaimedBy = gm
+		r.m.reports[i].Resolution = resolution
+
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+func (r memoryReports) Claim(reportID int32, gm string) error {
+	return r.update(reportID, gm, ReportClaimed, "")
+}
+
+func (r memoryReports) Resolve(reportID int32, gm, resolution string) error {
+	return r.update(reportID, gm, ReportResolved, resolution)
+}
+
+func (r memoryReports) CountSince(accountID int32, since time.Time) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	count := 0
+	for _, v := range r.m.reports {
+		if v.ReporterAccountID == accountID && v.CreatedAt.After(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+type memoryChatLogs struct {
+	m *Memory
+}
+
+func (r memoryChatLogs) Insert(lines []ChatLog) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, l := range lines {
+		r.m.nextChatLog++
+		l.ID = r.m.nextChatLog
+		r.m.chatLogs = append(r.m.chatLogs, l)
+	}
+
+	return nil
+}
+
+func (r memoryChatLogs) Recent(name string, limit int) ([]ChatLog, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	lines := []ChatLog{}
+	for i := len(r.m.chatLogs) - 1; i >= 0 && len(lines) < limit; i-- {
+		if l := r.m.chatLogs[i]; l.Sender == name || l.Target == name {
+			lines = append(lines, l)
+		}
+	}
+
+	return lines, nil
+}
+
+type memoryRaidEntries struct {
+	m *Memory
+}
+
+func (r memoryRaidEntries) Add(characterID int32, raid string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.raids = append(r.m.raids, memoryRaidEntry{characterID: characterID, raid: raid, createdAt: time.Now()})
+
+	return nil
+}
+
+func (r memoryRaidEntries) CountSince(characterID int32, raid string, since time.Time) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	count := 0
+	for _, v := range r.m.raids {
+		if v.characterID == characterID && v.raid == raid && v.createdAt.After(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+type memoryBuffs struct {
+	m *Memory
+}
+
+func (r memoryBuffs) ByCharacter(charID int32) ([]Buff, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	return append([]Buff{}, r.m.buffs[charID]...), nil
+}
+
+func (r memoryBuffs) Save(charID int32, buffs []Buff) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.buffs[charID] = append([]Buff{}, buffs...)
+
+	return nil
+}
+
+func (r memoryBuffs) Delete(charID int32, sourceIDs ...int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if len(sourceIDs) == 0 {
+		delete(r.m.buffs, charID)
+		return nil
+	}
+
+	kept := []Buff{}
+	for _, b := range r.m.buffs[charID] {
+		remove := false
+		for _, id := range sourceIDs {
+			remove = remove || b.SourceID == id
+		}
+
+		if !remove {
+			kept = append(kept, b)
+		}
+	}
+	r.m.buffs[charID] = kept
+
+	return nil
+}
+
+type memoryFame struct {
+	m *Memory
+}
+
+func (r memoryFame) Add(fromID, toID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.fame = append(r.m.fame, memoryFameEntry{fromID: fromID, toID: toID, createdAt: time.Now()})
+
+	return nil
+}
+
+func (r memoryFame) GivenWithin(fromID int32, window time.Duration) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	since := time.Now().Add(-window)
+	for _, f := range r.m.fame {
+		if f.fromID == fromID && f.createdAt.After(since) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+type memoryLieDetectors struct {
+	m *Memory
+}
+
+func (r memoryLieDetectors) Pend(characterID, reporterID int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.lieTests[characterID] = reporterID
+
+	return nil
+}
+
+func (r memoryLieDetectors) Take(characterID int32) (int32, bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	reporterID, ok := r.m.lieTests[characterID]
+	delete(r.m.lieTests, characterID)
+
+	return reporterID, ok, nil
+}
+
+func (r memoryLieDetectors) Reward(characterID, mesos int32) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.lieRewards[characterID] += mesos
+
+	return nil
+}
+
+func (r memoryLieDetectors) TakeReward(characterID int32) (int32, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	mesos := r.m.lieRewards[characterID]
+	delete(r.m.lieRewards, characterID)
+
+	return mesos, nil
+}
+
+type memoryPets struct {
+	m *Memory
+}
+
+func (r memoryPets) ByItem(itemID int64) (Pet, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	p, ok := r.m.pets[itemID]
+	if !ok {
+		return Pet{}, ErrNotFound
+	}
+
+	return p, nil
+}
+
+func (r memoryPets) Save(p Pet) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if existing, ok := r.m.pets[p.ItemID]; ok {
+		p.SN = existing.SN
+	}
+	r.m.pets[p.ItemID] = p
+
+	return nil
+}
+
+func (r memoryPets) Update(p Pet) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.pets[p.ItemID]; ok {
+		r.m.pets[p.ItemID] = p
+	}
+
+	return nil
+}
+
+type memoryPetEquips struct {
+	m *Memory
+}
+
+func (r memoryPetEquips) ByPet(petID int64) (map[byte]int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	equips := make(map[byte]int64, len(r.m.petEquips[petID]))
+	for slot, itemID := range r.m.petEquips[petID] {
+		equips[slot] = itemID
+	}
+
+	return equips, nil
+}
+
+func (r memoryPetEquips) Save(petID int64, equips map[byte]int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	saved := make(map[byte]int64, len(equips))
+	for slot, itemID := range equips {
+		saved[slot] = itemID
+	}
+	r.m.petEquips[petID] = saved
+
+	return nil
+}
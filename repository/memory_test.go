@@ -0,0 +1,438 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryCharacterCreate(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	c := CharacterData{
+		Character: Character{Name: "Tester", AccountID: 7, WorldID: 1, Face: 20000, Hair: 30000},
+		Str:       12,
+		Dex:       5,
+		Intt:      4,
+		Luk:       4,
+	}
+
+	if err := repo.Characters.Create(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.ID == 0 {
+		t.Fatal("Create did not set the ID")
+	}
+
+	got, err := repo.Characters.Load(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != "Tester" || got.Str != 12 || got.Level != 1 || got.ChannelID != -1 || got.MigrationID != -1 {
+		t.Errorf("Load() = %+v, want the created row with table defaults", got)
+	}
+
+	taken, err := repo.Characters.NameTaken("tester")
+	if err != nil || !taken {
+		t.Errorf("NameTaken(tester) = %v, %v, want true", taken, err)
+	}
+
+	if taken, _ := repo.Characters.NameTaken("Other"); taken {
+		t.Error("NameTaken(Other) = true, want false")
+	}
+
+	chars, err := repo.Characters.ByAccount(7, 1)
+	if err != nil || len(chars) != 1 || chars[0].ID != c.ID {
+		t.Errorf("ByAccount(7, 1) = %v, %v, want the created character", chars, err)
+	}
+
+	if chars, _ := repo.Characters.ByAccount(7, 0); len(chars) != 0 {
+		t.Errorf("ByAccount(7, 0) = %v, want none from another world", chars)
+	}
+
+	if err := repo.Characters.Delete(c.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Characters.Load(c.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() after Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryCharacterUpdate(t *testing.T) {
+	mem := NewMemory()
+	mem.PutCharacterData(CharacterData{Character: Character{ID: 1, Name: "Tester", Level: 10, MapID: 100}, Mesos: 50, Fame: 3})
+	repo := mem.Repositories()
+
+	changed := CharacterData{Character: Character{ID: 1, Level: 11, MapID: 200}, MapPos: 2, Mesos: 75, Fame: 9}
+
+	if err := repo.Characters.Update(changed, CharacterLevel|CharacterMesos|CharacterMap); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := repo.Characters.Load(1)
+
+	if got.Level != 11 || got.Mesos != 75 || got.MapID != 200 || got.MapPos != 2 {
+		t.Errorf("selected fields not written: %+v", got)
+	}
+
+	if got.Fame != 3 || got.Name != "Tester" {
+		t.Errorf("unselected fields changed: %+v", got)
+	}
+}
+
+func TestCharacterUpdates(t *testing.T) {
+	c := CharacterData{Character: Character{ID: 1, Hair: 30000, MapID: 100}, MapPos: 3, Mesos: 42}
+
+	tests := []struct {
+		name   string
+		fields CharacterFields
+		cols   []string
+		args   []any
+	}{
+		{"none", 0, []string{}, []any{}},
+		{"single", CharacterMesos, []string{"mesos=?"}, []any{int32(42)}},
+		{"map writes the portal", CharacterMap, []string{"mapID=?, mapPos=?"}, []any{int32(100), byte(3)}},
+		{"table order", CharacterMesos | CharacterHair, []string{"hair=?", "mesos=?"}, []any{int32(30000), int32(42)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols, args := characterUpdates(c, tt.fields)
+
+			if !reflect.DeepEqual(cols, tt.cols) || !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("characterUpdates() = %v %v, want %v %v", cols, args, tt.cols, tt.args)
+			}
+		})
+	}
+}
+
+func TestMemoryCharacterChannels(t *testing.T) {
+	mem := NewMemory()
+	mem.PutCharacter(Character{ID: 1, AccountID: 10, ChannelID: 2, MigrationID: -1})
+	mem.PutCharacter(Character{ID: 2, AccountID: 20, ChannelID: 2, MigrationID: 3})
+	mem.PutCharacter(Character{ID: 3, AccountID: 30, ChannelID: 1, MigrationID: -1})
+	repo := mem.Repositories()
+
+	accounts, err := repo.Characters.ClearChannel(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(accounts, []int32{10}) {
+		t.Errorf("ClearChannel(2) = %v, want only the account not migrating", accounts)
+	}
+
+	for id, want := range map[int32]int32{1: -1, 2: -1, 3: 1} {
+		if c, _ := repo.Characters.ByID(id); c.ChannelID != want {
+			t.Errorf("character %d channel = %d, want %d", id, c.ChannelID, want)
+		}
+	}
+
+	if err := repo.Characters.EnterCashShop(3, 50, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ := repo.Characters.ByID(3); !c.InCashShop || c.MigrationID != 50 || c.PreviousChannelID != 1 {
+		t.Errorf("after EnterCashShop = %+v", c)
+	}
+
+	if err := repo.Characters.LeaveCashShop(3, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ := repo.Characters.ByID(3); c.InCashShop || c.MigrationID != 1 {
+		t.Errorf("after LeaveCashShop = %+v", c)
+	}
+}
+
+func TestMemoryClearStaleLogins(t *testing.T) {
+	mem := NewMemory()
+	repo := mem.Repositories()
+
+	online, _ := repo.Accounts.Create(Account{Username: "online", LoggedIn: true})
+	stale, _ := repo.Accounts.Create(Account{Username: "stale", LoggedIn: true})
+
+	mem.PutCharacter(Character{ID: 1, AccountID: online, ChannelID: 0})
+	mem.PutCharacter(Character{ID: 2, AccountID: stale, ChannelID: -1})
+
+	changed, err := repo.Accounts.ClearStaleLogins()
+	if err != nil || changed != 1 {
+		t.Fatalf("ClearStaleLogins() = %d, %v, want 1", changed, err)
+	}
+
+	if a, _ := repo.Accounts.ByID(online); !a.LoggedIn {
+		t.Error("account with a character on a channel was logged out")
+	}
+
+	if a, _ := repo.Accounts.ByID(stale); a.LoggedIn {
+		t.Error("stale account still logged in")
+	}
+}
+
+func TestMemoryGuildInvites(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	id, err := repo.Guilds.Create(Guild{Name: "Heroes", WorldID: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if taken, _ := repo.Guilds.NameTaken("heroes", 0); !taken {
+		t.Error("NameTaken(heroes, 0) = false, want true")
+	}
+
+	if taken, _ := repo.Guilds.NameTaken("Heroes", 1); taken {
+		t.Error("NameTaken(Heroes, 1) = true, want false for another world")
+	}
+
+	_ = repo.Guilds.Invite(GuildInvite{CharacterID: 5, GuildID: id, Inviter: "Leader"})
+	_ = repo.Guilds.Invite(GuildInvite{CharacterID: 6, GuildID: id, Inviter: "Leader"})
+
+	invites, _ := repo.Guilds.Invites(5)
+	if len(invites) != 1 || invites[0].Inviter != "Leader" {
+		t.Errorf("Invites(5) = %v", invites)
+	}
+
+	_ = repo.Guilds.DeleteInvite(5, id)
+
+	if invites, _ := repo.Guilds.Invites(5); len(invites) != 0 {
+		t.Errorf("Invites(5) after DeleteInvite = %v, want none", invites)
+	}
+
+	if invites, _ := repo.Guilds.Invites(6); len(invites) != 1 {
+		t.Errorf("Invites(6) = %v, want the other invite kept", invites)
+	}
+}
+
+func TestMemoryBuffs(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	buffs := []Buff{{SourceID: 2001002, Level: 10, ExpiresAtMs: 1000}, {SourceID: -2022003, ExpiresAtMs: 2000}, {SourceID: 1101004, Level: 5, ExpiresAtMs: 3000}}
+	if err := repo.Buffs.Save(1, buffs); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = repo.Buffs.Delete(1, 2001002, 1101004)
+
+	got, _ := repo.Buffs.ByCharacter(1)
+	if !reflect.DeepEqual(got, buffs[1:2]) {
+		t.Errorf("ByCharacter() after Delete = %v, want %v", got, buffs[1:2])
+	}
+
+	_ = repo.Buffs.Delete(1)
+
+	if got, _ := repo.Buffs.ByCharacter(1); len(got) != 0 {
+		t.Errorf("ByCharacter() after deleting all = %v", got)
+	}
+}
+
+func TestMemoryFame(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	if given, _ := repo.Fame.GivenWithin(1, time.Hour); given {
+		t.Error("GivenWithin() = true before any fame was given")
+	}
+
+	_ = repo.Fame.Add(1, 2)
+
+	if given, _ := repo.Fame.GivenWithin(1, time.Hour); !given {
+		t.Error("GivenWithin() = false after giving fame")
+	}
+
+	if given, _ := repo.Fame.GivenWithin(2, time.Hour); given {
+		t.Error("GivenWithin() = true for the receiver")
+	}
+}
+
+func TestMemoryCouponRedeem(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	if err := repo.Coupons.Create(Coupon{Code: "ONCE", MaxUses: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Coupons.Create(Coupon{Code: "ONCE"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Create() duplicate error = %v, want ErrDuplicate", err)
+	}
+
+	if err := repo.Coupons.Redeem("ONCE", 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Coupons.Redeem("ONCE", 1, 1); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("second Redeem() by the account error = %v, want ErrDuplicate", err)
+	}
+
+	if err := repo.Coupons.Redeem("ONCE", 2, 2); !errors.Is(err, ErrExhausted) {
+		t.Errorf("Redeem() with no uses left error = %v, want ErrExhausted", err)
+	}
+}
+
+func TestCouponExpired(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt int64
+		now       int64
+		want      bool
+	}{
+		{"never expires", 0, 1 << 40, false},
+		{"before expiry", 2000, 1999, false},
+		{"at expiry", 2000, 2000, true},
+		{"after expiry", 2000, 2001, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Coupon{ExpiresAt: tt.expiresAt}).Expired(tt.now); got != tt.want {
+				t.Errorf("Expired(%d) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCouponRedeemExpired(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	if err := repo.Coupons.Create(Coupon{Code: "OLD", ExpiresAt: time.Now().Add(-time.Minute).UnixMilli()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Coupons.Redeem("OLD", 1, 1); !errors.Is(err, ErrExpired) {
+		t.Errorf("Redeem() of an expired coupon error = %v, want ErrExpired", err)
+	}
+
+	if c, _ := repo.Coupons.ByCode("OLD"); c.Uses != 0 {
+		t.Errorf("expired coupon uses = %d, want 0", c.Uses)
+	}
+}
+
+func TestMemoryLieDetectors(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	if _, ok, _ := repo.LieTests.Take(1); ok {
+		t.Error("Take() = ok before any test was pending")
+	}
+
+	_ = repo.LieTests.Pend(1, 5)
+	_ = repo.LieTests.Pend(1, 7)
+
+	if reporterID, ok, err := repo.LieTests.Take(1); err != nil || !ok || reporterID != 7 {
+		t.Errorf("Take() = %d, %v, %v, want the latest reporter 7", reporterID, ok, err)
+	}
+
+	if _, ok, _ := repo.LieTests.Take(1); ok {
+		t.Error("Take() = ok twice for the same pending test")
+	}
+
+	_ = repo.LieTests.Reward(5, 5000)
+	_ = repo.LieTests.Reward(5, 5000)
+
+	if mesos, err := repo.LieTests.TakeReward(5); err != nil || mesos != 10000 {
+		t.Errorf("TakeReward() = %d, %v, want both rewards", mesos, err)
+	}
+
+	if mesos, _ := repo.LieTests.TakeReward(5); mesos != 0 {
+		t.Errorf("TakeReward() = %d twice for the same reward, want 0", mesos)
+	}
+}
+
+func TestMemoryReportResolve(t *testing.T) {
+	testReportResolve(t, NewMemory().Repositories())
+}
+
+// testReportResolve runs the report moderation rules against a repository, the MySQL store has to agree with the
+// memory one
+func testReportResolve(t *testing.T, repo Repositories) {
+	rep := Report{ReporterID: 1, TargetID: 2}
+	if err := repo.Reports.Create(&rep); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Reports.Claim(rep.ID, "Alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Reports.Claim(rep.ID, "Alice"); err != nil {
+		t.Errorf("Claim() of a report the GM holds error = %v, want nil", err)
+	}
+
+	if err := repo.Reports.Claim(rep.ID, "Bob"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Claim() by another GM error = %v, want ErrDuplicate", err)
+	}
+
+	if err := repo.Reports.Resolve(rep.ID, "Bob", "dismissed"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Resolve() by another GM error = %v, want ErrDuplicate", err)
+	}
+
+	if err := repo.Reports.Resolve(rep.ID, "Alice", "banned for 72 hours"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Reports.Resolve(rep.ID, "Alice", "dismissed"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Resolve() error = %v, want ErrNotFound", err)
+	}
+
+	if err := repo.Reports.Claim(-1, "Alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Claim() of a missing report error = %v, want ErrNotFound", err)
+	}
+
+	if got, _ := repo.Reports.ByID(rep.ID); got.Status != ReportResolved || got.Resolution != "banned for 72 hours" {
+		t.Errorf("ByID() = %+v, want the first resolution kept", got)
+	}
+}
+
+func TestMemoryPets(t *testing.T) {
+	repo := NewMemory().Repositories()
+
+	if _, err := repo.Pets.ByItem(1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ByItem() error = %v, want ErrNotFound", err)
+	}
+
+	_ = repo.Pets.Update(Pet{ItemID: 1, Name: "Kitty"})
+	if _, err := repo.Pets.ByItem(1); !errors.Is(err, ErrNotFound) {
+		t.Error("Update() created a pet that was not saved")
+	}
+
+	_ = repo.Pets.Save(Pet{ItemID: 1, Name: "Kitty", SN: 10})
+	_ = repo.Pets.Save(Pet{ItemID: 1, Name: "Tom", SN: 20, Level: 2})
+
+	if p, err := repo.Pets.ByItem(1); err != nil || p.Name != "Tom" || p.SN != 10 || p.Level != 2 {
+		t.Errorf("ByItem() = %+v, %v, want the second save with the first SN", p, err)
+	}
+}
+
+func TestMemoryPetEquips(t *testing.T) {
+	m := NewMemory()
+	repo := m.Repositories()
+
+	pet := Item{ItemID: 5000000}
+	magnet := Item{ItemID: 1812000}
+	pouch := Item{ItemID: 1812001}
+	for _, it := range []*Item{&pet, &magnet, &pouch} {
+		_ = repo.Items.Save(1, it)
+	}
+
+	_ = repo.Pets.Save(Pet{ItemID: pet.ID})
+	_ = repo.PetEquips.Save(pet.ID, map[byte]int64{0: magnet.ID, 1: pouch.ID})
+
+	// Removing an item takes it off the pet, the rest stays worn
+	_ = repo.Items.Delete(pouch.ID)
+
+	equips, err := repo.PetEquips.ByPet(pet.ID)
+	if err != nil || len(equips) != 1 || equips[0] != magnet.ID {
+		t.Errorf("ByPet() = %v, %v, want only the magnet in slot 0", equips, err)
+	}
+
+	_ = repo.Items.Delete(pet.ID)
+
+	if equips, _ := repo.PetEquips.ByPet(pet.ID); len(equips) != 0 {
+		t.Errorf("ByPet() = %v after the pet was deleted, want none", equips)
+	}
+	if _, err := repo.Pets.ByItem(pet.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ByItem() error = %v after the pet was deleted, want ErrNotFound", err)
+	}
+}
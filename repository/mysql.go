@@ -0,0 +1,2212 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NewMySQL creates repositories that issue queries against the given connection pool
+func NewMySQL(db *sql.DB) Repositories {
+	return Repositories{
+		Accounts:   mysqlAccounts{db},
+		Characters: mysqlCharacters{db},
+		Items:      mysqlItems{db},
+		Skills:     mysqlSkills{db},
+		Quests:     mysqlQuests{db},
+		Guilds:     mysqlGuilds{db},
+		Buddies:    mysqlBuddies{db},
+		Bans:       mysqlBans{db},
+		Mutes:      mysqlMutes{db},
+		Jails:      mysqlJails{db},
+		Violations: mysqlViolations{db},
+		Storage:    mysqlStorage{db},
+		Gifts:      mysqlGifts{db},
+		Wishlists:  mysqlWishlists{db},
+		Coupons:    mysqlCoupons{db},
+		Merchants:  mysqlMerchants{db},
+		Reports:    mysqlReports{db},
+		ChatLogs:   mysqlChatLogs{db},
+		Raids:      mysqlRaidEntries{db},
+		Buffs:      mysqlBuffs{db},
+		Fame:       mysqlFame{db},
+		LieTests:   mysqlLieDetectors{db},
+		Pets:       mysqlPets{db},
+		PetEquips:  mysqlPetEquips{db},
+	}
+}
+
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// parseTimestamp handles TIMESTAMP columns returned as text when the DSN does not set parseTime
+func parseTimestamp(s string) (time.Time, error) {
+	layouts := []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04:05.999999",
+		"2006-01-02 15:04:05.999999999",
+		time.RFC3339Nano,
+	}
+
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("failed to parse timestamp %q: %w", s, err)
+}
+
+type mysqlAccounts struct {
+	db *sql.DB
+}
+
+const accountColumns = "accountID, username, password, pin, gender, dob, eula, adminLevel, isLogedIn, isBanned, isLocked, hwid, nx, maplepoints"
+
+func scanAccount(row *sql.Row) (Account, error) {
+	var a Account
+	var hwid sql.NullString
+
+	err := row.Scan(&a.ID, &a.Username, &a.Password, &a.Pin, &a.Gender, &a.DOB, &a.EULA, &a.AdminLevel,
+		&a.LoggedIn, &a.Banned, &a.Locked, &hwid, &a.NX, &a.MaplePoints)
+	if err != nil {
+		return a, notFound(err)
+	}
+
+	if hwid.Valid {
+		a.HWID = hwid.String
+	}
+
+	return a, nil
+}
+
+func (r mysqlAccounts) ByUsername(username string) (Account, error) {
+	return scanAccount(r.db.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE username=?", username))
+}
+
+func (r mysqlAccounts) ByID(accountID int32) (Account, error) {
+	return scanAccount(r.db.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE accountID=?", accountID))
+}
+
+func (r mysqlAccounts) Create(a Account) (int32, error) {
+	res, err := r.db.Exec("INSERT INTO accounts (username, password, pin, isLogedIn, adminLevel, isBanned, gender, dob, eula, nx, maplepoints, hwid) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		a.Username, a.Password, a.Pin, a.LoggedIn, a.AdminLevel, a.Banned, a.Gender, a.DOB, a.EULA, a.NX, a.MaplePoints, a.HWID)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(id), nil
+}
+
+func (r mysqlAccounts) SetHWID(accountID int32, hwid string) error {
+	_, err := r.db.Exec("UPDATE accounts SET hwid=? WHERE accountID=?", hwid, accountID)
+	return err
+}
+
+func (r mysqlAccounts) SetEULA(accountID int32, accepted bool) error {
+	_, err := r.db.Exec("UPDATE accounts SET eula=? WHERE accountID=?", accepted, accountID)
+	return err
+}
+
+func (r mysqlAccounts) SetPin(accountID int32, pin string) error {
+	_, err := r.db.Exec("UPDATE accounts SET pin=? WHERE accountID=?", pin, accountID)
+	return err
+}
+
+func (r mysqlAccounts) SetPassword(accountID int32, hash string) error {
+	_, err := r.db.Exec("UPDATE accounts SET password=? WHERE accountID=?", hash, accountID)
+	return err
+}
+
+func (r mysqlAccounts) SetLoggedIn(accountID int32, loggedIn bool) error {
+	_, err := r.db.Exec("UPDATE accounts SET isLogedIn=? WHERE accountID=?", loggedIn, accountID)
+	return err
+}
+
+func (r mysqlAccounts) SetLocked(accountID int32, locked bool) error {
+	_, err := r.db.Exec("UPDATE accounts SET isLocked=? WHERE accountID=?", locked, accountID)
+	return err
+}
+
+func (r mysqlAccounts) SetBanned(accountID int32, banned bool) error {
+	_, err := r.db.Exec("UPDATE accounts SET isBanned=? WHERE accountID=?", banned, accountID)
+	return err
+}
+
+func (r mysqlAccounts) SetCash(accountID, nx, maplePoints int32) error {
+	_, err := r.db.Exec("UPDATE accounts SET nx=?, maplepoints=? WHERE accountID=?", nx, maplePoints, accountID)
+	return err
+}
+
+func (r mysqlAccounts) ClearStaleLogins() (int64, error) {
+	res, err := r.db.Exec("UPDATE accounts AS a INNER JOIN characters c ON a.accountID = c.accountID SET a.isLogedIn = 0 WHERE isLogedIn = 1 AND a.accountID != ALL (SELECT c.accountID FROM characters c WHERE c.channelID != -1);")
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+type mysqlCharacters struct {
+	db *sql.DB
+}
+
+const characterColumns = "ID, accountID, worldID, Name, gender, skin, hair, face, level, job, mapID, channelID, inCashShop, buddyListSize, guildID, guildRank, migrationID, previousChannelID"
+
+const characterDataColumns = characterColumns + ", str, dex, intt, luk, hp, maxHP, mp, maxMP, ap, sp, exp, fame, mapPos, previousMapID, mesos, " +
+	"equipSlotSize, useSlotSize, setupSlotSize, etcSlotSize, cashSlotSize, miniGameWins, miniGameDraw, miniGameLoss, miniGamePoints, " +
+	"regTeleportRocks, vipTeleportRocks"
+
+func (r mysqlCharacters) scan(row interface{ Scan(...any) error }, extra ...any) (Character, error) {
+	var c Character
+	var guildID sql.NullInt64
+
+	dest := []any{&c.ID, &c.AccountID, &c.WorldID, &c.Name, &c.Gender, &c.Skin, &c.Hair, &c.Face, &c.Level, &c.Job,
+		&c.MapID, &c.ChannelID, &c.InCashShop, &c.BuddyListSize, &guildID, &c.GuildRank, &c.MigrationID, &c.PreviousChannelID}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return c, notFound(err)
+	}
+
+	if guildID.Valid {
+		c.GuildID = int32(guildID.Int64)
+	}
+
+	return c, nil
+}
+
+func (r mysqlCharacters) scanData(row interface{ Scan(...any) error }) (CharacterData, error) {
+	var d CharacterData
+	var regRocks, vipRocks sql.NullString
+
+	c, err := r.scan(row, &d.Str, &d.Dex, &d.Intt, &d.Luk, &d.HP, &d.MaxHP, &d.MP, &d.MaxMP, &d.AP, &d.SP, &d.Exp, &d.Fame,
+		&d.MapPos, &d.PreviousMapID, &d.Mesos, &d.EquipSlotSize, &d.UseSlotSize, &d.SetupSlotSize, &d.EtcSlotSize,
+		&d.CashSlotSize, &d.MiniGameWins, &d.MiniGameDraw, &d.MiniGameLoss, &d.MiniGamePoints, &regRocks, &vipRocks)
+	if err != nil {
+		return d, err
+	}
+
+	d.Character = c
+	d.RegTeleportRocks = regRocks.String
+	d.VIPTeleportRocks = vipRocks.String
+
+	return d, nil
+}
+
+func (r mysqlCharacters) ByID(charID int32) (Character, error) {
+	return r.scan(r.db.QueryRow("SELECT "+characterColumns+" FROM characters WHERE ID=?", charID))
+}
+
+func (r mysqlCharacters) ByName(name string, worldID byte) (Character, error) {
+	return r.scan(r.db.QueryRow("SELECT "+characterColumns+" FROM characters WHERE BINARY Name=? AND worldID=?", name, worldID))
+}
+
+func (r mysqlCharacters) AccountIDByName(name string) (int32, error) {
+	var accountID int32
+	err := r.db.QueryRow("SELECT accountID FROM characters WHERE name=? LIMIT 1", name).Scan(&accountID)
+	return accountID, notFound(err)
+}
+
+func (r mysqlCharacters) Load(charID int32) (CharacterData, error) {
+	return r.scanData(r.db.QueryRow("SELECT "+characterDataColumns+" FROM characters WHERE ID=?", charID))
+}
+
+func (r mysqlCharacters) ByAccount(accountID int32, worldID byte) ([]CharacterData, error) {
+	rows, err := r.db.Query("SELECT "+characterDataColumns+" FROM characters WHERE accountID=? AND worldID=? ORDER BY ID", accountID, worldID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chars := []CharacterData{}
+	for rows.Next() {
+		d, err := r.scanData(rows)
+		if err != nil {
+			return chars, err
+		}
+
+		chars = append(chars, d)
+	}
+
+	return chars, rows.Err()
+}
+
+func (r mysqlCharacters) NameTaken(name string) (bool, error) {
+	var count int
+	err := r.db.QueryRow("SELECT count(*) FROM characters WHERE name=?", name).Scan(&count)
+	return count > 0, err
+}
+
+func (r mysqlCharacters) Create(c *CharacterData) error {
+	res, err := r.db.Exec("INSERT INTO characters (name, accountID, worldID, face, hair, skin, gender, str, dex, intt, luk) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		c.Name, c.AccountID, c.WorldID, c.Face, c.Hair, c.Skin, c.Gender, c.Str, c.Dex, c.Intt, c.Luk)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	c.ID = int32(id)
+
+	return nil
+}
+
+// characterUpdates pairs each updatable field with its columns and values
+func characterUpdates(c CharacterData, fields CharacterFields) ([]string, []any) {
+	updates := []struct {
+		field CharacterFields
+		cols  string
+		args  []any
+	}{
+		{CharacterSkin, "skin=?", []any{c.Skin}},
+		{CharacterHair, "hair=?", []any{c.Hair}},
+		{CharacterFace, "face=?", []any{c.Face}},
+		{CharacterLevel, "level=?", []any{c.Level}},
+		{CharacterJob, "job=?", []any{c.Job}},
+		{CharacterStr, "str=?", []any{c.Str}},
+		{CharacterDex, "dex=?", []any{c.Dex}},
+		{CharacterInt, "intt=?", []any{c.Intt}},
+		{CharacterLuk, "luk=?", []any{c.Luk}},
+		{CharacterHP, "hp=?", []any{c.HP}},
+		{CharacterMaxHP, "maxHP=?", []any{c.MaxHP}},
+		{CharacterMP, "mp=?", []any{c.MP}},
+		{CharacterMaxMP, "maxMP=?", []any{c.MaxMP}},
+		{CharacterAP, "ap=?", []any{c.AP}},
+		{CharacterSP, "sp=?", []any{c.SP}},
+		{CharacterExp, "exp=?", []any{c.Exp}},
+		{CharacterFame, "fame=?", []any{c.Fame}},
+		{CharacterMap, "mapID=?, mapPos=?", []any{c.MapID, c.MapPos}},
+		{CharacterPreviousMap, "previousMapID=?", []any{c.PreviousMapID}},
+		{CharacterMesos, "mesos=?", []any{c.Mesos}},
+		{CharacterSlotSizes, "equipSlotSize=?, useSlotSize=?, setupSlotSize=?, etcSlotSize=?, cashSlotSize=?",
+			[]any{c.EquipSlotSize, c.UseSlotSize, c.SetupSlotSize, c.EtcSlotSize, c.CashSlotSize}},
+		{CharacterMiniGame, "miniGameWins=?, miniGameDraw=?, miniGameLoss=?, miniGamePoints=?",
+			[]any{c.MiniGameWins, c.MiniGameDraw, c.MiniGameLoss, c.MiniGamePoints}},
+		{CharacterBuddyListSize, "buddyListSize=?", []any{c.BuddyListSize}},
+		{CharacterTeleportRocks, "regTeleportRocks=?, vipTeleportRocks=?", []any{c.RegTeleportRocks, c.VIPTeleportRocks}},
+	}
+
+	cols := make([]string, 0, len(updates))
+	args := make([]any, 0, len(updates)+1)
+
+	for _, u := range updates {
+		if fields&u.field != 0 {
+			cols = append(cols, u.cols)
+			args = append(args, u.args...)
+		}
+	}
+
+	return cols, args
+}
+
+func (r mysqlCharacters) Update(c CharacterData, fields CharacterFields) error {
+	cols, args := characterUpdates(c, fields)
+	if len(cols) == 0 {
+		return nil
+	}
+
+	_, err := r.db.Exec("UPDATE characters SET "+strings.Join(cols, ", ")+" WHERE ID=?", append(args, c.ID)...)
+	return err
+}
+
+func (r mysqlCharacters) Delete(charID int32) error {
+	_, err := r.db.Exec("DELETE FROM characters WHERE ID=?", charID)
+	return err
+}
+
+func (r mysqlCharacters) SetChannel(charID, channelID int32) error {
+	_, err := r.db.Exec("UPDATE characters SET channelID=? WHERE ID=?", channelID, charID)
+	return err
+}
+
+func (r mysqlCharacters) SetMigration(charID, migrationID int32) error {
+	_, err := r.db.Exec("UPDATE characters SET migrationID=? WHERE ID=?", migrationID, charID)
+	return err
+}
+
+func (r mysqlCharacters) SetInCashShop(charID int32, inCashShop bool) error {
+	_, err := r.db.Exec("UPDATE characters SET inCashShop=? WHERE ID=?", inCashShop, charID)
+	return err
+}
+
+func (r mysqlCharacters) EnterCashShop(charID, migrationID, fromChannelID int32) error {
+	_, err := r.db.Exec("UPDATE characters SET migrationID=?, previousChannelID=?, inCashShop=1 WHERE ID=?", migrationID, fromChannelID, charID)
+	return err
+}
+
+func (r mysqlCharacters) LeaveCashShop(charID, migrationID int32) error {
+	_, err := r.db.Exec("UPDATE characters SET migrationID=?, inCashShop=0 WHERE ID=?", migrationID, charID)
+	return err
+}
+
+func (r mysqlCharacters) ClearChannel(channelID int32) (accountIDs []int32, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.Query("SELECT accountID FROM characters WHERE channelID=? AND migrationID=-1", channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var accountID int32
+		if err = rows.Scan(&accountID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		accountIDs = append(accountIDs, accountID)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err = tx.Exec("UPDATE characters SET channelID=-1 WHERE channelID=?", channelID); err != nil {
+		return nil, err
+	}
+
+	return accountIDs, tx.Commit()
+}
+
+type mysqlItems struct {
+	db *sql.DB
+}
+
+func (r mysqlItems) ByCharacter(charID int32) ([]Item, error) {
+	const filter = "ID,inventoryID,itemID,slotNumber,amount,flag,upgradeSlots,level,str,dex,intt,luk,hp,mp,watk,matk,wdef,mdef,accuracy,avoid,hands,speed,jump,expireTime,creatorName,cashID,cashSN"
+
+	rows, err := r.db.Query("SELECT "+filter+" FROM items WHERE characterID=?", charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		var it Item
+		var cashID sql.NullInt64
+		var cashSN sql.NullInt32
+
+		if err := rows.Scan(&it.ID, &it.InventoryID, &it.ItemID, &it.Slot, &it.Amount, &it.Flag, &it.UpgradeSlots, &it.Level,
+			&it.Str, &it.Dex, &it.Intt, &it.Luk, &it.HP, &it.MP, &it.Watk, &it.Matk, &it.Wdef, &it.Mdef,
+			&it.Accuracy, &it.Avoid, &it.Hands, &it.Speed, &it.Jump, &it.ExpireTime, &it.CreatorName, &cashID, &cashSN); err != nil {
+			return items, err
+		}
+
+		it.CashID = cashID.Int64
+		it.CashSN = cashSN.Int32
+
+		items = append(items, it)
+	}
+
+	return items, rows.Err()
+}
+
+func (r mysqlItems) Save(charID int32, it *Item) error {
+	cashID := sql.NullInt64{Int64: it.CashID, Valid: it.CashID != 0}
+	cashSN := sql.NullInt32{Int32: it.CashSN, Valid: it.CashSN != 0}
+
+	if it.ID == 0 {
+		props := `characterID,inventoryID,itemID,slotNumber,amount,flag,upgradeSlots,level,
+				str,dex,intt,luk,hp,mp,watk,matk,wdef,mdef,accuracy,avoid,hands,speed,jump,
+				expireTime,creatorName,cashID,cashSN`
+
+		query := "INSERT into items (" + props + ") VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)"
+
+		res, err := r.db.Exec(query,
+			charID, it.InventoryID, it.ItemID, it.Slot, it.Amount, it.Flag, it.UpgradeSlots, it.Level,
+			it.Str, it.Dex, it.Intt, it.Luk, it.HP, it.MP, it.Watk, it.Matk, it.Wdef, it.Mdef, it.Accuracy, it.Avoid, it.Hands, it.Speed, it.Jump,
+			it.ExpireTime, it.CreatorName, cashID, cashSN)
+		if err != nil {
+			return err
+		}
+
+		it.ID, err = res.LastInsertId()
+
+		return err
+	}
+
+	props := `slotNumber=?,amount=?,flag=?,upgradeSlots=?,level=?,
+			str=?,dex=?,intt=?,luk=?,hp=?,mp=?,watk=?,matk=?,wdef=?,mdef=?,accuracy=?,avoid=?,hands=?,speed=?,jump=?,
+			expireTime=?,cashID=?,cashSN=?`
+
+	_, err := r.db.Exec("UPDATE items SET "+props+" WHERE ID=?",
+		it.Slot, it.Amount, it.Flag, it.UpgradeSlots, it.Level,
+		it.Str, it.Dex, it.Intt, it.Luk, it.HP, it.MP, it.Watk, it.Matk, it.Wdef, it.Mdef, it.Accuracy, it.Avoid, it.Hands, it.Speed, it.Jump,
+		it.ExpireTime, cashID, cashSN, it.ID)
+
+	return err
+}
+
+func (r mysqlItems) Delete(itemID int64) error {
+	_, err := r.db.Exec("DELETE FROM `items` WHERE ID=?", itemID)
+	return err
+}
+
+type mysqlSkills struct {
+	db *sql.DB
+}
+
+func (r mysqlSkills) ByCharacter(charID int32) ([]Skill, error) {
+	rows, err := r.db.Query("SELECT skillID, level, cooldown FROM skills WHERE characterID=?", charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := []Skill{}
+	for rows.Next() {
+		var s Skill
+		if err := rows.Scan(&s.ID, &s.Level, &s.Cooldown); err != nil {
+			return skills, err
+		}
+		skills = append(skills, s)
+	}
+
+	return skills, rows.Err()
+}
+
+func (r mysqlSkills) Save(charID int32, s Skill) error {
+	_, err := r.db.Exec(`INSERT INTO skills(characterID,skillID,level,cooldown)
+	         VALUES(?,?,?,?)
+	         ON DUPLICATE KEY UPDATE level=VALUES(level), cooldown=VALUES(cooldown)`,
+		charID, s.ID, s.Level, s.Cooldown)
+	return err
+}
+
+type mysqlQuests struct {
+	db *sql.DB
+}
+
+func (r mysqlQuests) ByCharacter(charID int32) ([]Quest, error) {
+	rows, err := r.db.Query("SELECT questID, record, completed, completedAt FROM character_quests WHERE characterID=?", charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quests := []Quest{}
+	for rows.Next() {
+		var q Quest
+		if err := rows.Scan(&q.ID, &q.Record, &q.Completed, &q.CompletedAt); err != nil {
+			continue
+		}
+		quests = append(quests, q)
+	}
+
+	return quests, rows.Err()
+}
+
+func (r mysqlQuests) MobKills(charID int32) ([]QuestMobKill, error) {
+	rows, err := r.db.Query("SELECT questID, mobID, kills FROM character_quest_kills WHERE characterID=?", charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kills := []QuestMobKill{}
+	for rows.Next() {
+		var k QuestMobKill
+		if err := rows.Scan(&k.QuestID, &k.MobID, &k.Kills); err != nil {
+			continue
+		}
+		kills = append(kills, k)
+	}
+
+	return kills, rows.Err()
+}
+
+func (r mysqlQuests) SetRecord(charID int32, questID int16, record string) error {
+	_, err := r.db.Exec(
+		"INSERT INTO character_quests(characterID, questID, record, completed, completedAt) "+
+			"VALUES(?,?,?,?,?) ON DUPLICATE KEY UPDATE record=VALUES(record), completed=0, completedAt=0",
+		charID, questID, record, 0, 0,
+	)
+	return err
+}
+
+func (r mysqlQuests) SetCompleted(charID int32, questID int16, completedAt int64) error {
+	_, err := r.db.Exec(
+		"INSERT INTO character_quests(characterID, questID, record, completed, completedAt) "+
+			"VALUES(?,?,?,?,?) ON DUPLICATE KEY UPDATE completed=1, completedAt=VALUES(completedAt)",
+		charID, questID, "", 1, completedAt,
+	)
+	return err
+}
+
+func (r mysqlQuests) Delete(charID int32, questID int16) error {
+	_, err := r.db.Exec("DELETE FROM character_quests WHERE characterID=? AND questID=?", charID, questID)
+	return err
+}
+
+func (r mysqlQuests) AddMobKill(charID int32, questID int16, mobID int32, delta int32) error {
+	_, err := r.db.Exec(
+		"INSERT INTO character_quest_kills(characterID, questID, mobID, kills) VALUES(?,?,?,?) "+
+			"ON DUPLICATE KEY UPDATE kills = kills + VALUES(kills)",
+		charID, questID, mobID, delta,
+	)
+	return err
+}
+
+func (r mysqlQuests) ClearMobKills(charID int32, questID int16) error {
+	_, err := r.db.Exec("DELETE FROM character_quest_kills WHERE characterID=? AND questID=?", charID, questID)
+	return err
+}
+
+type mysqlGuilds struct {
+	db *sql.DB
+}
+
+func (r mysqlGuilds) ByID(guildID int32) (Guild, error) {
+	var g Guild
+
+	query := "SELECT ID,capacity,worldID,Name,notice,master,jrMaster,member1,member2,member3,logoBg,logoBgColour,logo,logoColour,points FROM guilds WHERE ID=?"
+	err := r.db.QueryRow(query, guildID).Scan(&g.ID, &g.Capacity, &g.WorldID, &g.Name, &g.Notice, &g.Master, &g.JrMaster,
+		&g.Member1, &g.Member2, &g.Member3, &g.LogoBg, &g.LogoBgColour, &g.Logo, &g.LogoColour, &g.Points)
+
+	return g, notFound(err)
+}
+
+func (r mysqlGuilds) Members(guildID int32) ([]GuildMember, error) {
+	rows, err := r.db.Query("SELECT ID, guildRank, Name, job, level, channelID FROM characters WHERE guildID=?", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []GuildMember{}
+	for rows.Next() {
+		var m GuildMember
+		var channelID int32
+
+		if err := rows.Scan(&m.CharacterID, &m.Rank, &m.Name, &m.Job, &m.Level, &channelID); err != nil {
+			return members, err
+		}
+
+		m.Online = channelID > -1
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+func (r mysqlGuilds) Create(g Guild) (int32, error) {
+	query := "INSERT INTO guilds (Name, worldID, notice, master, jrMaster, member1, member2, member3) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+
+	res, err := r.db.Exec(query, g.Name, g.WorldID, g.Notice, g.Master, g.JrMaster, g.Member1, g.Member2, g.Member3)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(id), nil
+}
+
+func (r mysqlGuilds) Delete(guildID int32) error {
+	_, err := r.db.Exec("DELETE FROM guilds WHERE (id=?)", guildID)
+	return err
+}
+
+func (r mysqlGuilds) SetMember(charID, guildID int32, rank byte) error {
+	_, err := r.db.Exec("UPDATE characters SET guildID=?, guildRank=? WHERE ID=?", guildID, rank, charID)
+	return err
+}
+
+func (r mysqlGuilds) RemoveMember(charID int32) error {
+	_, err := r.db.Exec("UPDATE characters SET guildID=?, guildRank=? WHERE ID=?", nil, 0, charID)
+	return err
+}
+
+func (r mysqlGuilds) SetRank(charID int32, rank byte) error {
+	_, err := r.db.Exec("UPDATE characters SET guildRank=? WHERE ID=?", rank, charID)
+	return err
+}
+
+func (r mysqlGuilds) SetPoints(guildID, points int32) error {
+	_, err := r.db.Exec("UPDATE guilds SET points=? WHERE id=?", points, guildID)
+	return err
+}
+
+func (r mysqlGuilds) SetTitles(guildID int32, master, jrMaster, member1, member2, member3 string) error {
+	_, err := r.db.Exec("UPDATE guilds SET master=?, jrMaster=?, member1=?, member2=?, member3=? WHERE id=?",
+		master, jrMaster, member1, member2, member3, guildID)
+	return err
+}
+
+func (r mysqlGuilds) SetNotice(guildID int32, notice string) error {
+	_, err := r.db.Exec("UPDATE guilds SET notice=? WHERE id=?", notice, guildID)
+	return err
+}
+
+func (r mysqlGuilds) SetEmblem(guildID int32, logoBg int16, logoBgColour byte, logo int16, logoColour byte) error {
+	_, err := r.db.Exec("UPDATE guilds SET logoBg=?,logoBgColour=?,logo=?,logoColour=? WHERE id=?",
+		logoBg, logoBgColour, logo, logoColour, guildID)
+	return err
+}
+
+func (r mysqlGuilds) NameTaken(name string, worldID int32) (bool, error) {
+	var count int
+	err := r.db.QueryRow("SELECT count(*) FROM guilds WHERE Name=? AND worldID=?", name, worldID).Scan(&count)
+	return count > 0, err
+}
+
+func (r mysqlGuilds) Invites(charID int32) ([]GuildInvite, error) {
+	rows, err := r.db.Query("SELECT guildID, inviter FROM guild_invites WHERE playerID=?", charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []GuildInvite{}
+	for rows.Next() {
+		invite := GuildInvite{CharacterID: charID}
+
+		if err := rows.Scan(&invite.GuildID, &invite.Inviter); err != nil {
+			return invites, err
+		}
+
+		invites = append(invites, invite)
+	}
+
+	return invites, rows.Err()
+}
+
+func (r mysqlGuilds) Invite(invite GuildInvite) error {
+	_, err := r.db.Exec("INSERT INTO guild_invites (playerID, guildID, inviter) VALUES (?, ?, ?)", invite.CharacterID, invite.GuildID, invite.Inviter)
+	return err
+}
+
+func (r mysqlGuilds) DeleteInvite(charID, guildID int32) error {
+	_, err := r.db.Exec("DELETE FROM guild_invites WHERE playerID=? AND guildID=?", charID, guildID)
+	return err
+}
+
+type mysqlBuddies struct {
+	db *sql.DB
+}
+
+func (r mysqlBuddies) ByCharacter(charID int32) ([]Buddy, error) {
+	rows, err := r.db.Query(`
+		SELECT b.friendID, b.accepted, c.Name, c.channelID, c.inCashShop
+		FROM buddy b INNER JOIN characters c ON c.ID = b.friendID
+		WHERE b.characterID=?`, charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buddies := []Buddy{}
+	for rows.Next() {
+		var b Buddy
+		if err := rows.Scan(&b.FriendID, &b.Accepted, &b.Name, &b.ChannelID, &b.InCashShop); err != nil {
+			return buddies, err
+		}
+		buddies = append(buddies, b)
+	}
+
+	return buddies, rows.Err()
+}
+
+func (r mysqlBuddies) CountAccepted(charID int32) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM buddy WHERE characterID=? AND accepted=1", charID).Scan(&count)
+	return count, err
+}
+
+func (r mysqlBuddies) Request(charID, friendID int32) error {
+	_, err := r.db.Exec("INSERT INTO buddy(characterID,friendID) VALUES(?,?)", charID, friendID)
+	return err
+}
+
+func (r mysqlBuddies) Accept(charID, friendID int32) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("UPDATE buddy SET accepted=1 WHERE characterID=? AND friendID=?", charID, friendID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("INSERT INTO buddy(characterID,friendID,accepted) VALUES(?,?,?)", friendID, charID, 1); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r mysqlBuddies) Delete(charID, friendID int32) error {
+	_, err := r.db.Exec("DELETE FROM buddy WHERE (characterID=? AND friendID=?) OR (characterID=? AND friendID=?)",
+		friendID, charID, charID, friendID)
+	return err
+}
+
+type mysqlBans struct {
+	db *sql.DB
+}
+
+func scanBan(reason string, banEnd, createdAt sql.NullString, ip, hwid sql.NullString) (Ban, error) {
+	b := Ban{Reason: reason, IP: ip.String, HWID: hwid.String}
+
+	if banEnd.Valid {
+		end, err := parseTimestamp(banEnd.String)
+		if err != nil {
+			return b, err
+		}
+		b.End = end
+	}
+
+	if createdAt.Valid {
+		created, err := parseTimestamp(createdAt.String)
+		if err != nil {
+			return b, err
+		}
+		b.CreatedAt = created
+	}
+
+	return b, nil
+}
+
+func (r mysqlBans) Create(b Ban) error {
+	var accountID, banEnd any
+	if b.AccountID != 0 {
+		accountID = b.AccountID
+	}
+	if !b.Permanent() {
+		banEnd = b.End
+	}
+
+	_, err := r.db.Exec(`INSERT INTO bans (accountID, reason, banEnd, ip, hwid) VALUES (?, ?, ?, ?, ?)`,
+		accountID, b.Reason, banEnd, b.IP, b.HWID)
+	return err
+}
+
+func (r mysqlBans) Active(accountID int32, ip, hwid string) (Ban, bool, error) {
+	var reason string
+	var banEnd, createdAt, banIP, banHWID sql.NullString
+
+	err := r.db.QueryRow(`
+SELECT reason, banEnd, createdAt, ip, hwid FROM bans
+WHERE (accountID = ? OR ip = ? OR (hwid = ? AND hwid != ''))
+AND (banEnd IS NULL OR banEnd > NOW())
+ORDER BY createdAt DESC
+LIMIT 1`, accountID, ip, hwid).Scan(&reason, &banEnd, &createdAt, &banIP, &banHWID)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Ban{}, false, nil
+	}
+
+	if err != nil {
+		return Ban{}, false, err
+	}
+
+	b, err := scanBan(reason, banEnd, createdAt, banIP, banHWID)
+	b.AccountID = accountID
+
+	return b, true, err
+}
+
+func (r mysqlBans) ByAccount(accountID int32, limit int) ([]Ban, error) {
+	rows, err := r.db.Query(`
+SELECT reason, banEnd, createdAt, ip, hwid FROM bans
+WHERE accountID = ?
+ORDER BY createdAt DESC
+LIMIT ?`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bans := []Ban{}
+	for rows.Next() {
+		var reason string
+		var banEnd, createdAt, ip, hwid sql.NullString
+
+		if err := rows.Scan(&reason, &banEnd, &createdAt, &ip, &hwid); err != nil {
+			continue
+		}
+
+		b, err := scanBan(reason, banEnd, createdAt, ip, hwid)
+		if err != nil {
+			continue
+		}
+
+		b.AccountID = accountID
+		bans = append(bans, b)
+	}
+
+	return bans, rows.Err()
+}
+
+func (r mysqlBans) DeleteByAccount(accountID int32) error {
+	_, err := r.db.Exec(`DELETE FROM bans WHERE accountID = ?`, accountID)
+	return err
+}
+
+func (r mysqlBans) IncrementEscalation(accountID int32) (int, error) {
+	_, err := r.db.Exec(`
+		INSERT INTO ban_escalation (accountID, count) VALUES (?, 1)
+		ON DUPLICATE KEY UPDATE count = count + 1`, accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = r.db.QueryRow(`SELECT count FROM ban_escalation WHERE accountID = ?`, accountID).Scan(&count)
+	return count, err
+}
+
+type mysqlMutes struct {
+	db *sql.DB
+}
+
+const muteColumns = "id, accountID, reason, issuedBy, muteEnd, liftedBy, liftedAt, createdAt"
+
+func (r mysqlMutes) scan(rows interface{ Scan(...any) error }) (Mute, error) {
+	var m Mute
+	var createdAt string
+	var muteEnd, liftedBy, liftedAt sql.NullString
+
+	err := rows.Scan(&m.ID, &m.AccountID, &m.Reason, &m.IssuedBy, &muteEnd, &liftedBy, &liftedAt, &createdAt)
+	if err != nil {
+		return m, err
+	}
+
+	m.LiftedBy = liftedBy.String
+
+	if muteEnd.Valid {
+		if m.End, err = parseTimestamp(muteEnd.String); err != nil {
+			return m, err
+		}
+	}
+
+	if liftedAt.Valid {
+		if m.LiftedAt, err = parseTimestamp(liftedAt.String); err != nil {
+			return m, err
+		}
+	}
+
+	m.CreatedAt, err = parseTimestamp(createdAt)
+
+	return m, err
+}
+
+func (r mysqlMutes) Create(m *Mute) error {
+	var muteEnd any
+	if !m.Permanent() {
+		muteEnd = m.End
+	}
+
+	m.CreatedAt = time.Now()
+
+	res, err := r.db.Exec(`INSERT INTO mutes (accountID, reason, issuedBy, muteEnd, createdAt) VALUES (?, ?, ?, ?, ?)`,
+		m.AccountID, m.Reason, m.IssuedBy, muteEnd, m.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	m.ID = int32(id)
+
+	return nil
+}
+
+func (r mysqlMutes) Active(accountID int32) (Mute, bool, error) {
+	m, err := r.scan(r.db.QueryRow(`
+SELECT `+muteColumns+` FROM mutes
+WHERE accountID = ? AND liftedAt IS NULL
+AND (muteEnd IS NULL OR muteEnd > NOW())
+ORDER BY muteEnd IS NULL DESC, muteEnd DESC
+LIMIT 1`, accountID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Mute{}, false, nil
+	}
+
+	if err != nil {
+		return Mute{}, false, err
+	}
+
+	return m, true, nil
+}
+
+func (r mysqlMutes) ByAccount(accountID int32, limit int) ([]Mute, error) {
+	rows, err := r.db.Query(`SELECT `+muteColumns+` FROM mutes WHERE accountID = ? ORDER BY id DESC LIMIT ?`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mutes := []Mute{}
+	for rows.Next() {
+		m, err := r.scan(rows)
+		if err != nil {
+			return mutes, err
+		}
+		mutes = append(mutes, m)
+	}
+
+	return mutes, rows.Err()
+}
+
+func (r mysqlMutes) Lift(accountID int32, by string) error {
+	res, err := r.db.Exec(`
+UPDATE mutes SET liftedBy = ?, liftedAt = NOW()
+WHERE accountID = ? AND liftedAt IS NULL
+AND (muteEnd IS NULL OR muteEnd > NOW())`, by, accountID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type mysqlJails struct {
+	db *sql.DB
+}
+
+const jailColumns = "id, accountID, reason, issuedBy, jailEnd, releasedBy, releasedAt, createdAt"
+
+func (r mysqlJails) scan(rows interface{ Scan(...any) error }) (Jail, error) {
+	var j Jail
+	var jailEnd, createdAt string
+	var releasedBy, releasedAt sql.NullString
+
+	err := rows.Scan(&j.ID, &j.AccountID, &j.Reason, &j.IssuedBy, &jailEnd, &releasedBy, &releasedAt, &createdAt)
+	if err != nil {
+		return j, err
+	}
+
+	j.ReleasedBy = releasedBy.String
+
+	if j.End, err = parseTimestamp(jailEnd); err != nil {
+		return j, err
+	}
+
+	if releasedAt.Valid {
+		if j.ReleasedAt, err = parseTimestamp(releasedAt.String); err != nil {
+			return j, err
+		}
+	}
+
+	j.CreatedAt, err = parseTimestamp(createdAt)
+
+	return j, err
+}
+
+func (r mysqlJails) Create(j *Jail) error {
+	j.CreatedAt = time.Now()
+
+	res, err := r.db.Exec(`INSERT INTO jails (accountID, reason, issuedBy, jailEnd, createdAt) VALUES (?, ?, ?, ?, ?)`,
+		j.AccountID, j.Reason, j.IssuedBy, j.End, j.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	j.ID = int32(id)
+
+	return nil
+}
+
+func (r mysqlJails) Active(accountID int32) (Jail, bool, error) {
+	j, err := r.scan(r.db.QueryRow(`
+SELECT `+jailColumns+` FROM jails
+WHERE accountID = ? AND releasedAt IS NULL AND jailEnd > NOW()
+ORDER BY jailEnd DESC
+LIMIT 1`, accountID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Jail{}, false, nil
+	}
+
+	if err != nil {
+		return Jail{}, false, err
+	}
+
+	return j, true, nil
+}
+
+func (r mysqlJails) ByAccount(accountID int32, limit int) ([]Jail, error) {
+	rows, err := r.db.Query(`SELECT `+jailColumns+` FROM jails WHERE accountID = ? ORDER BY id DESC LIMIT ?`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jails := []Jail{}
+	for rows.Next() {
+		j, err := r.scan(rows)
+		if err != nil {
+			return jails, err
+		}
+		jails = append(jails, j)
+	}
+
+	return jails, rows.Err()
+}
+
+func (r mysqlJails) Release(accountID int32, by string) error {
+	res, err := r.db.Exec(`
+UPDATE jails SET releasedBy = ?, releasedAt = NOW()
+WHERE accountID = ? AND releasedAt IS NULL AND jailEnd > NOW()`, by, accountID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+type mysqlViolations struct {
+	db *sql.DB
+}
+
+func (r mysqlViolations) Create(v *Violation) error {
+	v.CreatedAt = time.Now()
+
+	res, err := r.db.Exec(`
+		INSERT INTO violations(accountID, characterID, characterName, channelID, mapID, type, detail, createdAt)
+		VALUES (?,?,?,?,?,?,?,?)`,
+		v.AccountID, v.CharacterID, v.CharacterName, v.ChannelID, v.MapID, v.Type, v.Detail, v.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	v.ID, err = res.LastInsertId()
+
+	return err
+}
+
+func (r mysqlViolations) Insert(vs []Violation) error {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	query := "INSERT INTO violations(accountID, characterID, characterName, channelID, mapID, type, detail, createdAt) VALUES " +
+		strings.TrimSuffix(strings.Repeat("(?,?,?,?,?,?,?,?),", len(vs)), ",")
+
+	args := make([]any, 0, len(vs)*8)
+	for _, v := range vs {
+		args = append(args, v.AccountID, v.CharacterID, v.CharacterName, v.ChannelID, v.MapID, v.Type, v.Detail, v.CreatedAt)
+	}
+
+	_, err := r.db.Exec(query, args...)
+	return err
+}
+
+func (r mysqlViolations) ByAccount(accountID int32, limit int) ([]Violation, error) {
+	rows, err := r.db.Query(`
+		SELECT id, accountID, characterID, characterName, channelID, mapID, type, detail, createdAt
+		FROM violations WHERE accountID=? ORDER BY id DESC LIMIT ?`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violations := []Violation{}
+	for rows.Next() {
+		var v Violation
+		var createdAt string
+
+		err := rows.Scan(&v.ID, &v.AccountID, &v.CharacterID, &v.CharacterName, &v.ChannelID, &v.MapID, &v.Type,
+			&v.Detail, &createdAt)
+		if err != nil {
+			return violations, err
+		}
+
+		if v.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+			return violations, err
+		}
+
+		violations = append(violations, v)
+	}
+
+	return violations, rows.Err()
+}
+
+func (r mysqlViolations) TopOffenders(channelID byte, since time.Time, limit int) ([]Offender, error) {
+	rows, err := r.db.Query(`
+		SELECT t.accountID, v.characterName, t.total, v.createdAt FROM (
+			SELECT accountID, COUNT(*) AS total, MAX(id) AS lastID FROM violations
+			WHERE channelID=? AND createdAt >= ? GROUP BY accountID
+		) t JOIN violations v ON v.id = t.lastID
+		ORDER BY t.total DESC, t.lastID DESC LIMIT ?`, channelID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	offenders := []Offender{}
+	for rows.Next() {
+		var o Offender
+		var lastAt string
+
+		if err := rows.Scan(&o.AccountID, &o.CharacterName, &o.Count, &lastAt); err != nil {
+			return offenders, err
+		}
+
+		if o.LastAt, err = parseTimestamp(lastAt); err != nil {
+			return offenders, err
+		}
+
+		offenders = append(offenders, o)
+	}
+
+	return offenders, rows.Err()
+}
+
+type mysqlStorage struct {
+	db *sql.DB
+}
+
+func (r mysqlStorage) Load(accountID int32) (StorageContents, error) {
+	var contents StorageContents
+
+	if err := r.db.QueryRow("SELECT slots, mesos FROM account_storage WHERE accountID=?", accountID).
+		Scan(&contents.Slots, &contents.Mesos); err != nil {
+		return contents, notFound(err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT
+			id, itemID, inventoryID, slotNumber, amount,
+			flag, upgradeSlots, level, str, dex, intt, luk, hp, mp,
+			watk, matk, wdef, mdef, accuracy, avoid, hands, speed, jump,
+			expireTime, creatorName
+		FROM account_storage_items
+		WHERE accountID=?
+		ORDER BY slotNumber ASC`, accountID)
+	if err != nil {
+		return contents, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var it Item
+		var creator sql.NullString
+
+		if err := rows.Scan(
+			&it.ID, &it.ItemID, &it.InventoryID, &it.Slot, &it.Amount,
+			&it.Flag, &it.UpgradeSlots, &it.Level, &it.Str, &it.Dex, &it.Intt, &it.Luk, &it.HP, &it.MP,
+			&it.Watk, &it.Matk, &it.Wdef, &it.Mdef, &it.Accuracy, &it.Avoid, &it.Hands, &it.Speed, &it.Jump,
+			&it.ExpireTime, &creator,
+		); err != nil {
+			continue
+		}
+
+		it.CreatorName = creator.String
+		contents.Items = append(contents.Items, it)
+	}
+
+	return contents, rows.Err()
+}
+
+func (r mysqlStorage) Create(accountID int32, slots byte) error {
+	_, err := r.db.Exec("INSERT INTO account_storage(accountID, slots, mesos) VALUES(?,?,?)", accountID, slots, 0)
+	return err
+}
+
+func (r mysqlStorage) Save(accountID int32, contents StorageContents) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("couldn't open transaction to save storage (acct %d): %w", accountID, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("UPDATE account_storage SET slots=?, mesos=? WHERE accountID=?",
+		contents.Slots, contents.Mesos, accountID); err != nil {
+		return fmt.Errorf("failed to update storage header (acct %d): %w", accountID, err)
+	}
+
+	if _, err = tx.Exec("DELETE FROM account_storage_items WHERE accountID=?", accountID); err != nil {
+		return fmt.Errorf("failed to clear storage items (acct %d): %w", accountID, err)
+	}
+
+	const ins = `
+		INSERT INTO account_storage_items(
+			accountID, itemID, inventoryID, slotNumber, amount, flag, upgradeSlots, level,
+			str, dex, intt, luk, hp, mp, watk, matk, wdef, mdef, accuracy, avoid, hands,
+			speed, jump, expireTime, creatorName
+		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+	`
+	stmt, err := tx.Prepare(ins)
+	if err != nil {
+		return fmt.Errorf("failed to prepare item insert (acct %d): %w", accountID, err)
+	}
+	defer stmt.Close()
+
+	for _, it := range contents.Items {
+		if _, err = stmt.Exec(
+			accountID, it.ItemID, it.InventoryID, it.Slot, it.Amount, it.Flag, it.UpgradeSlots, it.Level,
+			it.Str, it.Dex, it.Intt, it.Luk, it.HP, it.MP, it.Watk, it.Matk, it.Wdef, it.Mdef, it.Accuracy, it.Avoid, it.Hands,
+			it.Speed, it.Jump, it.ExpireTime, it.CreatorName,
+		); err != nil {
+			return fmt.Errorf("failed inserting item %d (acct %d, slot %d): %w", it.ItemID, accountID, it.Slot, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit storage save (acct %d): %w", accountID, err)
+	}
+
+	return nil
+}
+
+func (r mysqlStorage) LoadCashShop(accountID int32) (StorageContents, error) {
+	var contents StorageContents
+
+	if err := r.db.QueryRow("SELECT slots FROM account_cashshop_storage WHERE accountID=?", accountID).
+		Scan(&contents.Slots); err != nil {
+		return contents, notFound(err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT
+			itemID, cashID, sn, slotNumber, amount,
+			flag, upgradeSlots, level, str, dex, intt, luk, hp, mp,
+			watk, matk, wdef, mdef, accuracy, avoid, hands, speed, jump,
+			expireTime, creatorName
+		FROM account_cashshop_storage_items
+		WHERE accountID=?
+		ORDER BY slotNumber ASC`, accountID)
+	if err != nil {
+		return contents, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var it Item
+		var cashID sql.NullInt64
+		var creator sql.NullString
+
+		if err := rows.Scan(
+			&it.ItemID, &cashID, &it.CashSN, &it.Slot, &it.Amount,
+			&it.Flag, &it.UpgradeSlots, &it.Level, &it.Str, &it.Dex, &it.Intt, &it.Luk, &it.HP, &it.MP,
+			&it.Watk, &it.Matk, &it.Wdef, &it.Mdef, &it.Accuracy, &it.Avoid, &it.Hands, &it.Speed, &it.Jump,
+			&it.ExpireTime, &creator,
+		); err != nil {
+			continue
+		}
+
+		it.CashID = cashID.Int64
+		it.CreatorName = creator.String
+		contents.Items = append(contents.Items, it)
+	}
+
+	return contents, rows.Err()
+}
+
+func (r mysqlStorage) CreateCashShop(accountID int32, slots byte) error {
+	_, err := r.db.Exec("INSERT INTO account_cashshop_storage(accountID, slots) VALUES(?,?)", accountID, slots)
+	return err
+}
+
+func (r mysqlStorage) SaveCashShop(accountID int32, contents StorageContents) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("couldn't open transaction to save cash shop storage (acct %d): %w", accountID, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("UPDATE account_cashshop_storage SET slots=? WHERE accountID=?", contents.Slots, accountID); err != nil {
+		return fmt.Errorf("failed to update cash shop storage header (acct %d): %w", accountID, err)
+	}
+
+	if _, err = tx.Exec("DELETE FROM account_cashshop_storage_items WHERE accountID=?", accountID); err != nil {
+		return fmt.Errorf("failed to clear cash shop storage items (acct %d): %w", accountID, err)
+	}
+
+	const ins = `
+		INSERT INTO account_cashshop_storage_items(
+			accountID, itemID, cashID, sn, slotNumber, amount, flag, upgradeSlots, level,
+			str, dex, intt, luk, hp, mp, watk, matk, wdef, mdef, accuracy, avoid, hands,
+			speed, jump, expireTime, creatorName
+		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+
+	for _, it := range contents.Items {
+		if _, err = tx.Exec(ins,
+			accountID, it.ItemID, it.CashID, it.CashSN, it.Slot, it.Amount,
+			it.Flag, it.UpgradeSlots, it.Level,
+			it.Str, it.Dex, it.Intt, it.Luk,
+			it.HP, it.MP, it.Watk, it.Matk,
+			it.Wdef, it.Mdef, it.Accuracy, it.Avoid,
+			it.Hands, it.Speed, it.Jump,
+			it.ExpireTime, it.CreatorName,
+		); err != nil {
+			return fmt.Errorf("failed inserting cash shop item (acct %d, slot %d): %w", accountID, it.Slot, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cash shop storage save (acct %d): %w", accountID, err)
+	}
+
+	return nil
+}
+
+type mysqlGifts struct {
+	db *sql.DB
+}
+
+func (r mysqlGifts) Create(g Gift) error {
+	_, err := r.db.Exec("INSERT INTO account_cashshop_gifts(accountID, cashID, itemID, sender, message) VALUES(?,?,?,?,?)",
+		g.AccountID, g.CashID, g.ItemID, g.Sender, g.Message)
+	return err
+}
+
+func (r mysqlGifts) ByAccount(accountID int32) ([]Gift, error) {
+	rows, err := r.db.Query("SELECT id, cashID, itemID, sender, message FROM account_cashshop_gifts WHERE accountID=? ORDER BY id ASC", accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gifts := []Gift{}
+	for rows.Next() {
+		g := Gift{AccountID: accountID}
+		if err := rows.Scan(&g.ID, &g.CashID, &g.ItemID, &g.Sender, &g.Message); err != nil {
+			return gifts, err
+		}
+		gifts = append(gifts, g)
+	}
+
+	return gifts, rows.Err()
+}
+
+func (r mysqlGifts) Delete(giftID int64) error {
+	_, err := r.db.Exec("DELETE FROM account_cashshop_gifts WHERE id=?", giftID)
+	return err
+}
+
+type mysqlWishlists struct {
+	db *sql.DB
+}
+
+func (r mysqlWishlists) ByCharacter(charID int32) ([]int32, error) {
+	rows, err := r.db.Query("SELECT sn FROM character_wishlist WHERE characterID=? ORDER BY slot ASC", charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sns := []int32{}
+	for rows.Next() {
+		var sn int32
+		if err := rows.Scan(&sn); err != nil {
+			return sns, err
+		}
+		sns = append(sns, sn)
+	}
+
+	return sns, rows.Err()
+}
+
+func (r mysqlWishlists) Save(charID int32, sns []int32) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("DELETE FROM character_wishlist WHERE characterID=?", charID); err != nil {
+		return err
+	}
+
+	slot := 0
+	for _, sn := range sns {
+		if sn == 0 {
+			continue
+		}
+
+		if _, err = tx.Exec("INSERT INTO character_wishlist(characterID, slot, sn) VALUES(?,?,?)", charID, slot, sn); err != nil {
+			return err
+		}
+		slot++
+	}
+
+	return tx.Commit()
+}
+
+type mysqlCoupons struct {
+	db *sql.DB
+}
+
+func (r mysqlCoupons) Create(c Coupon) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var exists int
+	err = tx.QueryRow("SELECT COUNT(*) FROM coupons WHERE code=? FOR UPDATE", c.Code).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists > 0 {
+		return ErrDuplicate
+	}
+
+	if _, err = tx.Exec("INSERT INTO coupons(code, nx, maplePoints, mesos, maxUses, expiresAt, createdBy) VALUES(?,?,?,?,?,?,?)",
+		c.Code, c.NX, c.MaplePoints, c.Mesos, c.MaxUses, c.ExpiresAt, c.CreatedBy); err != nil {
+		return err
+	}
+
+	for _, item := range c.Items {
+		if _, err = tx.Exec("INSERT INTO coupon_items(code, itemID, amount) VALUES(?,?,?)", c.Code, item.ItemID, item.Amount); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r mysqlCoupons) ByCode(code string) (Coupon, error) {
+	c := Coupon{}
+	err := r.db.QueryRow("SELECT code, nx, maplePoints, mesos, maxUses, uses, expiresAt, createdBy FROM coupons WHERE code=?", code).
+		Scan(&c.Code, &c.NX, &c.MaplePoints, &c.Mesos, &c.MaxUses, &c.Uses, &c.ExpiresAt, &c.CreatedBy)
+	if err != nil {
+		return c, notFound(err)
+	}
+
+	rows, err := r.db.Query("SELECT itemID, amount FROM coupon_items WHERE code=? ORDER BY id ASC", code)
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+
+	c.Items = []CouponItem{}
+	for rows.Next() {
+		var item CouponItem
+		if err := rows.Scan(&item.ItemID, &item.Amount); err != nil {
+			return c, err
+		}
+		c.Items = append(c.Items, item)
+	}
+
+	return c, rows.Err()
+}
+
+func (r mysqlCoupons) Redeem(code string, accountID, charID int32) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var c Coupon
+	if err = tx.QueryRow("SELECT maxUses, uses, expiresAt FROM coupons WHERE code=? FOR UPDATE", code).Scan(&c.MaxUses, &c.Uses, &c.ExpiresAt); err != nil {
+		return notFound(err)
+	}
+
+	if c.Expired(time.Now().UnixMilli()) {
+		return ErrExpired
+	}
+
+	var redeemed int
+	err = tx.QueryRow("SELECT COUNT(*) FROM coupon_redemptions WHERE code=? AND accountID=?", code, accountID).Scan(&redeemed)
+	if err != nil {
+		return err
+	}
+
+	if redeemed > 0 {
+		return ErrDuplicate
+	}
+
+	if c.MaxUses > 0 && c.Uses >= c.MaxUses {
+		return ErrExhausted
+	}
+
+	if _, err = tx.Exec("INSERT INTO coupon_redemptions(code, accountID, characterID) VALUES(?,?,?)", code, accountID, charID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("UPDATE coupons SET uses=uses+1 WHERE code=?", code); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type mysqlMerchants struct {
+	db *sql.DB
+}
+
+const merchantColumns = "id, characterID, channelID, mapID, x, y, foothold, title, mesos, closed, expiresAt"
+
+func (r mysqlMerchants) scan(rows interface{ Scan(...any) error }) (Merchant, error) {
+	var m Merchant
+	err := rows.Scan(&m.ID, &m.CharacterID, &m.ChannelID, &m.MapID, &m.X, &m.Y, &m.Foothold, &m.Title, &m.Mesos,
+		&m.Closed, &m.ExpiresAt)
+	return m, err
+}
+
+func (r mysqlMerchants) items(merchantID int32) ([]MerchantItem, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			bundles, price, itemID, inventoryID, amount,
+			flag, upgradeSlots, level, str, dex, intt, luk, hp, mp,
+			watk, matk, wdef, mdef, accuracy, avoid, hands, speed, jump,
+			expireTime, creatorName, cashID, cashSN
+		FROM hired_merchant_items
+		WHERE merchantID=?
+		ORDER BY position ASC`, merchantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []MerchantItem{}
+	for rows.Next() {
+		var mi MerchantItem
+		var creator sql.NullString
+		var cashID sql.NullInt64
+		var cashSN sql.NullInt32
+
+		it := &mi.Item
+		if err := rows.Scan(
+			&mi.Bundles, &mi.Price, &it.ItemID, &it.InventoryID, &it.Amount,
+			&it.Flag, &it.UpgradeSlots, &it.Level, &it.Str, &it.Dex, &it.Intt, &it.Luk, &it.HP, &it.MP,
+			&it.Watk, &it.Matk, &it.Wdef, &it.Mdef, &it.Accuracy, &it.Avoid, &it.Hands, &it.Speed, &it.Jump,
+			&it.ExpireTime, &creator, &cashID, &cashSN,
+		); err != nil {
+			return items, err
+		}
+
+		it.CreatorName = creator.String
+		it.CashID = cashID.Int64
+		it.CashSN = cashSN.Int32
+		items = append(items, mi)
+	}
+
+	return items, rows.Err()
+}
+
+func (r mysqlMerchants) Create(m *Merchant) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var exists int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM hired_merchants WHERE characterID=? FOR UPDATE", m.CharacterID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists > 0 {
+		return ErrDuplicate
+	}
+
+	res, err := tx.Exec("INSERT INTO hired_merchants(characterID, channelID, mapID, x, y, foothold, title, mesos, closed, expiresAt) VALUES(?,?,?,?,?,?,?,?,?,?)",
+		m.CharacterID, m.ChannelID, m.MapID, m.X, m.Y, m.Foothold, m.Title, m.Mesos, m.Closed, m.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	m.ID = int32(id)
+
+	return nil
+}
+
+func (r mysqlMerchants) ByCharacter(charID int32) (Merchant, error) {
+	m, err := r.scan(r.db.QueryRow("SELECT "+merchantColumns+" FROM hired_merchants WHERE characterID=?", charID))
+	if err != nil {
+		return m, notFound(err)
+	}
+
+	m.Items, err = r.items(m.ID)
+
+	return m, err
+}
+
+func (r mysqlMerchants) Trading(channelID byte) ([]Merchant, error) {
+	rows, err := r.db.Query("SELECT "+merchantColumns+" FROM hired_merchants WHERE channelID=? AND closed=0", channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	merchants := []Merchant{}
+	for rows.Next() {
+		m, err := r.scan(rows)
+		if err != nil {
+			rows.Close()
+			return merchants, err
+		}
+		merchants = append(merchants, m)
+	}
+
+	if err := rows.Close(); err != nil {
+		return merchants, err
+	}
+
+	for i := range merchants {
+		if merchants[i].Items, err = r.items(merchants[i].ID); err != nil {
+			return merchants, err
+		}
+	}
+
+	return merchants, nil
+}
+
+func (r mysqlMerchants) Save(m Merchant) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("UPDATE hired_merchants SET title=?, mesos=?, closed=? WHERE id=?", m.Title, m.Mesos, m.Closed, m.ID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM hired_merchant_items WHERE merchantID=?", m.ID); err != nil {
+		return err
+	}
+
+	const ins = `
+		INSERT INTO hired_merchant_items(
+			merchantID, position, bundles, price, itemID, inventoryID, amount, flag, upgradeSlots, level,
+			str, dex, intt, luk, hp, mp, watk, matk, wdef, mdef, accuracy, avoid, hands,
+			speed, jump, expireTime, creatorName, cashID, cashSN
+		) VALUES (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+	`
+
+	for i, mi := range m.Items {
+		it := mi.Item
+		if _, err = tx.Exec(ins,
+			m.ID, i, mi.Bundles, mi.Price, it.ItemID, it.InventoryID, it.Amount, it.Flag, it.UpgradeSlots, it.Level,
+			it.Str, it.Dex, it.Intt, it.Luk, it.HP, it.MP, it.Watk, it.Matk, it.Wdef, it.Mdef, it.Accuracy, it.Avoid, it.Hands,
+			it.Speed, it.Jump, it.ExpireTime, it.CreatorName, it.CashID, it.CashSN,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r mysqlMerchants) Delete(merchantID int32) error {
+	_, err := r.db.Exec("DELETE FROM hired_merchants WHERE id=?", merchantID)
+	return err
+}
+
+type mysqlReports struct {
+	db *sql.DB
+}
+
+const reportColumns = "id, reporterID, reporterAccountID, reporterName, targetID, targetAccountID, targetName, reason, description, mapID, chatLog, status, claimedBy, resolution, createdAt"
+
+func (r mysqlReports) scan(rows interface{ Scan(...any) error }) (Report, error) {
+	var rep Report
+	var createdAt string
+
+	err := rows.Scan(&rep.ID, &rep.ReporterID, &rep.ReporterAccountID, &rep.ReporterName, &rep.TargetID,
+		&rep.TargetAccountID, &rep.TargetName, &rep.Reason, &rep.Description, &rep.MapID, &rep.ChatLog, &rep.Status,
+		&rep.ClaimedBy, &rep.Resolution, &createdAt)
+	if err != nil {
+		return rep, err
+	}
+
+	rep.CreatedAt, err = parseTimestamp(createdAt)
+
+	return rep, err
+}
+
+func (r mysqlReports) Create(rep *Report) error {
+	rep.CreatedAt = time.Now()
+
+	res, err := r.db.Exec(`
+		INSERT INTO reports(
+			reporterID, reporterAccountID, reporterName, targetID, targetAccountID, targetName,
+			reason, description, mapID, chatLog, createdAt
+		) VALUES (?,?,?,?,?,?,?,?,?,?,?)`,
+		rep.ReporterID, rep.ReporterAccountID, rep.ReporterName, rep.TargetID, rep.TargetAccountID, rep.TargetName,
+		rep.Reason, rep.Description, rep.MapID, rep.ChatLog, rep.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	rep.ID = int32(id)
+	rep.Status = ReportOpen
+
+	return nil
+}
+
+func (r mysqlReports) ByID(reportID int32) (Report, error) {
+	rep, err := r.scan(r.db.QueryRow("SELECT "+reportColumns+" FROM reports WHERE id=?", reportID))
+	return rep, notFound(err)
+}
+
+func (r mysqlReports) Unresolved(limit int) ([]Report, error) {
+	rows, err := r.db.Query("SELECT "+reportColumns+" FROM reports WHERE status != ? ORDER BY id ASC LIMIT ?", ReportResolved, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		rep, err := r.scan(rows)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, rep)
+	}
+
+	return reports, rows.Err()
+}
+
+// update moves an unresolved report to status, provided it is unclaimed or already claimed by gm. The check and the
+// write are a single conditional UPDATE so two GMs acting on the same report cannot both succeed.
+func (r mysqlReports) update(reportID int32, gm string, status byte, resolution string) error {
+	res, err := r.db.Exec(`
+UPDATE reports SET status = ?, claimedBy = ?, resolution = ?
+WHERE id = ? AND status != ? AND (status != ? OR claimedBy = ?)`,
+		status, gm, resolution, reportID, ReportResolved, ReportClaimed, gm)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		return nil
+	}
+
+	// Nothing changed, the driver counts changed rows rather than matched ones so a GM claiming a report they
+	// already hold lands here too
+	var current byte
+	var claimedBy string
+	if err := r.db.QueryRow("SELECT status, claimedBy FROM reports WHERE id=?", reportID).Scan(&current, &claimedBy); err != nil {
+		return notFound(err)
+	}
+
+	switch {
+	case current == ReportResolved:
+		return ErrNotFound
+	case current == ReportClaimed && claimedBy == gm:
+		return nil
+	}
+
+	return ErrDuplicate
+}
+
+func (r mysqlReports) Claim(reportID int32, gm string) error {
+	return r.update(reportID, gm, ReportClaimed, "")
+}
+
+func (r mysqlReports) Resolve(reportID int32, gm, resolution string) error {
+	return r.update(reportID, gm, ReportResolved, resolution)
+}
+
+func (r mysqlReports) CountSince(accountID int32, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM reports WHERE reporterAccountID=? AND createdAt > ?", accountID, since).Scan(&count)
+	return count, err
+}
+
+type mysqlChatLogs struct {
+	db *sql.DB
+}
+
+func (r mysqlChatLogs) Insert(lines []ChatLog) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	query := "INSERT INTO chat_logs(kind, channelID, mapID, senderID, sender, target, message, createdAt) VALUES " +
+		strings.TrimSuffix(strings.Repeat("(?,?,?,?,?,?,?,?),", len(lines)), ",")
+
+	args := make([]any, 0, len(lines)*8)
+	for _, l := range lines {
+		args = append(args, l.Kind, l.ChannelID, l.MapID, l.SenderID, l.Sender, l.Target, l.Message, l.CreatedAt)
+	}
+
+	_, err := r.db.Exec(query, args...)
+	return err
+}
+
+func (r mysqlChatLogs) Recent(name string, limit int) ([]ChatLog, error) {
+	rows, err := r.db.Query(`
+		SELECT id, kind, channelID, mapID, senderID, sender, target, message, createdAt
+		FROM chat_logs
+		WHERE sender=? OR target=?
+		ORDER BY id DESC
+		LIMIT ?`, name, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []ChatLog{}
+	for rows.Next() {
+		var l ChatLog
+		var createdAt string
+
+		if err := rows.Scan(&l.ID, &l.Kind, &l.ChannelID, &l.MapID, &l.SenderID, &l.Sender, &l.Target, &l.Message, &createdAt); err != nil {
+			return lines, err
+		}
+
+		if l.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+			return lines, err
+		}
+
+		lines = append(lines, l)
+	}
+
+	return lines, rows.Err()
+}
+
+type mysqlRaidEntries struct {
+	db *sql.DB
+}
+
+func (r mysqlRaidEntries) Add(characterID int32, raid string) error {
+	_, err := r.db.Exec("INSERT INTO raid_entries(characterID, raid, createdAt) VALUES(?, ?, ?)", characterID, raid, time.Now())
+	return err
+}
+
+func (r mysqlRaidEntries) CountSince(characterID int32, raid string, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM raid_entries WHERE characterID=? AND raid=? AND createdAt > ?", characterID, raid, since).Scan(&count)
+	return count, err
+}
+
+type mysqlBuffs struct {
+	db *sql.DB
+}
+
+func (r mysqlBuffs) ByCharacter(charID int32) ([]Buff, error) {
+	rows, err := r.db.Query("SELECT sourceID, level, expiresAtMs FROM character_buffs WHERE characterID=?", charID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buffs := []Buff{}
+	for rows.Next() {
+		var b Buff
+
+		if err := rows.Scan(&b.SourceID, &b.Level, &b.ExpiresAtMs); err != nil {
+			return buffs, err
+		}
+
+		buffs = append(buffs, b)
+	}
+
+	return buffs, rows.Err()
+}
+
+func (r mysqlBuffs) Save(charID int32, buffs []Buff) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("DELETE FROM character_buffs WHERE characterID=?", charID); err != nil {
+		return err
+	}
+
+	for _, b := range buffs {
+		if _, err = tx.Exec("INSERT INTO character_buffs(characterID, sourceID, level, expiresAtMs) VALUES(?,?,?,?)",
+			charID, b.SourceID, b.Level, b.ExpiresAtMs); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r mysqlBuffs) Delete(charID int32, sourceIDs ...int32) error {
+	if len(sourceIDs) == 0 {
+		_, err := r.db.Exec("DELETE FROM character_buffs WHERE characterID=?", charID)
+		return err
+	}
+
+	args := make([]any, 0, len(sourceIDs)+1)
+	args = append(args, charID)
+	for _, id := range sourceIDs {
+		args = append(args, id)
+	}
+
+	query := "DELETE FROM character_buffs WHERE characterID=? AND sourceID IN (?" + strings.Repeat(",?", len(sourceIDs)-1) + ")"
+	_, err := r.db.Exec(query, args...)
+
+	return err
+}
+
+type mysqlFame struct {
+	db *sql.DB
+}
+
+func (r mysqlFame) Add(fromID, toID int32) error {
+	_, err := r.db.Exec("INSERT INTO fame_log (`from`, `to`, `time`) VALUES (?,?, NOW())", fromID, toID)
+	return err
+}
+
+func (r mysqlFame) GivenWithin(fromID int32, window time.Duration) (bool, error) {
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM `fame_log` WHERE `from`=? AND `time` > (NOW() - INTERVAL ? SECOND)",
+		fromID, int64(window.Seconds())).Scan(&count)
+	return count > 0, err
+}
+
+type mysqlLieDetectors struct {
+	db *sql.DB
+}
+
+func (r mysqlLieDetectors) Pend(characterID, reporterID int32) error {
+	_, err := r.db.Exec(`INSERT INTO lie_detector_pending (characterID, reporterID) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE reporterID = VALUES(reporterID), createdAt = CURRENT_TIMESTAMP`, characterID, reporterID)
+	return err
+}
+
+func (r mysqlLieDetectors) Take(characterID int32) (int32, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var reporterID int32
+	err = tx.QueryRow(`SELECT reporterID FROM lie_detector_pending WHERE characterID = ? FOR UPDATE`, characterID).Scan(&reporterID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM lie_detector_pending WHERE characterID = ?`, characterID); err != nil {
+		return 0, false, err
+	}
+
+	return reporterID, true, tx.Commit()
+}
+
+func (r mysqlLieDetectors) Reward(characterID, mesos int32) error {
+	_, err := r.db.Exec(`INSERT INTO lie_detector_rewards (characterID, mesos) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE mesos = mesos + VALUES(mesos)`, characterID, mesos)
+	return err
+}
+
+func (r mysqlLieDetectors) TakeReward(characterID int32) (int32, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var mesos int32
+	err = tx.QueryRow(`SELECT mesos FROM lie_detector_rewards WHERE characterID = ? FOR UPDATE`, characterID).Scan(&mesos)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM lie_detector_rewards WHERE characterID = ?`, characterID); err != nil {
+		return 0, err
+	}
+
+	return mesos, tx.Commit()
+}
+
+type mysqlPets struct {
+	db *sql.DB
+}
+
+func (r mysqlPets) ByItem(itemID int64) (Pet, error) {
+	p := Pet{ItemID: itemID}
+	err := r.db.QueryRow(`SELECT name, sn, level, closeness, fullness, deadDate, spawnDate, lastInteraction
+		FROM pets WHERE parentID=?`, itemID).Scan(&p.Name, &p.SN, &p.Level, &p.Closeness, &p.Fullness, &p.DeadDate,
+		&p.SpawnDate, &p.LastInteraction)
+	return p, notFound(err)
+}
+
+func (r mysqlPets) Save(p Pet) error {
+	_, err := r.db.Exec(`
+		INSERT INTO pets (
+			parentID, name, sn, level, closeness, fullness,
+			deadDate, spawnDate, lastInteraction
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			name = VALUES(name),
+			level = VALUES(level),
+			closeness = VALUES(closeness),
+			fullness = VALUES(fullness),
+			deadDate = VALUES(deadDate),
+			spawnDate = VALUES(spawnDate),
+			lastInteraction = VALUES(lastInteraction)
+	`, p.ItemID, p.Name, p.SN, p.Level, p.Closeness, p.Fullness, p.DeadDate, p.SpawnDate, p.LastInteraction)
+	return err
+}
+
+func (r mysqlPets) Update(p Pet) error {
+	_, err := r.db.Exec("UPDATE pets SET name=?, sn=?, level=?, closeness=?, fullness=?, deadDate=?, spawnDate=?, lastInteraction=? WHERE parentID=?",
+		p.Name, p.SN, p.Level, p.Closeness, p.Fullness, p.DeadDate, p.SpawnDate, p.LastInteraction, p.ItemID)
+	return err
+}
+
+type mysqlPetEquips struct {
+	db *sql.DB
+}
+
+func (r mysqlPetEquips) ByPet(petID int64) (map[byte]int64, error) {
+	rows, err := r.db.Query("SELECT slot, itemID FROM pet_equips WHERE petID=?", petID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	equips := make(map[byte]int64)
+	for rows.Next() {
+		var slot byte
+		var itemID int64
+
+		if err := rows.Scan(&slot, &itemID); err != nil {
+			return equips, err
+		}
+
+		equips[slot] = itemID
+	}
+
+	return equips, rows.Err()
+}
+
+func (r mysqlPetEquips) Save(petID int64, equips map[byte]int64) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec("DELETE FROM pet_equips WHERE petID=?", petID); err != nil {
+		return err
+	}
+
+	for slot, itemID := range equips {
+		if _, err = tx.Exec("INSERT INTO pet_equips(petID, slot, itemID) VALUES(?,?,?)", petID, slot, itemID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
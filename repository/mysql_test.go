@@ -0,0 +1,36 @@
+//go:build integration
+
+package repository
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// mysqlRepo connects to a database loaded with sql/maplestory.sql, VALHALLA_TEST_MYSQL overrides the DSN of the CI
+// service
+func mysqlRepo(t *testing.T) Repositories {
+	dsn := os.Getenv("VALHALLA_TEST_MYSQL")
+	if dsn == "" {
+		dsn = "root:password@tcp(127.0.0.1:3306)/maplestory"
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewMySQL(db)
+}
+
+func TestMySQLReportResolve(t *testing.T) {
+	testReportResolve(t, mysqlRepo(t))
+}
@@ -0,0 +1,716 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a lookup matches no rows
+var ErrNotFound = errors.New("repository: not found")
+
+// ErrDuplicate is returned by the in-memory store where MySQL would reject the row on a unique key
+var ErrDuplicate = errors.New("repository: duplicate entry")
+
+// ErrExhausted is returned when a limited resource, e.g. a coupon's uses, has run out
+var ErrExhausted = errors.New("repository: exhausted")
+
+// ErrExpired is returned when a time-limited row, e.g. a coupon, is used after it has expired
+var ErrExpired = errors.New("repository: expired")
+
+// Repositories groups every persistence interface used by the servers
+type Repositories struct {
+	Accounts   Accounts
+	Characters Characters
+	Items      Items
+	Skills     Skills
+	Quests     Quests
+	Guilds     Guilds
+	Buddies    Buddies
+	Bans       Bans
+	Mutes      Mutes
+	Jails      Jails
+	Violations Violations
+	Storage    Storage
+	Gifts      Gifts
+	Wishlists  Wishlists
+	Coupons    Coupons
+	Merchants  Merchants
+	Reports    Reports
+	ChatLogs   ChatLogs
+	Raids      RaidEntries
+	Buffs      Buffs
+	Fame       Fame
+	LieTests   LieDetectors
+	Pets       Pets
+	PetEquips  PetEquips
+}
+
+// Account row
+type Account struct {
+	ID          int32
+	Username    string
+	Password    string
+	Pin         string
+	Gender      byte
+	DOB         int
+	EULA        byte
+	AdminLevel  int
+	LoggedIn    bool
+	Banned      int
+	Locked      int
+	HWID        string
+	NX          int32
+	MaplePoints int32
+}
+
+// Accounts persistence
+type Accounts interface {
+	ByUsername(username string) (Account, error)
+	ByID(accountID int32) (Account, error)
+	Create(account Account) (int32, error)
+	SetHWID(accountID int32, hwid string) error
+	SetEULA(accountID int32, accepted bool) error
+	SetPin(accountID int32, pin string) error
+	SetPassword(accountID int32, hash string) error
+	SetLoggedIn(accountID int32, loggedIn bool) error
+	SetLocked(accountID int32, locked bool) error
+	SetBanned(accountID int32, banned bool) error
+	SetCash(accountID, nx, maplePoints int32) error
+	// ClearStaleLogins marks accounts logged out when none of their characters are on a channel, returning how many
+	// were changed
+	ClearStaleLogins() (int64, error)
+}
+
+// Character summary used for lookups between players, e.g. buddy requests and moderation
+type Character struct {
+	ID            int32
+	AccountID     int32
+	WorldID       byte
+	Name          string
+	Gender        byte
+	Skin          byte
+	Hair          int32
+	Face          int32
+	Level         byte
+	Job           int16
+	MapID         int32
+	ChannelID     int32
+	InCashShop    bool
+	BuddyListSize byte
+	GuildID       int32
+	GuildRank     byte
+	// MigrationID is the channel the character is moving to, -1 when it is not moving
+	MigrationID       int32
+	PreviousChannelID int32
+}
+
+// CharacterData is the full character row loaded when a character enters a channel or is listed at login
+type CharacterData struct {
+	Character
+	Str              int16
+	Dex              int16
+	Intt             int16
+	Luk              int16
+	HP               int16
+	MaxHP            int16
+	MP               int16
+	MaxMP            int16
+	AP               int16
+	SP               int16
+	Exp              int32
+	Fame             int16
+	MapPos           byte
+	PreviousMapID    int32
+	Mesos            int32
+	EquipSlotSize    byte
+	UseSlotSize      byte
+	SetupSlotSize    byte
+	EtcSlotSize      byte
+	CashSlotSize     byte
+	MiniGameWins     int32
+	MiniGameDraw     int32
+	MiniGameLoss     int32
+	MiniGamePoints   int32
+	RegTeleportRocks string
+	VIPTeleportRocks string
+}
+
+// CharacterFields selects the columns written by Characters.Update
+type CharacterFields uint32
+
+// Columns of CharacterData that can be updated
+const (
+	CharacterSkin CharacterFields = 1 << iota
+	CharacterHair
+	CharacterFace
+	CharacterLevel
+	CharacterJob
+	CharacterStr
+	CharacterDex
+	CharacterInt
+	CharacterLuk
+	CharacterHP
+	CharacterMaxHP
+	CharacterMP
+	CharacterMaxMP
+	CharacterAP
+	CharacterSP
+	CharacterExp
+	CharacterFame
+	CharacterMap // map and spawn portal
+	CharacterPreviousMap
+	CharacterMesos
+	CharacterSlotSizes
+	CharacterMiniGame
+	CharacterBuddyListSize
+	CharacterTeleportRocks
+)
+
+// Characters persistence
+type Characters interface {
+	ByID(charID int32) (Character, error)
+	// ByName is case sensitive and scoped to a world
+	ByName(name string, worldID byte) (Character, error)
+	AccountIDByName(name string) (int32, error)
+	Load(charID int32) (CharacterData, error)
+	ByAccount(accountID int32, worldID byte) ([]CharacterData, error)
+	// NameTaken reports whether a character in any world has the name, ignoring case
+	NameTaken(name string) (bool, error)
+	// Create inserts the character's name, account, world, look and base stats and sets its ID
+	Create(c *CharacterData) error
+	Update(c CharacterData, fields CharacterFields) error
+	Delete(charID int32) error
+	SetChannel(charID, channelID int32) error
+	SetMigration(charID, migrationID int32) error
+	SetInCashShop(charID int32, inCashShop bool) error
+	// EnterCashShop marks the character in the cash shop, moving there from the given channel
+	EnterCashShop(charID, migrationID, fromChannelID int32) error
+	// LeaveCashShop marks the character out of the cash shop, moving to the given channel
+	LeaveCashShop(charID, migrationID int32) error
+	// ClearChannel takes every character on the channel off it, returning the accounts of those that were not moving
+	// to another channel
+	ClearChannel(channelID int32) ([]int32, error)
+}
+
+// Item row shared by character inventories and account storages
+type Item struct {
+	ID           int64
+	InventoryID  byte
+	ItemID       int32
+	Slot         int16
+	Amount       int16
+	Flag         int16
+	UpgradeSlots byte
+	Level        byte
+	Str          int16
+	Dex          int16
+	Intt         int16
+	Luk          int16
+	HP           int16
+	MP           int16
+	Watk         int16
+	Matk         int16
+	Wdef         int16
+	Mdef         int16
+	Accuracy     int16
+	Avoid        int16
+	Hands        int16
+	Speed        int16
+	Jump         int16
+	ExpireTime   int64
+	CreatorName  string
+	CashID       int64
+	CashSN       int32
+}
+
+// Items persistence for character inventories
+type Items interface {
+	ByCharacter(charID int32) ([]Item, error)
+	// Save inserts the item when its ID is zero and sets the ID, otherwise it updates the existing row
+	Save(charID int32, item *Item) error
+	Delete(itemID int64) error
+}
+
+// Skill row
+type Skill struct {
+	ID       int32
+	Level    byte
+	Cooldown int16
+}
+
+// Skills persistence
+type Skills interface {
+	ByCharacter(charID int32) ([]Skill, error)
+	Save(charID int32, skill Skill) error
+}
+
+// Quest row
+type Quest struct {
+	ID          int16
+	Record      string
+	Completed   bool
+	CompletedAt int64
+}
+
+// QuestMobKill row
+type QuestMobKill struct {
+	QuestID int16
+	MobID   int32
+	Kills   int32
+}
+
+// Quests persistence
+type Quests interface {
+	ByCharacter(charID int32) ([]Quest, error)
+	MobKills(charID int32) ([]QuestMobKill, error)
+	SetRecord(charID int32, questID int16, record string) error
+	SetCompleted(charID int32, questID int16, completedAt int64) error
+	Delete(charID int32, questID int16) error
+	AddMobKill(charID int32, questID int16, mobID int32, delta int32) error
+	ClearMobKills(charID int32, questID int16) error
+}
+
+// Guild row
+type Guild struct {
+	ID           int32
+	WorldID      int32
+	Capacity     byte
+	Name         string
+	Notice       string
+	Master       string
+	JrMaster     string
+	Member1      string
+	Member2      string
+	Member3      string
+	LogoBg       int16
+	LogoBgColour byte
+	Logo         int16
+	LogoColour   byte
+	Points       int32
+}
+
+// GuildMember row
+type GuildMember struct {
+	CharacterID int32
+	Name        string
+	Job         int32
+	Level       int32
+	Rank        byte
+	Online      bool
+}
+
+// GuildInvite waiting for a character to accept or reject it
+type GuildInvite struct {
+	CharacterID int32
+	GuildID     int32
+	Inviter     string
+}
+
+// Guilds persistence
+type Guilds interface {
+	ByID(guildID int32) (Guild, error)
+	// NameTaken reports whether a guild in the world has the name, ignoring case
+	NameTaken(name string, worldID int32) (bool, error)
+	Members(guildID int32) ([]GuildMember, error)
+	Create(guild Guild) (int32, error)
+	Delete(guildID int32) error
+	SetMember(charID, guildID int32, rank byte) error
+	RemoveMember(charID int32) error
+	SetRank(charID int32, rank byte) error
+	SetPoints(guildID, points int32) error
+	SetTitles(guildID int32, master, jrMaster, member1, member2, member3 string) error
+	SetNotice(guildID int32, notice string) error
+	SetEmblem(guildID int32, logoBg int16, logoBgColour byte, logo int16, logoColour byte) error
+	Invites(charID int32) ([]GuildInvite, error)
+	Invite(invite GuildInvite) error
+	DeleteInvite(charID, guildID int32) error
+}
+
+// Buddy entry on a character's list
+type Buddy struct {
+	FriendID   int32
+	Name       string
+	ChannelID  int32
+	InCashShop bool
+	Accepted   bool
+}
+
+// Buddies persistence
+type Buddies interface {
+	ByCharacter(charID int32) ([]Buddy, error)
+	CountAccepted(charID int32) (int, error)
+	// Request adds a pending entry for friendID on charID's list
+	Request(charID, friendID int32) error
+	// Accept confirms friendID's request on charID's list and adds charID to friendID's list
+	Accept(charID, friendID int32) error
+	// Delete removes the relationship in both directions
+	Delete(charID, friendID int32) error
+}
+
+// Ban record. A zero AccountID is an ip/hwid only ban and a zero End is permanent.
+type Ban struct {
+	AccountID int32
+	Reason    string
+	End       time.Time
+	IP        string
+	HWID      string
+	CreatedAt time.Time
+}
+
+// Permanent ban
+func (b Ban) Permanent() bool {
+	return b.End.IsZero()
+}
+
+// Bans persistence
+type Bans interface {
+	Create(ban Ban) error
+	// Active returns the most recent unexpired ban matching the account, ip or hwid
+	Active(accountID int32, ip, hwid string) (Ban, bool, error)
+	ByAccount(accountID int32, limit int) ([]Ban, error)
+	DeleteByAccount(accountID int32) error
+	// IncrementEscalation bumps the temporary ban count for the account and returns the new total
+	IncrementEscalation(accountID int32) (int, error)
+}
+
+// Mute record, the account can still play but not chat. A zero End is permanent and a set LiftedAt means a GM removed
+// the mute early.
+type Mute struct {
+	ID        int32
+	AccountID int32
+	Reason    string
+	IssuedBy  string
+	End       time.Time
+	LiftedBy  string
+	LiftedAt  time.Time
+	CreatedAt time.Time
+}
+
+// Permanent mute
+func (m Mute) Permanent() bool {
+	return m.End.IsZero()
+}
+
+// ActiveAt reports whether the mute is in force at t
+func (m Mute) ActiveAt(t time.Time) bool {
+	return m.LiftedAt.IsZero() && (m.Permanent() || m.End.After(t))
+}
+
+// Mutes persistence
+type Mutes interface {
+	Create(mute *Mute) error
+	// Active returns the mute in force on the account that ends last
+	Active(accountID int32) (Mute, bool, error)
+	ByAccount(accountID int32, limit int) ([]Mute, error)
+	// Lift ends every mute in force on the account, ErrNotFound if there were none
+	Lift(accountID int32, by string) error
+}
+
+// Jail sentence, the account's characters are held on the jail map until End. A set ReleasedAt means a GM let them out
+// early.
+type Jail struct {
+	ID         int32
+	AccountID  int32
+	Reason     string
+	IssuedBy   string
+	End        time.Time
+	ReleasedBy string
+	ReleasedAt time.Time
+	CreatedAt  time.Time
+}
+
+// ActiveAt reports whether the sentence is being served at t
+func (j Jail) ActiveAt(t time.Time) bool {
+	return j.ReleasedAt.IsZero() && j.End.After(t)
+}
+
+// Jails persistence
+type Jails interface {
+	Create(jail *Jail) error
+	// Active returns the sentence being served by the account that ends last
+	Active(accountID int32) (Jail, bool, error)
+	ByAccount(accountID int32, limit int) ([]Jail, error)
+	// Release ends every sentence being served by the account, ErrNotFound if there were none
+	Release(accountID int32, by string) error
+}
+
+// Violation of an anti-cheat check, Detail holds what was seen e.g. the damage against the calculated maximum
+type Violation struct {
+	ID            int64
+	AccountID     int32
+	CharacterID   int32
+	CharacterName string
+	ChannelID     byte
+	MapID         int32
+	Type          string
+	Detail        string
+	CreatedAt     time.Time
+}
+
+// Offender totals an account's violations, CharacterName is the character of the most recent one
+type Offender struct {
+	AccountID     int32
+	CharacterName string
+	Count         int
+	LastAt        time.Time
+}
+
+// Violations persistence
+type Violations interface {
+	Create(v *Violation) error
+	// Insert writes a batch of violations in one statement, CreatedAt is kept as given
+	Insert(vs []Violation) error
+	// ByAccount returns the newest violations first
+	ByAccount(accountID int32, limit int) ([]Violation, error)
+	// TopOffenders returns the accounts with the most violations on the channel since the given time
+	TopOffenders(channelID byte, since time.Time, limit int) ([]Offender, error)
+}
+
+// StorageContents of an account storage, slot numbers on the items are 1 based
+type StorageContents struct {
+	Slots byte
+	Mesos int32
+	Items []Item
+}
+
+// Storage persistence for the account-wide storage and cash shop locker
+type Storage interface {
+	Load(accountID int32) (StorageContents, error)
+	Create(accountID int32, slots byte) error
+	// Save replaces the stored contents in a single transaction
+	Save(accountID int32, contents StorageContents) error
+	LoadCashShop(accountID int32) (StorageContents, error)
+	CreateCashShop(accountID int32, slots byte) error
+	SaveCashShop(accountID int32, contents StorageContents) error
+}
+
+// Gift note attached to a cash shop item sent from another account
+type Gift struct {
+	ID        int64
+	AccountID int32
+	CashID    int64
+	ItemID    int32
+	Sender    string
+	Message   string
+}
+
+// Gifts persistence, notes are kept until the gifted item leaves the recipient's locker
+type Gifts interface {
+	Create(gift Gift) error
+	ByAccount(accountID int32) ([]Gift, error)
+	Delete(giftID int64) error
+}
+
+// Wishlists persistence for the cash shop wishlist, SNs are returned in slot order
+type Wishlists interface {
+	ByCharacter(charID int32) ([]int32, error)
+	// Save replaces the character's wishlist, zero SNs are empty slots and are not stored
+	Save(charID int32, sns []int32) error
+}
+
+// CouponItem granted on redemption
+type CouponItem struct {
+	ItemID int32
+	Amount int16
+}
+
+// Coupon code and its rewards. A zero MaxUses is unlimited and a zero ExpiresAt (unix milliseconds) never expires.
+type Coupon struct {
+	Code        string
+	NX          int32
+	MaplePoints int32
+	Mesos       int32
+	MaxUses     int32
+	Uses        int32
+	ExpiresAt   int64
+	CreatedBy   string
+	Items       []CouponItem
+}
+
+// Expired at the given unix millisecond time
+func (c Coupon) Expired(now int64) bool {
+	return c.ExpiresAt != 0 && now >= c.ExpiresAt
+}
+
+// Coupons persistence
+type Coupons interface {
+	// Create returns ErrDuplicate if the code already exists
+	Create(coupon Coupon) error
+	ByCode(code string) (Coupon, error)
+	// Redeem records the account's use of the code, returning ErrDuplicate if the account has already redeemed it,
+	// ErrExhausted if no uses remain and ErrExpired if the code has expired
+	Redeem(code string, accountID, charID int32) error
+}
+
+// MerchantItem listed in a hired merchant, the item's Amount is the size of one bundle
+type MerchantItem struct {
+	Item    Item
+	Bundles int16
+	Price   int32
+}
+
+// Merchant is a hired merchant. Once Closed it no longer trades and its remaining items and mesos wait to be
+// collected by the owner.
+type Merchant struct {
+	ID          int32
+	CharacterID int32
+	ChannelID   byte
+	MapID       int32
+	X           int16
+	Y           int16
+	Foothold    int16
+	Title       string
+	Mesos       int32
+	Closed      bool
+	ExpiresAt   int64
+	Items       []MerchantItem
+}
+
+// Merchants persistence, a character has at most one merchant at a time
+type Merchants interface {
+	// Create sets the merchant's ID, returning ErrDuplicate if the character already has a merchant
+	Create(merchant *Merchant) error
+	ByCharacter(charID int32) (Merchant, error)
+	// Trading returns the merchants on the channel that have not been closed
+	Trading(channelID byte) ([]Merchant, error)
+	// Save updates the title, earnings and closed state and replaces the listings in a single transaction
+	Save(merchant Merchant) error
+	Delete(merchantID int32) error
+}
+
+// Report states
+const (
+	ReportOpen byte = iota
+	ReportClaimed
+	ReportResolved
+)
+
+// Report of a character filed by another player. ChatLog is an excerpt of the map chat around the time of the report.
+type Report struct {
+	ID                int32
+	ReporterID        int32
+	ReporterAccountID int32
+	ReporterName      string
+	TargetID          int32
+	TargetAccountID   int32
+	TargetName        string
+	Reason            byte
+	Description       string
+	MapID             int32
+	ChatLog           string
+	Status            byte
+	ClaimedBy         string
+	Resolution        string
+	CreatedAt         time.Time
+}
+
+// Reports persistence
+type Reports interface {
+	// Create sets the report's ID and CreatedAt
+	Create(report *Report) error
+	ByID(reportID int32) (Report, error)
+	// Unresolved returns open and claimed reports, oldest first
+	Unresolved(limit int) ([]Report, error)
+	// Claim assigns the report to a GM. Returns ErrNotFound if the report does not exist or is resolved and
+	// ErrDuplicate if another GM has claimed it.
+	Claim(reportID int32, gm string) error
+	// Resolve closes the report, with the same errors as Claim
+	Resolve(reportID int32, gm, resolution string) error
+	// CountSince returns how many reports the account has filed since the given time
+	CountSince(accountID int32, since time.Time) (int, error)
+}
+
+// ChatLog line, Target is the recipient of a whisper and empty for chat that goes to a group of players
+type ChatLog struct {
+	ID        int64
+	Kind      string
+	ChannelID byte
+	MapID     int32
+	SenderID  int32
+	Sender    string
+	Target    string
+	Message   string
+	CreatedAt time.Time
+}
+
+// ChatLogs persistence
+type ChatLogs interface {
+	// Insert writes the lines in a single statement
+	Insert(lines []ChatLog) error
+	// Recent returns the newest lines sent by or whispered to the named character, newest first
+	Recent(name string, limit int) ([]ChatLog, error)
+}
+
+// RaidEntries persistence, a row is written each time a character enters a boss raid
+type RaidEntries interface {
+	Add(characterID int32, raid string) error
+	// CountSince returns how many times the character has entered the raid since the given time
+	CountSince(characterID int32, raid string, since time.Time) (int, error)
+}
+
+// Buff kept across a channel change or logout, SourceID is the skill ID or the negated item ID
+type Buff struct {
+	SourceID    int32
+	Level       byte
+	ExpiresAtMs int64
+}
+
+// Buffs persistence
+type Buffs interface {
+	ByCharacter(charID int32) ([]Buff, error)
+	// Save replaces the character's buffs in a single transaction
+	Save(charID int32, buffs []Buff) error
+	// Delete removes the given buffs, or every buff of the character when no source IDs are given
+	Delete(charID int32, sourceIDs ...int32) error
+}
+
+// Fame persistence, a row is written each time a character raises or lowers another's fame
+type Fame interface {
+	Add(fromID, toID int32) error
+	// GivenWithin reports whether the character has given fame in the window up to now
+	GivenWithin(fromID int32, window time.Duration) (bool, error)
+}
+
+// LieDetectors persistence, a lie detector test pending when the character left the channel is run again at their
+// next login and a reward the reporter was not on the channel for is handed over at theirs
+type LieDetectors interface {
+	// Pend records the character's pending test, replacing any already recorded
+	Pend(characterID, reporterID int32) error
+	// Take removes the character's pending test, ok is false when there was none
+	Take(characterID int32) (reporterID int32, ok bool, err error)
+	// Reward adds mesos to the character's unclaimed reward
+	Reward(characterID, mesos int32) error
+	// TakeReward removes the character's unclaimed reward, 0 when there was none
+	TakeReward(characterID int32) (int32, error)
+}
+
+// Pet row, ItemID is the database ID of the pet's cash item
+type Pet struct {
+	ItemID          int64
+	Name            string
+	SN              int32
+	Level           byte
+	Closeness       int16
+	Fullness        byte
+	DeadDate        int64
+	SpawnDate       int64
+	LastInteraction int64
+}
+
+// Pets persistence
+type Pets interface {
+	// ByItem returns ErrNotFound when the pet item has no pet row yet
+	ByItem(itemID int64) (Pet, error)
+	// Save inserts the pet or updates everything but its SN
+	Save(p Pet) error
+	// Update changes an existing pet and does nothing once its item is gone
+	Update(p Pet) error
+}
+
+// PetEquips persistence, the equipment a pet wears by equipment slot as the database IDs of the items. The items keep
+// their rows while the pet wears them.
+type PetEquips interface {
+	ByPet(petID int64) (map[byte]int64, error)
+	// Save replaces the pet's equipment in a single transaction
+	Save(petID int64, equips map[byte]int64) error
+}
@@ -16,6 +16,10 @@ var questStage2 = 7001  // Stage 2: Jump Quest
 var questStage3 = 7002  // Stage 3: Item Exchange
 var questComplete = "end"  // Quest data value for completed stages
 
+var raid = "zakum"         // scripts/raid/zakum.js
+
+npc.include("raid_menu")   // scripts/npc/raid_menu.js
+
 if (plr.level() < minLevel) {
     npc.sendOk("You are not yet ready to face Zakum. Train a bit more and return when you are at least level " + minLevel + ".")
 } else {
@@ -37,7 +41,8 @@ if (plr.level() < minLevel) {
         "The Door to Zakum lies ahead. How may I assist you?\r\n" +
         "#L0#Enter the Zakum Party Quest.#l\r\n" +
         "#L1#Enter the Zakum Jump Quest.#l\r\n" +
-        "#L2#Exchange quest items for #t" + itemEyeOfFire + "#.#l" +
+        "#L2#Exchange quest items for #t" + itemEyeOfFire + "#.#l\r\n" +
+        "#L3#Join a raid against Zakum.#l" +
         statusText
 
     npc.sendSelection(menuText)
@@ -120,5 +125,8 @@ if (plr.level() < minLevel) {
         } else {
             npc.sendOk("Come back when you are ready to exchange.")
         }
+    } else if (sel == 3) {
+        raidMenu(raid)
     }
-}
\ No newline at end of file
+}
+
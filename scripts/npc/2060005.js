@@ -0,0 +1,15 @@
+// Kenta - Aquarium, opens the raids against Pianus
+
+var raid = "pianus"        // scripts/raid/pianus.js
+
+npc.include("raid_menu")   // scripts/npc/raid_menu.js
+
+npc.sendSelection("Something huge has been stirring in the Cave of Pianus, the animals of the Aquarium can sense it. What brings you here?\r\n" +
+    "#L0#Join a raid against Pianus.#l\r\n" +
+    "#L1#Nothing, just looking around.#l")
+
+if (npc.selection() == 0) {
+    raidMenu(raid)
+} else {
+    npc.sendOk("Take care out on Aqua Road, the deeper waters are no place for the unprepared.")
+}
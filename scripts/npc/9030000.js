@@ -0,0 +1,22 @@
+// Fredrick - looks after the goods of closed hired merchants
+if (!plr.hasMerchant()) {
+    npc.sendOk("Hi, I'm Fredrick. When a hired merchant closes, whatever it didn't sell and the mesos it earned are left with me. It looks like I'm not holding anything for you right now.")
+} else {
+    var text = "I'm holding #b" + plr.merchantItemCount() + "#k unsold item(s) and #b" + plr.merchantMesos() + " mesos#k from your hired merchant. Would you like to collect them now?"
+
+    if (plr.merchantOpen()) {
+        text = "Your hired merchant is still trading. Would you like me to close it and hand you whatever it hasn't sold along with its earnings?"
+    }
+
+    if (npc.sendYesNo(text)) {
+        if (plr.merchantOpen() && !plr.closeMerchant()) {
+            npc.sendOk("Your hired merchant is trading on #bchannel " + plr.merchantChannel() + "#k, please come and see me there.")
+        } else if (plr.collectMerchant()) {
+            npc.sendOk("Here you go. Thank you for using the hired merchant service!")
+        } else {
+            npc.sendOk("You can't carry everything I'm holding. Please make some room in your inventory, or spend some of your mesos, and come back.")
+        }
+    } else {
+        npc.sendOk("Come back whenever you are ready.")
+    }
+}
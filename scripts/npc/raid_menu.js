@@ -0,0 +1,73 @@
+// Raid signup menu shared by the NPCs that open boss raids. An NPC script runs npc.include("raid_menu") and then calls
+// raidMenu with the name of the raid script, e.g. raidMenu("zakum") for scripts/raid/zakum.js
+
+function raidMenu(raid) {
+    var config = plr.raidConfig(raid)
+
+    if (config == null) {
+        npc.sendOk("The raid is not available right now.")
+    } else if (plr.inRaid() && plr.raidName() != raid) {
+        npc.sendOk("You are already signed up to another raid. Leave it before joining one against " + config.name + ".")
+    } else if (plr.isRaidLeader()) {
+        var members = plr.raidMembers()
+        var text = "Your raid has " + members.length + " player(s) signed up:\r\n"
+
+        for (let i = 0; i < members.length; i++) {
+            text += "- " + members[i] + "\r\n"
+        }
+
+        text += "\r\nOnly those here with you will be taken in when the raid starts.\r\n" +
+            "#L0#Start the raid.#l\r\n" +
+            "#L1#Close the signup list.#l"
+
+        npc.sendSelection(text)
+        var choice = npc.selection()
+
+        if (choice == 0) {
+            if (!plr.startRaid()) {
+                npc.sendOk("The raid could not be started.")
+            }
+        } else if (choice == 1) {
+            plr.leaveRaid()
+            npc.sendOk("The signup list has been closed.")
+        }
+    } else if (plr.inRaid()) {
+        if (npc.sendYesNo("You are signed up to a raid against " + config.name + ". Would you like to leave the signup list?")) {
+            plr.leaveRaid()
+            npc.sendOk("You have left the signup list.")
+        } else {
+            npc.sendOk("Wait here with your raid leader until they start the raid.")
+        }
+    } else {
+        var leaders = plr.raidSignups(raid)
+        var entries = plr.raidEntriesLeft(raid)
+        var text = "A raid leader can sign up to " + config.maxPlayers + " adventurers to face " + config.name + " together."
+
+        if (entries >= 0) {
+            text += " You can enter #b" + entries + "#k more time(s) today."
+        }
+
+        text += "\r\n#L0#Open a new signup list.#l\r\n"
+
+        for (let i = 0; i < leaders.length; i++) {
+            text += "#L" + (i + 1) + "#Join " + leaders[i] + "'s raid.#l\r\n"
+        }
+
+        npc.sendSelection(text)
+        var choice = npc.selection()
+
+        if (choice == 0) {
+            if (plr.openRaid(raid)) {
+                npc.sendOk("Your signup list is open. Gather your raid here and speak to me again to start.")
+            } else {
+                npc.sendOk("You cannot open a raid right now.")
+            }
+        } else if (choice > 0 && choice <= leaders.length) {
+            if (plr.joinRaid(raid, leaders[choice - 1])) {
+                npc.sendOk("You have joined " + leaders[choice - 1] + "'s raid. Wait here until they start it.")
+            } else {
+                npc.sendOk("You cannot join that raid right now.")
+            }
+        }
+    }
+}
@@ -0,0 +1,40 @@
+// Papulatus raid, fought in the Origin of Clocktower
+var config = {
+    name: "Papulatus",
+    map: 220080001,   // Ludibrium: Origin of Clocktower
+    exit: 220080000,  // Ludibrium: Deep Inside the Clocktower
+    minLevel: 60,
+    maxPlayers: 15,
+    entries: 3        // per character per day
+};
+
+var ball = 8500000;
+var forms = [8500000, 8500001, 8500002];
+var rewardExp = 30000;
+var rewardMesos = 50000;
+
+function start() {
+    ctrl.setDuration("45m");
+    ctrl.spawnMob(ball, -413, -400);
+    ctrl.notice("The crack of dimension has opened. Defeat Papulatus before the time runs out!");
+}
+
+function mobDeath(mob) {
+    // Papulatus revives into its next form, it is beaten once the last one goes down
+    if (forms.indexOf(mob) < 0 || ctrl.getMap().mobCount() > 0) {
+        return;
+    }
+
+    ctrl.clear();
+    ctrl.notice("Papulatus has been defeated! You will be sent out of the Clocktower in 2 minutes.");
+    ctrl.setDuration("2m");
+}
+
+function reward(plr) {
+    plr.giveEXP(rewardExp);
+    plr.giveMesos(rewardMesos);
+}
+
+function playerLeave(plr) {
+    ctrl.notice(plr.name() + " has left the raid. " + ctrl.playerCount() + " player(s) remain.");
+}
@@ -0,0 +1,46 @@
+// Pianus raid, fought in the Cave of Pianus
+var config = {
+    name: "Pianus",
+    map: 230040420,   // Aqua Road: The Cave of Pianus
+    exit: 230000000,  // Aquarium
+    minLevel: 70,
+    maxPlayers: 15,
+    entries: 3        // per character per day
+};
+
+var pianus = [8510000, 8520000];
+var rewardExp = 40000;
+var rewardMesos = 80000;
+
+function start() {
+    ctrl.setDuration("45m");
+
+    // Pianus is one of the cave's own spawns, bring it back in case the instance was left without it
+    var field = ctrl.getMap();
+    field.reset();
+
+    if (field.mobCount() == 0) {
+        ctrl.spawnMob(pianus[0], 0, 0);
+    }
+
+    ctrl.notice("Pianus stirs in the depths. Defeat it before the time runs out!");
+}
+
+function mobDeath(mob) {
+    if (pianus.indexOf(mob) < 0) {
+        return;
+    }
+
+    ctrl.clear();
+    ctrl.notice("Pianus has been defeated! You will be sent back to Aquarium in 2 minutes.");
+    ctrl.setDuration("2m");
+}
+
+function reward(plr) {
+    plr.giveEXP(rewardExp);
+    plr.giveMesos(rewardMesos);
+}
+
+function playerLeave(plr) {
+    ctrl.notice(plr.name() + " has left the raid. " + ctrl.playerCount() + " player(s) remain.");
+}
@@ -0,0 +1,45 @@
+// Zakum raid, fought on the Altar of Zakum
+var config = {
+    name: "Zakum",
+    map: 280030000,   // El Nath: Zakum's Altar
+    exit: 211042300,  // El Nath: Door to Zakum
+    minLevel: 50,
+    maxPlayers: 30,
+    entries: 2        // per character per day
+};
+
+var arms = [8800003, 8800004, 8800005, 8800006, 8800007, 8800008, 8800009, 8800010];
+var bodies = [8800000, 8800001, 8800002];
+var rewardExp = 50000;
+var rewardMesos = 100000;
+
+function start() {
+    ctrl.setDuration("1h");
+
+    for (let i = 0; i < arms.length; i++) {
+        ctrl.spawnMob(arms[i], -10, -215);
+    }
+
+    ctrl.spawnMob(bodies[0], -10, -215);
+    ctrl.notice("Zakum has awoken. Defeat it before the time runs out!");
+}
+
+function mobDeath(mob) {
+    // Zakum's bodies revive into each other, it is beaten once the last one goes down
+    if (bodies.indexOf(mob) < 0 || ctrl.getMap().mobCount() > 0) {
+        return;
+    }
+
+    ctrl.clear();
+    ctrl.notice("Zakum has been defeated! You will be sent back to the Door to Zakum in 2 minutes.");
+    ctrl.setDuration("2m");
+}
+
+function reward(plr) {
+    plr.giveEXP(rewardExp);
+    plr.giveMesos(rewardMesos);
+}
+
+function playerLeave(plr) {
+    ctrl.notice(plr.name() + " has left the raid. " + ctrl.playerCount() + " player(s) remain.");
+}
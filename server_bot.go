@@ -0,0 +1,33 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/Hucaru/Valhalla/bot"
+)
+
+// runBot plays the smoke scenario against a running login server and exits non-zero on failure so it can gate CI
+func runBot(configFile string) {
+	config := botConfigFromFile(configFile)
+
+	cfg := bot.Config{
+		LoginAddress:  config.LoginAddress + ":" + config.LoginPort,
+		Username:      config.Username,
+		Password:      config.Password,
+		WorldID:       config.World,
+		ChannelID:     config.Channel,
+		CharacterName: config.CharacterName,
+		Timeout:       time.Duration(config.Timeout) * time.Second,
+	}
+
+	log.Println("Bot connecting to", cfg.LoginAddress, "as", cfg.Username)
+
+	if err := bot.Run(cfg, bot.Smoke()...); err != nil {
+		log.Println("Bot scenario failed:", err)
+		os.Exit(1)
+	}
+
+	log.Println("Bot scenario passed")
+}
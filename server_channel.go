@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"github.com/Hucaru/Valhalla/channel"
+	"github.com/Hucaru/Valhalla/chatlog"
+	"github.com/Hucaru/Valhalla/common"
 	"github.com/Hucaru/Valhalla/constant"
 	"github.com/Hucaru/Valhalla/nx"
 
@@ -20,6 +22,12 @@ import (
 	"github.com/Hucaru/Valhalla/mpacket"
 )
 
+// Channels started together in dev mode share the chat log outputs
+var (
+	chatLogOnce    sync.Once
+	channelChatLog *chatlog.Logger
+)
+
 type channelServer struct {
 	config    channelConfig
 	dbConfig  dbConfig
@@ -65,6 +73,7 @@ func (cs *channelServer) run() {
 		select {
 		case <-sigCh:
 			log.Println("Shutdown signal received")
+			cs.drain()
 			cs.shutdown()
 		case <-cs.ctx.Done():
 		}
@@ -88,7 +97,24 @@ func (cs *channelServer) run() {
 		cs.dbConfig.Database,
 		"drops.json",
 		"reactors.json",
-		"reactor_drops.json")
+		"reactor_drops.json",
+		"chat_filter.json")
+
+	cs.gameState.SetChatLog(cs.chatLog())
+
+	policies, err := cs.config.AntiCheat.policies()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cs.gameState.SetAntiCheatPolicies(policies, cs.config.AntiCheat.Shadow)
+
+	if err := cs.gameState.SetJail(cs.config.JailMap, cs.config.JailReleaseMap); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := cs.gameState.SetPetPotions(cs.config.PetAutoHPItem, cs.config.PetAutoMPItem, cs.config.PetAutoHPPercent, cs.config.PetAutoMPPercent); err != nil {
+		log.Fatal(err)
+	}
 
 	cs.wg.Add(1)
 	go cs.acceptNewConnections()
@@ -100,6 +126,45 @@ func (cs *channelServer) run() {
 	log.Println("Channel Server stopped")
 }
 
+// drain moves players to the other channels before shutting down, a second signal skips the rest of it
+func (cs *channelServer) drain() {
+	if cs.config.DrainSeconds <= 0 {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(cs.ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cs.gameState.Drain(ctx, time.Duration(cs.config.DrainSeconds)*time.Second)
+}
+
+// chatLog creates the configured chat log outputs, the database must already be connected
+func (cs *channelServer) chatLog() *chatlog.Logger {
+	chatLogOnce.Do(func() {
+		var sinks []chatlog.Sink
+
+		if cs.config.ChatLogDB {
+			sinks = append(sinks, chatlog.NewDBSink(common.Repo.ChatLogs))
+		}
+
+		if cs.config.ChatLogDir != "" {
+			sink, err := chatlog.NewFileSink(cs.config.ChatLogDir, int64(cs.config.ChatLogMaxMB)<<20)
+			if err != nil {
+				log.Println("Chat log files disabled:", err)
+			} else {
+				sinks = append(sinks, sink)
+			}
+		}
+
+		if len(sinks) > 0 {
+			channelChatLog = chatlog.New(sinks...)
+			log.Println("Chat logging enabled")
+		}
+	})
+
+	return channelChatLog
+}
+
 func (cs *channelServer) shutdown() {
 	log.Println("Flushing players")
 	cs.gameState.CheckpointAll(cs.ctx)
@@ -107,6 +172,12 @@ func (cs *channelServer) shutdown() {
 	log.Println("Stopping saver")
 	channel.StopSaver()
 
+	cs.gameState.CloseViolations()
+
+	if err := channelChatLog.Close(); err != nil {
+		log.Println("Closing chat log:", err)
+	}
+
 	cs.cancel()
 	if cs.listener != nil {
 		_ = cs.listener.Close()
@@ -2,10 +2,13 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"reflect"
 	"strings"
+	"time"
 
+	"github.com/Hucaru/Valhalla/anticheat"
 	"github.com/spf13/viper"
 )
 
@@ -24,6 +27,7 @@ type loginConfig struct {
 	ServerListenPort    string	`mapstructure:"serverListenPort"`
 	WithPin             bool	`mapstructure:"withPin"`
 	AutoRegister        bool	`mapstructure:"autoRegister"`
+	PasswordCost        int		`mapstructure:"passwordCost"`
 	PacketQueueSize     int		`mapstructure:"packetQueueSize"`
 	Latency             int		`mapstructure:"latency"`
 	Jitter              int		`mapstructure:"jitter"`
@@ -52,6 +56,90 @@ type channelConfig struct {
 	MaxPop                  int16	`mapstructure:"maxPop"`
 	Latency                 int		`mapstructure:"latency"`
 	Jitter                  int		`mapstructure:"jitter"`
+	DrainSeconds            int		`mapstructure:"drainSeconds"`
+	ChatLogDB               bool	`mapstructure:"chatLogDB"`
+	ChatLogDir              string	`mapstructure:"chatLogDir"`
+	ChatLogMaxMB            int		`mapstructure:"chatLogMaxMB"`
+	JailMap                 int32	`mapstructure:"jailMap"`
+	JailReleaseMap          int32	`mapstructure:"jailReleaseMap"`
+	PetAutoHPItem           int32	`mapstructure:"petAutoHPItem"`
+	PetAutoMPItem           int32	`mapstructure:"petAutoMPItem"`
+	PetAutoHPPercent        int		`mapstructure:"petAutoHPPercent"`
+	PetAutoMPPercent        int		`mapstructure:"petAutoMPPercent"`
+	AntiCheat               anticheatConfig	`mapstructure:"anticheat"`
+}
+
+// anticheatPolicyConfig for one violation type, zero values keep the default
+type anticheatPolicyConfig struct {
+	Threshold   int		`mapstructure:"threshold"`
+	WindowSecs  int		`mapstructure:"windowSecs"`
+	Action      string	`mapstructure:"action"`
+	BanHours    int		`mapstructure:"banHours"`
+	JailMinutes int		`mapstructure:"jailMinutes"`
+	Shadow      bool	`mapstructure:"shadow"`
+}
+
+type anticheatConfig struct {
+	Shadow       bool					`mapstructure:"shadow"`
+	Damage       anticheatPolicyConfig	`mapstructure:"damage"`
+	AttackSpeed  anticheatPolicyConfig	`mapstructure:"attackSpeed"`
+	Teleport     anticheatPolicyConfig	`mapstructure:"teleport"`
+	InvalidItem  anticheatPolicyConfig	`mapstructure:"invalidItem"`
+	InvalidTrade anticheatPolicyConfig	`mapstructure:"invalidTrade"`
+	SkillAbuse   anticheatPolicyConfig	`mapstructure:"skillAbuse"`
+	PickupRange  anticheatPolicyConfig	`mapstructure:"pickupRange"`
+	PickupRate   anticheatPolicyConfig	`mapstructure:"pickupRate"`
+	MobVac       anticheatPolicyConfig	`mapstructure:"mobVac"`
+}
+
+// policies merges the configured values over the default policy of each violation type
+func (c anticheatConfig) policies() (map[string]anticheat.Policy, error) {
+	configured := []struct {
+		key       string
+		violation string
+		cfg       anticheatPolicyConfig
+	}{
+		{"damage", anticheat.ViolationDamage, c.Damage},
+		{"attackSpeed", anticheat.ViolationAttackSpeed, c.AttackSpeed},
+		{"teleport", anticheat.ViolationTeleport, c.Teleport},
+		{"invalidItem", anticheat.ViolationInvalidItem, c.InvalidItem},
+		{"invalidTrade", anticheat.ViolationInvalidTrade, c.InvalidTrade},
+		{"skillAbuse", anticheat.ViolationSkillAbuse, c.SkillAbuse},
+		{"pickupRange", anticheat.ViolationPickupRange, c.PickupRange},
+		{"pickupRate", anticheat.ViolationPickupRate, c.PickupRate},
+		{"mobVac", anticheat.ViolationMobVac, c.MobVac},
+	}
+
+	policies := make(map[string]anticheat.Policy, len(configured))
+
+	for _, v := range configured {
+		p := anticheat.DefaultPolicies[v.violation]
+
+		if v.cfg.Threshold > 0 {
+			p.Threshold = v.cfg.Threshold
+		}
+		if v.cfg.WindowSecs > 0 {
+			p.Window = time.Duration(v.cfg.WindowSecs) * time.Second
+		}
+		if v.cfg.Action != "" {
+			action, err := anticheat.ParseAction(v.cfg.Action)
+			if err != nil {
+				return nil, fmt.Errorf("channel.anticheat.%s: %w", v.key, err)
+			}
+			p.Action = action
+		}
+		if v.cfg.BanHours > 0 {
+			p.BanHours = v.cfg.BanHours
+		}
+		if v.cfg.JailMinutes > 0 {
+			p.JailTime = time.Duration(v.cfg.JailMinutes) * time.Minute
+		}
+		p.Shadow = v.cfg.Shadow
+
+		policies[v.violation] = p
+	}
+
+	return policies, nil
 }
 
 type cashShopConfig struct {
@@ -65,12 +153,24 @@ type cashShopConfig struct {
 	Jitter                  int		`mapstructure:"jitter"`
 }
 
+type botConfig struct {
+	LoginAddress  string	`mapstructure:"loginAddress"`
+	LoginPort     string	`mapstructure:"loginPort"`
+	Username      string	`mapstructure:"username"`
+	Password      string	`mapstructure:"password"`
+	World         byte		`mapstructure:"world"`
+	Channel       byte		`mapstructure:"channel"`
+	CharacterName string	`mapstructure:"characterName"`
+	Timeout       int		`mapstructure:"timeout"`
+}
+
 type fullConfig struct {
 	Database dbConfig		`mapstructure:"database"`
 	Login    loginConfig	`mapstructure:"login"`
 	World    worldConfig	`mapstructure:"world"`
 	Channel  channelConfig	`mapstructure:"channel"`
 	CashShop cashShopConfig	`mapstructure:"cashshop"`
+	Bot      botConfig		`mapstructure:"bot"`
 }
 
 // Load from TOML if exists, then load/overwrite with ENV
@@ -124,6 +224,11 @@ func cashShopConfigFromFile(fname string) (cashShopConfig, dbConfig) {
 	return config.CashShop, config.Database
 }
 
+func botConfigFromFile(fname string) botConfig {
+	config := LoadConfig(fname)
+	return config.Bot
+}
+
 func bindEnvs(v *viper.Viper, typ reflect.Type, path []string, envPrefix string) {
 	for typ.Kind() == reflect.Pointer {
 		typ = typ.Elem()
@@ -23,6 +23,7 @@ type loginServer struct {
 	config    loginConfig
 	dbConfig  dbConfig
 	eRecv     chan *mnet.Event
+	wRecv     chan func()
 	wg        *sync.WaitGroup
 	gameState login.Server
 
@@ -52,6 +53,7 @@ func newLoginServer(configFile string) *loginServer {
 
 	return &loginServer{
 		eRecv:    make(chan *mnet.Event),
+		wRecv:    make(chan func()),
 		config:   config,
 		dbConfig: dbConfig,
 		wg:       &sync.WaitGroup{},
@@ -70,7 +72,7 @@ func (ls *loginServer) run() {
 
 	log.Println("Loaded and parsed Wizet data (NX) in", elapsed)
 
-	ls.gameState.Initialise(ls.dbConfig.User, ls.dbConfig.Password, ls.dbConfig.Address, ls.dbConfig.Port, ls.dbConfig.Database, ls.config.WithPin, ls.config.AutoRegister)
+	ls.gameState.Initialise(ls.wRecv, ls.dbConfig.User, ls.dbConfig.Password, ls.dbConfig.Address, ls.dbConfig.Port, ls.dbConfig.Database, ls.config.WithPin, ls.config.AutoRegister, ls.config.PasswordCost, "chat_filter.json")
 
 	// OS signal handler for graceful shutdown
 	ls.wg.Add(1)
@@ -250,6 +252,19 @@ func (ls *loginServer) processEvent() {
 			default:
 				// Unknown event origin; ignore safely
 			}
+
+		case work, ok := <-ls.wRecv:
+			if !ok {
+				continue
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Println("panic in scheduled work:", r)
+					}
+				}()
+				work()
+			}()
 		}
 	}
 }
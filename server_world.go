@@ -21,6 +21,7 @@ type worldServer struct {
 	config   worldConfig
 	dbConfig dbConfig
 	eRecv    chan *mnet.Event
+	wRecv    chan func()
 	wg       *sync.WaitGroup
 
 	lconn mnet.Server
@@ -38,6 +39,7 @@ func newWorldServer(configFile string) *worldServer {
 
 	ws := worldServer{
 		eRecv:    make(chan *mnet.Event),
+		wRecv:    make(chan func()),
 		config:   config,
 		dbConfig: dbConfig,
 		wg:       &sync.WaitGroup{},
@@ -57,7 +59,7 @@ func (ws *worldServer) run() {
 	log.Println("World Server")
 	log.Printf("Listening on %q:%q", ws.config.ListenAddress, ws.config.ListenPort)
 
-	ws.state.Initialise(ws.dbConfig.User, ws.dbConfig.Password, ws.dbConfig.Address, ws.dbConfig.Port, ws.dbConfig.Database)
+	ws.state.Initialise(ws.wRecv, ws.dbConfig.User, ws.dbConfig.Password, ws.dbConfig.Address, ws.dbConfig.Port, ws.dbConfig.Database)
 
 	// Signal handler for graceful shutdown
 	ws.wg.Add(1)
@@ -204,6 +206,19 @@ func (ws *worldServer) processEvent() {
 					ws.state.HandleServerPacket(conn, mpacket.NewReader(&e.Packet, time.Now().Unix()))
 				}
 			}
+
+		case work, ok := <-ws.wRecv:
+			if !ok {
+				continue
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Println("panic in scheduled work:", r)
+					}
+				}()
+				work()
+			}()
 		}
 	}
 }
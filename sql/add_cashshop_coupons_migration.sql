@@ -0,0 +1,42 @@
+-- Migration to add cash shop coupon codes
+-- A zero maxUses is unlimited and a zero expiresAt (unix milliseconds) never expires, each account may redeem a
+-- code once
+
+CREATE TABLE IF NOT EXISTS coupons (
+    code        VARCHAR(32) NOT NULL,
+    nx          INT(11) NOT NULL DEFAULT 0,
+    maplePoints INT(11) NOT NULL DEFAULT 0,
+    mesos       INT(11) NOT NULL DEFAULT 0,
+    maxUses     INT(11) NOT NULL DEFAULT 0,
+    uses        INT(11) NOT NULL DEFAULT 0,
+    expiresAt   BIGINT(20) NOT NULL DEFAULT 0,
+    createdBy   VARCHAR(13) NOT NULL DEFAULT '',
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (code)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS coupon_items (
+    id          INT(11) NOT NULL AUTO_INCREMENT,
+    code        VARCHAR(32) NOT NULL,
+    itemID      INT(11) NOT NULL,
+    amount      SMALLINT(6) NOT NULL DEFAULT 1,
+    PRIMARY KEY (id),
+    KEY idx_coupon_items_code (code),
+    CONSTRAINT fk_coupon_items_code
+    FOREIGN KEY (code) REFERENCES coupons(code)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    code        VARCHAR(32) NOT NULL,
+    accountID   INT(10) UNSIGNED NOT NULL,
+    characterID INT(11) NOT NULL,
+    redeemedAt  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (code, accountID),
+    CONSTRAINT fk_coupon_redemptions_code
+    FOREIGN KEY (code) REFERENCES coupons(code)
+    ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_coupon_redemptions_account
+    FOREIGN KEY (accountID) REFERENCES accounts(accountID)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
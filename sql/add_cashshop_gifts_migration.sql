@@ -0,0 +1,18 @@
+-- Migration to add cash shop gift notes
+-- The gifted item itself lives in account_cashshop_storage_items, this keeps the sender and message until the
+-- recipient next opens their locker
+
+CREATE TABLE IF NOT EXISTS account_cashshop_gifts (
+    id          BIGINT(20) NOT NULL AUTO_INCREMENT,
+    accountID   INT(10) UNSIGNED NOT NULL,
+    cashID      BIGINT(20) NOT NULL,
+    itemID      INT(11) NOT NULL,
+    sender      VARCHAR(13) NOT NULL,
+    message     VARCHAR(73) NOT NULL DEFAULT '',
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_cashshop_gifts_account (accountID),
+    CONSTRAINT fk_cashshop_gifts_account
+    FOREIGN KEY (accountID) REFERENCES accounts(accountID)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
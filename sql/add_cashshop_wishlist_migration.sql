@@ -0,0 +1,9 @@
+-- Migration to add the cash shop wishlist, up to ten commodity serial numbers per character
+
+CREATE TABLE IF NOT EXISTS `character_wishlist` (
+  `characterID` INT(11) NOT NULL,
+  `slot` TINYINT(3) UNSIGNED NOT NULL,
+  `sn` INT(11) NOT NULL,
+  PRIMARY KEY (`characterID`, `slot`),
+  CONSTRAINT `character_wishlist_fk_character` FOREIGN KEY (`characterID`) REFERENCES `characters` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,18 @@
+-- Migration to add the chat log
+-- Channels write map, buddy, party, guild, whisper, megaphone and mini room chat here when chatLogDB is enabled. The
+-- table is append only, prune it with e.g. DELETE FROM chat_logs WHERE createdAt < NOW() - INTERVAL 90 DAY.
+
+CREATE TABLE IF NOT EXISTS chat_logs (
+    id        BIGINT(20) NOT NULL AUTO_INCREMENT,
+    kind      VARCHAR(16) NOT NULL,
+    channelID TINYINT(3) UNSIGNED NOT NULL,
+    mapID     INT(11) NOT NULL,
+    senderID  INT(11) NOT NULL,
+    sender    VARCHAR(13) NOT NULL,
+    target    VARCHAR(13) NOT NULL DEFAULT '',
+    message   VARCHAR(255) NOT NULL,
+    createdAt TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    PRIMARY KEY (id),
+    KEY idx_chat_logs_sender (sender, id),
+    KEY idx_chat_logs_target (target, id)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
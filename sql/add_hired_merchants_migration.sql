@@ -0,0 +1,62 @@
+-- Migration to add hired merchants
+-- A merchant keeps trading while its owner is offline. Once closed (sold out, expired or shut by the owner) the
+-- remaining listings and earnings stay here until the owner collects them from Fredrick.
+
+CREATE TABLE IF NOT EXISTS hired_merchants (
+    id          INT(11) NOT NULL AUTO_INCREMENT,
+    characterID INT(11) NOT NULL,
+    channelID   TINYINT(3) UNSIGNED NOT NULL,
+    mapID       INT(11) NOT NULL,
+    x           SMALLINT(6) NOT NULL DEFAULT 0,
+    y           SMALLINT(6) NOT NULL DEFAULT 0,
+    foothold    SMALLINT(6) NOT NULL DEFAULT 0,
+    title       VARCHAR(64) NOT NULL DEFAULT '',
+    mesos       INT(11) NOT NULL DEFAULT 0,
+    closed      TINYINT(1) NOT NULL DEFAULT 0,
+    expiresAt   BIGINT(20) NOT NULL DEFAULT 0,
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_hired_merchants_character (characterID),
+    KEY idx_hired_merchants_channel (channelID, closed),
+    CONSTRAINT fk_hired_merchants_character
+    FOREIGN KEY (characterID) REFERENCES characters(id)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS hired_merchant_items (
+    id           INT(11) NOT NULL AUTO_INCREMENT,
+    merchantID   INT(11) NOT NULL,
+    position     SMALLINT(6) NOT NULL,
+    bundles      SMALLINT(6) NOT NULL,
+    price        INT(11) NOT NULL,
+    itemID       INT(11) NOT NULL,
+    inventoryID  TINYINT(3) UNSIGNED NOT NULL,
+    amount       INT(11) NOT NULL DEFAULT 1,
+    flag         TINYINT(4) NOT NULL DEFAULT 0,
+    upgradeSlots TINYINT(4) NOT NULL DEFAULT 0,
+    level        TINYINT(4) NOT NULL DEFAULT 0,
+    str          SMALLINT(6) NOT NULL DEFAULT 0,
+    dex          SMALLINT(6) NOT NULL DEFAULT 0,
+    intt         SMALLINT(6) NOT NULL DEFAULT 0,
+    luk          SMALLINT(6) NOT NULL DEFAULT 0,
+    hp           SMALLINT(6) NOT NULL DEFAULT 0,
+    mp           SMALLINT(6) NOT NULL DEFAULT 0,
+    watk         SMALLINT(6) NOT NULL DEFAULT 0,
+    matk         SMALLINT(6) NOT NULL DEFAULT 0,
+    wdef         SMALLINT(6) NOT NULL DEFAULT 0,
+    mdef         SMALLINT(6) NOT NULL DEFAULT 0,
+    accuracy     SMALLINT(6) NOT NULL DEFAULT 0,
+    avoid        SMALLINT(6) NOT NULL DEFAULT 0,
+    hands        SMALLINT(6) NOT NULL DEFAULT 0,
+    speed        SMALLINT(6) NOT NULL DEFAULT 0,
+    jump         SMALLINT(6) NOT NULL DEFAULT 0,
+    expireTime   BIGINT(20) NOT NULL DEFAULT 0,
+    creatorName  TINYTEXT NULL,
+    cashID       BIGINT(20) NULL,
+    cashSN       INT(11) NULL,
+    PRIMARY KEY (id),
+    KEY idx_hired_merchant_items_merchant (merchantID, position),
+    CONSTRAINT fk_hired_merchant_items_merchant
+    FOREIGN KEY (merchantID) REFERENCES hired_merchants(id)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,17 @@
+-- Migration to add jail sentences
+-- A jailed account's characters are held on the jail map until jailEnd, portals, scrolls, teleport rocks and changing
+-- channel are refused. Releasing a sentence early keeps the row for /banhistory and sets releasedAt instead.
+
+CREATE TABLE IF NOT EXISTS `jails` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `accountID` INT(10) UNSIGNED NOT NULL,
+  `reason` TEXT NOT NULL,
+  `issuedBy` VARCHAR(13) NOT NULL DEFAULT '',
+  `jailEnd` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `releasedBy` VARCHAR(13) DEFAULT NULL,
+  `releasedAt` TIMESTAMP NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_account` (`accountID`, `jailEnd`),
+  CONSTRAINT `jails_fk_account` FOREIGN KEY (`accountID`) REFERENCES `accounts` (`accountID`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,13 @@
+-- Migration to add pending lie detector tests
+-- A character that logs out, drops their connection or is moved by a channel drain during a lie detector test has the
+-- test recorded here and is tested again at their next login instead of being banned.
+
+CREATE TABLE IF NOT EXISTS lie_detector_pending (
+  characterID INT(11) NOT NULL,
+  reporterID  INT(11) NOT NULL DEFAULT 0,
+  createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (characterID),
+  CONSTRAINT fk_lie_detector_pending_character
+  FOREIGN KEY (characterID) REFERENCES characters(id)
+  ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,12 @@
+-- Migration to add unclaimed lie detector rewards
+-- A reporter who is not on the channel when the player they reported fails a lie detector test has the reward recorded
+-- here and receives it at their next login.
+
+CREATE TABLE IF NOT EXISTS lie_detector_rewards (
+  characterID INT(11) NOT NULL,
+  mesos       INT(11) NOT NULL DEFAULT 0,
+  PRIMARY KEY (characterID),
+  CONSTRAINT fk_lie_detector_rewards_character
+  FOREIGN KEY (characterID) REFERENCES characters(id)
+  ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,17 @@
+-- Migration to add chat mutes
+-- Mutes sit alongside bans: a muted account can still play but every chat message is refused until muteEnd. Lifting a
+-- mute early keeps the row for /mutehistory and sets liftedAt instead.
+
+CREATE TABLE IF NOT EXISTS `mutes` (
+  `id` INT(11) NOT NULL AUTO_INCREMENT,
+  `accountID` INT(10) UNSIGNED NOT NULL,
+  `reason` TEXT NOT NULL,
+  `issuedBy` VARCHAR(13) NOT NULL DEFAULT '',
+  `muteEnd` TIMESTAMP NULL DEFAULT NULL COMMENT 'NULL = permanent',
+  `liftedBy` VARCHAR(13) DEFAULT NULL,
+  `liftedAt` TIMESTAMP NULL DEFAULT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_account` (`accountID`, `muteEnd`),
+  CONSTRAINT `mutes_fk_account` FOREIGN KEY (`accountID`) REFERENCES `accounts` (`accountID`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
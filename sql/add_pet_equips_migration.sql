@@ -0,0 +1,18 @@
+-- Migration to add pet equipment
+-- Each pet keeps the equipment it wears, e.g. a meso magnet or an auto HP pouch, while it is home. itemID is the row
+-- in items, which stays in place with its stats and expiry while the pet is home and is shown in the pet's equip slots
+-- when the pet is summoned.
+
+CREATE TABLE IF NOT EXISTS `pet_equips` (
+  `petID` INT(11) NOT NULL,
+  `slot` TINYINT(3) UNSIGNED NOT NULL,
+  `itemID` INT(11) NOT NULL,
+  PRIMARY KEY (`petID`, `slot`),
+  UNIQUE KEY `uq_pet_equip_item` (`itemID`),
+  CONSTRAINT `fk_pet_equip_pet` FOREIGN KEY (`petID`)
+    REFERENCES `pets` (`parentID`)
+    ON DELETE CASCADE ON UPDATE CASCADE,
+  CONSTRAINT `fk_pet_equip_item` FOREIGN KEY (`itemID`)
+    REFERENCES `items` (`id`)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
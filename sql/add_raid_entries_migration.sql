@@ -0,0 +1,15 @@
+-- Migration to add boss raid entries
+-- A row is written for every character that goes into a boss raid, the rows since midnight are counted against the
+-- raid script's daily entry limit.
+
+CREATE TABLE IF NOT EXISTS raid_entries (
+  id          BIGINT(20) NOT NULL AUTO_INCREMENT,
+  characterID INT(11) NOT NULL,
+  raid        VARCHAR(32) NOT NULL,
+  createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (id),
+  KEY idx_raid_entries_character (characterID, raid, createdAt),
+  CONSTRAINT fk_raid_entries_character
+  FOREIGN KEY (characterID) REFERENCES characters(id)
+  ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
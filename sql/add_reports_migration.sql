@@ -0,0 +1,24 @@
+-- Migration to add character reports
+-- Players report each other from the client, the report keeps an excerpt of the map chat so a GM can review it
+-- later. Reports are not tied to the characters table so they survive the reported character being deleted.
+
+CREATE TABLE IF NOT EXISTS reports (
+    id                INT(11) NOT NULL AUTO_INCREMENT,
+    reporterID        INT(11) NOT NULL,
+    reporterAccountID INT(11) NOT NULL,
+    reporterName      VARCHAR(13) NOT NULL,
+    targetID          INT(11) NOT NULL,
+    targetAccountID   INT(11) NOT NULL,
+    targetName        VARCHAR(13) NOT NULL,
+    reason            TINYINT(3) UNSIGNED NOT NULL DEFAULT 0,
+    description       VARCHAR(255) NOT NULL DEFAULT '',
+    mapID             INT(11) NOT NULL,
+    chatLog           TEXT NOT NULL,
+    status            TINYINT(3) UNSIGNED NOT NULL DEFAULT 0,
+    claimedBy         VARCHAR(13) NOT NULL DEFAULT '',
+    resolution        VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_reports_status (status, id),
+    KEY idx_reports_reporter (reporterAccountID, createdAt)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,20 @@
+-- Migration to add the anti-cheat violation log
+-- Every violation a channel detects is stored with what was seen, e.g. the damage against the calculated maximum, for
+-- the /violations and /topOffenders GM commands. The table is append only, prune it with e.g.
+-- DELETE FROM violations WHERE createdAt < NOW() - INTERVAL 90 DAY.
+
+CREATE TABLE IF NOT EXISTS `violations` (
+  `id` BIGINT(20) NOT NULL AUTO_INCREMENT,
+  `accountID` INT(10) UNSIGNED NOT NULL,
+  `characterID` INT(11) NOT NULL,
+  `characterName` VARCHAR(13) NOT NULL,
+  `channelID` TINYINT(3) UNSIGNED NOT NULL,
+  `mapID` INT(11) NOT NULL,
+  `type` VARCHAR(32) NOT NULL,
+  `detail` VARCHAR(255) NOT NULL,
+  `createdAt` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_account` (`accountID`, `id`),
+  KEY `idx_channel` (`channelID`, `createdAt`),
+  CONSTRAINT `violations_fk_account` FOREIGN KEY (`accountID`) REFERENCES `accounts` (`accountID`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
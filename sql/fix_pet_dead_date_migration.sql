@@ -0,0 +1,7 @@
+-- Migration to fix the dead date of existing pets
+-- Pets used to be given a deadDate only about 13 minutes after they were created, as 90 days in milliseconds was added
+-- to a FILETIME. Now that dead pets cannot be summoned, give every pet that was hit by this its 90 days from creation.
+
+UPDATE `pets`
+SET `deadDate` = (UNIX_TIMESTAMP(`createdAt`) + 90 * 86400) * 10000000 + 116444592000000000
+WHERE `deadDate` < (UNIX_TIMESTAMP(`createdAt`) + 86400) * 10000000 + 116444592000000000;
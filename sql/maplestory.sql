@@ -52,6 +52,56 @@ CREATE TABLE `ban_escalation` (
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
 
 
+DROP TABLE IF EXISTS `mutes`;
+CREATE TABLE `mutes` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `accountID` int(10) unsigned NOT NULL,
+  `reason` text NOT NULL,
+  `issuedBy` varchar(13) NOT NULL DEFAULT '',
+  `muteEnd` timestamp NULL DEFAULT NULL COMMENT 'NULL = permanent',
+  `liftedBy` varchar(13) DEFAULT NULL,
+  `liftedAt` timestamp NULL DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_account` (`accountID`,`muteEnd`),
+  CONSTRAINT `mutes_fk_account` FOREIGN KEY (`accountID`) REFERENCES `accounts` (`accountID`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+
+DROP TABLE IF EXISTS `jails`;
+CREATE TABLE `jails` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `accountID` int(10) unsigned NOT NULL,
+  `reason` text NOT NULL,
+  `issuedBy` varchar(13) NOT NULL DEFAULT '',
+  `jailEnd` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `releasedBy` varchar(13) DEFAULT NULL,
+  `releasedAt` timestamp NULL DEFAULT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_account` (`accountID`,`jailEnd`),
+  CONSTRAINT `jails_fk_account` FOREIGN KEY (`accountID`) REFERENCES `accounts` (`accountID`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+
+DROP TABLE IF EXISTS `violations`;
+CREATE TABLE `violations` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `accountID` int(10) unsigned NOT NULL,
+  `characterID` int(11) NOT NULL,
+  `characterName` varchar(13) NOT NULL,
+  `channelID` tinyint(3) unsigned NOT NULL,
+  `mapID` int(11) NOT NULL,
+  `type` varchar(32) NOT NULL,
+  `detail` varchar(255) NOT NULL,
+  `createdAt` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `idx_account` (`accountID`,`id`),
+  KEY `idx_channel` (`channelID`,`createdAt`),
+  CONSTRAINT `violations_fk_account` FOREIGN KEY (`accountID`) REFERENCES `accounts` (`accountID`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+
 DROP TABLE IF EXISTS `buddy`;
 CREATE TABLE `buddy` (
   `id` int(11) NOT NULL AUTO_INCREMENT,
@@ -234,6 +284,15 @@ CREATE TABLE `character_quest_kills` (
   PRIMARY KEY (`characterID`, `questID`, `mobID`), CONSTRAINT `c_q_kills_fk_character` FOREIGN KEY (`characterID`) REFERENCES `characters` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
 
+DROP TABLE IF EXISTS `character_wishlist`;
+CREATE TABLE `character_wishlist` (
+  `characterID` INT(11) NOT NULL,
+  `slot` TINYINT(3) UNSIGNED NOT NULL,
+  `sn` INT(11) NOT NULL,
+  PRIMARY KEY (`characterID`, `slot`),
+  CONSTRAINT `character_wishlist_fk_character` FOREIGN KEY (`characterID`) REFERENCES `characters` (`id`) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
 DROP TABLE IF EXISTS `fame_log`;
 CREATE TABLE `fame_log` (
   `id` int(11) NOT NULL AUTO_INCREMENT,
@@ -340,6 +399,155 @@ CREATE TABLE IF NOT EXISTS account_cashshop_storage_items (
     ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
 
+CREATE TABLE IF NOT EXISTS account_cashshop_gifts (
+    id          BIGINT(20) NOT NULL AUTO_INCREMENT,
+    accountID   INT(10) UNSIGNED NOT NULL,
+    cashID      BIGINT(20) NOT NULL,
+    itemID      INT(11) NOT NULL,
+    sender      VARCHAR(13) NOT NULL,
+    message     VARCHAR(73) NOT NULL DEFAULT '',
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_cashshop_gifts_account (accountID),
+    CONSTRAINT fk_cashshop_gifts_account
+    FOREIGN KEY (accountID) REFERENCES accounts(accountID)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS coupons (
+    code        VARCHAR(32) NOT NULL,
+    nx          INT(11) NOT NULL DEFAULT 0,
+    maplePoints INT(11) NOT NULL DEFAULT 0,
+    mesos       INT(11) NOT NULL DEFAULT 0,
+    maxUses     INT(11) NOT NULL DEFAULT 0,
+    uses        INT(11) NOT NULL DEFAULT 0,
+    expiresAt   BIGINT(20) NOT NULL DEFAULT 0,
+    createdBy   VARCHAR(13) NOT NULL DEFAULT '',
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (code)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS coupon_items (
+    id          INT(11) NOT NULL AUTO_INCREMENT,
+    code        VARCHAR(32) NOT NULL,
+    itemID      INT(11) NOT NULL,
+    amount      SMALLINT(6) NOT NULL DEFAULT 1,
+    PRIMARY KEY (id),
+    KEY idx_coupon_items_code (code),
+    CONSTRAINT fk_coupon_items_code
+    FOREIGN KEY (code) REFERENCES coupons(code)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    code        VARCHAR(32) NOT NULL,
+    accountID   INT(10) UNSIGNED NOT NULL,
+    characterID INT(11) NOT NULL,
+    redeemedAt  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (code, accountID),
+    CONSTRAINT fk_coupon_redemptions_code
+    FOREIGN KEY (code) REFERENCES coupons(code)
+    ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT fk_coupon_redemptions_account
+    FOREIGN KEY (accountID) REFERENCES accounts(accountID)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS hired_merchants (
+    id          INT(11) NOT NULL AUTO_INCREMENT,
+    characterID INT(11) NOT NULL,
+    channelID   TINYINT(3) UNSIGNED NOT NULL,
+    mapID       INT(11) NOT NULL,
+    x           SMALLINT(6) NOT NULL DEFAULT 0,
+    y           SMALLINT(6) NOT NULL DEFAULT 0,
+    foothold    SMALLINT(6) NOT NULL DEFAULT 0,
+    title       VARCHAR(64) NOT NULL DEFAULT '',
+    mesos       INT(11) NOT NULL DEFAULT 0,
+    closed      TINYINT(1) NOT NULL DEFAULT 0,
+    expiresAt   BIGINT(20) NOT NULL DEFAULT 0,
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_hired_merchants_character (characterID),
+    KEY idx_hired_merchants_channel (channelID, closed),
+    CONSTRAINT fk_hired_merchants_character
+    FOREIGN KEY (characterID) REFERENCES characters(id)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS hired_merchant_items (
+    id           INT(11) NOT NULL AUTO_INCREMENT,
+    merchantID   INT(11) NOT NULL,
+    position     SMALLINT(6) NOT NULL,
+    bundles      SMALLINT(6) NOT NULL,
+    price        INT(11) NOT NULL,
+    itemID       INT(11) NOT NULL,
+    inventoryID  TINYINT(3) UNSIGNED NOT NULL,
+    amount       INT(11) NOT NULL DEFAULT 1,
+    flag         TINYINT(4) NOT NULL DEFAULT 0,
+    upgradeSlots TINYINT(4) NOT NULL DEFAULT 0,
+    level        TINYINT(4) NOT NULL DEFAULT 0,
+    str          SMALLINT(6) NOT NULL DEFAULT 0,
+    dex          SMALLINT(6) NOT NULL DEFAULT 0,
+    intt         SMALLINT(6) NOT NULL DEFAULT 0,
+    luk          SMALLINT(6) NOT NULL DEFAULT 0,
+    hp           SMALLINT(6) NOT NULL DEFAULT 0,
+    mp           SMALLINT(6) NOT NULL DEFAULT 0,
+    watk         SMALLINT(6) NOT NULL DEFAULT 0,
+    matk         SMALLINT(6) NOT NULL DEFAULT 0,
+    wdef         SMALLINT(6) NOT NULL DEFAULT 0,
+    mdef         SMALLINT(6) NOT NULL DEFAULT 0,
+    accuracy     SMALLINT(6) NOT NULL DEFAULT 0,
+    avoid        SMALLINT(6) NOT NULL DEFAULT 0,
+    hands        SMALLINT(6) NOT NULL DEFAULT 0,
+    speed        SMALLINT(6) NOT NULL DEFAULT 0,
+    jump         SMALLINT(6) NOT NULL DEFAULT 0,
+    expireTime   BIGINT(20) NOT NULL DEFAULT 0,
+    creatorName  TINYTEXT NULL,
+    cashID       BIGINT(20) NULL,
+    cashSN       INT(11) NULL,
+    PRIMARY KEY (id),
+    KEY idx_hired_merchant_items_merchant (merchantID, position),
+    CONSTRAINT fk_hired_merchant_items_merchant
+    FOREIGN KEY (merchantID) REFERENCES hired_merchants(id)
+    ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id                INT(11) NOT NULL AUTO_INCREMENT,
+    reporterID        INT(11) NOT NULL,
+    reporterAccountID INT(11) NOT NULL,
+    reporterName      VARCHAR(13) NOT NULL,
+    targetID          INT(11) NOT NULL,
+    targetAccountID   INT(11) NOT NULL,
+    targetName        VARCHAR(13) NOT NULL,
+    reason            TINYINT(3) UNSIGNED NOT NULL DEFAULT 0,
+    description       VARCHAR(255) NOT NULL DEFAULT '',
+    mapID             INT(11) NOT NULL,
+    chatLog           TEXT NOT NULL,
+    status            TINYINT(3) UNSIGNED NOT NULL DEFAULT 0,
+    claimedBy         VARCHAR(13) NOT NULL DEFAULT '',
+    resolution        VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_reports_status (status, id),
+    KEY idx_reports_reporter (reporterAccountID, createdAt)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS chat_logs (
+    id        BIGINT(20) NOT NULL AUTO_INCREMENT,
+    kind      VARCHAR(16) NOT NULL,
+    channelID TINYINT(3) UNSIGNED NOT NULL,
+    mapID     INT(11) NOT NULL,
+    senderID  INT(11) NOT NULL,
+    sender    VARCHAR(13) NOT NULL,
+    target    VARCHAR(13) NOT NULL DEFAULT '',
+    message   VARCHAR(255) NOT NULL,
+    createdAt TIMESTAMP(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    PRIMARY KEY (id),
+    KEY idx_chat_logs_sender (sender, id),
+    KEY idx_chat_logs_target (target, id)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
 CREATE TABLE IF NOT EXISTS  `pets` (
     `parentID` INT(11) NOT NULL,
     `name` VARCHAR(64) NOT NULL,
@@ -359,3 +567,48 @@ CREATE TABLE IF NOT EXISTS  `pets` (
         REFERENCES `items` (`id`)
         ON DELETE CASCADE ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS  `pet_equips` (
+    `petID` INT(11) NOT NULL,
+    `slot` TINYINT(3) UNSIGNED NOT NULL,
+    `itemID` INT(11) NOT NULL,
+    PRIMARY KEY (`petID`, `slot`),
+    UNIQUE KEY `uq_pet_equip_item` (`itemID`),
+    CONSTRAINT `fk_pet_equip_pet` FOREIGN KEY (`petID`)
+        REFERENCES `pets` (`parentID`)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT `fk_pet_equip_item` FOREIGN KEY (`itemID`)
+        REFERENCES `items` (`id`)
+        ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS raid_entries (
+    id          BIGINT(20) NOT NULL AUTO_INCREMENT,
+    characterID INT(11) NOT NULL,
+    raid        VARCHAR(32) NOT NULL,
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_raid_entries_character (characterID, raid, createdAt),
+    CONSTRAINT fk_raid_entries_character
+    FOREIGN KEY (characterID) REFERENCES characters(id)
+    ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS lie_detector_pending (
+    characterID INT(11) NOT NULL,
+    reporterID  INT(11) NOT NULL DEFAULT 0,
+    createdAt   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (characterID),
+    CONSTRAINT fk_lie_detector_pending_character
+    FOREIGN KEY (characterID) REFERENCES characters(id)
+    ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE IF NOT EXISTS lie_detector_rewards (
+    characterID INT(11) NOT NULL,
+    mesos       INT(11) NOT NULL DEFAULT 0,
+    PRIMARY KEY (characterID),
+    CONSTRAINT fk_lie_detector_rewards_character
+    FOREIGN KEY (characterID) REFERENCES characters(id)
+    ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,222 @@
+package world
+
+import (
+	"net/http"
+
+	"github.com/Hucaru/Valhalla/common"
+	"github.com/Hucaru/Valhalla/internal"
+)
+
+type adminRates struct {
+	Exp   float32 `json:"exp"`
+	Drop  float32 `json:"drop"`
+	Mesos float32 `json:"mesos"`
+}
+
+type adminChannel struct {
+	Channel int   `json:"channel"`
+	Online  bool  `json:"online"`
+	Pop     int16 `json:"pop"`
+	MaxPop  int16 `json:"maxPop"`
+}
+
+type adminWorldInfo struct {
+	Name         string         `json:"name"`
+	Ribbon       byte           `json:"ribbon"`
+	Message      string         `json:"message"`
+	Rates        adminRates     `json:"rates"`
+	DefaultRates adminRates     `json:"defaultRates"`
+	Channels     []adminChannel `json:"channels"`
+	CashShop     bool           `json:"cashShop"`
+}
+
+type adminRatesRequest struct {
+	Exp   *float32 `json:"exp"`
+	Drop  *float32 `json:"drop"`
+	Mesos *float32 `json:"mesos"`
+}
+
+type adminMessageRequest struct {
+	Ribbon  *byte   `json:"ribbon"`
+	Message *string `json:"message"`
+}
+
+type adminNoticeRequest struct {
+	Message string `json:"message"`
+}
+
+func toAdminRates(r internal.Rates) adminRates {
+	return adminRates{Exp: r.Exp, Drop: r.Drop, Mesos: r.Mesos}
+}
+
+// registerAdminHandlers adds the world endpoints to the admin API, a process only ever runs a single world
+func (server *Server) registerAdminHandlers() {
+	common.HandleAdmin("GET /admin/world", server.adminWorld)
+	common.HandleAdmin("PUT /admin/world/rates", server.adminSetRates)
+	common.HandleAdmin("PUT /admin/world/message", server.adminSetMessage)
+	common.HandleAdmin("POST /admin/world/notice", server.adminNotice)
+}
+
+func (server *Server) adminDispatch(w http.ResponseWriter, fn func()) bool {
+	if err := common.AdminDispatch(server.dispatch, fn); err != nil {
+		common.WriteJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return false
+	}
+
+	return true
+}
+
+// adminInfo must be called from the dispatch loop
+func (server *Server) adminInfo() adminWorldInfo {
+	info := adminWorldInfo{
+		Name:         server.Info.Name,
+		Ribbon:       server.Info.Ribbon,
+		Message:      server.Info.Message,
+		Rates:        toAdminRates(server.Info.Rates),
+		DefaultRates: toAdminRates(server.Info.DefaultRates),
+		Channels:     make([]adminChannel, 0, len(server.Info.Channels)),
+		CashShop:     server.Info.CashShop.Conn != nil,
+	}
+
+	for i, v := range server.Info.Channels {
+		info.Channels = append(info.Channels, adminChannel{
+			Channel: i + 1,
+			Online:  v.Conn != nil,
+			Pop:     v.Pop,
+			MaxPop:  v.MaxPop,
+		})
+	}
+
+	return info
+}
+
+func (server *Server) adminWorld(w http.ResponseWriter, r *http.Request) {
+	var info adminWorldInfo
+
+	if server.adminDispatch(w, func() {
+		info = server.adminInfo()
+	}) {
+		common.WriteJSON(w, http.StatusOK, info)
+	}
+}
+
+// adminSetRates changes the rates the same way the /rate GM command does, omitted rates are left alone
+func (server *Server) adminSetRates(w http.ResponseWriter, r *http.Request) {
+	var req adminRatesRequest
+	if err := common.ReadJSON(r, &req); err != nil {
+		common.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, rate := range []*float32{req.Exp, req.Drop, req.Mesos} {
+		if rate != nil && *rate <= 0 {
+			common.WriteJSONError(w, http.StatusBadRequest, "rates must be positive")
+			return
+		}
+	}
+
+	if req.Exp == nil && req.Drop == nil && req.Mesos == nil {
+		common.WriteJSONError(w, http.StatusBadRequest, "no rates given")
+		return
+	}
+
+	var info adminWorldInfo
+	connected := true
+
+	if !server.adminDispatch(w, func() {
+		if server.login == nil {
+			connected = false
+			return
+		}
+
+		if req.Exp != nil {
+			server.Info.Rates.Exp = *req.Exp
+			server.channelBroadcast(internal.PacketChangeExpRate(*req.Exp))
+		}
+
+		if req.Drop != nil {
+			server.Info.Rates.Drop = *req.Drop
+			server.channelBroadcast(internal.PacketChangeDropRate(*req.Drop))
+		}
+
+		if req.Mesos != nil {
+			server.Info.Rates.Mesos = *req.Mesos
+			server.channelBroadcast(internal.PacketChangeMesosRate(*req.Mesos))
+		}
+
+		server.ratesChanged()
+		info = server.adminInfo()
+	}) {
+		return
+	}
+
+	if !connected {
+		common.WriteJSONError(w, http.StatusServiceUnavailable, "world is not registered with login")
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, info)
+}
+
+func (server *Server) adminSetMessage(w http.ResponseWriter, r *http.Request) {
+	var req adminMessageRequest
+	if err := common.ReadJSON(r, &req); err != nil {
+		common.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Ribbon == nil && req.Message == nil {
+		common.WriteJSONError(w, http.StatusBadRequest, "no ribbon or message given")
+		return
+	}
+
+	var info adminWorldInfo
+	connected := true
+
+	if !server.adminDispatch(w, func() {
+		if server.login == nil {
+			connected = false
+			return
+		}
+
+		if req.Ribbon != nil {
+			server.Info.Ribbon = *req.Ribbon
+		}
+
+		if req.Message != nil {
+			server.Info.Message = *req.Message
+		}
+
+		server.login.Send(server.Info.GenerateInfoPacket())
+		info = server.adminInfo()
+	}) {
+		return
+	}
+
+	if !connected {
+		common.WriteJSONError(w, http.StatusServiceUnavailable, "world is not registered with login")
+		return
+	}
+
+	common.WriteJSON(w, http.StatusOK, info)
+}
+
+// adminNotice sends a notice to every player on every channel of the world
+func (server *Server) adminNotice(w http.ResponseWriter, r *http.Request) {
+	var req adminNoticeRequest
+	if err := common.ReadJSON(r, &req); err != nil {
+		common.WriteJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Message == "" {
+		common.WriteJSONError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+
+	if server.adminDispatch(w, func() {
+		server.channelBroadcast(internal.PacketChatNotice(req.Message))
+	}) {
+		common.WriteJSON(w, http.StatusOK, req)
+	}
+}
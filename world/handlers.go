@@ -150,6 +150,11 @@ func (server *Server) handleChannelUpdate(conn mnet.Server, reader mpacket.Reade
 	switch op {
 	case 0: //population
 		server.Info.Channels[id].Pop = reader.ReadInt16()
+	case 1: // draining, hide it from login and stop siblings migrating players into it
+		server.Info.Channels[id].Port = 0
+		server.Info.Channels[id].MaxPop = 0
+		log.Println("Channel", id, "is draining")
+		server.sendChannelInfo()
 	default:
 		log.Println("Unkown channel update type", op)
 	}
@@ -373,6 +378,16 @@ func (server *Server) handleChangeRate(conn mnet.Server, reader mpacket.Reader)
 		server.Info.Rates.Mesos = rate
 	}
 
+	server.ratesChanged()
+
+	p := mpacket.CreateInternal(opcode.ChangeRate)
+	p.Append(reader.GetBuffer()[1:])
+
+	server.channelBroadcast(p)
+}
+
+// ratesChanged flags a rates event on the login ribbon whilst the rates differ from the configured defaults
+func (server *Server) ratesChanged() {
 	if server.Info.Rates.Exp != server.Info.DefaultRates.Exp ||
 		server.Info.Rates.Drop != server.Info.DefaultRates.Drop ||
 		server.Info.Rates.Mesos != server.Info.DefaultRates.Mesos { // Rates event
@@ -382,11 +397,6 @@ func (server *Server) handleChangeRate(conn mnet.Server, reader mpacket.Reader)
 		server.Info.Ribbon = 0
 	}
 	server.login.Send(server.Info.GenerateInfoPacket())
-
-	p := mpacket.CreateInternal(opcode.ChangeRate)
-	p.Append(reader.GetBuffer()[1:])
-
-	server.channelBroadcast(p)
 }
 
 func (server *Server) handleUpdateLoginInfo(conn mnet.Server, reader mpacket.Reader) {
@@ -404,7 +414,7 @@ func (server *Server) handleGuildEvent(conn mnet.Server, reader mpacket.Reader)
 	case internal.OpGuildDisband:
 		guildID := reader.ReadInt32()
 
-		if _, err := common.DB.Exec("DELETE FROM guilds WHERE (id=?)", guildID); err != nil {
+		if err := common.Repo.Guilds.Delete(guildID); err != nil {
 			log.Println(err)
 		} else {
 			server.forwardPacketToChannels(conn, reader)
@@ -415,9 +425,7 @@ func (server *Server) handleGuildEvent(conn mnet.Server, reader mpacket.Reader)
 		playerID := reader.ReadInt32()
 		rank := reader.ReadByte()
 
-		query := "UPDATE characters set guildRank=? WHERE id=?"
-
-		if _, err := common.DB.Exec(query, rank, playerID); err != nil {
+		if err := common.Repo.Guilds.SetRank(playerID, rank); err != nil {
 			log.Println(err)
 		} else {
 			server.forwardPacketToChannels(conn, reader)
@@ -428,9 +436,7 @@ func (server *Server) handleGuildEvent(conn mnet.Server, reader mpacket.Reader)
 		guildID := reader.ReadInt32()
 		points := reader.ReadInt32()
 
-		query := "UPDATE guilds set points=? WHERE id=?"
-
-		if _, err := common.DB.Exec(query, points, guildID); err != nil {
+		if err := common.Repo.Guilds.SetPoints(guildID, points); err != nil {
 			log.Fatal(err)
 		} else {
 			server.forwardPacketToChannels(conn, reader)
@@ -443,9 +449,7 @@ func (server *Server) handleGuildEvent(conn mnet.Server, reader mpacket.Reader)
 		member2 := reader.ReadString(reader.ReadInt16())
 		member3 := reader.ReadString(reader.ReadInt16())
 
-		query := "UPDATE guilds set master=?, jrMaster=?, member1=?, member2=?, member3=? WHERE id=?"
-
-		if _, err := common.DB.Exec(query, master, jrMaster, member1, member2, member3, guildID); err != nil {
+		if err := common.Repo.Guilds.SetTitles(guildID, master, jrMaster, member1, member2, member3); err != nil {
 			log.Fatal(err)
 		} else {
 			server.forwardPacketToChannels(conn, reader)
@@ -454,9 +458,7 @@ func (server *Server) handleGuildEvent(conn mnet.Server, reader mpacket.Reader)
 		reader.Skip(4)
 		playerID := reader.ReadInt32()
 
-		query := "UPDATE characters set guildID=?, guildRank=? WHERE id=?"
-
-		if _, err := common.DB.Exec(query, nil, 0, playerID); err != nil {
+		if err := common.Repo.Guilds.RemoveMember(playerID); err != nil {
 			log.Fatal(err)
 		} else {
 			server.forwardPacketToChannels(conn, reader)
@@ -465,9 +467,7 @@ func (server *Server) handleGuildEvent(conn mnet.Server, reader mpacket.Reader)
 		guildID := reader.ReadInt32()
 		notice := reader.ReadString(reader.ReadInt16())
 
-		query := "UPDATE guilds SET notice=? WHERE id=?"
-
-		if _, err := common.DB.Exec(query, notice, guildID); err != nil {
+		if err := common.Repo.Guilds.SetNotice(guildID, notice); err != nil {
 			log.Fatal(err)
 		} else {
 			server.forwardPacketToChannels(conn, reader)
@@ -479,9 +479,7 @@ func (server *Server) handleGuildEvent(conn mnet.Server, reader mpacket.Reader)
 		logoBgColour := reader.ReadByte()
 		logoColour := reader.ReadByte()
 
-		query := "UPDATE guilds SET logoBg=?,logoBgColour=?,logo=?,logoColour=? WHERE id=?"
-
-		if _, err := common.DB.Exec(query, logoBg, logoBgColour, logo, logoColour, guildID); err != nil {
+		if err := common.Repo.Guilds.SetEmblem(guildID, logoBg, logoBgColour, logo, logoColour); err != nil {
 			log.Fatal(err)
 		} else {
 			server.forwardPacketToChannels(conn, reader)
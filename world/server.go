@@ -13,6 +13,7 @@ import (
 // Server data
 type Server struct {
 	Info             internal.World
+	dispatch         chan func()
 	login            mnet.Server
 	nextPartyID      int32
 	reusablePartyIDs []int32
@@ -21,7 +22,9 @@ type Server struct {
 }
 
 // Initialise internal state
-func (server *Server) Initialise(dbuser, dbpassword, dbaddress, dbport, dbdatabase string) {
+func (server *Server) Initialise(work chan func(), dbuser, dbpassword, dbaddress, dbport, dbdatabase string) {
+	server.dispatch = work
+
 	err := common.ConnectToDB(dbuser, dbpassword, dbaddress, dbport, dbdatabase)
 
 	if err != nil {
@@ -30,6 +33,13 @@ func (server *Server) Initialise(dbuser, dbpassword, dbaddress, dbport, dbdataba
 
 	server.parties = make(map[int32]*internal.Party)
 	server.messengerRooms = make(map[int32]*messengerRoom)
+
+	// Login and world only serve the admin api, leave the port free for other servers on the host otherwise
+	if common.AdminToken != "" {
+		server.registerAdminHandlers()
+		common.StartMetrics()
+		log.Println("Started serving metrics and admin api on :" + common.MetricsPort)
+	}
 }
 
 // RegisterWithLogin server